@@ -1,6 +1,10 @@
 package client
 
-import "quorumengineering/quorum-report/types"
+import (
+	"fmt"
+
+	"quorumengineering/quorum-report/types"
+)
 
 // templates for GraphQL queries
 
@@ -14,8 +18,8 @@ func CurrentBlockQuery() string {
 	`
 }
 
-func TransactionDetailQuery(hash types.Hash) string {
-	return `query { transaction(hash:"` + hash.Hex() + `") {
+// transactionFields selects a transaction with its receipt and logs
+const transactionFields = `
         hash
         status
 		index
@@ -36,6 +40,31 @@ func TransactionDetailQuery(hash types.Hash) string {
 			account { address }
 			topics
 			data
-		}
+		}`
+
+func TransactionDetailQuery(hash types.Hash) string {
+	return `query { transaction(hash:"` + hash.Hex() + `") {` + transactionFields + `
     } }`
 }
+
+// BlockTransactionsQuery fetches every transaction of the block, with its
+// receipt and logs, in a single query.
+func BlockTransactionsQuery(blockNumber uint64) string {
+	return fmt.Sprintf(`query { block(number: %d) {
+		number
+		hash
+		transactions {`+transactionFields+`
+		}
+    } }`, blockNumber)
+}
+
+// BlockRangeTransactionsQuery fetches every transaction of the blocks from start
+// to end inclusive, with its receipt and logs, in a single query.
+func BlockRangeTransactionsQuery(start, end uint64) string {
+	return fmt.Sprintf(`query { blocks(from: %d, to: %d) {
+		number
+		hash
+		transactions {`+transactionFields+`
+		}
+    } }`, start, end)
+}
@@ -12,10 +12,25 @@ type TransactionResult struct {
 	Transaction Transaction
 }
 
+type BlockTransactionsResult struct {
+	Block BlockTransactions
+}
+
+type BlockRangeTransactionsResult struct {
+	Blocks []BlockTransactions
+}
+
 type Block struct {
 	Number types.HexNumber
 }
 
+// BlockTransactions is a block with all of its transactions
+type BlockTransactions struct {
+	Number       types.HexNumber
+	Hash         types.Hash
+	Transactions []Transaction
+}
+
 type Transaction struct {
 	Hash              types.Hash
 	Status            string
@@ -23,8 +38,8 @@ type Transaction struct {
 	Nonce             types.HexNumber
 	From              Address
 	To                Address
-	Value             types.HexNumber
-	GasPrice          types.HexNumber
+	Value             types.HexBigNumber
+	GasPrice          types.HexBigNumber
 	Gas               types.HexNumber
 	GasUsed           types.HexNumber
 	CumulativeGasUsed types.HexNumber
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"quorumengineering/quorum-report/log"
+)
+
+// httpRPCClient makes JSON-RPC calls over HTTP, with a pool of connections so
+// that calls are made in parallel rather than queuing on a single socket.
+type httpRPCClient struct {
+	url        string
+	httpClient *http.Client
+	idCounter  uint32
+}
+
+func newHTTPRPCClient(url string, poolSize int, transport *Transport) *httpRPCClient {
+	return &httpRPCClient{url: url, httpClient: transport.httpClient(poolSize)}
+}
+
+// call makes a single call, which is given up on once the context ends
+func (c *httpRPCClient) call(ctx context.Context, method string, args ...interface{}) (*message, error) {
+	msg, err := newRPCMessage(c.nextID(), method, args)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Send HTTP JSON RPC message", "msg.Method", msg.Method, "args", args, "msg.ID", msg.ID)
+
+	var response message
+	if err := c.post(ctx, msg, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// batchCall makes a batch of calls in a single request, returning the response
+// of each call at the same index, or nil if the call was not answered
+func (c *httpRPCClient) batchCall(ctx context.Context, batch []BatchElem) ([]*message, error) {
+	msgs := make([]*message, len(batch))
+	for i, elem := range batch {
+		msg, err := newRPCMessage(c.nextID(), elem.Method, elem.Args)
+		if err != nil {
+			return nil, err
+		}
+		msgs[i] = msg
+	}
+	log.Debug("Send HTTP JSON RPC batch message", "calls", len(msgs))
+
+	var responses []*message
+	if err := c.post(ctx, msgs, &responses); err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*message, len(responses))
+	for _, response := range responses {
+		byID[response.ID] = response
+	}
+	ordered := make([]*message, len(msgs))
+	for i, msg := range msgs {
+		ordered[i] = byID[msg.ID]
+	}
+	return ordered, nil
+}
+
+func (c *httpRPCClient) post(ctx context.Context, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return contextError(ctx)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rpc call failed with status %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("decode rpc response: %v", err)
+	}
+	return nil
+}
+
+func (c *httpRPCClient) nextID() string {
+	return strconv.Itoa(int(atomic.AddUint32(&c.idCounter, 1)))
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// jsonRPCServer answers eth_blockNumber and eth_getCode calls over HTTP,
+// recording the most calls that were in flight at once. Batches are answered
+// in reverse order.
+type jsonRPCServer struct {
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (s *jsonRPCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	inFlight := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+	for {
+		max := atomic.LoadInt32(&s.maxInFlight)
+		if inFlight <= max || atomic.CompareAndSwapInt32(&s.maxInFlight, max, inFlight) {
+			break
+		}
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	_, _ = w.Write(answerJSONRPC(body))
+}
+
+// answerJSONRPC answers a single call or a batch of calls
+func answerJSONRPC(body []byte) []byte {
+	if !bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		var msg message
+		_ = json.Unmarshal(body, &msg)
+		response, _ := json.Marshal(answerJSONRPCMessage(msg))
+		return response
+	}
+
+	var msgs []message
+	_ = json.Unmarshal(body, &msgs)
+	responses := make([]message, len(msgs))
+	for i, msg := range msgs {
+		responses[len(msgs)-1-i] = answerJSONRPCMessage(msg)
+	}
+	response, _ := json.Marshal(responses)
+	return response
+}
+
+func answerJSONRPCMessage(msg message) message {
+	response := message{Version: "2.0", ID: msg.ID}
+	switch msg.Method {
+	case "eth_blockNumber":
+		response.Result = json.RawMessage(`"0x20"`)
+	case "eth_getCode":
+		time.Sleep(50 * time.Millisecond)
+		response.Result = json.RawMessage(`"0x6080"`)
+	default:
+		response.Error = &msgError{Code: -32601, Message: "the method " + msg.Method + " does not exist/is not available"}
+	}
+	return response
+}
+
+func TestQuorumClient_HTTPRPCCall(t *testing.T) {
+	wsServer := httptest.NewServer(http.HandlerFunc(echo))
+	defer wsServer.Close()
+	graphqlServer := httptest.NewServer(&recordingServer{})
+	defer graphqlServer.Close()
+	rpcServer := &jsonRPCServer{}
+	httpServer := httptest.NewServer(rpcServer)
+	defer httpServer.Close()
+
+	transport, _ := NewTransport(types.ConnectionConfig{})
+	quorumClient, err := NewQuorumClient(types.ConnectionConfig{
+		WSUrl:       "ws" + strings.TrimPrefix(wsServer.URL, "http"),
+		GraphQLUrl:  graphqlServer.URL,
+		RPCUrl:      httpServer.URL,
+		RPCPoolSize: 3,
+	}, transport)
+	assert.Nil(t, err)
+	defer quorumClient.Stop()
+
+	var blockNumber string
+	assert.Nil(t, quorumClient.RPCCall(context.Background(), &blockNumber, "eth_blockNumber"))
+	assert.Equal(t, "0x20", blockNumber)
+
+	var res interface{}
+	assert.EqualError(t, quorumClient.RPCCall(context.Background(), &res, "eth_unknown"), "the method eth_unknown does not exist/is not available")
+
+	// calls are made in parallel, up to the pool size
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var code string
+			assert.Nil(t, quorumClient.RPCCall(context.Background(), &code, "eth_getCode", "0x0000000000000000000000000000000000000001", "latest"))
+			assert.Equal(t, "0x6080", code)
+		}()
+	}
+	wg.Wait()
+	assert.True(t, rpcServer.maxInFlight > 1, "calls were not made in parallel")
+	assert.True(t, rpcServer.maxInFlight <= 3, "calls exceeded the pool size")
+}
+
+func TestQuorumClient_HTTPBatchRPCCall(t *testing.T) {
+	graphqlServer := httptest.NewServer(&recordingServer{})
+	defer graphqlServer.Close()
+	httpServer := httptest.NewServer(&jsonRPCServer{})
+	defer httpServer.Close()
+
+	transport, _ := NewTransport(types.ConnectionConfig{})
+	quorumClient, err := NewQuorumClient(types.ConnectionConfig{GraphQLUrl: graphqlServer.URL, RPCUrl: httpServer.URL}, transport)
+	assert.Nil(t, err)
+	defer quorumClient.Stop()
+
+	var blockNumber, code string
+	var res interface{}
+	batch := []BatchElem{
+		{Method: "eth_blockNumber", Result: &blockNumber},
+		{Method: "eth_unknown", Result: &res},
+		{Method: "eth_getCode", Args: []interface{}{"0x0000000000000000000000000000000000000001", "latest"}, Result: &code},
+	}
+	assert.Nil(t, quorumClient.BatchRPCCall(context.Background(), batch))
+	assert.Nil(t, batch[0].Error)
+	assert.Equal(t, "0x20", blockNumber)
+	assert.EqualError(t, batch[1].Error, "the method eth_unknown does not exist/is not available")
+	assert.Nil(t, batch[2].Error)
+	assert.Equal(t, "0x6080", code)
+
+	assert.Nil(t, quorumClient.BatchRPCCall(context.Background(), nil))
+}
+
+func TestNewQuorumClient_InvalidHTTPRPCEndpoint(t *testing.T) {
+	wsServer := httptest.NewServer(http.HandlerFunc(echo))
+	defer wsServer.Close()
+	graphqlServer := httptest.NewServer(&recordingServer{})
+	defer graphqlServer.Close()
+
+	transport, _ := NewTransport(types.ConnectionConfig{})
+	_, err := NewQuorumClient(types.ConnectionConfig{
+		WSUrl:      "ws" + strings.TrimPrefix(wsServer.URL, "http"),
+		GraphQLUrl: graphqlServer.URL,
+		RPCUrl:     "http://invalid",
+	}, transport)
+	assert.EqualError(t, err, "call http json-rpc endpoint failed")
+}
+
+func TestQuorumClient_WithoutWebSocket(t *testing.T) {
+	graphqlServer := httptest.NewServer(&recordingServer{})
+	defer graphqlServer.Close()
+	httpServer := httptest.NewServer(&jsonRPCServer{})
+	defer httpServer.Close()
+
+	transport, _ := NewTransport(types.ConnectionConfig{})
+	quorumClient, err := NewQuorumClient(types.ConnectionConfig{GraphQLUrl: graphqlServer.URL, RPCUrl: httpServer.URL}, transport)
+	assert.Nil(t, err)
+	defer quorumClient.Stop()
+
+	var blockNumber string
+	assert.Nil(t, quorumClient.RPCCall(context.Background(), &blockNumber, "eth_blockNumber"))
+	assert.Equal(t, "0x20", blockNumber)
+	assert.EqualError(t, quorumClient.SubscribeChainHead(make(chan types.RawHeader)), "no WebSocket endpoint configured")
+	assert.True(t, quorumClient.IsConnected())
+
+	_, err = NewQuorumClient(types.ConnectionConfig{GraphQLUrl: graphqlServer.URL}, transport)
+	assert.EqualError(t, err, "no Quorum WebSocket or HTTP JSON-RPC endpoint configured")
+}
@@ -1,17 +1,35 @@
 package client
 
 import (
+	"context"
+
 	"quorumengineering/quorum-report/types"
 )
 
+// Client makes calls to Quorum. Calls are given up on once their context ends,
+// or the client's own timeout for the call passes.
 type Client interface {
 	// SubscribeChainHead subscribes to new chain header
 	SubscribeChainHead(chan<- types.RawHeader) error
 	// ExecuteGraphQLQuery performs a fully constructed query against the Geth
 	// GraphQL server
-	ExecuteGraphQLQuery(interface{}, string) error
+	ExecuteGraphQLQuery(context.Context, interface{}, string) error
 	// RPCCall makes a JSON RPC call to the Geth RPC server
-	RPCCall(interface{}, string, ...interface{}) error
+	RPCCall(context.Context, interface{}, string, ...interface{}) error
+	// BatchRPCCall makes several JSON RPC calls to the Geth RPC server in a
+	// single request. An error is only returned if the request itself failed;
+	// the error of each call is set on its BatchElem.
+	BatchRPCCall(context.Context, []BatchElem) error
 	// Stop quorum client connection
 	Stop()
 }
+
+// BatchElem is a single call in a batch of JSON RPC calls
+type BatchElem struct {
+	Method string
+	Args   []interface{}
+	// Result is decoded into if the call succeeds
+	Result interface{}
+	// Error is set if the call failed, or its result could not be decoded
+	Error error
+}
@@ -0,0 +1,405 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/metrics"
+	"quorumengineering/quorum-report/types"
+)
+
+var ErrNoHealthyNode = errors.New("no healthy Quorum node")
+
+// MultiNodeClient spreads calls across several Quorum nodes, trying another node
+// when a call fails. The nodes are health checked periodically, and calls are
+// only sent to nodes that are reachable and not lagging behind the others.
+type MultiNodeClient struct {
+	nodes           []*node
+	config          types.ConnectionConfig
+	transport       *Transport
+	maxBlocksBehind uint64
+	timeouts        callTimeouts
+	next            uint32
+
+	// chain heads are followed from one node at a time, failing over to
+	// another if it becomes unhealthy
+	headMu     sync.Mutex
+	headChan   chan<- types.RawHeader
+	headSource *node
+	lastHead   uint64
+
+	// To check we have actually shut down before returning
+	shutdownChan chan struct{}
+	shutdownWg   sync.WaitGroup
+}
+
+type node struct {
+	config *types.NodeConfig
+
+	mu      sync.RWMutex
+	client  *QuorumClient
+	healthy bool
+
+	// set once subscribed to chain heads, guarded by the headMu of the client
+	subscribed bool
+}
+
+// NewMultiNodeClient connects to each of the configured nodes, failing only if
+// none of them are healthy. Nodes that cannot be connected to are retried on
+// each health check.
+func NewMultiNodeClient(config types.ConnectionConfig, transport *Transport) (*MultiNodeClient, error) {
+	mc := &MultiNodeClient{
+		config:          config,
+		transport:       transport,
+		maxBlocksBehind: config.MaxNodeBlocksBehind,
+		timeouts:        newCallTimeouts(config),
+		shutdownChan:    make(chan struct{}),
+	}
+	for _, nodeConfig := range config.Nodes {
+		mc.nodes = append(mc.nodes, &node{config: nodeConfig})
+	}
+
+	mc.checkHealth()
+	if len(mc.healthyNodes()) == 0 {
+		mc.stopNodes()
+		return nil, ErrNoHealthyNode
+	}
+
+	mc.shutdownWg.Add(1)
+	go func() {
+		defer mc.shutdownWg.Done()
+		ticker := time.NewTicker(time.Duration(config.HealthCheckInterval) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				mc.checkHealth()
+			case <-mc.shutdownChan:
+				return
+			}
+		}
+	}()
+	return mc, nil
+}
+
+// SubscribeChainHead follows chain heads from a healthy node. If that node
+// becomes unhealthy, chain heads are followed from another node instead, and the
+// headers of any blocks missed in between are sent.
+func (mc *MultiNodeClient) SubscribeChainHead(ch chan<- types.RawHeader) error {
+	mc.headMu.Lock()
+	defer mc.headMu.Unlock()
+	mc.headChan = ch
+	return mc.subscribeChainHead()
+}
+
+// ExecuteGraphQLQuery runs the query against a healthy node, trying the others
+// in turn if it fails.
+func (mc *MultiNodeClient) ExecuteGraphQLQuery(ctx context.Context, result interface{}, query string) error {
+	err := ErrNoHealthyNode
+	for _, n := range mc.healthyNodes() {
+		if err = n.quorumClient().ExecuteGraphQLQuery(ctx, result, query); err == nil || ctx.Err() != nil {
+			return err
+		}
+		log.Warn("GraphQL query to Quorum node failed, trying another node", "node", n.config.Name, "err", err)
+	}
+	return err
+}
+
+// RPCCall makes the call to a healthy node, spreading calls across the nodes.
+// If the node cannot be reached, or does not have the block or transaction
+// asked for yet, the call is made to the other nodes in turn.
+func (mc *MultiNodeClient) RPCCall(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	nodes := mc.healthyNodes()
+	err := ErrNoHealthyNode
+	for i, n := range nodes {
+		var raw json.RawMessage
+		if err = n.quorumClient().RPCCall(ctx, &raw, method, args...); err != nil {
+			if _, ok := err.(*msgError); ok || ctx.Err() != nil {
+				// the node answered, so the others would give the same error,
+				// or the caller is no longer waiting
+				return err
+			}
+			log.Warn("RPC call to Quorum node failed, trying another node", "node", n.config.Name, "method", method, "err", err)
+			continue
+		}
+		// a null result is not decoded into the raw message
+		if len(raw) == 0 {
+			raw = json.RawMessage("null")
+		}
+		// nodes slightly behind the others may not have the block or transaction yet
+		if string(raw) == "null" && i < len(nodes)-1 {
+			continue
+		}
+		return decodeRPCResponse(result, &message{Result: raw})
+	}
+	return err
+}
+
+// BatchRPCCall makes the batch of calls to a healthy node, trying the others in
+// turn if the node cannot be reached. Calls the node does not have the block or
+// transaction for yet are made to the other nodes.
+func (mc *MultiNodeClient) BatchRPCCall(ctx context.Context, batch []BatchElem) error {
+	nodes := mc.healthyNodes()
+	err := ErrNoHealthyNode
+	pending := make([]int, len(batch))
+	for i := range pending {
+		pending[i] = i
+	}
+	for i, n := range nodes {
+		raws := make([]json.RawMessage, len(pending))
+		nodeBatch := make([]BatchElem, len(pending))
+		for j, index := range pending {
+			nodeBatch[j] = BatchElem{Method: batch[index].Method, Args: batch[index].Args, Result: &raws[j]}
+		}
+		if err = n.quorumClient().BatchRPCCall(ctx, nodeBatch); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Warn("RPC batch call to Quorum node failed, trying another node", "node", n.config.Name, "err", err)
+			continue
+		}
+
+		var retry []int
+		for j, index := range pending {
+			if nodeBatch[j].Error != nil {
+				batch[index].Error = nodeBatch[j].Error
+				continue
+			}
+			// a null result is not decoded into the raw message
+			if len(raws[j]) == 0 {
+				raws[j] = json.RawMessage("null")
+			}
+			if string(raws[j]) == "null" && i < len(nodes)-1 {
+				retry = append(retry, index)
+				continue
+			}
+			batch[index].Error = decodeRPCResponse(batch[index].Result, &message{Result: raws[j]})
+		}
+		if pending = retry; len(pending) == 0 {
+			return nil
+		}
+	}
+	return err
+}
+
+// IsConnected checks whether any node is healthy.
+func (mc *MultiNodeClient) IsConnected() bool {
+	return len(mc.healthyNodes()) > 0
+}
+
+func (mc *MultiNodeClient) Stop() {
+	close(mc.shutdownChan)
+	mc.shutdownWg.Wait()
+	mc.stopNodes()
+	log.Info("Multi-node Quorum client stopped")
+}
+
+func (mc *MultiNodeClient) stopNodes() {
+	for _, n := range mc.nodes {
+		if client := n.quorumClient(); client != nil {
+			client.Stop()
+		}
+	}
+}
+
+// healthyNodes returns the healthy nodes, starting from a different node each
+// time so that calls are spread across them
+func (mc *MultiNodeClient) healthyNodes() []*node {
+	start := int(atomic.AddUint32(&mc.next, 1))
+	var healthy []*node
+	for i := range mc.nodes {
+		if n := mc.nodes[(start+i)%len(mc.nodes)]; n.isHealthy() {
+			healthy = append(healthy, n)
+		}
+	}
+	return healthy
+}
+
+// checkHealth finds the latest block of each node, marking nodes unhealthy if
+// they cannot be reached or are lagging behind the highest node. Chain heads are
+// then followed from another node if the current one is unhealthy.
+func (mc *MultiNodeClient) checkHealth() {
+	reachable := make([]bool, len(mc.nodes))
+	heads := make([]uint64, len(mc.nodes))
+	var wg sync.WaitGroup
+	for i, n := range mc.nodes {
+		wg.Add(1)
+		go func(i int, n *node) {
+			defer wg.Done()
+			reachable[i], heads[i] = mc.probe(n)
+		}(i, n)
+	}
+	wg.Wait()
+
+	var highest uint64
+	for i := range mc.nodes {
+		if reachable[i] && heads[i] > highest {
+			highest = heads[i]
+		}
+	}
+	for i, n := range mc.nodes {
+		healthy := reachable[i] && highest-heads[i] <= mc.maxBlocksBehind
+		if n.setHealthy(healthy) {
+			if healthy {
+				log.Info("Quorum node is healthy", "node", n.config.Name, "head", heads[i])
+			} else if !reachable[i] {
+				log.Warn("Quorum node is unreachable", "node", n.config.Name)
+			} else {
+				log.Warn("Quorum node is lagging behind the other nodes", "node", n.config.Name, "head", heads[i], "highest", highest)
+			}
+		}
+		if healthy {
+			metrics.QuorumNodeHealthy.WithLabelValues(n.config.Name).Set(1)
+		} else {
+			metrics.QuorumNodeHealthy.WithLabelValues(n.config.Name).Set(0)
+		}
+		if reachable[i] {
+			metrics.QuorumNodeHead.WithLabelValues(n.config.Name).Set(float64(heads[i]))
+		}
+	}
+
+	mc.failOverChainHead()
+}
+
+// probe connects to the node if not yet connected, and queries its latest block
+func (mc *MultiNodeClient) probe(n *node) (bool, uint64) {
+	client := n.quorumClient()
+	if client == nil {
+		nodeConfig := mc.config
+		nodeConfig.WSUrl, nodeConfig.GraphQLUrl, nodeConfig.RPCUrl = n.config.WSUrl, n.config.GraphQLUrl, n.config.RPCUrl
+		nodeConfig.Nodes = nil
+		var err error
+		if client, err = NewQuorumClient(nodeConfig, mc.transport); err != nil {
+			log.Debug("Unable to connect to Quorum node", "node", n.config.Name, "err", err)
+			return false, 0
+		}
+		n.mu.Lock()
+		n.client = client
+		n.mu.Unlock()
+	}
+	if !client.IsConnected() {
+		return false, 0
+	}
+	ctx, cancel := withShutdown(context.Background(), mc.timeouts.graphQL, mc.shutdownChan)
+	defer cancel()
+	head, err := CurrentBlock(ctx, client)
+	if err != nil {
+		log.Debug("Unable to query latest block of Quorum node", "node", n.config.Name, "err", err)
+		return false, 0
+	}
+	return true, head
+}
+
+func (mc *MultiNodeClient) failOverChainHead() {
+	mc.headMu.Lock()
+	defer mc.headMu.Unlock()
+	if mc.headChan == nil || (mc.headSource != nil && mc.headSource.isHealthy()) {
+		return
+	}
+	if err := mc.subscribeChainHead(); err != nil {
+		log.Error("Unable to follow chain heads from another Quorum node", "err", err)
+	}
+}
+
+// subscribeChainHead follows chain heads from the first healthy node that can
+// be subscribed to. The headMu lock must be held.
+func (mc *MultiNodeClient) subscribeChainHead() error {
+	err := ErrNoHealthyNode
+	for _, n := range mc.healthyNodes() {
+		if err = mc.subscribeNode(n); err != nil {
+			log.Warn("Subscribe to chain head of Quorum node failed", "node", n.config.Name, "err", err)
+			continue
+		}
+		if mc.headSource != n {
+			log.Info("Following chain heads from Quorum node", "node", n.config.Name)
+			mc.headSource = n
+		}
+		return nil
+	}
+	return err
+}
+
+// subscribeNode subscribes to the chain heads of the node, if not already
+// subscribed. Headers are only passed on while the node is the head source.
+func (mc *MultiNodeClient) subscribeNode(n *node) error {
+	if n.subscribed {
+		return nil
+	}
+	// make headers channel buffered so that it doesn't block websocket listener
+	headers := make(chan types.RawHeader, 10)
+	if err := n.quorumClient().SubscribeChainHead(headers); err != nil {
+		return err
+	}
+	n.subscribed = true
+
+	mc.shutdownWg.Add(1)
+	go func() {
+		defer mc.shutdownWg.Done()
+		for {
+			select {
+			case header := <-headers:
+				mc.forwardHeader(n, header)
+			case <-mc.shutdownChan:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// forwardHeader passes on a header from the node if it is the head source,
+// first sending headers for any blocks missed while failing over to the node
+func (mc *MultiNodeClient) forwardHeader(n *node, header types.RawHeader) {
+	mc.headMu.Lock()
+	if mc.headSource != n || mc.headChan == nil {
+		mc.headMu.Unlock()
+		return
+	}
+	ch := mc.headChan
+	var headers []types.RawHeader
+	number := header.Number.ToUint64()
+	if mc.lastHead > 0 {
+		for missing := mc.lastHead + 1; missing < number; missing++ {
+			headers = append(headers, types.RawHeader{Number: types.HexNumber(missing)})
+		}
+	}
+	if number > mc.lastHead {
+		mc.lastHead = number
+	}
+	mc.headMu.Unlock()
+
+	for _, h := range append(headers, header) {
+		select {
+		case ch <- h:
+		case <-mc.shutdownChan:
+			return
+		}
+	}
+}
+
+func (n *node) quorumClient() *QuorumClient {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.client
+}
+
+func (n *node) isHealthy() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.healthy
+}
+
+// setHealthy records the result of a health check, returning whether the health
+// of the node changed
+func (n *node) setHealthy(healthy bool) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	changed := n.healthy != healthy
+	n.healthy = healthy
+	return changed
+}
@@ -0,0 +1,227 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// nodeServer serves the GraphQL endpoint of a node at /graphql, and its HTTP
+// JSON-RPC endpoint at /rpc, with blocks up to its head
+type nodeServer struct {
+	*httptest.Server
+	head  uint64
+	calls int32
+}
+
+func newNodeServer(head uint64) *nodeServer {
+	s := &nodeServer{head: head}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		head := atomic.LoadUint64(&s.head)
+		if r.URL.Path == "/graphql" {
+			io.WriteString(w, fmt.Sprintf(`{"data": {"block": {"number": "0x%x"}}}`, head))
+			return
+		}
+		atomic.AddInt32(&s.calls, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		if !bytes.HasPrefix(body, []byte("[")) {
+			var msg message
+			_ = json.Unmarshal(body, &msg)
+			_ = json.NewEncoder(w).Encode(answerNodeMessage(msg, head))
+			return
+		}
+		var msgs []message
+		_ = json.Unmarshal(body, &msgs)
+		responses := make([]message, len(msgs))
+		for i, msg := range msgs {
+			responses[i] = answerNodeMessage(msg, head)
+		}
+		_ = json.NewEncoder(w).Encode(responses)
+	}))
+	return s
+}
+
+func answerNodeMessage(msg message, head uint64) message {
+	var params []interface{}
+	_ = json.Unmarshal(msg.Params, &params)
+	response := message{Version: "2.0", ID: msg.ID, Result: json.RawMessage("null")}
+	switch msg.Method {
+	case "eth_getBlockByNumber":
+		if number, _ := strconv.ParseUint(strings.TrimPrefix(params[0].(string), "0x"), 16, 64); number <= head {
+			response.Result = json.RawMessage(fmt.Sprintf(`{"number": "0x%x"}`, number))
+		}
+	case "eth_blockNumber":
+		response.Result = json.RawMessage(fmt.Sprintf(`"0x%x"`, head))
+	default:
+		response.Error = &msgError{Code: -32601, Message: "the method " + msg.Method + " does not exist/is not available"}
+	}
+	return response
+}
+
+func (s *nodeServer) config(name string) *types.NodeConfig {
+	return &types.NodeConfig{Name: name, GraphQLUrl: s.URL + "/graphql", RPCUrl: s.URL + "/rpc"}
+}
+
+func newMultiNodeClient(t *testing.T, nodes ...*types.NodeConfig) *MultiNodeClient {
+	transport, _ := NewTransport(types.ConnectionConfig{})
+	mc, err := NewMultiNodeClient(types.ConnectionConfig{Nodes: nodes, HealthCheckInterval: 60, MaxNodeBlocksBehind: 5}, transport)
+	assert.Nil(t, err)
+	return mc
+}
+
+func TestMultiNodeClient_SpreadsCalls(t *testing.T) {
+	node1, node2 := newNodeServer(10), newNodeServer(10)
+	defer node1.Close()
+	defer node2.Close()
+	mc := newMultiNodeClient(t, node1.config("node1"), node2.config("node2"))
+	defer mc.Stop()
+
+	for i := uint64(1); i <= 10; i++ {
+		block, err := BlockByNumber(context.Background(), mc, i)
+		assert.Nil(t, err)
+		assert.EqualValues(t, i, block.Number)
+	}
+	// the startup connection test also calls each node once
+	assert.EqualValues(t, 6, atomic.LoadInt32(&node1.calls))
+	assert.EqualValues(t, 6, atomic.LoadInt32(&node2.calls))
+
+	// errors returned by a node are not retried on another
+	var res interface{}
+	assert.EqualError(t, mc.RPCCall(context.Background(), &res, "eth_unknown"), "the method eth_unknown does not exist/is not available")
+	assert.EqualValues(t, 13, atomic.LoadInt32(&node1.calls)+atomic.LoadInt32(&node2.calls))
+}
+
+func TestMultiNodeClient_FailsOver(t *testing.T) {
+	node1, node2 := newNodeServer(10), newNodeServer(10)
+	defer node2.Close()
+	mc := newMultiNodeClient(t, node1.config("node1"), node2.config("node2"))
+	defer mc.Stop()
+
+	// calls to the dead node are made to the other node instead
+	node1.Close()
+	for i := uint64(1); i <= 4; i++ {
+		block, err := BlockByNumber(context.Background(), mc, i)
+		assert.Nil(t, err)
+		assert.EqualValues(t, i, block.Number)
+	}
+	head, err := CurrentBlock(context.Background(), mc)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 10, head)
+
+	mc.checkHealth()
+	assert.False(t, mc.nodes[0].isHealthy())
+	assert.True(t, mc.nodes[1].isHealthy())
+	assert.True(t, mc.IsConnected())
+
+	node2.Close()
+	mc.checkHealth()
+	assert.False(t, mc.IsConnected())
+	_, err = BlockByNumber(context.Background(), mc, 1)
+	assert.Equal(t, ErrNoHealthyNode, err)
+}
+
+func TestMultiNodeClient_DetectsLaggingNode(t *testing.T) {
+	node1, node2 := newNodeServer(20), newNodeServer(20)
+	defer node1.Close()
+	defer node2.Close()
+	mc := newMultiNodeClient(t, node1.config("node1"), node2.config("node2"))
+	defer mc.Stop()
+
+	// nodes slightly behind are still used, asking another node for blocks they do not have
+	atomic.StoreUint64(&node2.head, 16)
+	mc.checkHealth()
+	assert.True(t, mc.nodes[1].isHealthy())
+	for i := 0; i < 4; i++ {
+		block, err := BlockByNumber(context.Background(), mc, 20)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 20, block.Number)
+	}
+
+	atomic.StoreUint64(&node2.head, 14)
+	mc.checkHealth()
+	assert.True(t, mc.nodes[0].isHealthy())
+	assert.False(t, mc.nodes[1].isHealthy())
+
+	calls := atomic.LoadInt32(&node2.calls)
+	for i := uint64(1); i <= 4; i++ {
+		_, err := BlockByNumber(context.Background(), mc, i)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, calls, atomic.LoadInt32(&node2.calls))
+
+	// the node is used again once it catches up
+	atomic.StoreUint64(&node2.head, 20)
+	mc.checkHealth()
+	assert.True(t, mc.nodes[1].isHealthy())
+}
+
+func TestMultiNodeClient_BatchRPCCall(t *testing.T) {
+	node1, node2 := newNodeServer(20), newNodeServer(16)
+	defer node2.Close()
+	mc := newMultiNodeClient(t, node1.config("node1"), node2.config("node2"))
+	defer mc.Stop()
+
+	// blocks the lagging node does not have yet are fetched from the other node
+	for i := 0; i < 2; i++ {
+		blocks, err := BlocksByNumber(context.Background(), mc, 14, 20)
+		assert.Nil(t, err)
+		assert.Len(t, blocks, 7)
+		for j, block := range blocks {
+			assert.EqualValues(t, 14+j, block.Number)
+		}
+	}
+
+	var res interface{}
+	batch := []BatchElem{{Method: "eth_unknown", Result: &res}}
+	assert.Nil(t, mc.BatchRPCCall(context.Background(), batch))
+	assert.EqualError(t, batch[0].Error, "the method eth_unknown does not exist/is not available")
+
+	// batches are made to the other node if a node cannot be reached
+	node1.Close()
+	for i := 0; i < 2; i++ {
+		blocks, err := BlocksByNumber(context.Background(), mc, 1, 16)
+		assert.Nil(t, err)
+		assert.Len(t, blocks, 16)
+	}
+}
+
+func TestMultiNodeClient_ForwardsHeadersFromHeadSource(t *testing.T) {
+	headers := make(chan types.RawHeader, 10)
+	source, other := &node{}, &node{}
+	mc := &MultiNodeClient{headChan: headers, headSource: source, shutdownChan: make(chan struct{})}
+
+	mc.forwardHeader(source, types.RawHeader{Number: 5})
+	mc.forwardHeader(other, types.RawHeader{Number: 6})
+	// after failing over, headers for the blocks missed are sent
+	mc.headSource = other
+	mc.forwardHeader(other, types.RawHeader{Number: 8})
+	close(headers)
+
+	var numbers []uint64
+	for header := range headers {
+		numbers = append(numbers, header.Number.ToUint64())
+	}
+	assert.Equal(t, []uint64{5, 6, 7, 8}, numbers)
+}
+
+func TestNewMultiNodeClient_NoHealthyNode(t *testing.T) {
+	transport, _ := NewTransport(types.ConnectionConfig{})
+	_, err := NewMultiNodeClient(types.ConnectionConfig{
+		Nodes:               []*types.NodeConfig{{Name: "node1", GraphQLUrl: "http://invalid", RPCUrl: "http://invalid"}},
+		HealthCheckInterval: 60,
+	}, transport)
+	assert.Equal(t, ErrNoHealthyNode, err)
+}
@@ -11,96 +11,226 @@ import (
 	"github.com/machinebox/graphql"
 
 	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/metrics"
 	"quorumengineering/quorum-report/types"
 )
 
+// batchMethod labels the metrics of batch calls, which can mix methods
+const batchMethod = "batch"
+
 // QuorumClient provides access to quorum blockchain node.
 type QuorumClient struct {
 	wsClient      *webSocketClient
+	httpClient    *httpRPCClient
 	graphqlClient *graphql.Client
+	timeouts      callTimeouts
 
 	// To check we have actually shut down before returning
 	shutdownChan chan struct{}
 	shutdownWg   sync.WaitGroup
 }
 
-func NewQuorumClient(rawUrl, qgUrl string) (*QuorumClient, error) {
+// NewQuorumClient connects to Quorum over WebSocket and GraphQL, using the
+// credentials and TLS settings of the transport for both. If an HTTP JSON-RPC
+// URL is configured, RPC calls are made over HTTP, and the WebSocket is only
+// used for the chain head subscription, so can be left out.
+func NewQuorumClient(config types.ConnectionConfig, transport *Transport) (*QuorumClient, error) {
 	quorumClient := &QuorumClient{
-		graphqlClient: graphql.NewClient(qgUrl),
+		graphqlClient: graphql.NewClient(config.GraphQLUrl, graphql.WithHTTPClient(transport.httpClient(0))),
+		timeouts:      newCallTimeouts(config),
 		shutdownChan:  make(chan struct{}),
 	}
-	var err error
-	log.Debug("Connecting to Quorum WebSocket endpoint", "rawUrl", rawUrl)
-	quorumClient.wsClient, err = newWebSocketClient(rawUrl)
-	if err != nil {
-		return nil, errors.New("connect Quorum WebSocket endpoint failed")
+	if config.WSUrl == "" && config.RPCUrl == "" {
+		return nil, errors.New("no Quorum WebSocket or HTTP JSON-RPC endpoint configured")
+	}
+	if config.WSUrl != "" {
+		var err error
+		log.Debug("Connecting to Quorum WebSocket endpoint", "rawUrl", config.WSUrl)
+		quorumClient.wsClient, err = newWebSocketClient(config.WSUrl, transport)
+		if err != nil {
+			return nil, errors.New("connect Quorum WebSocket endpoint failed")
+		}
+		log.Debug("Connected to WebSocket endpoint")
 	}
-	log.Debug("Connected to WebSocket endpoint")
 
 	// Test graphql endpoint connection.
-	log.Debug("Connecting to GraphQL endpoint", "url", qgUrl)
+	log.Debug("Connecting to GraphQL endpoint", "url", config.GraphQLUrl)
 	var resp map[string]interface{}
-	if err := quorumClient.ExecuteGraphQLQuery(&resp, CurrentBlockQuery()); err != nil || len(resp) == 0 {
+	if err := quorumClient.ExecuteGraphQLQuery(context.Background(), &resp, CurrentBlockQuery()); err != nil || len(resp) == 0 {
 		log.Error("Error calling GraphQL endpoint at startup", "err", err)
 		return nil, errors.New("call graphql endpoint failed")
 	}
 	log.Debug("Connected to GraphQL endpoint")
 
+	if config.RPCUrl != "" {
+		quorumClient.httpClient = newHTTPRPCClient(config.RPCUrl, config.RPCPoolSize, transport)
+		// Test HTTP JSON-RPC endpoint connection.
+		log.Debug("Connecting to HTTP JSON-RPC endpoint", "url", config.RPCUrl)
+		if err := quorumClient.RPCCall(context.Background(), new(json.RawMessage), "eth_blockNumber"); err != nil {
+			log.Error("Error calling HTTP JSON-RPC endpoint at startup", "err", err)
+			return nil, errors.New("call http json-rpc endpoint failed")
+		}
+		log.Debug("Connected to HTTP JSON-RPC endpoint", "pool size", config.RPCPoolSize)
+	}
+
 	// Start websocket receiver.
-	go func() {
-		quorumClient.shutdownWg.Add(1)
-		quorumClient.wsClient.listen(quorumClient.shutdownChan)
-		quorumClient.shutdownWg.Done()
-	}()
+	if quorumClient.wsClient != nil {
+		go func() {
+			quorumClient.shutdownWg.Add(1)
+			quorumClient.wsClient.listen(quorumClient.shutdownChan)
+			quorumClient.shutdownWg.Done()
+		}()
+	}
 
 	return quorumClient, nil
 }
 
+// NewClient connects to the Quorum nodes in the config, using a MultiNodeClient
+// if several nodes are configured.
+func NewClient(config types.ConnectionConfig, transport *Transport) (Client, error) {
+	if len(config.Nodes) > 0 {
+		multiNodeClient, err := NewMultiNodeClient(config, transport)
+		if err != nil {
+			return nil, err
+		}
+		return multiNodeClient, nil
+	}
+	quorumClient, err := NewQuorumClient(config, transport)
+	if err != nil {
+		return nil, err
+	}
+	return quorumClient, nil
+}
+
 // Subscribe to chain head event.
 func (qc *QuorumClient) SubscribeChainHead(ch chan<- types.RawHeader) error {
+	if qc.wsClient == nil {
+		return errors.New("no WebSocket endpoint configured")
+	}
 	return qc.wsClient.subscribeChainHead(ch)
 }
 
 // Execute customized graphql query.
-func (qc *QuorumClient) ExecuteGraphQLQuery(result interface{}, query string) error {
+func (qc *QuorumClient) ExecuteGraphQLQuery(ctx context.Context, result interface{}, query string) error {
+	ctx, cancel := withShutdown(ctx, qc.timeouts.graphQL, qc.shutdownChan)
+	defer cancel()
 	// Build a request from query.
 	req := graphql.NewRequest(query)
 	// Run it and capture the response.
-	return qc.graphqlClient.Run(context.Background(), req, &result)
+	return qc.graphqlClient.Run(ctx, req, &result)
 }
 
-// Execute customized rpc call.
-func (qc *QuorumClient) RPCCall(result interface{}, method string, args ...interface{}) error {
+// Execute customized rpc call. The call is given up on if the context ends
+// first, or the timeout for the method passes.
+func (qc *QuorumClient) RPCCall(ctx context.Context, result interface{}, method string, args ...interface{}) (err error) {
+	defer func(start time.Time) {
+		metrics.QuorumRPCDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.QuorumRPCErrors.WithLabelValues(method).Inc()
+		}
+	}(time.Now())
+
+	ctx, cancel := withShutdown(ctx, qc.timeouts.forMethod(method), qc.shutdownChan)
+	defer cancel()
+
+	if qc.httpClient != nil {
+		response, err := qc.httpClient.call(ctx, method, args...)
+		if err != nil {
+			return err
+		}
+		return decodeRPCResponse(result, response)
+	}
+
 	resultChan := make(chan *message, 1)
-	err := qc.wsClient.sendRPCMsg(resultChan, method, args...)
-	if err != nil {
+	if err := qc.wsClient.sendRPCMsg(resultChan, method, args...); err != nil {
 		return err
 	}
 
-	rpcCallTimeout := time.NewTicker(time.Second * 1)
-	defer rpcCallTimeout.Stop()
 	select {
 	case response := <-resultChan:
 		if response == nil {
 			return errors.New("nil rpc response")
 		}
-		log.Debug("rpc call response", "response", string(response.Result))
-		if response.Error != nil {
-			return response.Error
-		}
-		if err := json.Unmarshal(response.Result, &result); err != nil {
-			// if response.Result is not a JSON, assign to result directly
-			reflect.ValueOf(result).Elem().Set(reflect.ValueOf(response.Result))
+		return decodeRPCResponse(result, response)
+	case <-ctx.Done():
+		return contextError(ctx)
+	}
+}
+
+// Execute a batch of rpc calls in a single request.
+func (qc *QuorumClient) BatchRPCCall(ctx context.Context, batch []BatchElem) (err error) {
+	defer func(start time.Time) {
+		metrics.QuorumRPCDuration.WithLabelValues(batchMethod).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.QuorumRPCErrors.WithLabelValues(batchMethod).Inc()
 		}
+	}(time.Now())
+	if len(batch) == 0 {
 		return nil
-	case <-rpcCallTimeout.C:
-		return errors.New("rpc call timeout")
 	}
+
+	ctx, cancel := withShutdown(ctx, qc.timeouts.forBatch(batch), qc.shutdownChan)
+	defer cancel()
+
+	responses := make([]*message, len(batch))
+	if qc.httpClient != nil {
+		if responses, err = qc.httpClient.batchCall(ctx, batch); err != nil {
+			return err
+		}
+	} else {
+		resultChans := make([]chan *message, len(batch))
+		for i := range resultChans {
+			resultChans[i] = make(chan *message, 1)
+		}
+		if err := qc.wsClient.sendBatchRPCMsg(resultChans, batch); err != nil {
+			return err
+		}
+
+		for i, ch := range resultChans {
+			select {
+			case responses[i] = <-ch:
+			case <-ctx.Done():
+				return contextError(ctx)
+			}
+		}
+	}
+
+	for i, response := range responses {
+		if response == nil {
+			batch[i].Error = errors.New("nil rpc response")
+			continue
+		}
+		batch[i].Error = decodeRPCResponse(batch[i].Result, response)
+	}
+	return nil
+}
+
+// decodeRPCResponse sets the result of a call from its response
+func decodeRPCResponse(result interface{}, response *message) error {
+	log.Debug("rpc call response", "response", string(response.Result))
+	if response.Error != nil {
+		return response.Error
+	}
+	if err := json.Unmarshal(response.Result, &result); err != nil {
+		// if response.Result is not a JSON, assign to result directly
+		reflect.ValueOf(result).Elem().Set(reflect.ValueOf(response.Result))
+	}
+	return nil
+}
+
+// IsConnected checks whether the WebSocket connection to Quorum is currently up.
+// Without a WebSocket endpoint there is no long-lived connection, so the client
+// is always connected.
+func (qc *QuorumClient) IsConnected() bool {
+	if qc.wsClient == nil {
+		return true
+	}
+	return qc.wsClient.isConnected()
 }
 
 func (qc *QuorumClient) Stop() {
 	close(qc.shutdownChan)
-	if qc.wsClient.conn != nil {
+	if qc.wsClient != nil && qc.wsClient.conn != nil {
 		qc.wsClient.conn.Close()
 	}
 	qc.shutdownWg.Wait()
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"reflect"
@@ -28,7 +29,7 @@ func (qc *StubQuorumClient) SubscribeChainHead(chan<- types.RawHeader) error {
 	return errors.New("not implemented")
 }
 
-func (qc *StubQuorumClient) ExecuteGraphQLQuery(result interface{}, query string) error {
+func (qc *StubQuorumClient) ExecuteGraphQLQuery(_ context.Context, result interface{}, query string) error {
 	if resp, ok := qc.mockGraphQL[query]; ok {
 		out, _ := json.Marshal(resp)
 		return json.Unmarshal(out, &result)
@@ -36,15 +37,26 @@ func (qc *StubQuorumClient) ExecuteGraphQLQuery(result interface{}, query string
 	return errors.New("not found")
 }
 
-func (qc *StubQuorumClient) RPCCall(result interface{}, method string, args ...interface{}) error {
+func (qc *StubQuorumClient) RPCCall(_ context.Context, result interface{}, method string, args ...interface{}) error {
 	for _, arg := range args {
 		method += reflect.ValueOf(arg).String()
 	}
 	if resp, ok := qc.mockRPC[method]; ok {
+		// a mocked error is returned as the error of the call
+		if err, isErr := resp.(error); isErr {
+			return err
+		}
 		reflect.ValueOf(result).Elem().Set(reflect.ValueOf(resp))
 		return nil
 	}
 	return errors.New("not found")
 }
 
+func (qc *StubQuorumClient) BatchRPCCall(ctx context.Context, batch []BatchElem) error {
+	for i := range batch {
+		batch[i].Error = qc.RPCCall(ctx, batch[i].Result, batch[i].Method, batch[i].Args...)
+	}
+	return nil
+}
+
 func (qc *StubQuorumClient) Stop() {}
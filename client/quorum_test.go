@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -10,6 +11,8 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
 )
 
 var upgrader = websocket.Upgrader{}
@@ -61,16 +64,64 @@ func TestQuorumClient(t *testing.T) {
 	assert.Nil(t, err, "expected no error, but got %v", err)
 	_ = ws.Close()
 
-	_, err = NewQuorumClient("ws://invalid", "http://invalid")
+	transport, err := NewTransport(types.ConnectionConfig{})
+	assert.Nil(t, err)
+
+	_, err = NewQuorumClient(types.ConnectionConfig{WSUrl: "ws://invalid", GraphQLUrl: "http://invalid"}, transport)
 	assert.NotNil(t, err, "expected error but got nil")
 
-	_, err = NewQuorumClient(rpcurl, "http://invalid")
+	_, err = NewQuorumClient(types.ConnectionConfig{WSUrl: rpcurl, GraphQLUrl: "http://invalid"}, transport)
 	assert.NotNil(t, err, "expected error but got nil")
 
-	_, err = NewQuorumClient(rpcurl, graphqlServer.URL)
+	_, err = NewQuorumClient(types.ConnectionConfig{WSUrl: rpcurl, GraphQLUrl: graphqlServer.URL}, transport)
 	assert.Nil(t, err, "expected no error, but got %v", err)
 }
 
+func TestQuorumClient_WebSocketBatchRPCCall(t *testing.T) {
+	// Create test rpc websocket server answering calls and batches of calls.
+	rpcServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			mt, message, err := c.ReadMessage()
+			if err != nil {
+				break
+			}
+			if err = c.WriteMessage(mt, answerJSONRPC(message)); err != nil {
+				break
+			}
+		}
+	}))
+	defer rpcServer.Close()
+	graphqlServer := httptest.NewServer(&recordingServer{})
+	defer graphqlServer.Close()
+
+	transport, _ := NewTransport(types.ConnectionConfig{})
+	quorumClient, err := NewQuorumClient(types.ConnectionConfig{
+		WSUrl:      "ws" + strings.TrimPrefix(rpcServer.URL, "http"),
+		GraphQLUrl: graphqlServer.URL,
+	}, transport)
+	assert.Nil(t, err)
+	defer quorumClient.Stop()
+
+	var blockNumber, code string
+	var res interface{}
+	batch := []BatchElem{
+		{Method: "eth_getCode", Args: []interface{}{"0x0000000000000000000000000000000000000001", "latest"}, Result: &code},
+		{Method: "eth_unknown", Result: &res},
+		{Method: "eth_blockNumber", Result: &blockNumber},
+	}
+	assert.Nil(t, quorumClient.BatchRPCCall(context.Background(), batch))
+	assert.Nil(t, batch[0].Error)
+	assert.Equal(t, "0x6080", code)
+	assert.EqualError(t, batch[1].Error, "the method eth_unknown does not exist/is not available")
+	assert.Nil(t, batch[2].Error)
+	assert.Equal(t, "0x20", blockNumber)
+}
+
 func TestStubQuorumClient(t *testing.T) {
 	mockGraphQL := map[string]map[string]interface{}{
 		"query": {"hello": "world"},
@@ -83,19 +134,19 @@ func TestStubQuorumClient(t *testing.T) {
 
 	// test mock GraphQL
 	var resp map[string]interface{}
-	err = c.ExecuteGraphQLQuery(&resp, "query")
+	err = c.ExecuteGraphQLQuery(context.Background(), &resp, "query")
 	assert.Nil(t, err, "expected no error, but got %v", err)
 	assert.Equal(t, "world", resp["hello"], "expected resp hello world, but got %v", resp["hello"])
 
-	err = c.ExecuteGraphQLQuery(&resp, "random")
+	err = c.ExecuteGraphQLQuery(context.Background(), &resp, "random")
 	assert.EqualError(t, err, "not found", "unexpected error message")
 
 	// test mock RPC
 	var res string
-	err = c.RPCCall(&res, "rpc_method")
+	err = c.RPCCall(context.Background(), &res, "rpc_method")
 	assert.Nil(t, err, "expected no error, but got %v", err)
 	assert.Equal(t, "hi", res, "expected res hi, but got %v", res)
 
-	err = c.RPCCall(&res, "rpc_nil")
+	err = c.RPCCall(context.Background(), &res, "rpc_nil")
 	assert.EqualError(t, err, "not found", "unexpected error message")
 }
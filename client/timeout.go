@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"quorumengineering/quorum-report/types"
+)
+
+const (
+	defaultRPCTimeout     = 10 * time.Second
+	defaultGraphQLTimeout = 10 * time.Second
+)
+
+// defaultRPCMethodTimeouts gives methods that can take a long time on big
+// contracts longer than other calls
+var defaultRPCMethodTimeouts = map[string]time.Duration{
+	traceTransaction: 2 * time.Minute,
+	traceBlock:       2 * time.Minute,
+	dumpAddress:      2 * time.Minute,
+}
+
+// callTimeouts holds how long to wait for calls to Quorum
+type callTimeouts struct {
+	rpc        time.Duration
+	rpcMethods map[string]time.Duration
+	graphQL    time.Duration
+}
+
+func newCallTimeouts(config types.ConnectionConfig) callTimeouts {
+	timeouts := callTimeouts{
+		rpc:        defaultRPCTimeout,
+		rpcMethods: make(map[string]time.Duration),
+		graphQL:    defaultGraphQLTimeout,
+	}
+	if config.RPCTimeout > 0 {
+		timeouts.rpc = time.Duration(config.RPCTimeout) * time.Second
+	} else {
+		for method, timeout := range defaultRPCMethodTimeouts {
+			timeouts.rpcMethods[method] = timeout
+		}
+	}
+	for method, timeout := range config.RPCMethodTimeouts {
+		timeouts.rpcMethods[method] = time.Duration(timeout) * time.Second
+	}
+	if config.GraphQLTimeout > 0 {
+		timeouts.graphQL = time.Duration(config.GraphQLTimeout) * time.Second
+	}
+	return timeouts
+}
+
+// forMethod returns how long to wait for a call of the method
+func (t callTimeouts) forMethod(method string) time.Duration {
+	if timeout, ok := t.rpcMethods[method]; ok {
+		return timeout
+	}
+	return t.rpc
+}
+
+// forBatch returns how long to wait for all the calls of the batch, which is as
+// long as the slowest method in it is given
+func (t callTimeouts) forBatch(batch []BatchElem) time.Duration {
+	var timeout time.Duration
+	for _, elem := range batch {
+		if methodTimeout := t.forMethod(elem.Method); methodTimeout > timeout {
+			timeout = methodTimeout
+		}
+	}
+	return timeout
+}
+
+// withShutdown derives a context for a call, which is cancelled once the
+// timeout passes or the shutdown channel is closed, so that shutting down does
+// not wait on calls in flight
+func withShutdown(ctx context.Context, timeout time.Duration, shutdownChan <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	go func() {
+		select {
+		case <-shutdownChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// contextError describes why the context of a call ended
+func contextError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("rpc call timeout")
+	}
+	return errors.New("rpc call cancelled")
+}
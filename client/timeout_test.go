@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+func TestNewCallTimeouts(t *testing.T) {
+	timeouts := newCallTimeouts(types.ConnectionConfig{})
+	assert.Equal(t, defaultRPCTimeout, timeouts.forMethod(getCode))
+	assert.Equal(t, 2*time.Minute, timeouts.forMethod(traceTransaction))
+	assert.Equal(t, defaultGraphQLTimeout, timeouts.graphQL)
+	assert.Equal(t, 2*time.Minute, timeouts.forBatch([]BatchElem{{Method: getCode}, {Method: dumpAddress}}))
+
+	timeouts = newCallTimeouts(types.ConnectionConfig{
+		RPCTimeout:        30,
+		RPCMethodTimeouts: map[string]int{dumpAddress: 600},
+		GraphQLTimeout:    5,
+	})
+	assert.Equal(t, 30*time.Second, timeouts.forMethod(getCode))
+	assert.Equal(t, 30*time.Second, timeouts.forMethod(traceTransaction))
+	assert.Equal(t, 10*time.Minute, timeouts.forMethod(dumpAddress))
+	assert.Equal(t, 5*time.Second, timeouts.graphQL)
+}
+
+// newBlockingRPCServer answers eth_blockNumber, and blocks on any other call
+// until the client gives up on it
+func newBlockingRPCServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg message
+		_ = json.NewDecoder(r.Body).Decode(&msg)
+		if msg.Method != "eth_blockNumber" {
+			<-r.Context().Done()
+			return
+		}
+		_ = json.NewEncoder(w).Encode(message{Version: "2.0", ID: msg.ID, Result: json.RawMessage(`"0x20"`)})
+	}))
+}
+
+func TestQuorumClient_RPCCallTimeout(t *testing.T) {
+	graphqlServer := httptest.NewServer(&recordingServer{})
+	defer graphqlServer.Close()
+	rpcServer := newBlockingRPCServer()
+	defer rpcServer.Close()
+
+	transport, _ := NewTransport(types.ConnectionConfig{})
+	quorumClient, err := NewQuorumClient(types.ConnectionConfig{GraphQLUrl: graphqlServer.URL, RPCUrl: rpcServer.URL}, transport)
+	assert.Nil(t, err)
+	defer quorumClient.Stop()
+	quorumClient.timeouts.rpcMethods[getCode] = 50 * time.Millisecond
+
+	var code string
+	assert.EqualError(t, quorumClient.RPCCall(context.Background(), &code, getCode, "0x0000000000000000000000000000000000000001", "latest"), "rpc call timeout")
+
+	// the caller can give up sooner than the timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.EqualError(t, quorumClient.RPCCall(ctx, &code, dumpAddress, "0x0000000000000000000000000000000000000001", "latest"), "rpc call cancelled")
+}
+
+func TestQuorumClient_StopCancelsCalls(t *testing.T) {
+	graphqlServer := httptest.NewServer(&recordingServer{})
+	defer graphqlServer.Close()
+	rpcServer := newBlockingRPCServer()
+	defer rpcServer.Close()
+
+	transport, _ := NewTransport(types.ConnectionConfig{})
+	quorumClient, err := NewQuorumClient(types.ConnectionConfig{GraphQLUrl: graphqlServer.URL, RPCUrl: rpcServer.URL}, transport)
+	assert.Nil(t, err)
+
+	errChan := make(chan error)
+	go func() {
+		var res interface{}
+		errChan <- quorumClient.RPCCall(context.Background(), &res, traceTransaction, "0x0000000000000000000000000000000000000000000000000000000000000001")
+	}()
+	time.Sleep(50 * time.Millisecond)
+	quorumClient.Stop()
+
+	select {
+	case err := <-errChan:
+		assert.EqualError(t, err, "rpc call cancelled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("call in flight was not cancelled on stop")
+	}
+}
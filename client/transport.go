@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// Transport holds the credentials and TLS settings used to connect to Quorum,
+// and applies them to both the WebSocket and GraphQL connections.
+type Transport struct {
+	tlsConfig   *tls.Config
+	headers     http.Header
+	tokenSource oauth2.TokenSource
+}
+
+// NewTransport creates a transport from the connection config. If OAuth2 is
+// configured, tokens are fetched when first needed and again when they expire.
+func NewTransport(config types.ConnectionConfig) (*Transport, error) {
+	transport := &Transport{headers: make(http.Header)}
+	for name, value := range config.Headers {
+		transport.headers.Set(name, value)
+	}
+
+	if config.TLS != nil {
+		tlsConfig, err := clientTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.tlsConfig = tlsConfig
+	}
+
+	if config.Token != "" {
+		transport.tokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.Token})
+	} else if config.OAuth2 != nil {
+		credentials := &clientcredentials.Config{
+			ClientID:     config.OAuth2.ClientID,
+			ClientSecret: config.OAuth2.ClientSecret,
+			TokenURL:     config.OAuth2.TokenURL,
+			Scopes:       config.OAuth2.Scopes,
+		}
+		// the token endpoint is reached with the same TLS settings, but without the headers
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: transport.tlsConfig},
+			Timeout:   30 * time.Second,
+		})
+		transport.tokenSource = credentials.TokenSource(ctx)
+	}
+	return transport, nil
+}
+
+// header returns the headers to send with a request, including the current
+// bearer token, which is refreshed first if it has expired
+func (t *Transport) header() (http.Header, error) {
+	header := t.headers.Clone()
+	if t.tokenSource != nil {
+		token, err := t.tokenSource.Token()
+		if err != nil {
+			return nil, fmt.Errorf("fetching quorum access token: %v", err)
+		}
+		token.SetAuthHeader(&http.Request{Header: header})
+	}
+	return header, nil
+}
+
+// dialer returns a WebSocket dialer using the TLS settings
+func (t *Transport) dialer() *websocket.Dialer {
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = t.tlsConfig
+	return &dialer
+}
+
+// httpClient returns an HTTP client that uses the TLS settings and sends the
+// headers and bearer token with each request. If maxConnections is set, it
+// limits the connections to each host, all of which are kept open when idle.
+func (t *Transport) httpClient(maxConnections int) *http.Client {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: t.tlsConfig}
+	if maxConnections > 0 {
+		transport.MaxConnsPerHost = maxConnections
+		transport.MaxIdleConnsPerHost = maxConnections
+	}
+	return &http.Client{Transport: &headerRoundTripper{transport: t, next: transport}}
+}
+
+type headerRoundTripper struct {
+	transport *Transport
+	next      http.RoundTripper
+}
+
+func (rt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	header, err := rt.transport.header()
+	if err != nil {
+		return nil, err
+	}
+	// a RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	return rt.next.RoundTrip(req)
+}
+
+func clientTLSConfig(config *types.ClientTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.CACert != "" {
+		pem, err := ioutil.ReadFile(config.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("loading connection ca file: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("loading connection ca file: no certificates found")
+		}
+	}
+	if config.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading connection client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// recordingServer serves both the WebSocket and GraphQL endpoints, recording
+// the headers of each request
+type recordingServer struct {
+	mu      sync.Mutex
+	headers []http.Header
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.headers = append(s.headers, r.Header.Clone())
+	s.mu.Unlock()
+	if r.Header.Get("Upgrade") == "websocket" {
+		echo(w, r)
+		return
+	}
+	io.WriteString(w, `{"data": {"block": {"number": "0x6"}}}`)
+}
+
+func (s *recordingServer) lastHeader() http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers[len(s.headers)-1]
+}
+
+func TestTransport_SendsHeadersAndToken(t *testing.T) {
+	server := &recordingServer{}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	transport, err := NewTransport(types.ConnectionConfig{Headers: map[string]string{"X-Tenant": "reporting"}, Token: "static-token"})
+	assert.Nil(t, err)
+	quorumClient, err := NewQuorumClient(types.ConnectionConfig{WSUrl: "ws" + strings.TrimPrefix(httpServer.URL, "http"), GraphQLUrl: httpServer.URL}, transport)
+	assert.Nil(t, err)
+	defer quorumClient.Stop()
+
+	// the first request is the WebSocket handshake, and the second the GraphQL connection test
+	assert.Len(t, server.headers, 2)
+	for _, header := range server.headers {
+		assert.Equal(t, "reporting", header.Get("X-Tenant"))
+		assert.Equal(t, "Bearer static-token", header.Get("Authorization"))
+	}
+}
+
+func TestTransport_RefreshesOAuth2Token(t *testing.T) {
+	var tokenRequests int
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		tokenRequests++
+		w.Header().Set("Content-Type", "application/json")
+		// tokens expiring within 10 seconds are treated as already expired, so
+		// a new token is fetched for each request
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": 1}`, tokenRequests)
+	}))
+	defer tokenServer.Close()
+	server := &recordingServer{}
+	graphqlServer := httptest.NewServer(server)
+	defer graphqlServer.Close()
+
+	transport, err := NewTransport(types.ConnectionConfig{OAuth2: &types.OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"}})
+	assert.Nil(t, err)
+	client := transport.httpClient(0)
+
+	for i := 1; i <= 2; i++ {
+		resp, err := client.Get(graphqlServer.URL)
+		assert.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, fmt.Sprintf("Bearer token-%d", i), server.lastHeader().Get("Authorization"))
+	}
+}
+
+func TestTransport_MutualTLS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transport")
+	defer os.RemoveAll(dir)
+
+	clientCert := writeClientCertificate(t, dir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server := httptest.NewUnstartedServer(&recordingServer{})
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.Nil(t, ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644))
+
+	// without a client certificate the connection is rejected
+	transport, err := NewTransport(types.ConnectionConfig{TLS: &types.ClientTLSConfig{CACert: caFile}})
+	assert.Nil(t, err)
+	_, err = transport.httpClient(0).Get(server.URL)
+	assert.NotNil(t, err)
+
+	transport, err = NewTransport(types.ConnectionConfig{TLS: &types.ClientTLSConfig{
+		CACert:   caFile,
+		CertFile: filepath.Join(dir, "client.crt"),
+		KeyFile:  filepath.Join(dir, "client.key"),
+	}})
+	assert.Nil(t, err)
+	resp, err := transport.httpClient(0).Get(server.URL)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	ws, _, err := transport.dialer().Dial("wss"+strings.TrimPrefix(server.URL, "https"), nil)
+	assert.Nil(t, err)
+	ws.Close()
+}
+
+func TestNewTransport_InvalidCA(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "transport")
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.crt")
+	assert.Nil(t, ioutil.WriteFile(caFile, []byte("invalid"), 0644))
+
+	_, err := NewTransport(types.ConnectionConfig{TLS: &types.ClientTLSConfig{CACert: caFile}})
+	assert.EqualError(t, err, "loading connection ca file: no certificates found")
+}
+
+// writeClientCertificate writes a self-signed client certificate and key to
+// client.crt and client.key in the directory
+func writeClientCertificate(t *testing.T, dir string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "reporting"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "client.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "client.key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return cert
+}
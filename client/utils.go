@@ -1,9 +1,11 @@
 package client
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
 
 	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
@@ -14,19 +16,21 @@ const (
 	adminInfo        = "admin_nodeInfo"
 	dumpAddress      = "debug_dumpAddress"
 	traceTransaction = "debug_traceTransaction"
+	traceBlock       = "debug_traceBlockByNumber"
 	getCode          = "eth_getCode"
 	getBlockByNumber = "eth_getBlockByNumber"
 	ethStorageRoot   = "eth_storageRoot"
+	getBalance       = "eth_getBalance"
 	protocolKey      = "protocols"
 	istanbulKey      = "istanbul"
 	consensusKey     = "consensus"
 	ethKey           = "eth"
 )
 
-func DumpAddress(c Client, address types.Address, blockNumber uint64) (*types.AccountState, error) {
+func DumpAddress(ctx context.Context, c Client, address types.Address, blockNumber uint64) (*types.AccountState, error) {
 	log.Debug("Fetching account dump", "account", address.String(), "blocknumber", blockNumber)
 	dumpAccount := &types.RawAccountState{}
-	err := c.RPCCall(&dumpAccount, dumpAddress, address.String(), fmtBlockNum(blockNumber))
+	err := c.RPCCall(ctx, &dumpAccount, dumpAddress, address.String(), fmtBlockNum(blockNumber))
 	if err != nil {
 		return nil, err
 	}
@@ -42,26 +46,84 @@ func fmtBlockNum(blockNumber uint64) string {
 	return fmt.Sprintf("0x%x", blockNumber)
 }
 
-func TraceTransaction(c Client, txHash types.Hash) (types.RawOuterCall, error) {
-	log.Debug("Tracing transaction", "tx", txHash.String())
+const (
+	callTracer       = "callTracer"
+	nativeCallTracer = "callTracerNative"
+)
+
+// TraceConfig selects the tracer a transaction is traced with
+type TraceConfig struct {
+	Tracer string
+}
+
+// StructLogConfig traces a transaction with the default struct logger, leaving
+// out the memory and storage at each step
+type StructLogConfig struct {
+	DisableMemory  bool
+	DisableStorage bool
+}
+
+// TraceTransaction traces the internal calls of the transaction with the JS call tracer
+func TraceTransaction(ctx context.Context, c Client, txHash types.Hash) (types.RawOuterCall, error) {
+	return traceCalls(ctx, c, txHash, callTracer)
+}
+
+// TraceTransactionNative traces the internal calls of the transaction with the
+// native call tracer, which is much faster than the JS one. Newer nodes run the
+// native tracer as callTracer, so TraceTransaction is already native on them.
+func TraceTransactionNative(ctx context.Context, c Client, txHash types.Hash) (types.RawOuterCall, error) {
+	return traceCalls(ctx, c, txHash, nativeCallTracer)
+}
+
+func traceCalls(ctx context.Context, c Client, txHash types.Hash, tracer string) (types.RawOuterCall, error) {
+	log.Debug("Tracing transaction", "tx", txHash.String(), "tracer", tracer)
 
 	// Trace internal calls of the transaction
 	// Reference: https://github.com/ethereum/go-ethereum/issues/3128
 	var resp types.RawOuterCall
-	type TraceConfig struct {
-		Tracer string
-	}
-	err := c.RPCCall(&resp, traceTransaction, txHash.String(), &TraceConfig{Tracer: "callTracer"})
+	err := c.RPCCall(ctx, &resp, traceTransaction, txHash.String(), &TraceConfig{Tracer: tracer})
 	if err != nil {
 		return types.RawOuterCall{}, err
 	}
 	return resp, nil
 }
 
-func GetCode(c Client, address types.Address, blockNumber uint64) (types.HexData, error) {
+// TraceBlock traces the internal calls of every transaction of the block with a
+// single call, returning the calls of each transaction in the order of the block
+func TraceBlock(ctx context.Context, c Client, blockNumber uint64) ([]types.RawOuterCall, error) {
+	log.Debug("Tracing block", "blocknumber", blockNumber)
+
+	var resp []types.RawTransactionTrace
+	if err := c.RPCCall(ctx, &resp, traceBlock, fmtBlockNum(blockNumber), &TraceConfig{Tracer: callTracer}); err != nil {
+		return nil, err
+	}
+	traces := make([]types.RawOuterCall, len(resp))
+	for i, txTrace := range resp {
+		if txTrace.Error != "" {
+			return nil, fmt.Errorf("trace transaction %d of block %d: %s", i, blockNumber, txTrace.Error)
+		}
+		traces[i] = txTrace.Result
+	}
+	return traces, nil
+}
+
+// TraceTransactionStructLogs traces the transaction with the default struct
+// logger, which needs no JS or native tracer to be available on the node
+func TraceTransactionStructLogs(ctx context.Context, c Client, txHash types.Hash) (types.RawStructLogTrace, error) {
+	log.Debug("Tracing transaction struct logs", "tx", txHash.String())
+
+	var resp types.RawStructLogTrace
+	err := c.RPCCall(ctx, &resp, traceTransaction, txHash.String(), &StructLogConfig{DisableMemory: true, DisableStorage: true})
+	if err != nil {
+		return types.RawStructLogTrace{}, err
+	}
+	return resp, nil
+}
+
+func GetCode(ctx context.Context, c Client, address types.Address, blockNumber uint64) (types.HexData, error) {
 	log.Debug("Querying account code", "account", address.String(), "block number", blockNumber)
 	var res types.HexData
-	if err := c.RPCCall(&res, getCode, address.String(), fmtBlockNum(blockNumber)); err != nil {
+	if err := c.RPCCall(ctx, &res, getCode, address.String(), fmtBlockNum(blockNumber)); err != nil {
 		log.Debug("Error querying account code", "account", address.String(), "block number", blockNumber, "err", err)
 		return "", err
 	}
@@ -69,11 +131,11 @@ func GetCode(c Client, address types.Address, blockNumber uint64) (types.HexData
 	return res, nil
 }
 
-func Consensus(c Client) (string, error) {
+func Consensus(ctx context.Context, c Client) (string, error) {
 	log.Debug("Fetching consensus info")
 
 	var resp map[string]interface{}
-	err := c.RPCCall(&resp, adminInfo)
+	err := c.RPCCall(ctx, &resp, adminInfo)
 	if err != nil {
 		return "", err
 	}
@@ -91,7 +153,7 @@ func Consensus(c Client) (string, error) {
 	return protocol[consensusKey].(string), nil
 }
 
-func CallEIP165(c Client, address types.Address, interfaceId []byte, blockNum uint64) (bool, error) {
+func CallEIP165(ctx context.Context, c Client, address types.Address, interfaceId []byte, blockNum uint64) (bool, error) {
 	eip165Id, _ := hex.DecodeString("01ffc9a70")
 
 	//interfaceId should be 4 bytes long
@@ -109,7 +171,7 @@ func CallEIP165(c Client, address types.Address, interfaceId []byte, blockNum ui
 	}
 
 	var res types.HexData
-	err := c.RPCCall(&res, ethCall, msg, fmtBlockNum(blockNum))
+	err := c.RPCCall(ctx, &res, ethCall, msg, fmtBlockNum(blockNum))
 	if err != nil {
 		return false, err
 	}
@@ -121,18 +183,44 @@ func CallEIP165(c Client, address types.Address, interfaceId []byte, blockNum ui
 	return asBytes[len(asBytes)-1] == 0x1, nil
 }
 
-func BlockByNumber(c Client, blockNum uint64) (types.RawBlock, error) {
+func BlockByNumber(ctx context.Context, c Client, blockNum uint64) (types.RawBlock, error) {
 	var blockOrigin types.RawBlock
-	err := c.RPCCall(&blockOrigin, getBlockByNumber, fmtBlockNum(blockNum), false)
+	err := c.RPCCall(ctx, &blockOrigin, getBlockByNumber, fmtBlockNum(blockNum), false)
 
 	return blockOrigin, err
 }
 
-func CurrentBlock(c Client) (uint64, error) {
+// BlocksByNumber fetches the blocks from start to end inclusive in a single
+// batch call.
+func BlocksByNumber(ctx context.Context, c Client, start, end uint64) ([]types.RawBlock, error) {
+	if start > end {
+		return nil, nil
+	}
+	blocks := make([]types.RawBlock, end-start+1)
+	batch := make([]BatchElem, len(blocks))
+	for i := range batch {
+		batch[i] = BatchElem{
+			Method: getBlockByNumber,
+			Args:   []interface{}{fmtBlockNum(start + uint64(i)), false},
+			Result: &blocks[i],
+		}
+	}
+	if err := c.BatchRPCCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return nil, elem.Error
+		}
+	}
+	return blocks, nil
+}
+
+func CurrentBlock(ctx context.Context, c Client) (uint64, error) {
 	log.Debug("Fetching current block number")
 
 	var currentBlockResult CurrentBlockResult
-	if err := c.ExecuteGraphQLQuery(&currentBlockResult, CurrentBlockQuery()); err != nil {
+	if err := c.ExecuteGraphQLQuery(ctx, &currentBlockResult, CurrentBlockQuery()); err != nil {
 		return 0, err
 	}
 
@@ -140,15 +228,35 @@ func CurrentBlock(c Client) (uint64, error) {
 	return currentBlockResult.Block.Number.ToUint64(), nil
 }
 
-func TransactionWithReceipt(c Client, transactionHash types.Hash) (Transaction, error) {
+func TransactionWithReceipt(ctx context.Context, c Client, transactionHash types.Hash) (Transaction, error) {
 	var txResult TransactionResult
-	if err := c.ExecuteGraphQLQuery(&txResult, TransactionDetailQuery(transactionHash)); err != nil {
+	if err := c.ExecuteGraphQLQuery(ctx, &txResult, TransactionDetailQuery(transactionHash)); err != nil {
 		return Transaction{}, err
 	}
 	return txResult.Transaction, nil
 }
 
-func CallBalanceOfERC20(c Client, contract types.Address, holder types.Address, blockNum uint64) (types.HexData, error) {
+// BlockTransactionsByNumber fetches the block with all of its transactions, their
+// receipts and logs.
+func BlockTransactionsByNumber(ctx context.Context, c Client, blockNumber uint64) (BlockTransactions, error) {
+	var blockResult BlockTransactionsResult
+	if err := c.ExecuteGraphQLQuery(ctx, &blockResult, BlockTransactionsQuery(blockNumber)); err != nil {
+		return BlockTransactions{}, err
+	}
+	return blockResult.Block, nil
+}
+
+// BlockTransactionsInRange fetches the blocks from start to end inclusive, with
+// all of their transactions, receipts and logs.
+func BlockTransactionsInRange(ctx context.Context, c Client, start, end uint64) ([]BlockTransactions, error) {
+	var blocksResult BlockRangeTransactionsResult
+	if err := c.ExecuteGraphQLQuery(ctx, &blocksResult, BlockRangeTransactionsQuery(start, end)); err != nil {
+		return nil, err
+	}
+	return blocksResult.Blocks, nil
+}
+
+func CallBalanceOfERC20(ctx context.Context, c Client, contract types.Address, holder types.Address, blockNum uint64) (types.HexData, error) {
 	// 70a08231 is the 4byte function sig for `balanceOf(address)`
 	// "000000000000000000000000" + string(holder) is the token holders address, padded to 32 bytes
 
@@ -159,15 +267,143 @@ func CallBalanceOfERC20(c Client, contract types.Address, holder types.Address,
 	}
 
 	var res types.HexData
-	err := c.RPCCall(&res, ethCall, msg, blockAsHex)
+	err := c.RPCCall(ctx, &res, ethCall, msg, blockAsHex)
 	return res, err
 }
 
-func StorageRoot(c Client, account types.Address, blockNum uint64) (types.Hash, error) {
+// CallBalancesOfERC20 fetches the balances of the token holders at the block in
+// a single batch call, returning them in the same order as the holders.
+func CallBalancesOfERC20(ctx context.Context, c Client, contract types.Address, holders []types.Address, blockNum uint64) ([]types.HexData, error) {
+	balances := make([]types.HexData, len(holders))
+	batch := make([]BatchElem, len(holders))
+	for i, holder := range holders {
+		msg := types.EIP165Call{
+			To:   contract,
+			Data: types.NewHexData("0x70a08231" + "000000000000000000000000" + string(holder)),
+		}
+		batch[i] = BatchElem{Method: ethCall, Args: []interface{}{msg, fmtBlockNum(blockNum)}, Result: &balances[i]}
+	}
+	if err := c.BatchRPCCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return nil, elem.Error
+		}
+	}
+	return balances, nil
+}
+
+// CallBalancesOfERC1155 fetches the balances of each pair of holder and token ID at
+// the block in a single batch call, returning them in the same order as the pairs.
+func CallBalancesOfERC1155(ctx context.Context, c Client, contract types.Address, holders []types.Address, tokenIds []*big.Int, blockNum uint64) ([]types.HexData, error) {
+	if len(holders) != len(tokenIds) {
+		return nil, errors.New("holders and token IDs differ in length")
+	}
+	balances := make([]types.HexData, len(holders))
+	batch := make([]BatchElem, len(holders))
+	for i, holder := range holders {
+		// 00fdd58e is the 4byte function sig for `balanceOf(address,uint256)`
+		msg := types.EIP165Call{
+			To:   contract,
+			Data: types.NewHexData("0x00fdd58e" + "000000000000000000000000" + string(holder) + fmt.Sprintf("%064x", tokenIds[i])),
+		}
+		batch[i] = BatchElem{Method: ethCall, Args: []interface{}{msg, fmtBlockNum(blockNum)}, Result: &balances[i]}
+	}
+	if err := c.BatchRPCCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return nil, elem.Error
+		}
+	}
+	return balances, nil
+}
+
+// CallTotalsOfERC4626 fetches the total assets held by each vault, and the total
+// supply of its shares, at the block in a single batch call, returning them in the
+// same order as the vaults.
+func CallTotalsOfERC4626(ctx context.Context, c Client, vaults []types.Address, blockNum uint64) ([]types.HexData, []types.HexData, error) {
+	totalAssets := make([]types.HexData, len(vaults))
+	totalSupplies := make([]types.HexData, len(vaults))
+	batch := make([]BatchElem, 0, 2*len(vaults))
+	for i, vault := range vaults {
+		// 01e1d114 is the 4byte function sig for `totalAssets()`
+		assetsMsg := types.EIP165Call{To: vault, Data: types.NewHexData("0x01e1d114")}
+		// 18160ddd is the 4byte function sig for `totalSupply()`
+		supplyMsg := types.EIP165Call{To: vault, Data: types.NewHexData("0x18160ddd")}
+		batch = append(batch,
+			BatchElem{Method: ethCall, Args: []interface{}{assetsMsg, fmtBlockNum(blockNum)}, Result: &totalAssets[i]},
+			BatchElem{Method: ethCall, Args: []interface{}{supplyMsg, fmtBlockNum(blockNum)}, Result: &totalSupplies[i]},
+		)
+	}
+	if err := c.BatchRPCCall(ctx, batch); err != nil {
+		return nil, nil, err
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return nil, nil, elem.Error
+		}
+	}
+	return totalAssets, totalSupplies, nil
+}
+
+// GetBalances fetches the ether balances of the accounts at the block in a single
+// batch call, returning them in the same order as the accounts.
+func GetBalances(ctx context.Context, c Client, accounts []types.Address, blockNum uint64) ([]*big.Int, error) {
+	results := make([]types.HexBigNumber, len(accounts))
+	batch := make([]BatchElem, len(accounts))
+	for i, account := range accounts {
+		batch[i] = BatchElem{
+			Method: getBalance,
+			Args:   []interface{}{account.String(), fmtBlockNum(blockNum)},
+			Result: &results[i],
+		}
+	}
+	if err := c.BatchRPCCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	balances := make([]*big.Int, len(accounts))
+	for i, elem := range batch {
+		if elem.Error != nil {
+			return nil, elem.Error
+		}
+		balances[i] = results[i].ToBigInt()
+	}
+	return balances, nil
+}
+
+func StorageRoot(ctx context.Context, c Client, account types.Address, blockNum uint64) (types.Hash, error) {
 	var res types.Hash
-	err := c.RPCCall(&res, ethStorageRoot, account.String(), fmt.Sprintf("0x%x", blockNum))
+	err := c.RPCCall(ctx, &res, ethStorageRoot, account.String(), fmt.Sprintf("0x%x", blockNum))
 	if err != nil && err.Error() == "can't find state object" {
 		return types.NewHash(""), nil
 	}
 	return res, err
 }
+
+// StorageRoots fetches the storage roots of the accounts at the block in a
+// single batch call, returning them in the same order as the accounts.
+func StorageRoots(ctx context.Context, c Client, accounts []types.Address, blockNum uint64) ([]types.Hash, error) {
+	roots := make([]types.Hash, len(accounts))
+	batch := make([]BatchElem, len(accounts))
+	for i, account := range accounts {
+		batch[i] = BatchElem{
+			Method: ethStorageRoot,
+			Args:   []interface{}{account.String(), fmtBlockNum(blockNum)},
+			Result: &roots[i],
+		}
+	}
+	if err := c.BatchRPCCall(ctx, batch); err != nil {
+		return nil, err
+	}
+	for i, elem := range batch {
+		if elem.Error != nil && elem.Error.Error() == "can't find state object" {
+			roots[i] = types.NewHash("")
+		} else if elem.Error != nil {
+			return nil, elem.Error
+		}
+	}
+	return roots, nil
+}
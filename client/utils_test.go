@@ -1,6 +1,10 @@
 package client
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,7 +18,7 @@ func TestConsensus_BadResponse(t *testing.T) {
 	}
 	stubClient := NewStubQuorumClient(nil, mockRPC)
 
-	consensus, err := Consensus(stubClient)
+	consensus, err := Consensus(context.Background(), stubClient)
 	assert.EqualError(t, err, "not found")
 	assert.Equal(t, "", consensus)
 }
@@ -30,7 +34,7 @@ func TestConsensus_IstanbulExists(t *testing.T) {
 	}
 	stubClient := NewStubQuorumClient(nil, mockRPC)
 
-	consensus, err := Consensus(stubClient)
+	consensus, err := Consensus(context.Background(), stubClient)
 	assert.Nil(t, err, "unexpected error")
 	assert.Equal(t, "istanbul", consensus)
 }
@@ -48,7 +52,7 @@ func TestConsensus_RaftExists(t *testing.T) {
 	}
 	stubClient := NewStubQuorumClient(nil, mockRPC)
 
-	consensus, err := Consensus(stubClient)
+	consensus, err := Consensus(context.Background(), stubClient)
 	assert.Nil(t, err, "unexpected error")
 	assert.Equal(t, "raft", consensus)
 }
@@ -57,7 +61,7 @@ func TestTraceTransaction_WithError(t *testing.T) {
 	mockRPC := map[string]interface{}{}
 	stubClient := NewStubQuorumClient(nil, mockRPC)
 
-	trace, err := TraceTransaction(stubClient, types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000"))
+	trace, err := TraceTransaction(context.Background(), stubClient, types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000"))
 	assert.EqualError(t, err, "not found")
 	assert.Equal(t, trace, types.RawOuterCall{})
 }
@@ -70,16 +74,30 @@ func TestTraceTransaction(t *testing.T) {
 	}
 	stubClient := NewStubQuorumClient(nil, mockRPC)
 
-	trace, err := TraceTransaction(stubClient, types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000"))
+	trace, err := TraceTransaction(context.Background(), stubClient, types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000"))
 	assert.Nil(t, err)
 	assert.Len(t, trace.Calls, 1)
 }
 
+func TestTraceBlock_WithTransactionError(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"debug_traceBlockByNumber0x5<*client.TraceConfig Value>": []types.RawTransactionTrace{
+			{Result: types.RawOuterCall{Calls: []types.RawInnerCall{{}}}},
+			{Error: "execution timeout"},
+		},
+	}
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	traces, err := TraceBlock(context.Background(), stubClient, 5)
+	assert.EqualError(t, err, "trace transaction 1 of block 5: execution timeout")
+	assert.Nil(t, traces)
+}
+
 func TestDumpAddress_WithError(t *testing.T) {
 	mockRPC := map[string]interface{}{}
 	stubClient := NewStubQuorumClient(nil, mockRPC)
 
-	dump, err := DumpAddress(stubClient, types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"), 1)
+	dump, err := DumpAddress(context.Background(), stubClient, types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"), 1)
 	assert.EqualError(t, err, "not found")
 	assert.Nil(t, dump)
 }
@@ -93,7 +111,7 @@ func TestDumpAddress(t *testing.T) {
 	}
 	stubClient := NewStubQuorumClient(nil, mockRPC)
 
-	dump, err := DumpAddress(stubClient, types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"), 1)
+	dump, err := DumpAddress(context.Background(), stubClient, types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"), 1)
 	assert.Nil(t, err)
 	assert.EqualValues(t, &types.AccountState{
 		Root:    types.NewHash("0xefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36"),
@@ -110,7 +128,7 @@ func TestGetCode(t *testing.T) {
 	blockNum := uint64(5)
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 
-	code, err := GetCode(stubClient, address, blockNum)
+	code, err := GetCode(context.Background(), stubClient, address, blockNum)
 	assert.Nil(t, err)
 	assert.Equal(t, "0xefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36", code.String())
 }
@@ -121,7 +139,7 @@ func TestGetCode_WithError(t *testing.T) {
 	blockNum := uint64(5)
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 
-	code, err := GetCode(stubClient, address, blockNum)
+	code, err := GetCode(context.Background(), stubClient, address, blockNum)
 	assert.EqualError(t, err, "not found")
 	assert.Equal(t, types.HexData(""), code)
 }
@@ -135,7 +153,7 @@ func TestEIP165(t *testing.T) {
 
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 
-	exists, err := CallEIP165(stubClient, address, []byte("1234"), 2)
+	exists, err := CallEIP165(context.Background(), stubClient, address, []byte("1234"), 2)
 	assert.Nil(t, err)
 	assert.True(t, exists)
 }
@@ -145,7 +163,7 @@ func TestEIP165_WithWrongInterfaceLengthError(t *testing.T) {
 
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 
-	exists, err := CallEIP165(stubClient, address, []byte("1234567890"), 0)
+	exists, err := CallEIP165(context.Background(), stubClient, address, []byte("1234567890"), 0)
 	assert.EqualError(t, err, "interfaceId wrong size")
 	assert.False(t, exists)
 }
@@ -155,7 +173,7 @@ func TestEIP165_WithClientError(t *testing.T) {
 
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 
-	exists, err := CallEIP165(stubClient, address, []byte("1234"), 0)
+	exists, err := CallEIP165(context.Background(), stubClient, address, []byte("1234"), 0)
 	assert.EqualError(t, err, "not found")
 	assert.False(t, exists)
 }
@@ -169,7 +187,7 @@ func TestEIP165_WithWrongSizeResult(t *testing.T) {
 
 	address := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 
-	exists, err := CallEIP165(stubClient, address, []byte("1234"), 1)
+	exists, err := CallEIP165(context.Background(), stubClient, address, []byte("1234"), 1)
 	assert.Nil(t, err)
 	assert.False(t, exists)
 }
@@ -180,7 +198,7 @@ func TestCurrentBlock(t *testing.T) {
 	}
 	stubClient := NewStubQuorumClient(mockGraphQL, nil)
 
-	currentBlockNumber, err := CurrentBlock(stubClient)
+	currentBlockNumber, err := CurrentBlock(context.Background(), stubClient)
 
 	assert.Nil(t, err)
 	assert.EqualValues(t, 16, currentBlockNumber)
@@ -189,12 +207,18 @@ func TestCurrentBlock(t *testing.T) {
 func TestCurrentBlock_WithError(t *testing.T) {
 	stubClient := NewStubQuorumClient(nil, nil)
 
-	currentBlockNumber, err := CurrentBlock(stubClient)
+	currentBlockNumber, err := CurrentBlock(context.Background(), stubClient)
 
 	assert.EqualError(t, err, "not found")
 	assert.EqualValues(t, 0, currentBlockNumber)
 }
 
+func hexBigNumber(hexString string) types.HexBigNumber {
+	var num types.HexBigNumber
+	_ = json.Unmarshal([]byte(`"`+hexString+`"`), &num)
+	return num
+}
+
 func TestTransactionWithReceipt(t *testing.T) {
 	testTransactionHash := types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8")
 	fullGraphQLTransaction := map[string]interface{}{
@@ -205,7 +229,7 @@ func TestTransactionWithReceipt(t *testing.T) {
 		"nonce":             "0x1",
 		"from":              map[string]interface{}{"address": "0xed9d02e382b34818e88b88a309c7fe71e65f419d"},
 		"to":                nil,
-		"value":             "0x0",
+		"value":             "0x3635c9adc5dea00000",
 		"gasPrice":          "0x0",
 		"gas":               "0x47b760",
 		"gasUsed":           "0x280a7",
@@ -250,7 +274,7 @@ func TestTransactionWithReceipt(t *testing.T) {
 	mockGraphQL := map[string]map[string]interface{}{fullGraphQLQuery: {"transaction": fullGraphQLTransaction}}
 	stubClient := NewStubQuorumClient(mockGraphQL, nil)
 
-	result, err := TransactionWithReceipt(stubClient, testTransactionHash)
+	result, err := TransactionWithReceipt(context.Background(), stubClient, testTransactionHash)
 
 	expectedResult := Transaction{
 		Hash:              "e625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8",
@@ -259,8 +283,8 @@ func TestTransactionWithReceipt(t *testing.T) {
 		Nonce:             types.HexNumber(1),
 		From:              Address{Address: "ed9d02e382b34818e88b88a309c7fe71e65f419d"},
 		To:                Address{},
-		Value:             types.HexNumber(0),
-		GasPrice:          types.HexNumber(0),
+		Value:             hexBigNumber("0x3635c9adc5dea00000"),
+		GasPrice:          hexBigNumber("0x0"),
 		Gas:               types.HexNumber(4700000),
 		GasUsed:           types.HexNumber(164007),
 		CumulativeGasUsed: types.HexNumber(164007),
@@ -285,19 +309,72 @@ func TestTransactionWithReceipt(t *testing.T) {
 func TestTransactionWithReceipt_WithError(t *testing.T) {
 	stubClient := NewStubQuorumClient(nil, nil)
 
-	result, err := TransactionWithReceipt(stubClient, types.NewHash(""))
+	result, err := TransactionWithReceipt(context.Background(), stubClient, types.NewHash(""))
 
 	assert.EqualError(t, err, "not found")
 	assert.Equal(t, Transaction{}, result)
 }
 
+func TestBlockTransactionsInRange(t *testing.T) {
+	mockGraphQL := map[string]map[string]interface{}{
+		BlockRangeTransactionsQuery(1, 2): {
+			"blocks": []interface{}{
+				map[string]interface{}{"number": "0x1", "hash": "0x01", "transactions": []interface{}{}},
+				map[string]interface{}{"number": "0x2", "hash": "0x02", "transactions": []interface{}{
+					map[string]interface{}{"hash": "0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8", "index": 0},
+				}},
+			},
+		},
+	}
+	stubClient := NewStubQuorumClient(mockGraphQL, nil)
+
+	blocks, err := BlockTransactionsInRange(context.Background(), stubClient, 1, 2)
+
+	assert.Nil(t, err)
+	assert.Len(t, blocks, 2)
+	assert.EqualValues(t, 1, blocks[0].Number)
+	assert.Empty(t, blocks[0].Transactions)
+	assert.EqualValues(t, 2, blocks[1].Number)
+	assert.Equal(t, types.NewHash("0x02"), blocks[1].Hash)
+	assert.Len(t, blocks[1].Transactions, 1)
+	assert.Equal(t, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"), blocks[1].Transactions[0].Hash)
+}
+
+func TestBlockTransactionsByNumber(t *testing.T) {
+	mockGraphQL := map[string]map[string]interface{}{
+		BlockTransactionsQuery(2): {
+			"block": map[string]interface{}{"number": "0x2", "hash": "0x02", "transactions": []interface{}{
+				map[string]interface{}{"hash": "0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8", "index": 0},
+			}},
+		},
+	}
+	stubClient := NewStubQuorumClient(mockGraphQL, nil)
+
+	block, err := BlockTransactionsByNumber(context.Background(), stubClient, 2)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, block.Number)
+	assert.Equal(t, types.NewHash("0x02"), block.Hash)
+	assert.Len(t, block.Transactions, 1)
+	assert.Equal(t, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"), block.Transactions[0].Hash)
+}
+
+func TestBlockTransactionsByNumber_WithError(t *testing.T) {
+	stubClient := NewStubQuorumClient(nil, nil)
+
+	result, err := BlockTransactionsByNumber(context.Background(), stubClient, 1)
+
+	assert.EqualError(t, err, "not found")
+	assert.Equal(t, BlockTransactions{}, result)
+}
+
 func TestCallBalanceOfERC20_WithError(t *testing.T) {
 	stubClient := NewStubQuorumClient(nil, nil)
 
 	tokenContract := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 	holder := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
 
-	contractCallResult, err := CallBalanceOfERC20(stubClient, tokenContract, holder, 1)
+	contractCallResult, err := CallBalanceOfERC20(context.Background(), stubClient, tokenContract, holder, 1)
 	assert.EqualError(t, err, "not found")
 	assert.Equal(t, types.HexData(""), contractCallResult)
 }
@@ -313,7 +390,7 @@ func TestCallBalanceOfERC20(t *testing.T) {
 	tokenContract := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
 	holder := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
 
-	contractCallResult, err := CallBalanceOfERC20(stubClient, tokenContract, holder, 1)
+	contractCallResult, err := CallBalanceOfERC20(context.Background(), stubClient, tokenContract, holder, 1)
 	assert.Nil(t, err)
 	assert.Equal(t, types.HexData("12345"), contractCallResult)
 }
@@ -321,7 +398,7 @@ func TestCallBalanceOfERC20(t *testing.T) {
 func TestStorageRoot_WithError(t *testing.T) {
 	stubClient := NewStubQuorumClient(nil, nil)
 
-	result, err := StorageRoot(stubClient, types.NewAddress(""), 1)
+	result, err := StorageRoot(context.Background(), stubClient, types.NewAddress(""), 1)
 	assert.EqualError(t, err, "not found")
 	assert.EqualValues(t, "", result)
 }
@@ -333,8 +410,125 @@ func TestStorageRoot(t *testing.T) {
 
 	stubClient := NewStubQuorumClient(nil, mockRPC)
 
-	result, err := StorageRoot(stubClient, types.NewAddress(""), 1)
+	result, err := StorageRoot(context.Background(), stubClient, types.NewAddress(""), 1)
 
 	assert.Nil(t, err)
 	assert.EqualValues(t, "0000000000000000000000000000000000000000000000000000000000000001", result)
 }
+
+func TestBlocksByNumber(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_getBlockByNumber0x1<bool Value>": types.RawBlock{Number: 1},
+		"eth_getBlockByNumber0x2<bool Value>": types.RawBlock{Number: 2},
+	}
+
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	blocks, err := BlocksByNumber(context.Background(), stubClient, 1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.RawBlock{{Number: 1}, {Number: 2}}, blocks)
+
+	_, err = BlocksByNumber(context.Background(), stubClient, 1, 3)
+	assert.EqualError(t, err, "not found")
+}
+
+func TestCallBalancesOfERC20(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x12345"),
+	}
+
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	tokenContract := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	holders := []types.Address{
+		types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"),
+		types.NewAddress("0x2932c48b2bf8102ba33b4a6b545c32236e342f34"),
+	}
+
+	balances, err := CallBalancesOfERC20(context.Background(), stubClient, tokenContract, holders, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.HexData{"12345", "12345"}, balances)
+
+	_, err = CallBalancesOfERC20(context.Background(), stubClient, tokenContract, holders, 2)
+	assert.EqualError(t, err, "not found")
+}
+
+func TestCallBalancesOfERC1155(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x12345"),
+	}
+
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	tokenContract := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	holders := []types.Address{
+		types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"),
+		types.NewAddress("0x2932c48b2bf8102ba33b4a6b545c32236e342f34"),
+	}
+	tokenIds := []*big.Int{big.NewInt(1), big.NewInt(2)}
+
+	balances, err := CallBalancesOfERC1155(context.Background(), stubClient, tokenContract, holders, tokenIds, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.HexData{"12345", "12345"}, balances)
+
+	_, err = CallBalancesOfERC1155(context.Background(), stubClient, tokenContract, holders, tokenIds[:1], 1)
+	assert.EqualError(t, err, "holders and token IDs differ in length")
+
+	_, err = CallBalancesOfERC1155(context.Background(), stubClient, tokenContract, holders, tokenIds, 2)
+	assert.EqualError(t, err, "not found")
+}
+
+func TestCallTotalsOfERC4626(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x12345"),
+	}
+
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	vaults := []types.Address{
+		types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"),
+		types.NewAddress("0x2932c48b2bf8102ba33b4a6b545c32236e342f34"),
+	}
+
+	totalAssets, totalSupplies, err := CallTotalsOfERC4626(context.Background(), stubClient, vaults, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.HexData{"12345", "12345"}, totalAssets)
+	assert.Equal(t, []types.HexData{"12345", "12345"}, totalSupplies)
+
+	_, _, err = CallTotalsOfERC4626(context.Background(), stubClient, vaults, 2)
+	assert.EqualError(t, err, "not found")
+}
+
+func TestGetBalances(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_getBalance0x00000000000000000000000000000000000000010x1": hexBigNumber("0x3635c9adc5dea00000"),
+		"eth_getBalance0x00000000000000000000000000000000000000020x1": hexBigNumber("0x0"),
+	}
+
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	balances, err := GetBalances(context.Background(), stubClient, []types.Address{types.NewAddress("1"), types.NewAddress("2")}, 1)
+	assert.Nil(t, err)
+	assert.Len(t, balances, 2)
+	assert.Equal(t, "1000000000000000000000", balances[0].String())
+	assert.Equal(t, "0", balances[1].String())
+
+	_, err = GetBalances(context.Background(), stubClient, []types.Address{types.NewAddress("3")}, 1)
+	assert.EqualError(t, err, "not found")
+}
+
+func TestStorageRoots(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_storageRoot0x00000000000000000000000000000000000000010x1": types.NewHash("1"),
+		"eth_storageRoot0x00000000000000000000000000000000000000020x1": errors.New("can't find state object"),
+	}
+
+	stubClient := NewStubQuorumClient(nil, mockRPC)
+
+	roots, err := StorageRoots(context.Background(), stubClient, []types.Address{types.NewAddress("1"), types.NewAddress("2")}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{types.NewHash("1"), types.NewHash("")}, roots)
+
+	_, err = StorageRoots(context.Background(), stubClient, []types.Address{types.NewAddress("3")}, 1)
+	assert.EqualError(t, err, "not found")
+}
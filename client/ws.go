@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -45,6 +46,7 @@ func (err *msgError) Error() string {
 
 type webSocketClient struct {
 	rawUrl                      string
+	transport                   *Transport
 	conn                        *websocket.Conn
 	connMux                     sync.Mutex
 	connWriteMux                sync.Mutex
@@ -56,9 +58,10 @@ type webSocketClient struct {
 	rpcMux                      sync.RWMutex
 }
 
-func newWebSocketClient(rawUrl string) (*webSocketClient, error) {
+func newWebSocketClient(rawUrl string, transport *Transport) (*webSocketClient, error) {
 	client := &webSocketClient{
 		rawUrl:         rawUrl,
+		transport:      transport,
 		idCounter:      0,
 		rpcPendingResp: make(map[string]chan<- *message),
 	}
@@ -71,8 +74,13 @@ func newWebSocketClient(rawUrl string) (*webSocketClient, error) {
 func (c *webSocketClient) dial(rawUrl string) error {
 	c.connMux.Lock()
 	defer c.connMux.Unlock()
-	var err error
-	c.conn, _, err = websocket.DefaultDialer.Dial(rawUrl, nil)
+	// the headers are fetched on each dial, so a reconnection uses the latest token
+	header, err := c.transport.header()
+	if err != nil {
+		log.Error("Dial WebSocket endpoint error", "error", err)
+		return err
+	}
+	c.conn, _, err = c.transport.dialer().Dial(rawUrl, header)
 	if err != nil {
 		log.Error("Dial WebSocket endpoint error", "error", err)
 		return err
@@ -116,27 +124,42 @@ func (c *webSocketClient) subscribeChainHead(ch chan<- types.RawHeader) error {
 	return nil
 }
 
-// send rpc call
-func (c *webSocketClient) sendRPCMsg(ch chan<- *message, method string, args ...interface{}) error {
+// isConnected checks whether the WebSocket connection is currently up
+func (c *webSocketClient) isConnected() bool {
 	c.connMux.Lock()
 	defer c.connMux.Unlock()
-	if c.conn == nil {
-		return errors.New("no WebSocket connection")
-	}
+	return c.conn != nil
+}
 
+func newRPCMessage(id string, method string, args []interface{}) (*message, error) {
 	msg := &message{
 		Version: "2.0",
-		ID:      c.nextID(),
+		ID:      id,
 		Method:  method,
 	}
 	// marshal args to params
 	if args != nil {
 		params, err := json.Marshal(args)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		msg.Params = params
 	}
+	return msg, nil
+}
+
+// send rpc call
+func (c *webSocketClient) sendRPCMsg(ch chan<- *message, method string, args ...interface{}) error {
+	c.connMux.Lock()
+	defer c.connMux.Unlock()
+	if c.conn == nil {
+		return errors.New("no WebSocket connection")
+	}
+
+	msg, err := newRPCMessage(c.nextID(), method, args)
+	if err != nil {
+		return err
+	}
 
 	c.setPendingRPC(msg.ID, ch)
 	log.Debug("Send JSON RPC message", "msg.Method", msg.Method, "args", args, "msg.ID", msg.ID)
@@ -151,6 +174,38 @@ func (c *webSocketClient) sendRPCMsg(ch chan<- *message, method string, args ...
 	return nil
 }
 
+// send a batch of rpc calls in a single message, with the response of each
+// call sent to the channel at the same index
+func (c *webSocketClient) sendBatchRPCMsg(chs []chan *message, batch []BatchElem) error {
+	c.connMux.Lock()
+	defer c.connMux.Unlock()
+	if c.conn == nil {
+		return errors.New("no WebSocket connection")
+	}
+
+	msgs := make([]*message, len(batch))
+	for i, elem := range batch {
+		msg, err := newRPCMessage(c.nextID(), elem.Method, elem.Args)
+		if err != nil {
+			return err
+		}
+		msgs[i] = msg
+	}
+	for i, msg := range msgs {
+		c.setPendingRPC(msg.ID, chs[i])
+	}
+	log.Debug("Send JSON RPC batch message", "calls", len(msgs))
+
+	c.connWriteMux.Lock()
+	defer c.connWriteMux.Unlock()
+
+	if err := c.conn.WriteJSON(msgs); err != nil {
+		log.Error("Write JSON RPC batch message error", "error", err)
+		return err
+	}
+	return nil
+}
+
 // listen and handle message
 func (c *webSocketClient) listen(shutdownChan <-chan struct{}) {
 	for {
@@ -192,6 +247,20 @@ func (c *webSocketClient) listen(shutdownChan <-chan struct{}) {
 			continue
 		}
 		log.Debug("WebSocket message received", "msg", string(msg))
+		// responses to a batch of rpc calls are received together
+		if trimmed := bytes.TrimSpace(msg); len(trimmed) > 0 && trimmed[0] == '[' {
+			var batchMsgs []message
+			if err = json.Unmarshal(trimmed, &batchMsgs); err != nil {
+				log.Error("Decode batch message error", "error", err)
+				continue
+			}
+			for i := range batchMsgs {
+				if ch := c.getPendingRPC(batchMsgs[i].ID); ch != nil {
+					ch <- &batchMsgs[i]
+				}
+			}
+			continue
+		}
 		var receivedMsg message
 		if err = json.Unmarshal(msg, &receivedMsg); err != nil {
 			log.Error("Decode message error", "error", err)
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"quorumengineering/quorum-report/types"
+)
+
+// APIKeyHeader is the header clients send their API key in
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator authenticates clients by a static API key
+type APIKeyAuthenticator struct {
+	keys []*types.APIKeyConfig
+}
+
+func NewAPIKeyAuthenticator(keys []*types.APIKeyConfig) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	for _, config := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(config.Key)) == 1 {
+			return &Identity{Name: config.Name, Roles: config.Roles, Addresses: config.Addresses}, nil
+		}
+	}
+	return nil, ErrInvalidCredentials
+}
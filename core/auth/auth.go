@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials provided")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Identity is an authenticated client of the RPC server
+type Identity struct {
+	Name  string
+	Roles []string
+	// Addresses restricts the client to the given registered addresses, if set
+	Addresses []types.Address
+}
+
+// HasRole checks whether the client has been given the role. Admins can also read.
+func (id *Identity) HasRole(role string) bool {
+	for _, r := range id.Roles {
+		if r == role || (r == types.AdminRole && role == types.ReadRole) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRestricted checks whether the client can only see some registered addresses
+func (id *Identity) IsRestricted() bool {
+	return len(id.Addresses) > 0
+}
+
+// CanView checks whether the client can see the data of the address
+func (id *Identity) CanView(address types.Address) bool {
+	if !id.IsRestricted() {
+		return true
+	}
+	for _, allowed := range id.Addresses {
+		if allowed == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator identifies the client making a request. It returns
+// ErrNoCredentials if the request has no credentials of the kind it checks, so
+// that another authenticator can be tried.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// NewAuthenticators creates the authenticators for the configured credentials.
+// No authenticators are returned if authentication is not configured.
+func NewAuthenticators(config *types.AuthConfig) ([]Authenticator, error) {
+	if config == nil {
+		return nil, nil
+	}
+	var authenticators []Authenticator
+	if len(config.APIKeys) > 0 {
+		authenticators = append(authenticators, NewAPIKeyAuthenticator(config.APIKeys))
+	}
+	if config.JWT != nil {
+		jwtAuthenticator, err := NewJWTAuthenticator(config.JWT)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+	return authenticators, nil
+}
+
+type identityKey struct{}
+
+// NewContext returns a copy of the context holding the identity
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity held by the context, or nil if the request
+// was not authenticated
+func FromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(identityKey{}).(*Identity)
+	return id
+}
+
+// Middleware rejects requests that are not authenticated by any of the
+// authenticators, passing the identity of the client on in the request context.
+func Middleware(authenticators []Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// CORS preflight requests never carry credentials
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		err := ErrNoCredentials
+		for _, authenticator := range authenticators {
+			var id *Identity
+			id, err = authenticator.Authenticate(r)
+			if err == nil {
+				next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+				return
+			}
+			if err != ErrNoCredentials {
+				break
+			}
+		}
+		log.Debug("Rejected unauthenticated request", "remote", r.RemoteAddr, "err", err)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	})
+}
+
+// RequireRole rejects authenticated requests from clients without the role. It
+// is used behind the middleware, for endpoints outside of the JSON-RPC APIs.
+func RequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := FromContext(r.Context()); id != nil && !id.HasRole(role) {
+			http.Error(w, fmt.Sprintf("permission denied: %s role required", role), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+var address = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+
+func TestIdentity(t *testing.T) {
+	admin := &Identity{Roles: []string{types.AdminRole}}
+	assert.True(t, admin.HasRole(types.AdminRole))
+	assert.True(t, admin.HasRole(types.ReadRole))
+	assert.True(t, admin.CanView(address))
+
+	reader := &Identity{Roles: []string{types.ReadRole}, Addresses: []types.Address{address}}
+	assert.False(t, reader.HasRole(types.AdminRole))
+	assert.True(t, reader.HasRole(types.ReadRole))
+	assert.True(t, reader.CanView(address))
+	assert.False(t, reader.CanView(types.NewAddress("0x01")))
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	authenticator := NewAPIKeyAuthenticator([]*types.APIKeyConfig{
+		{Name: "reader", Key: "read-key", Roles: []string{types.ReadRole}, Addresses: []types.Address{address}},
+		{Name: "admin", Key: "admin-key", Roles: []string{types.AdminRole}},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	_, err := authenticator.Authenticate(req)
+	assert.Equal(t, ErrNoCredentials, err)
+
+	req.Header.Set(APIKeyHeader, "wrong-key")
+	_, err = authenticator.Authenticate(req)
+	assert.Equal(t, ErrInvalidCredentials, err)
+
+	req.Header.Set(APIKeyHeader, "read-key")
+	id, err := authenticator.Authenticate(req)
+	assert.Nil(t, err)
+	assert.Equal(t, &Identity{Name: "reader", Roles: []string{types.ReadRole}, Addresses: []types.Address{address}}, id)
+}
+
+// newJWKS writes a JWKS file holding the public part of a new key, returning
+// the path of the file and the key
+func newJWKS(t *testing.T, dir string, kid string) (string, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": kid,
+			"kty": "EC",
+			"use": "sig",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+		}},
+	}
+	data, err := json.Marshal(jwks)
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "jwks.json")
+	assert.Nil(t, ioutil.WriteFile(path, data, 0644))
+	return path, key
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	assert.Nil(t, err)
+	return signed
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jwks")
+	defer os.RemoveAll(dir)
+	path, key := newJWKS(t, dir, "key-1")
+
+	authenticator, err := NewJWTAuthenticator(&types.JWTConfig{
+		JWKSFile:       path,
+		Issuer:         "issuer",
+		RolesClaim:     "roles",
+		AddressesClaim: "addresses",
+	})
+	assert.Nil(t, err)
+
+	authenticate := func(token string) (*Identity, error) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return authenticator.Authenticate(req)
+	}
+	expiry := time.Now().Add(time.Hour).Unix()
+
+	_, err = authenticate("")
+	assert.Equal(t, ErrNoCredentials, err)
+
+	id, err := authenticate(sign(t, key, "key-1", jwt.MapClaims{
+		"sub": "client", "iss": "issuer", "exp": expiry,
+		"roles": []string{types.ReadRole}, "addresses": []string{address.String()},
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, &Identity{Name: "client", Roles: []string{types.ReadRole}, Addresses: []types.Address{address}}, id)
+
+	// roles can also be given as a space separated string
+	id, err = authenticate(sign(t, key, "key-1", jwt.MapClaims{"iss": "issuer", "exp": expiry, "roles": "read admin"}))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{types.ReadRole, types.AdminRole}, id.Roles)
+
+	for name, token := range map[string]string{
+		"expired":      sign(t, key, "key-1", jwt.MapClaims{"iss": "issuer", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"wrong issuer": sign(t, key, "key-1", jwt.MapClaims{"iss": "other", "exp": expiry}),
+		"unknown key":  sign(t, key, "key-2", jwt.MapClaims{"iss": "issuer", "exp": expiry}),
+		"malformed":    "not-a-token",
+	} {
+		_, err := authenticate(token)
+		assert.Equal(t, ErrInvalidCredentials, err, name)
+	}
+
+	// tokens signed by other keys are rejected
+	_, otherKey := newJWKS(t, dir, "key-1")
+	_, err = authenticate(sign(t, otherKey, "key-1", jwt.MapClaims{"iss": "issuer", "exp": expiry}))
+	assert.Equal(t, ErrInvalidCredentials, err)
+}
+
+func TestNewJWTAuthenticator_InvalidJWKS(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "jwks")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "jwks.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"keys":[{"kid":"1","kty":"oct","k":"c2VjcmV0"}]}`), 0644))
+
+	_, err := NewJWTAuthenticator(&types.JWTConfig{JWKSFile: path})
+	assert.EqualError(t, err, "loading jwks file "+path+`: key "1": unsupported key type: "oct"`)
+}
+
+func TestMiddleware(t *testing.T) {
+	authenticators := []Authenticator{NewAPIKeyAuthenticator([]*types.APIKeyConfig{{Name: "reader", Key: "read-key", Roles: []string{types.ReadRole}}})}
+	var identity *Identity
+	handler := Middleware(authenticators, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity = FromContext(r.Context())
+	}))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Nil(t, identity)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set(APIKeyHeader, "read-key")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "reader", identity.Name)
+}
+
+func TestRequireRole(t *testing.T) {
+	authenticators := []Authenticator{NewAPIKeyAuthenticator([]*types.APIKeyConfig{
+		{Name: "reader", Key: "read-key", Roles: []string{types.ReadRole}},
+		{Name: "admin", Key: "admin-key", Roles: []string{types.AdminRole}},
+	})}
+	handler := Middleware(authenticators, RequireRole(types.AdminRole, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	for key, expected := range map[string]int{"": http.StatusUnauthorized, "read-key": http.StatusForbidden, "admin-key": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		assert.Equal(t, expected, recorder.Code, key)
+	}
+}
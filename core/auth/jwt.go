@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// signingMethods are the algorithms tokens may be signed with, which all use
+// the public keys a JWKS holds
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// jsonWebKey is a public key from a JSON Web Key Set, as defined in RFC 7517
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// JWTAuthenticator authenticates clients by a bearer token, signed by one of the
+// keys in a local JWKS file. The roles and addresses of the client are read from
+// the configured claims.
+type JWTAuthenticator struct {
+	keys   map[string]crypto.PublicKey
+	config *types.JWTConfig
+	parser *jwt.Parser
+}
+
+func NewJWTAuthenticator(config *types.JWTConfig) (*JWTAuthenticator, error) {
+	keys, err := loadJWKS(config.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading jwks file %s: %v", config.JWKSFile, err)
+	}
+	log.Info("Loaded JWT signing keys", "file", config.JWKSFile, "keys", len(keys))
+	return &JWTAuthenticator{
+		keys:   keys,
+		config: config,
+		parser: jwt.NewParser(jwt.WithValidMethods(signingMethods)),
+	}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, ErrNoCredentials
+	}
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(strings.TrimPrefix(header, "Bearer "), claims, a.key); err != nil {
+		log.Debug("Invalid JWT", "err", err)
+		return nil, ErrInvalidCredentials
+	}
+	if a.config.Issuer != "" && !claims.VerifyIssuer(a.config.Issuer, true) {
+		return nil, ErrInvalidCredentials
+	}
+	if a.config.Audience != "" && !claims.VerifyAudience(a.config.Audience, true) {
+		return nil, ErrInvalidCredentials
+	}
+
+	id := &Identity{}
+	id.Name, _ = claims["sub"].(string)
+	id.Roles = stringsClaim(claims[a.config.RolesClaim])
+	for _, address := range stringsClaim(claims[a.config.AddressesClaim]) {
+		id.Addresses = append(id.Addresses, types.NewAddress(address))
+	}
+	return id, nil
+}
+
+// key finds the key the token was signed with, by its key ID if it has one
+func (a *JWTAuthenticator) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, nil
+		}
+	}
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key: %q", kid)
+}
+
+// stringsClaim reads a claim given either as a list, or as a space separated string
+func stringsClaim(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		var values []string
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func loadJWKS(path string) (map[string]crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys found")
+	}
+	return keys, nil
+}
+
+func (jwk *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %q", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %q", jwk.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
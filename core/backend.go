@@ -1,16 +1,21 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/core/filter"
+	"quorumengineering/quorum-report/core/health"
 	"quorumengineering/quorum-report/core/monitor"
 	"quorumengineering/quorum-report/core/rpc"
+	"quorumengineering/quorum-report/core/subscription"
+	"quorumengineering/quorum-report/core/webhook"
 	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/database/factory"
 	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/metrics"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -19,6 +24,7 @@ type Backend struct {
 	monitor      *monitor.MonitorService
 	filter       *filter.FilterService
 	rpc          *rpc.RPCService
+	webhook      *webhook.WebhookService
 	db           database.Database
 	quorumClient client.Client
 
@@ -26,7 +32,11 @@ type Backend struct {
 }
 
 func New(config types.ReportingConfig) (*Backend, error) {
-	quorumClient, err := client.NewQuorumClient(config.Connection.WSUrl, config.Connection.GraphQLUrl)
+	transport, err := client.NewTransport(config.Connection)
+	if err != nil {
+		return nil, err
+	}
+	quorumClient, err := client.NewClient(config.Connection, transport)
 	if err != nil {
 		log.Error("Failed to initialize Quorum Client", "err", err)
 		// auto reconnect
@@ -36,7 +46,7 @@ func New(config types.ReportingConfig) (*Backend, error) {
 		for i := 0; i < config.Connection.MaxReconnectTries && err != nil; i++ {
 			log.Error("Trying to reconnect", "wait-time", config.Connection.ReconnectInterval)
 			time.Sleep(time.Duration(config.Connection.ReconnectInterval) * time.Second)
-			quorumClient, err = client.NewQuorumClient(config.Connection.WSUrl, config.Connection.GraphQLUrl)
+			quorumClient, err = client.NewClient(config.Connection, transport)
 		}
 		// max retries reached but still erroring, abort
 		if err != nil {
@@ -44,7 +54,7 @@ func New(config types.ReportingConfig) (*Backend, error) {
 		}
 	}
 
-	consensus, err := client.Consensus(quorumClient)
+	consensus, err := client.Consensus(context.Background(), quorumClient)
 	if err != nil {
 		return nil, err
 	}
@@ -91,16 +101,33 @@ func New(config types.ReportingConfig) (*Backend, error) {
 		}
 	}
 
+	log.Info("Adding webhooks from configuration file to database")
+	for _, webhookConfig := range config.Webhooks {
+		id, err := webhook.Register(db, webhookConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook for %s: %v", webhookConfig.Address.Hex(), err)
+		}
+		log.Info("Registered webhook", "id", id, "address", webhookConfig.Address.Hex(), "kind", webhookConfig.Kind)
+	}
+
+	if err := metrics.RegisterIndexingCollector(db); err != nil {
+		return nil, err
+	}
+
 	monitorService, err := monitor.NewMonitorService(db, quorumClient, consensus, config)
 	if err != nil {
 		return nil, err
 	}
 
 	backendErrorChan := make(chan error)
+	subscriptionHub := subscription.NewHub()
+	filterService := filter.NewFilterService(db, quorumClient, subscriptionHub, config.Tuning)
+	healthChecker := health.NewChecker(db, quorumClient, monitorService, filterService, config.Tuning)
 	return &Backend{
 		monitor:          monitorService,
-		filter:           filter.NewFilterService(db, quorumClient),
-		rpc:              rpc.NewRPCService(db, config, backendErrorChan),
+		filter:           filterService,
+		rpc:              rpc.NewRPCService(db, monitorService.PendingBlocks(), subscriptionHub, healthChecker, config, backendErrorChan),
+		webhook:          webhook.NewWebhookService(db, config.Tuning),
 		db:               db,
 		quorumClient:     quorumClient,
 		backendErrorChan: backendErrorChan,
@@ -115,6 +142,7 @@ func (b *Backend) Start() error {
 	for _, f := range []func() error{
 		b.monitor.Start, // monitor service
 		b.filter.Start,  // filter service
+		b.webhook.Start, // webhook service
 		b.rpc.Start,     // RPC service
 	} {
 		if err := f(); err != nil {
@@ -127,6 +155,7 @@ func (b *Backend) Start() error {
 func (b *Backend) Stop() {
 	// stop services
 	b.rpc.Stop()
+	b.webhook.Stop()
 	b.filter.Stop()
 	b.monitor.Stop()
 	// stop db connection
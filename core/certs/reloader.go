@@ -0,0 +1,121 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// Reloader serves a certificate, and optionally the CAs client certificates are
+// verified against, reloading them when the files change. The files are checked
+// at most once every checkInterval, on new connections.
+type Reloader struct {
+	config        *types.TLSConfig
+	checkInterval time.Duration
+
+	mu          sync.Mutex
+	tlsConfig   *tls.Config
+	modTimes    []time.Time
+	lastChecked time.Time
+}
+
+func NewReloader(config *types.TLSConfig) (*Reloader, error) {
+	r := &Reloader{config: config, checkInterval: time.Second}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	log.Info("Loaded TLS certificate", "cert", config.CertFile, "mutual TLS", config.ClientCAFile != "")
+	return r, nil
+}
+
+// TLSConfig returns the config a server should be given, which always uses the
+// latest loaded certificate and client CAs
+func (r *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current(), nil
+		},
+	}
+}
+
+// current returns the config for a new connection, first reloading the files
+// if they have changed. If they cannot be loaded, the previous config is kept.
+func (r *Reloader) current() *tls.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastChecked) >= r.checkInterval {
+		r.lastChecked = time.Now()
+		if r.changed() {
+			if err := r.reload(); err != nil {
+				log.Error("Unable to reload TLS certificate, continuing with the previous certificate", "cert", r.config.CertFile, "err", err)
+			} else {
+				log.Info("Reloaded TLS certificate", "cert", r.config.CertFile)
+			}
+		}
+	}
+	return r.tlsConfig
+}
+
+func (r *Reloader) changed() bool {
+	modTimes := r.fileModTimes()
+	for i := range modTimes {
+		if !modTimes[i].Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload reads the certificate, key and client CA files, replacing the current config
+func (r *Reloader) reload() error {
+	modTimes := r.fileModTimes()
+
+	certificate, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading tls certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+	}
+	if r.config.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("loading tls client ca file: %v", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("loading tls client ca file: no certificates found")
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.tlsConfig = tlsConfig
+	r.modTimes = modTimes
+	return nil
+}
+
+// fileModTimes returns the modification times of the files, with a zero time
+// for any that cannot be read
+func (r *Reloader) fileModTimes() []time.Time {
+	var modTimes []time.Time
+	for _, file := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		var modTime time.Time
+		if info, err := os.Stat(file); err == nil {
+			modTime = info.ModTime()
+		}
+		modTimes = append(modTimes, modTime)
+	}
+	return modTimes
+}
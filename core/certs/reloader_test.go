@@ -0,0 +1,175 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue creates a certificate for the given name signed by the CA, usable by
+// both servers and clients
+func (ca *testCA) issue(t *testing.T, name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writeCert(t *testing.T, path string, der []byte) {
+	assert.Nil(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+}
+
+func writeKeyPair(t *testing.T, config *types.TLSConfig, cert tls.Certificate, modTime time.Time) {
+	writeCert(t, config.CertFile, cert.Certificate[0])
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(config.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	// set the modification time explicitly, as rewrites within the file system's
+	// timestamp resolution would not be noticed
+	assert.Nil(t, os.Chtimes(config.CertFile, modTime, modTime))
+	assert.Nil(t, os.Chtimes(config.KeyFile, modTime, modTime))
+}
+
+func startServer(t *testing.T, reloader *Reloader) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = reloader.TLSConfig()
+	server.StartTLS()
+	return server
+}
+
+func newClient(ca *testCA, certificates ...tls.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates},
+		// a new connection is needed each time to see the server certificate
+		DisableKeepAlives: true,
+	}}
+}
+
+func TestReloader_ReloadsChangedCertificate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "certs")
+	defer os.RemoveAll(dir)
+	ca := newCA(t)
+	config := &types.TLSConfig{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key")}
+	writeKeyPair(t, config, ca.issue(t, "first"), time.Now().Add(-time.Minute))
+
+	reloader, err := NewReloader(config)
+	assert.Nil(t, err)
+	reloader.checkInterval = 0
+	server := startServer(t, reloader)
+	defer server.Close()
+	client := newClient(ca)
+
+	serverName := func() string {
+		resp, err := client.Get(server.URL)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	assert.Equal(t, "first", serverName())
+
+	writeKeyPair(t, config, ca.issue(t, "second"), time.Now())
+	assert.Equal(t, "second", serverName())
+
+	// an invalid certificate is not loaded, and the previous one is kept
+	assert.Nil(t, ioutil.WriteFile(config.CertFile, []byte("invalid"), 0644))
+	assert.Nil(t, os.Chtimes(config.CertFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	assert.Equal(t, "second", serverName())
+}
+
+func TestReloader_RequiresClientCertificate(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "certs")
+	defer os.RemoveAll(dir)
+	ca := newCA(t)
+	config := &types.TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	writeKeyPair(t, config, ca.issue(t, "server"), time.Now())
+	writeCert(t, config.ClientCAFile, ca.cert.Raw)
+
+	reloader, err := NewReloader(config)
+	assert.Nil(t, err)
+	server := startServer(t, reloader)
+	defer server.Close()
+
+	_, err = newClient(ca).Get(server.URL)
+	assert.NotNil(t, err)
+
+	// clients with a certificate signed by another CA are rejected
+	_, err = newClient(ca, newCA(t).issue(t, "other")).Get(server.URL)
+	assert.NotNil(t, err)
+
+	resp, err := newClient(ca, ca.issue(t, "client")).Get(server.URL)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewReloader_InvalidFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "certs")
+	defer os.RemoveAll(dir)
+	config := &types.TLSConfig{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key")}
+	writeKeyPair(t, config, newCA(t).issue(t, "server"), time.Now())
+
+	config.ClientCAFile = filepath.Join(dir, "ca.crt")
+	assert.Nil(t, ioutil.WriteFile(config.ClientCAFile, []byte("invalid"), 0644))
+	_, err := NewReloader(config)
+	assert.EqualError(t, err, "loading tls client ca file: no certificates found")
+
+	config.KeyFile = filepath.Join(dir, "missing.key")
+	_, err = NewReloader(config)
+	assert.Contains(t, err.Error(), "loading tls certificate:")
+}
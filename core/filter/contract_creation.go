@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"context"
 	"encoding/hex"
 
 	"quorumengineering/quorum-report/client"
@@ -22,7 +23,7 @@ func NewContractCreationFilter(db FilterServiceDB, quorumClient client.Client) *
 	}
 }
 
-func (ccFilter *ContractCreationFilter) ProcessBlocks(indexedAddresses []types.Address, blocks []*types.BlockWithTransactions) error {
+func (ccFilter *ContractCreationFilter) ProcessBlocks(ctx context.Context, indexedAddresses []types.Address, blocks []*types.BlockWithTransactions) error {
 	log.Debug("Filtering for contract creations")
 	defer func() { log.Debug("Finished filtering for contract creations") }()
 
@@ -34,7 +35,7 @@ func (ccFilter *ContractCreationFilter) ProcessBlocks(indexedAddresses []types.A
 	allDeployedContacts := make(map[types.Hash][]types.Address)
 	for _, block := range blocks {
 		for _, tx := range block.Transactions {
-			deployedContracts, err := ccFilter.findDeployedContracts(tx)
+			deployedContracts, err := ccFilter.findDeployedContracts(ctx, tx)
 			if err != nil {
 				return err
 			}
@@ -50,7 +51,7 @@ func (ccFilter *ContractCreationFilter) ProcessBlocks(indexedAddresses []types.A
 	return ccFilter.db.SetContractCreationTransaction(allDeployedContacts)
 }
 
-func (ccFilter *ContractCreationFilter) findDeployedContracts(tx *types.Transaction) ([]types.Address, error) {
+func (ccFilter *ContractCreationFilter) findDeployedContracts(ctx context.Context, tx *types.Transaction) ([]types.Address, error) {
 	deployedContracts := make([]types.Address, 0)
 
 	// Check for external deployment
@@ -74,7 +75,7 @@ func (ccFilter *ContractCreationFilter) findDeployedContracts(tx *types.Transact
 			address := types.NewAddress(hex.EncodeToString(addressBytes))
 
 			//check if the code exists to tell if the extension succeeded
-			code, err := client.GetCode(ccFilter.quorumClient, address, tx.BlockNumber-1)
+			code, err := client.GetCode(ctx, ccFilter.quorumClient, address, tx.BlockNumber-1)
 			if err != nil {
 				return nil, err
 			}
@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -65,7 +66,7 @@ func TestContractCreationFilter_ProcessBlocks(t *testing.T) {
 		"eth_getCode0x8a5e2a6343108babed07899510fb42297938d41f0x9": types.NewHexData("0x1234"),
 	}))
 
-	err := ccFilter.ProcessBlocks(testAddresses, []*types.BlockWithTransactions{testIndexBlock})
+	err := ccFilter.ProcessBlocks(context.Background(), testAddresses, []*types.BlockWithTransactions{testIndexBlock})
 	assert.Nil(t, err)
 
 	testCases := []struct {
@@ -99,7 +100,7 @@ func TestContractCreationFilter_ProcessBlocks_NonIndexedContracts(t *testing.T)
 		"eth_getCode0x8a5e2a6343108babed07899510fb42297938d41f0x9": types.NewHexData("0x1234"),
 	}))
 
-	err := ccFilter.ProcessBlocks([]types.Address{}, []*types.BlockWithTransactions{testIndexBlock})
+	err := ccFilter.ProcessBlocks(context.Background(), []types.Address{}, []*types.BlockWithTransactions{testIndexBlock})
 	assert.Nil(t, err)
 
 	for _, address := range testAddresses {
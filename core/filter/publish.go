@@ -0,0 +1,109 @@
+package filter
+
+import (
+	"math/big"
+
+	"quorumengineering/quorum-report/core/filter/token"
+	"quorumengineering/quorum-report/core/subscription"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// Publisher is sent the newly indexed data once a batch has been fully processed
+type Publisher interface {
+	PublishBlocks([]*types.BlockWithTransactions)
+	PublishEvents([]*types.ParsedEvent)
+	PublishERC20Balance(*subscription.ERC20BalanceChange)
+	PublishERC721Transfer(*types.ERC721Token)
+	PublishEtherBalance(*subscription.EtherBalanceChange)
+}
+
+// tokenRecorder passes token updates through to the database, keeping hold of
+// them so they can be published once the batch they are part of is processed.
+type tokenRecorder struct {
+	token.TokenFilterDatabase
+
+	erc20Balances   []*subscription.ERC20BalanceChange
+	erc721Transfers []*types.ERC721Token
+	etherBalances   []*subscription.EtherBalanceChange
+}
+
+func (r *tokenRecorder) RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	if err := r.TokenFilterDatabase.RecordNewERC20Balance(contract, holder, block, amount); err != nil {
+		return err
+	}
+	// ether balances are recorded alongside token balances, but published apart
+	if contract == types.EtherContract {
+		r.etherBalances = append(r.etherBalances, &subscription.EtherBalanceChange{
+			Holder:  holder,
+			Block:   block,
+			Balance: amount,
+		})
+		return nil
+	}
+	r.erc20Balances = append(r.erc20Balances, &subscription.ERC20BalanceChange{
+		Contract: contract,
+		Holder:   holder,
+		Block:    block,
+		Balance:  amount,
+	})
+	return nil
+}
+
+func (r *tokenRecorder) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
+	if err := r.TokenFilterDatabase.RecordERC721Token(contract, holder, block, tokenId); err != nil {
+		return err
+	}
+	r.erc721Transfers = append(r.erc721Transfers, &types.ERC721Token{
+		Contract: contract,
+		Holder:   holder,
+		Token:    tokenId.String(),
+		HeldFrom: block,
+	})
+	return nil
+}
+
+func (r *tokenRecorder) reset() {
+	r.erc20Balances = nil
+	r.erc721Transfers = nil
+	r.etherBalances = nil
+}
+
+// publish sends the blocks, events and token updates of a processed batch to
+// the publisher
+func (fs *FilterService) publish(batch IndexBatch, addressesWithAbi map[types.Address]string) {
+	if fs.publisher == nil {
+		return
+	}
+	fs.publisher.PublishBlocks(batch.blocks)
+
+	events := make([]*types.ParsedEvent, 0)
+	for _, block := range batch.blocks {
+		for _, tx := range block.Transactions {
+			for _, event := range tx.Events {
+				contractABI, ok := addressesWithAbi[event.Address]
+				if !ok {
+					continue
+				}
+				parsedEvent := &types.ParsedEvent{RawEvent: event}
+				if contractABI != "" {
+					if err := parsedEvent.ParseEvent(contractABI); err != nil {
+						log.Warn("Parsing event for publishing failed", "address", event.Address.Hex(), "err", err)
+					}
+				}
+				events = append(events, parsedEvent)
+			}
+		}
+	}
+	fs.publisher.PublishEvents(events)
+
+	for _, change := range fs.tokenRecorder.erc20Balances {
+		fs.publisher.PublishERC20Balance(change)
+	}
+	for _, transfer := range fs.tokenRecorder.erc721Transfers {
+		fs.publisher.PublishERC721Transfer(transfer)
+	}
+	for _, change := range fs.tokenRecorder.etherBalances {
+		fs.publisher.PublishEtherBalance(change)
+	}
+}
@@ -1,8 +1,10 @@
 package filter
 
 import (
+	"context"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"quorumengineering/quorum-report/client"
@@ -14,7 +16,12 @@ import (
 //TODO: clean this type up, find a better way to pass specific methods to needed pieces
 type FilterServiceDB interface {
 	RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error
+	GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
+	GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error)
 	RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error
+	RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error
+	RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error
+	RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error
 
 	ReadTransaction(types.Hash) (*types.Transaction, error)
 	ReadBlock(uint64) (*types.Block, error)
@@ -37,20 +44,49 @@ type FilterService struct {
 	contractCreationFilter *ContractCreationFilter
 	erc20processor         *token.ERC20Processor
 	erc721processor        *token.ERC721Processor
+	erc1155processor       *token.ERC1155Processor
+	erc777processor        *token.ERC777Processor
+	erc4626processor       *token.ERC4626Processor
+	etherprocessor         *token.EtherProcessor
+	tokenRecorder          *tokenRecorder
+	publisher              Publisher
+
+	// set while the indexing loop is running
+	running int32
+
+	// cancelled when shutting down, to give up on calls to Quorum in flight
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	// To check we have actually shut down before returning
 	shutdownChan chan struct{}
 	shutdownWg   sync.WaitGroup
 }
 
-func NewFilterService(db FilterServiceDB, client client.Client) *FilterService {
+// NewFilterService creates a filter service, which publishes newly indexed data
+// to the given publisher if it is non-nil.
+func NewFilterService(db FilterServiceDB, client client.Client, publisher Publisher, config types.TuningConfig) *FilterService {
+	recorder := &tokenRecorder{TokenFilterDatabase: db}
+	erc20processor := token.NewERC20Processor(recorder, client)
+	if config.ERC20BalanceMode == types.EventBalanceMode {
+		erc20processor = token.NewEventERC20Processor(recorder, client, config.ERC20ReconcileInterval)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	return &FilterService{
 		db:                     db,
-		storageFilter:          NewStorageFilter(db, client),
+		storageFilter:          NewStorageFilter(ctx, db, client),
 		contractCreationFilter: NewContractCreationFilter(db, client),
+		ctx:                    ctx,
+		cancel:                 cancel,
 		shutdownChan:           make(chan struct{}),
-		erc20processor:         token.NewERC20Processor(db, client),
-		erc721processor:        token.NewERC721Processor(db),
+		erc20processor:         erc20processor,
+		erc721processor:        token.NewERC721Processor(recorder),
+		erc1155processor:       token.NewERC1155Processor(recorder, client),
+		erc777processor:        token.NewERC777Processor(recorder),
+		erc4626processor:       token.NewERC4626Processor(recorder, client),
+		etherprocessor:         token.NewEtherProcessor(recorder, client),
+		tokenRecorder:          recorder,
+		publisher:              publisher,
 	}
 }
 
@@ -64,6 +100,8 @@ func (fs *FilterService) Start() error {
 		ticker := time.NewTicker(time.Second * 2)
 		defer ticker.Stop()
 		defer fs.shutdownWg.Done()
+		atomic.StoreInt32(&fs.running, 1)
+		defer atomic.StoreInt32(&fs.running, 0)
 		for {
 			select {
 			case <-ticker.C:
@@ -106,7 +144,13 @@ func (fs *FilterService) Start() error {
 	return nil
 }
 
+// IsRunning checks whether the filter service is indexing blocks.
+func (fs *FilterService) IsRunning() bool {
+	return atomic.LoadInt32(&fs.running) == 1
+}
+
 func (fs *FilterService) Stop() {
+	fs.cancel()
 	close(fs.shutdownChan)
 	fs.shutdownWg.Wait()
 	fs.storageFilter.Stop()
@@ -196,6 +240,7 @@ func (fs *FilterService) index(lastFiltered map[types.Address]uint64, blockNumbe
 
 func (fs *FilterService) processBatch(batch IndexBatch) error {
 	log.Info("Processing batch", "start", batch.blocks[0].Number, "end", batch.blocks[len(batch.blocks)-1].Number)
+	fs.tokenRecorder.reset()
 	if err := fs.storageFilter.IndexStorage(batch.addresses, batch.blocks[0].Number, batch.blocks[len(batch.blocks)-1].Number); err != nil {
 		return err
 	}
@@ -205,7 +250,7 @@ func (fs *FilterService) processBatch(batch IndexBatch) error {
 		return err
 	}
 
-	if err := fs.contractCreationFilter.ProcessBlocks(batch.addresses, batch.blocks); err != nil {
+	if err := fs.contractCreationFilter.ProcessBlocks(fs.ctx, batch.addresses, batch.blocks); err != nil {
 		return err
 	}
 
@@ -218,13 +263,26 @@ func (fs *FilterService) processBatch(batch IndexBatch) error {
 		addressesWithAbi[address] = abi
 	}
 	for _, b := range batch.blocks {
-		if err := fs.erc20processor.ProcessBlock(addressesWithAbi, b); err != nil {
+		if err := fs.erc20processor.ProcessBlock(fs.ctx, addressesWithAbi, b); err != nil {
 			return err
 		}
 		if err := fs.erc721processor.ProcessBlock(addressesWithAbi, b); err != nil {
 			return err
 		}
+		if err := fs.erc1155processor.ProcessBlock(fs.ctx, addressesWithAbi, b); err != nil {
+			return err
+		}
+		if err := fs.erc777processor.ProcessBlock(addressesWithAbi, b); err != nil {
+			return err
+		}
+		if err := fs.erc4626processor.ProcessBlock(fs.ctx, addressesWithAbi, b); err != nil {
+			return err
+		}
+		if err := fs.etherprocessor.ProcessBlock(fs.ctx, batch.addresses, b); err != nil {
+			return err
+		}
 	}
+	fs.publish(batch, addressesWithAbi)
 
 	log.Info("Processed batch", "start", batch.blocks[0].Number, "end", batch.blocks[len(batch.blocks)-1].Number)
 	return nil
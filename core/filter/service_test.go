@@ -1,6 +1,7 @@
 package filter
 
 import (
+	"context"
 	"errors"
 	"math/big"
 	"testing"
@@ -8,6 +9,8 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/core/subscription"
+	"quorumengineering/quorum-report/database/memory"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -25,7 +28,7 @@ func TestIndexBlock(t *testing.T) {
 		[]types.Address{types.NewAddress("1"), types.NewAddress("2")},
 		map[types.Address]uint64{types.NewAddress("1"): 3, types.NewAddress("2"): 5},
 	}
-	fs := NewFilterService(db, client.NewStubQuorumClient(nil, mockRPC))
+	fs := NewFilterService(db, client.NewStubQuorumClient(nil, mockRPC), nil, types.TuningConfig{})
 
 	// test fs.getLastFiltered
 	lastFilteredAll, lastFiltered, err := fs.getLastFiltered(6)
@@ -47,6 +50,84 @@ func TestIndexBlock(t *testing.T) {
 	assert.EqualValues(t, 6, db.lastFiltered[types.NewAddress("2")])
 }
 
+func TestIndexBlock_PublishesProcessedBatch(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_storageRoot0x00000000000000000000000000000000000000010x3": types.NewHash("1"),
+		"eth_storageRoot0x00000000000000000000000000000000000000010x4": types.NewHash("1"),
+		"eth_storageRoot0x00000000000000000000000000000000000000010x5": types.NewHash("1"),
+	}
+	db := &FakeDB{
+		[]types.Address{types.NewAddress("1")},
+		map[types.Address]uint64{types.NewAddress("1"): 3},
+	}
+	publisher := &fakePublisher{}
+	fs := NewFilterService(db, client.NewStubQuorumClient(nil, mockRPC), publisher, types.TuningConfig{})
+
+	err := fs.index(map[types.Address]uint64{types.NewAddress("1"): 3}, 4, 5)
+
+	assert.Nil(t, err)
+	assert.Len(t, publisher.blocks, 2)
+	assert.EqualValues(t, 4, publisher.blocks[0].Number)
+	assert.EqualValues(t, 5, publisher.blocks[1].Number)
+	assert.Len(t, publisher.events, 0)
+}
+
+func TestIndexBlock_GivesUpWhenStopping(t *testing.T) {
+	db := &FakeDB{
+		[]types.Address{types.NewAddress("1")},
+		map[types.Address]uint64{types.NewAddress("1"): 3},
+	}
+	// storage roots cannot be fetched, so would be retried until shutting down
+	fs := NewFilterService(db, client.NewStubQuorumClient(nil, nil), nil, types.TuningConfig{})
+	fs.cancel()
+
+	lastFilteredAll, _, err := fs.getLastFiltered(4)
+	assert.Nil(t, err)
+	assert.Equal(t, context.Canceled, fs.index(lastFilteredAll, 4, 4))
+	assert.EqualValues(t, 3, db.lastFiltered[types.NewAddress("1")])
+}
+
+func TestPublish_SeparatesEtherFromERC20Balances(t *testing.T) {
+	recorder := &tokenRecorder{TokenFilterDatabase: memory.NewMemoryDB()}
+	publisher := &fakePublisher{}
+	fs := &FilterService{tokenRecorder: recorder, publisher: publisher}
+	holder := types.NewAddress("0x0000000000000000000000000000000000000002")
+
+	assert.Nil(t, recorder.RecordNewERC20Balance(types.NewAddress("0x0000000000000000000000000000000000000001"), holder, 5, big.NewInt(10)))
+	assert.Nil(t, recorder.RecordNewERC20Balance(types.EtherContract, holder, 5, big.NewInt(20)))
+	fs.publish(IndexBatch{}, nil)
+
+	if assert.Len(t, publisher.erc20Balances, 1) {
+		assert.EqualValues(t, 10, publisher.erc20Balances[0].Balance.Int64())
+	}
+	assert.Equal(t, []*subscription.EtherBalanceChange{{Holder: holder, Block: 5, Balance: big.NewInt(20)}}, publisher.etherBalances)
+}
+
+type fakePublisher struct {
+	blocks        []*types.BlockWithTransactions
+	events        []*types.ParsedEvent
+	erc20Balances []*subscription.ERC20BalanceChange
+	etherBalances []*subscription.EtherBalanceChange
+}
+
+func (p *fakePublisher) PublishBlocks(blocks []*types.BlockWithTransactions) {
+	p.blocks = append(p.blocks, blocks...)
+}
+
+func (p *fakePublisher) PublishEvents(events []*types.ParsedEvent) {
+	p.events = append(p.events, events...)
+}
+
+func (p *fakePublisher) PublishERC20Balance(change *subscription.ERC20BalanceChange) {
+	p.erc20Balances = append(p.erc20Balances, change)
+}
+
+func (p *fakePublisher) PublishERC721Transfer(*types.ERC721Token) {}
+
+func (p *fakePublisher) PublishEtherBalance(change *subscription.EtherBalanceChange) {
+	p.etherBalances = append(p.etherBalances, change)
+}
+
 type FakeDB struct {
 	addresses    []types.Address
 	lastFiltered map[types.Address]uint64
@@ -96,10 +177,30 @@ func (f *FakeDB) RecordNewERC20Balance(contract types.Address, holder types.Addr
 	return errors.New("not implemented")
 }
 
+func (f *FakeDB) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *FakeDB) GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	return nil, errors.New("not implemented")
+}
+
 func (f *FakeDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
 	return errors.New("not implemented")
 }
 
+func (f *FakeDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error {
+	return errors.New("not implemented")
+}
+
+func (f *FakeDB) RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error {
+	return errors.New("not implemented")
+}
+
 func (f *FakeDB) GetContractABI(types.Address) (string, error) {
 	return "{}", nil
 }
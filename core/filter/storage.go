@@ -1,18 +1,22 @@
 package filter
 
 import (
+	"context"
 	"runtime"
 	"sync"
 	"time"
 
 	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/metrics"
 	"quorumengineering/quorum-report/types"
 )
 
 type StorageFilter struct {
 	db           FilterServiceDB
 	quorumClient client.Client
+	// cancelled when shutting down, after which no more state is fetched
+	ctx context.Context
 
 	outstandingBlocks sync.WaitGroup
 	maxEntriesToSave  int
@@ -30,10 +34,11 @@ type AccountStateWithBlock struct {
 	Addresses    []types.Address
 }
 
-func NewStorageFilter(db FilterServiceDB, quorumClient client.Client) *StorageFilter {
+func NewStorageFilter(ctx context.Context, db FilterServiceDB, quorumClient client.Client) *StorageFilter {
 	sf := &StorageFilter{
 		db:                db,
 		quorumClient:      quorumClient,
+		ctx:               ctx,
 		maxEntriesToSave:  100,
 		incomingBlockChan: make(chan AccountStateWithBlock),
 		pulledStateChan:   make(chan AccountStateWithBlock, 1000),
@@ -61,6 +66,7 @@ func (sf *StorageFilter) IndexStorage(addresses []types.Address, startBlockNumbe
 	log.Info("Indexing storage", "start", startBlockNumber, "end", endBlockNumber)
 	for i := startBlockNumber; i <= endBlockNumber; i++ {
 		sf.outstandingBlocks.Add(1)
+		metrics.StorageQueueDepth.Inc()
 		emptyStorage := AccountStateWithBlock{
 			BlockNumber:  i,
 			AccountState: make(map[types.Address]*types.AccountState),
@@ -70,6 +76,10 @@ func (sf *StorageFilter) IndexStorage(addresses []types.Address, startBlockNumbe
 	}
 
 	sf.outstandingBlocks.Wait()
+	// blocks are given up on when shutting down, so the storage is incomplete
+	if err := sf.ctx.Err(); err != nil {
+		return err
+	}
 	log.Info("Indexing storage complete", "start", startBlockNumber, "end", endBlockNumber)
 	return nil
 }
@@ -83,24 +93,11 @@ func (sf *StorageFilter) StateFetchWorker() {
 				log.Debug("Shutdown request received", "loc", "storage filter - state fetch worker")
 				return
 			case blockToPull := <-sf.incomingBlockChan:
-				log.Debug("Fetching contract storage", "block number", blockToPull.BlockNumber)
-				for _, address := range blockToPull.Addresses {
-					changed, err := sf.didStorageRootChange(address, blockToPull.BlockNumber)
-					for err != nil {
-						changed, err = sf.didStorageRootChange(address, blockToPull.BlockNumber)
-					}
-					if !changed {
-						continue
-					}
-
-					log.Debug("Fetching contract storage", "address", address.String(), "block number", blockToPull.BlockNumber)
-					dumpAccount, err := client.DumpAddress(sf.quorumClient, address, blockToPull.BlockNumber)
-					for err != nil {
-						log.Error("Unable to fetch contract state", "address", address.String(), "block number", blockToPull.BlockNumber, "err", err)
-						time.Sleep(time.Second) //TODO: make adaptive or block until websocket available
-						dumpAccount, err = client.DumpAddress(sf.quorumClient, address, blockToPull.BlockNumber)
-					}
-					blockToPull.AccountState[address] = dumpAccount
+				if err := sf.fetchState(blockToPull); err != nil {
+					log.Debug("Giving up fetching contract storage", "block number", blockToPull.BlockNumber, "err", err)
+					sf.outstandingBlocks.Done()
+					metrics.StorageQueueDepth.Dec()
+					continue
 				}
 				sf.pulledStateChan <- blockToPull
 			}
@@ -108,6 +105,33 @@ func (sf *StorageFilter) StateFetchWorker() {
 	}()
 }
 
+// fetchState fetches the storage of the contracts changed in the block,
+// retrying failed calls until shutting down
+func (sf *StorageFilter) fetchState(blockToPull AccountStateWithBlock) error {
+	log.Debug("Fetching contract storage", "block number", blockToPull.BlockNumber)
+	changed, err := sf.changedStorageRoots(blockToPull.Addresses, blockToPull.BlockNumber)
+	for err != nil {
+		if sf.ctx.Err() != nil {
+			return err
+		}
+		changed, err = sf.changedStorageRoots(blockToPull.Addresses, blockToPull.BlockNumber)
+	}
+	for _, address := range changed {
+		log.Debug("Fetching contract storage", "address", address.String(), "block number", blockToPull.BlockNumber)
+		dumpAccount, err := client.DumpAddress(sf.ctx, sf.quorumClient, address, blockToPull.BlockNumber)
+		for err != nil {
+			if sf.ctx.Err() != nil {
+				return err
+			}
+			log.Error("Unable to fetch contract state", "address", address.String(), "block number", blockToPull.BlockNumber, "err", err)
+			time.Sleep(time.Second) //TODO: make adaptive or block until websocket available
+			dumpAccount, err = client.DumpAddress(sf.ctx, sf.quorumClient, address, blockToPull.BlockNumber)
+		}
+		blockToPull.AccountState[address] = dumpAccount
+	}
+	return nil
+}
+
 func (sf *StorageFilter) StateSavingWorker() {
 	go func() {
 		defer sf.shutdownWg.Done()
@@ -160,6 +184,7 @@ func (sf *StorageFilter) SaveStorage(storage []AccountStateWithBlock) {
 			err = sf.db.IndexStorage(storageData.AccountState, storageData.BlockNumber)
 		}
 		sf.outstandingBlocks.Done()
+		metrics.StorageQueueDepth.Dec()
 	}
 
 	for _, storageData := range storage {
@@ -177,16 +202,28 @@ func (sf *StorageFilter) Stop() {
 	log.Info("Finished stopping storage filter")
 }
 
-func (sf *StorageFilter) didStorageRootChange(contract types.Address, blockNum uint64) (bool, error) {
-	storageRootThisBlock, err := client.StorageRoot(sf.quorumClient, contract, blockNum)
+// changedStorageRoots returns the contracts whose storage root changed in the
+// block, fetching the roots of all the contracts in a batch call per block
+func (sf *StorageFilter) changedStorageRoots(contracts []types.Address, blockNum uint64) ([]types.Address, error) {
+	if len(contracts) == 0 {
+		return nil, nil
+	}
+
+	storageRootsThisBlock, err := client.StorageRoots(sf.ctx, sf.quorumClient, contracts, blockNum)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	storageRootPrevBlock, err := client.StorageRoot(sf.quorumClient, contract, blockNum-1)
+	storageRootsPrevBlock, err := client.StorageRoots(sf.ctx, sf.quorumClient, contracts, blockNum-1)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	return storageRootPrevBlock != storageRootThisBlock, nil
+	changed := make([]types.Address, 0, len(contracts))
+	for i, contract := range contracts {
+		if storageRootsPrevBlock[i] != storageRootsThisBlock[i] {
+			changed = append(changed, contract)
+		}
+	}
+	return changed, nil
 }
@@ -0,0 +1,200 @@
+package token
+
+import (
+	"context"
+	"math/big"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+const erc1155AbiString = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"_owner","type":"address"},{"indexed":true,"internalType":"address","name":"_operator","type":"address"},{"indexed":false,"internalType":"bool","name":"_approved","type":"bool"}],"name":"ApprovalForAll","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"_operator","type":"address"},{"indexed":true,"internalType":"address","name":"_from","type":"address"},{"indexed":true,"internalType":"address","name":"_to","type":"address"},{"indexed":false,"internalType":"uint256[]","name":"_ids","type":"uint256[]"},{"indexed":false,"internalType":"uint256[]","name":"_values","type":"uint256[]"}],"name":"TransferBatch","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"_operator","type":"address"},{"indexed":true,"internalType":"address","name":"_from","type":"address"},{"indexed":true,"internalType":"address","name":"_to","type":"address"},{"indexed":false,"internalType":"uint256","name":"_id","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"_value","type":"uint256"}],"name":"TransferSingle","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"internalType":"string","name":"_value","type":"string"},{"indexed":true,"internalType":"uint256","name":"_id","type":"uint256"}],"name":"URI","type":"event"},{"inputs":[{"internalType":"address","name":"_owner","type":"address"},{"internalType":"uint256","name":"_id","type":"uint256"}],"name":"balanceOf","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address[]","name":"_owners","type":"address[]"},{"internalType":"uint256[]","name":"_ids","type":"uint256[]"}],"name":"balanceOfBatch","outputs":[{"internalType":"uint256[]","name":"","type":"uint256[]"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"_owner","type":"address"},{"internalType":"address","name":"_operator","type":"address"}],"name":"isApprovedForAll","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"_from","type":"address"},{"internalType":"address","name":"_to","type":"address"},{"internalType":"uint256[]","name":"_ids","type":"uint256[]"},{"internalType":"uint256[]","name":"_values","type":"uint256[]"},{"internalType":"bytes","name":"_data","type":"bytes"}],"name":"safeBatchTransferFrom","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"_from","type":"address"},{"internalType":"address","name":"_to","type":"address"},{"internalType":"uint256","name":"_id","type":"uint256"},{"internalType":"uint256","name":"_value","type":"uint256"},{"internalType":"bytes","name":"_data","type":"bytes"}],"name":"safeTransferFrom","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"_operator","type":"address"},{"internalType":"bool","name":"_approved","type":"bool"}],"name":"setApprovalForAll","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+
+var (
+	// erc1155TransferSingleTopicHash is the topic hash for an ERC1155 TransferSingle event
+	erc1155TransferSingleTopicHash = types.NewHash("0xc3d58168c5ae7397731d063d5bbf3d657854427343f4c083240f7aacaa2d0f62")
+	// erc1155TransferBatchTopicHash is the topic hash for an ERC1155 TransferBatch event
+	erc1155TransferBatchTopicHash = types.NewHash("0x4a39dc06d4c0dbc64b70af90fd698a233a518aa5d07e595d983b8c0526c8f7fb")
+	erc1155Abi, _                 = types.NewABIStructureFromJSON(erc1155AbiString)
+)
+
+// ERC1155Holding is a balance of a single token ID held by an account
+type ERC1155Holding struct {
+	Holder types.Address
+	Token  string
+}
+
+type ERC1155Processor struct {
+	db     TokenFilterDatabase
+	client client.Client
+}
+
+func NewERC1155Processor(database TokenFilterDatabase, client client.Client) *ERC1155Processor {
+	return &ERC1155Processor{db: database, client: client}
+}
+
+func (p *ERC1155Processor) ProcessBlock(ctx context.Context, lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+	changedHoldings := make(map[types.Address]map[ERC1155Holding]bool)
+	erc1155Contracts := p.filterForErc1155Contracts(lastFilteredWithAbi)
+
+	for _, tx := range block.Transactions {
+		for contract, holdings := range p.ChangedHoldings(erc1155Contracts, tx) {
+			if changedHoldings[contract] == nil {
+				changedHoldings[contract] = holdings
+				continue
+			}
+			for holding := range holdings {
+				changedHoldings[contract][holding] = true
+			}
+		}
+	}
+
+	return p.UpdateBalances(ctx, changedHoldings, block.Number)
+}
+
+// UpdateBalances fetches the balances of the changed holdings from the contracts
+// at the block, and records them
+func (p *ERC1155Processor) UpdateBalances(ctx context.Context, changedHoldings map[types.Address]map[ERC1155Holding]bool, blockNum uint64) error {
+	for contract, holdings := range changedHoldings {
+		holders := make([]types.Address, 0, len(holdings))
+		tokenIds := make([]*big.Int, 0, len(holdings))
+		for holding := range holdings {
+			tokenId, _ := new(big.Int).SetString(holding.Token, 10)
+			holders = append(holders, holding.Holder)
+			tokenIds = append(tokenIds, tokenId)
+		}
+
+		balances, err := client.CallBalancesOfERC1155(ctx, p.client, contract, holders, tokenIds, blockNum)
+		if err != nil {
+			return err
+		}
+
+		for i, holder := range holders {
+			balance := new(big.Int).SetBytes(balances[i].AsBytes())
+			if err := p.db.RecordERC1155Balance(contract, holder, blockNum, tokenIds[i], balance); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ChangedHoldings filters through all events in the transaction and returns the
+// token IDs of each contract whose balance has changed for the senders and
+// recipients. The zero address, which tokens are minted from and burned to, is
+// never a holder.
+func (p *ERC1155Processor) ChangedHoldings(erc1155Contracts map[types.Address]bool, tx *types.Transaction) map[types.Address]map[ERC1155Holding]bool {
+	changedHoldings := make(map[types.Address]map[ERC1155Holding]bool)
+
+	for _, event := range tx.Events {
+		if !erc1155Contracts[event.Address] || len(event.Topics) != 4 {
+			continue
+		}
+
+		var tokenIds []*big.Int
+		switch event.Topics[0] {
+		case erc1155TransferSingleTopicHash:
+			tokenIds = decodeTransferSingleId(event.Data.AsBytes())
+		case erc1155TransferBatchTopicHash:
+			tokenIds = decodeTransferBatchIds(event.Data.AsBytes())
+		default:
+			continue
+		}
+		if len(tokenIds) == 0 {
+			continue
+		}
+
+		from := types.NewAddress(string(event.Topics[2])[24:64]) //only take the last 40 chars (20 bytes)
+		to := types.NewAddress(string(event.Topics[3])[24:64])   //only take the last 40 chars (20 bytes)
+
+		if changedHoldings[event.Address] == nil {
+			changedHoldings[event.Address] = make(map[ERC1155Holding]bool)
+		}
+		for _, tokenId := range tokenIds {
+			for _, holder := range []types.Address{from, to} {
+				if !holder.IsEmpty() {
+					changedHoldings[event.Address][ERC1155Holding{Holder: holder, Token: tokenId.String()}] = true
+				}
+			}
+		}
+	}
+
+	return changedHoldings
+}
+
+func (p *ERC1155Processor) filterForErc1155Contracts(contractsWithAbi map[types.Address]string) map[types.Address]bool {
+	erc1155Contracts := make(map[types.Address]bool)
+
+	for address, abi := range contractsWithAbi {
+		contractAbi, _ := types.NewABIStructureFromJSON(abi)
+		isErc1155 := isErc1155(contractAbi)
+
+		if isErc1155 {
+			erc1155Contracts[address] = true
+		}
+	}
+
+	return erc1155Contracts
+}
+
+// decodeTransferSingleId returns the token ID from the data of a TransferSingle
+// event, which holds the ID and the value transferred
+func decodeTransferSingleId(data []byte) []*big.Int {
+	if len(data) < 64 {
+		return nil
+	}
+	return []*big.Int{new(big.Int).SetBytes(data[:32])}
+}
+
+// decodeTransferBatchIds returns the token IDs from the data of a TransferBatch
+// event, which holds the offsets of the arrays of IDs and of values transferred,
+// followed by each array as its length and elements
+func decodeTransferBatchIds(data []byte) []*big.Int {
+	if len(data) < 64 {
+		return nil
+	}
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(data)-32) {
+		return nil
+	}
+	start := offset.Uint64()
+	length := new(big.Int).SetBytes(data[start : start+32])
+	if !length.IsUint64() || length.Uint64() > (uint64(len(data))-start-32)/32 {
+		return nil
+	}
+
+	tokenIds := make([]*big.Int, length.Uint64())
+	for i := range tokenIds {
+		elementStart := start + 32 + uint64(i)*32
+		tokenIds[i] = new(big.Int).SetBytes(data[elementStart : elementStart+32])
+	}
+	return tokenIds
+}
+
+func isErc1155(contractAbi types.ABIStructure) bool {
+	for _, erc1155Event := range erc1155Abi.ToInternalABI().Events {
+		found := false
+		for _, contractEvent := range contractAbi.ToInternalABI().Events {
+			if erc1155Event.Signature() == contractEvent.Signature() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, erc1155Method := range erc1155Abi.ToInternalABI().Functions {
+		found := false
+		for _, contractMethod := range contractAbi.ToInternalABI().Functions {
+			if erc1155Method.Signature() == contractMethod.Signature() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
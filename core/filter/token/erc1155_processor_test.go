@@ -0,0 +1,122 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestERC1155Processor_TopicHashes(t *testing.T) {
+	assertEventTopics(t, erc1155Abi, erc1155TransferSingleTopicHash, erc1155TransferBatchTopicHash)
+	assert.True(t, isErc1155(erc1155Abi))
+	assert.False(t, isErc1155(erc721Abi))
+}
+
+func TestERC1155Processor_ChangedHoldings(t *testing.T) {
+	processor := NewERC1155Processor(nil, nil)
+	contracts := map[types.Address]bool{tokenTestContract: true}
+
+	tx := &types.Transaction{
+		Events: []*types.Event{
+			// id 5, value 10
+			tokenTestEvent(erc1155TransferSingleTopicHash,
+				"0x0000000000000000000000000000000000000000000000000000000000000005"+
+					"000000000000000000000000000000000000000000000000000000000000000a",
+				tokenTestOperator, tokenTestSender, tokenTestRecipient),
+			// minted ids [1, 2] with values [3, 4]
+			tokenTestEvent(erc1155TransferBatchTopicHash,
+				"0x0000000000000000000000000000000000000000000000000000000000000040"+
+					"00000000000000000000000000000000000000000000000000000000000000a0"+
+					"0000000000000000000000000000000000000000000000000000000000000002"+
+					"0000000000000000000000000000000000000000000000000000000000000001"+
+					"0000000000000000000000000000000000000000000000000000000000000002"+
+					"0000000000000000000000000000000000000000000000000000000000000002"+
+					"0000000000000000000000000000000000000000000000000000000000000003"+
+					"0000000000000000000000000000000000000000000000000000000000000004",
+				tokenTestOperator, types.NewAddress(""), tokenTestRecipient),
+			// malformed data is ignored
+			tokenTestEvent(erc1155TransferBatchTopicHash,
+				"0x00000000000000000000000000000000000000000000000000000000000000ff"+
+					"0000000000000000000000000000000000000000000000000000000000000000",
+				tokenTestOperator, tokenTestSender, tokenTestRecipient),
+		},
+	}
+
+	expected := map[types.Address]map[ERC1155Holding]bool{
+		tokenTestContract: {
+			{Holder: tokenTestSender, Token: "5"}:    true,
+			{Holder: tokenTestRecipient, Token: "5"}: true,
+			{Holder: tokenTestRecipient, Token: "1"}: true,
+			{Holder: tokenTestRecipient, Token: "2"}: true,
+		},
+	}
+	assert.Equal(t, expected, processor.ChangedHoldings(contracts, tx))
+
+	// events of contracts that are not ERC1155 are not followed
+	assert.Len(t, processor.ChangedHoldings(map[types.Address]bool{}, tx), 0)
+}
+
+func TestERC1155Processor_ProcessBlock(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+	}
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{
+				Events: []*types.Event{
+					tokenTestEvent(erc1155TransferSingleTopicHash,
+						"0x0000000000000000000000000000000000000000000000000000000000000005"+
+							"000000000000000000000000000000000000000000000000000000000000000a",
+						tokenTestOperator, tokenTestSender, tokenTestRecipient),
+				},
+			},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(nil)
+	processor := NewERC1155Processor(db, client.NewStubQuorumClient(nil, mockRPC))
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenTestContract: erc1155AbiString}, block)
+
+	assert.Nil(t, err)
+	assert.Len(t, db.RecordedHolder, 2)
+	assert.ElementsMatch(t, []types.Address{tokenTestSender, tokenTestRecipient}, db.RecordedHolder)
+	assert.EqualValues(t, 1, db.RecordedBlock)
+	for i := range db.RecordedHolder {
+		assert.Equal(t, tokenTestContract, db.RecordedContract[i])
+		assert.Equal(t, "5", db.RecordedToken[i].String())
+		assert.Equal(t, "1000", db.RecordedAmount[i].String())
+	}
+}
+
+func TestERC1155Processor_ProcessBlock_DatabaseError(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+	}
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{
+				Events: []*types.Event{
+					tokenTestEvent(erc1155TransferSingleTopicHash,
+						"0x0000000000000000000000000000000000000000000000000000000000000005"+
+							"000000000000000000000000000000000000000000000000000000000000000a",
+						tokenTestOperator, tokenTestSender, tokenTestRecipient),
+				},
+			},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(errors.New("test error"))
+	processor := NewERC1155Processor(db, client.NewStubQuorumClient(nil, mockRPC))
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenTestContract: erc1155AbiString}, block)
+
+	assert.EqualError(t, err, "test error")
+}
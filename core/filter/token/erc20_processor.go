@@ -1,9 +1,12 @@
 package token
 
 import (
+	"context"
 	"math/big"
 
 	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/metrics"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -13,23 +16,65 @@ var (
 	// erc20TransferTopicHash is the topic hash for an ERC20 Transfer event
 	erc20TransferTopicHash = types.NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
 	erc20Abi, _            = types.NewABIStructureFromJSON(erc20AbiString)
+
+	erc20BalanceEvents = []balanceEvent{
+		{topic: erc20TransferTopicHash, topicCount: 3, fromTopic: 1, toTopic: 2, amountWord: 0},
+	}
 )
 
+// number of holders whose balances are compared in each reconciliation call
+const reconcilePageSize = 100
+
+// balanceEvent is an event of a token standard that moves an amount, given by one
+// of its non-indexed parameters, from and to the accounts given by its topics. A
+// topic index of 0 means there is no such account, as with mints and burns. The
+// number of topics tells apart events with the same signature but different
+// indexed parameters.
+type balanceEvent struct {
+	topic      types.Hash
+	topicCount int
+	fromTopic  int
+	toTopic    int
+	amountWord int
+}
+
+// ERC20Processor records the balances of ERC20 tokens, and of ERC777 tokens and
+// ERC4626 vault shares, which are fungible balances too. By default, the holders
+// whose balance any of their events changed have their balance fetched from the
+// contract. Alternatively, balances can be worked out by replaying the amounts
+// of the events onto the last balance recorded, which needs no calls to the
+// contract, so works on nodes that have pruned historical state.
 type ERC20Processor struct {
 	db     TokenFilterDatabase
 	client client.Client
+
+	// set when balances are replayed from events, along with the number of blocks
+	// between checks of the replayed balances against the contract, if any
+	replayEvents      bool
+	reconcileInterval uint64
 }
 
 func NewERC20Processor(database TokenFilterDatabase, client client.Client) *ERC20Processor {
 	return &ERC20Processor{db: database, client: client}
 }
 
-func (p *ERC20Processor) ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+// NewEventERC20Processor creates a processor that replays the amounts of events
+// onto the last balance recorded. Every reconcileInterval blocks, if not 0, the
+// balances of all holders are compared with their balance from the contract.
+func NewEventERC20Processor(database TokenFilterDatabase, client client.Client, reconcileInterval uint64) *ERC20Processor {
+	return &ERC20Processor{db: database, client: client, replayEvents: true, reconcileInterval: reconcileInterval}
+}
+
+func (p *ERC20Processor) ProcessBlock(ctx context.Context, lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+	tokenContracts := p.filterForTokenContracts(lastFilteredWithAbi)
+	if p.replayEvents {
+		return p.replayBalances(ctx, tokenContracts, block)
+	}
+
 	addressesWithChangedBalances := make(map[types.Address]map[types.Address]bool)
-	erc20Contracts := p.filterForErc20Contracts(lastFilteredWithAbi)
 
 	for _, tx := range block.Transactions {
-		thisTxTokenChanges := p.ChangedTokenHolders(erc20Contracts, tx)
+		thisTxTokenChanges := p.ChangedTokenHolders(tokenContracts, tx)
 		for contract, holders := range thisTxTokenChanges {
 			if addressesWithChangedBalances[contract] == nil {
 				addressesWithChangedBalances[contract] = holders
@@ -41,33 +86,56 @@ func (p *ERC20Processor) ProcessBlock(lastFilteredWithAbi map[types.Address]stri
 		}
 	}
 
-	return p.UpdateBalances(addressesWithChangedBalances, block.Number)
+	return p.UpdateBalances(ctx, addressesWithChangedBalances, block.Number)
 }
 
-func (p *ERC20Processor) filterForErc20Contracts(contractsWithAbi map[types.Address]string) map[types.Address]bool {
-	erc20Contracts := make(map[types.Address]bool)
+// filterForTokenContracts finds the contracts implementing a token standard with
+// fungible balances, along with the events of each standard they implement.
+// ERC777 tokens and ERC4626 vaults that are ERC20 compatible emit a Transfer
+// event alongside their own, so when replaying events only the Transfer events
+// of such contracts are followed, lest the amounts be counted twice.
+func (p *ERC20Processor) filterForTokenContracts(contractsWithAbi map[types.Address]string) map[types.Address][]balanceEvent {
+	tokenContracts := make(map[types.Address][]balanceEvent)
 
 	for address, abi := range contractsWithAbi {
 		contractAbi, _ := types.NewABIStructureFromJSON(abi)
-		isErc20 := isErc20(contractAbi)
 
-		if isErc20 {
-			erc20Contracts[address] = true
+		var events []balanceEvent
+		if isErc20(contractAbi) {
+			events = append(events, erc20BalanceEvents...)
+		}
+		if p.replayEvents && len(events) > 0 {
+			tokenContracts[address] = events
+			continue
+		}
+		if isErc777(contractAbi) {
+			events = append(events, erc777BalanceEvents...)
+		}
+		if isErc4626(contractAbi) {
+			events = append(events, erc4626BalanceEvents...)
+		}
+		if len(events) > 0 {
+			tokenContracts[address] = events
 		}
 	}
 
-	return erc20Contracts
+	return tokenContracts
 }
 
-func (p *ERC20Processor) UpdateBalances(addressesWithChangedBalances map[types.Address]map[types.Address]bool, blockNum uint64) error {
+func (p *ERC20Processor) UpdateBalances(ctx context.Context, addressesWithChangedBalances map[types.Address]map[types.Address]bool, blockNum uint64) error {
 	for contract, tokenHolders := range addressesWithChangedBalances {
+		holders := make([]types.Address, 0, len(tokenHolders))
 		for tokenHolder := range tokenHolders {
-			bal, err := client.CallBalanceOfERC20(p.client, contract, tokenHolder, blockNum)
-			if err != nil {
-				return err
-			}
+			holders = append(holders, tokenHolder)
+		}
 
-			balance := new(big.Int).SetBytes(bal.AsBytes())
+		balances, err := client.CallBalancesOfERC20(ctx, p.client, contract, holders, blockNum)
+		if err != nil {
+			return err
+		}
+
+		for i, tokenHolder := range holders {
+			balance := new(big.Int).SetBytes(balances[i].AsBytes())
 			if err := p.db.RecordNewERC20Balance(contract, tokenHolder, blockNum, balance); err != nil {
 				return err
 			}
@@ -78,44 +146,281 @@ func (p *ERC20Processor) UpdateBalances(addressesWithChangedBalances map[types.A
 
 // ChangedTokenHolders filters through all events in the transaction and
 // returns a list of all the token holders who have had a balance change
-func (p *ERC20Processor) ChangedTokenHolders(lastFilteredWithAbi map[types.Address]bool, tx *types.Transaction) map[types.Address]map[types.Address]bool {
-	//find all ERC20 transfer events
-	erc20TransferEvents := p.filterForErc20Events(lastFilteredWithAbi, tx.Events)
-
-	//find all senders and recipients for each token
-	return p.filterErc20EventsForAddresses(erc20TransferEvents)
-}
-
-func (p *ERC20Processor) filterErc20EventsForAddresses(erc20TransferEvents []*types.Event) map[types.Address]map[types.Address]bool {
+func (p *ERC20Processor) ChangedTokenHolders(tokenContracts map[types.Address][]balanceEvent, tx *types.Transaction) map[types.Address]map[types.Address]bool {
 	//find all senders and recipients for each token
 	addressesWithChangedBalances := make(map[types.Address]map[types.Address]bool)
 
-	for _, event := range erc20TransferEvents {
-		firstAddressHex := string(event.Topics[1])[24:64]  //only take the last 40 chars (20 bytes)
-		secondAddressHex := string(event.Topics[2])[24:64] //only take the last 40 chars (20 bytes)
+	for _, event := range tx.Events {
+		balanceEvent := p.findBalanceEvent(tokenContracts[event.Address], event)
+		if balanceEvent == nil {
+			continue
+		}
 
 		if addressesWithChangedBalances[event.Address] == nil {
 			addressesWithChangedBalances[event.Address] = make(map[types.Address]bool)
 		}
-
-		addressesWithChangedBalances[event.Address][types.NewAddress(firstAddressHex)] = true
-		addressesWithChangedBalances[event.Address][types.NewAddress(secondAddressHex)] = true
+		for _, topic := range []int{balanceEvent.fromTopic, balanceEvent.toTopic} {
+			if topic != 0 {
+				addressesWithChangedBalances[event.Address][topicAddress(event.Topics[topic])] = true
+			}
+		}
 	}
 
 	return addressesWithChangedBalances
 }
 
-// filterForErc20Events filters out all non-ERC20 transfer events, returning
-// on the events we are interested in processing further
-func (p *ERC20Processor) filterForErc20Events(lastFiltered map[types.Address]bool, events []*types.Event) []*types.Event {
-	erc20TransferEvents := make([]*types.Event, 0, len(events))
-	for _, event := range events {
-		isErc20Transfer := (len(event.Topics) == 3) && (event.Topics[0] == erc20TransferTopicHash)
-		if lastFiltered[event.Address] && isErc20Transfer {
-			erc20TransferEvents = append(erc20TransferEvents, event)
+// BalanceChanges filters through all events in the transaction and returns the
+// amount the balance of each token holder changed by. The zero address, which
+// tokens are minted from and burned to, is left out. Events whose amount cannot
+// be read are ignored.
+func (p *ERC20Processor) BalanceChanges(tokenContracts map[types.Address][]balanceEvent, tx *types.Transaction) map[types.Address]map[types.Address]*big.Int {
+	changes := make(map[types.Address]map[types.Address]*big.Int)
+	change := func(contract types.Address, topic types.Hash, amount *big.Int) {
+		holder := topicAddress(topic)
+		if holder.IsEmpty() {
+			return
+		}
+		if changes[contract] == nil {
+			changes[contract] = make(map[types.Address]*big.Int)
+		}
+		if changes[contract][holder] == nil {
+			changes[contract][holder] = new(big.Int)
+		}
+		changes[contract][holder].Add(changes[contract][holder], amount)
+	}
+
+	for _, event := range tx.Events {
+		balanceEvent := p.findBalanceEvent(tokenContracts[event.Address], event)
+		if balanceEvent == nil {
+			continue
+		}
+
+		data := event.Data.AsBytes()
+		if len(data) < 32*(balanceEvent.amountWord+1) {
+			continue
+		}
+		amount := new(big.Int).SetBytes(data[32*balanceEvent.amountWord : 32*(balanceEvent.amountWord+1)])
+		if balanceEvent.fromTopic != 0 {
+			change(event.Address, event.Topics[balanceEvent.fromTopic], new(big.Int).Neg(amount))
+		}
+		if balanceEvent.toTopic != 0 {
+			change(event.Address, event.Topics[balanceEvent.toTopic], amount)
 		}
 	}
-	return erc20TransferEvents
+
+	return changes
+}
+
+// replayBalances records the balance of each holder the events of the block moved
+// tokens to or from, by adding the amounts moved to their last recorded balance.
+// A holder with no recorded balance starts from their balance from the contract
+// before the block, if the node can still give it, or else from 0. On blocks the
+// balances are reconciled, the replayed balances are checked before any is
+// recorded, so that each holder has a single balance recorded for the block.
+func (p *ERC20Processor) replayBalances(ctx context.Context, tokenContracts map[types.Address][]balanceEvent, block *types.BlockWithTransactions) error {
+	changes := make(map[types.Address]map[types.Address]*big.Int)
+	for _, tx := range block.Transactions {
+		for contract, holders := range p.BalanceChanges(tokenContracts, tx) {
+			if changes[contract] == nil {
+				changes[contract] = holders
+				continue
+			}
+			for holder, amount := range holders {
+				if changes[contract][holder] == nil {
+					changes[contract][holder] = amount
+					continue
+				}
+				changes[contract][holder].Add(changes[contract][holder], amount)
+			}
+		}
+	}
+
+	balances := make(map[types.Address]map[types.Address]*big.Int)
+	for contract, holders := range changes {
+		balances[contract] = make(map[types.Address]*big.Int)
+		var unrecorded []types.Address
+		for holder, amount := range holders {
+			balance, found, err := p.lastBalance(contract, holder, block.Number)
+			if err != nil {
+				return err
+			}
+			if !found {
+				unrecorded = append(unrecorded, holder)
+			}
+			balances[contract][holder] = balance.Add(balance, amount)
+		}
+		if len(unrecorded) > 0 && block.Number > 0 {
+			starting, err := client.CallBalancesOfERC20(ctx, p.client, contract, unrecorded, block.Number-1)
+			if err != nil {
+				// the node may have pruned the state before the block, so take the
+				// balances after it instead of guessing where they started from
+				log.Debug("Fetching starting token balances failed", "contract", contract.Hex(), "block", block.Number-1, "err", err)
+				p.callBalances(ctx, contract, unrecorded, block.Number, balances[contract])
+				continue
+			}
+			for i, holder := range unrecorded {
+				balances[contract][holder].Add(balances[contract][holder], new(big.Int).SetBytes(starting[i].AsBytes()))
+			}
+		}
+	}
+
+	if p.reconcileInterval > 0 && block.Number%p.reconcileInterval == 0 {
+		if err := p.reconcile(ctx, tokenContracts, block.Number, balances); err != nil {
+			return err
+		}
+	}
+
+	for contract, holders := range balances {
+		for holder, balance := range holders {
+			if balance.Sign() < 0 {
+				// the holder had a balance before it was first recorded, or the
+				// token changes balances without events, so leave the balance to
+				// be put right when next reconciled
+				log.Warn("Replayed token balance is negative", "contract", contract.Hex(), "holder", holder.Hex(), "block", block.Number, "balance", balance)
+				metrics.ERC20BalanceDiscrepancies.Inc()
+				continue
+			}
+			if err := p.db.RecordNewERC20Balance(contract, holder, block.Number, balance); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reconcile compares the balance of every holder of the token contracts with their
+// balance from the contract at the block, using the replayed balances of the block
+// where there are any, and the last recorded balance otherwise. Balances that
+// differ from the contract are put into the replayed balances to be recorded.
+// Replayed balances drift from the contract for tokens that change balances
+// without events, such as rebasing tokens, or that move a different amount to the
+// one in the event, such as fee-on-transfer tokens.
+// callBalances sets the balances of the holders at the block from the contract.
+// If that fails too, the holders are left out, to be recorded when next reconciled
+// or when their balance next changes.
+func (p *ERC20Processor) callBalances(ctx context.Context, contract types.Address, holders []types.Address, blockNum uint64, balances map[types.Address]*big.Int) {
+	current, err := client.CallBalancesOfERC20(ctx, p.client, contract, holders, blockNum)
+	if err != nil {
+		log.Warn("Fetching token balances failed, leaving them unrecorded", "contract", contract.Hex(), "block", blockNum, "holders", len(holders), "err", err)
+		for _, holder := range holders {
+			delete(balances, holder)
+		}
+		return
+	}
+	for i, holder := range holders {
+		balances[holder] = new(big.Int).SetBytes(current[i].AsBytes())
+	}
+}
+
+func (p *ERC20Processor) reconcile(ctx context.Context, tokenContracts map[types.Address][]balanceEvent, blockNum uint64, balances map[types.Address]map[types.Address]*big.Int) error {
+	for contract := range tokenContracts {
+		holders, err := p.allHolders(contract, blockNum, balances[contract])
+		if err != nil {
+			return err
+		}
+
+		for start := 0; start < len(holders); start += reconcilePageSize {
+			end := start + reconcilePageSize
+			if end > len(holders) {
+				end = len(holders)
+			}
+			page := holders[start:end]
+
+			actual, err := client.CallBalancesOfERC20(ctx, p.client, contract, page, blockNum)
+			if err != nil {
+				// the node may not have the state of the block, which replaying
+				// events does not need, so carry on without checking
+				log.Warn("Fetching token balances to reconcile failed", "contract", contract.Hex(), "block", blockNum, "err", err)
+				break
+			}
+			for i, holder := range page {
+				replayed, ok := balances[contract][holder]
+				if !ok {
+					if replayed, _, err = p.lastBalance(contract, holder, blockNum); err != nil {
+						return err
+					}
+				}
+				balance := new(big.Int).SetBytes(actual[i].AsBytes())
+				if replayed.Cmp(balance) == 0 {
+					continue
+				}
+				log.Warn("Replayed token balance differs from the contract", "contract", contract.Hex(), "holder", holder.Hex(), "block", blockNum, "replayed", replayed, "balance", balance)
+				metrics.ERC20BalanceDiscrepancies.Inc()
+				if balances[contract] == nil {
+					balances[contract] = make(map[types.Address]*big.Int)
+				}
+				balances[contract][holder] = balance
+			}
+		}
+	}
+	return nil
+}
+
+// allHolders returns the holders recorded for the contract up to the block, along
+// with the holders of the block's replayed balances that have not been recorded
+func (p *ERC20Processor) allHolders(contract types.Address, blockNum uint64, replayed map[types.Address]*big.Int) ([]types.Address, error) {
+	seen := make(map[types.Address]bool)
+	var holders []types.Address
+
+	options := &types.TokenQueryOptions{PageSize: reconcilePageSize}
+	options.SetDefaults()
+	for {
+		page, err := p.db.GetAllTokenHolders(contract, blockNum, options)
+		if err != nil {
+			return nil, err
+		}
+		for _, holder := range page {
+			seen[holder] = true
+		}
+		holders = append(holders, page...)
+		if len(page) < options.PageSize {
+			break
+		}
+		options.After = page[len(page)-1].String()
+	}
+
+	for holder := range replayed {
+		if !seen[holder] {
+			holders = append(holders, holder)
+		}
+	}
+	return holders, nil
+}
+
+// lastBalance returns the last balance recorded for the holder before the block,
+// and whether there was one, the balance being 0 if not
+func (p *ERC20Processor) lastBalance(contract types.Address, holder types.Address, block uint64) (*big.Int, bool, error) {
+	if block == 0 {
+		return new(big.Int), false, nil
+	}
+	previous := new(big.Int).SetUint64(block - 1)
+	options := &types.TokenQueryOptions{BeginBlockNumber: previous, EndBlockNumber: previous}
+	options.SetDefaults()
+
+	balances, err := p.db.GetERC20Balance(contract, holder, options)
+	if err != nil {
+		return nil, false, err
+	}
+	if balance, ok := balances[block-1]; ok {
+		return new(big.Int).Set(balance), true, nil
+	}
+	return new(big.Int), false, nil
+}
+
+// topicAddress reads the address held in an indexed event parameter
+func topicAddress(topic types.Hash) types.Address {
+	return types.NewAddress(string(topic)[24:64]) //only take the last 40 chars (20 bytes)
+}
+
+// findBalanceEvent returns which of the balance events of a contract the event is,
+// or nil if it is none of them
+func (p *ERC20Processor) findBalanceEvent(balanceEvents []balanceEvent, event *types.Event) *balanceEvent {
+	for i, balanceEvent := range balanceEvents {
+		if len(event.Topics) == balanceEvent.topicCount && event.Topics[0] == balanceEvent.topic {
+			return &balanceEvents[i]
+		}
+	}
+	return nil
 }
 
 func isErc20(contractAbi types.ABIStructure) bool {
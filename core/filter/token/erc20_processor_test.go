@@ -1,13 +1,16 @@
 package token
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database/memory"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -27,7 +30,7 @@ func TestERC20Processor_ProcessBlock_NoEventsDoesNothing(t *testing.T) {
 	db := NewFakeTestTokenDatabase(nil)
 	processor := NewERC20Processor(db, nil)
 
-	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
 	assert.Nil(t, err)
 	assert.Len(t, db.RecordedContract, 0)
@@ -55,7 +58,7 @@ func TestERC20Processor_ProcessBlock_NoErc20Events(t *testing.T) {
 	db := NewFakeTestTokenDatabase(nil)
 	processor := NewERC20Processor(db, nil)
 
-	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
 	assert.Nil(t, err)
 	assert.Len(t, db.RecordedContract, 0)
@@ -87,7 +90,7 @@ func TestERC20Processor_ProcessBlock_Erc20EventForNonTrackedAddress(t *testing.T
 	db := NewFakeTestTokenDatabase(nil)
 	processor := NewERC20Processor(db, nil)
 
-	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
 	assert.Nil(t, err)
 	assert.Len(t, db.RecordedContract, 0)
@@ -123,7 +126,7 @@ func TestERC20Processor_ProcessBlock_SingleErc20Event(t *testing.T) {
 	})
 	processor := NewERC20Processor(db, stubClient)
 
-	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
 	assert.Nil(t, err)
 	assert.Contains(t, db.RecordedContract, types.NewAddress("1932c48b2bf8102ba33b4a6b545c32236e342f34"))
@@ -166,7 +169,7 @@ func TestERC20Processor_ProcessBlock_SingleErc20EventOnNonErc20Contract(t *testi
 	})
 	processor := NewERC20Processor(db, stubClient)
 
-	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: `{}`}, block)
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenAddress: `{}`}, block)
 
 	assert.Nil(t, err)
 	assert.Len(t, db.RecordedContract, 0)
@@ -203,7 +206,7 @@ func TestERC20Processor_ProcessBlock_SingleErc20Event_WithClientError(t *testing
 	stubClient := client.NewStubQuorumClient(nil, nil)
 	processor := NewERC20Processor(db, stubClient)
 
-	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
 	assert.EqualError(t, err, "not found")
 	assert.Len(t, db.RecordedContract, 0)
@@ -239,7 +242,7 @@ func TestERC20Processor_ProcessBlock_SingleErc20Event_WithDatabaseError(t *testi
 	})
 	processor := NewERC20Processor(db, stubClient)
 
-	err := processor.ProcessBlock(map[types.Address]string{tokenAddress: erc20AbiString}, block)
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenAddress: erc20AbiString}, block)
 
 	assert.EqualError(t, err, "test error - database")
 	assert.Len(t, db.RecordedContract, 0)
@@ -283,7 +286,7 @@ func TestERC20Processor_ProcessBlock_MultipleErc20Events(t *testing.T) {
 	})
 	processor := NewERC20Processor(db, stubClient)
 
-	err := processor.ProcessBlock(map[types.Address]string{
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{
 		types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34"): erc20AbiString,
 		types.NewAddress("0x02826f2bce5596f49ef29f11de3dce29d6653f8c"): erc20AbiString,
 	}, block)
@@ -303,3 +306,240 @@ func TestERC20Processor_ProcessBlock_MultipleErc20Events(t *testing.T) {
 	assert.EqualValues(t, db.RecordedToken[2], big.NewInt(4660)) //TODO: improve stub client to return different value for second account
 	assert.EqualValues(t, db.RecordedToken[3], big.NewInt(4660)) //TODO: improve stub client to return different value for second account
 }
+
+var (
+	erc20TestContract  = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	erc20TestSender    = types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	erc20TestRecipient = types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+)
+
+func erc20TransferEvent(from, to types.Address, data string) *types.Event {
+	return &types.Event{
+		Address: erc20TestContract,
+		Topics: []types.Hash{
+			erc20TransferTopicHash,
+			types.Hash("000000000000000000000000" + string(from)),
+			types.Hash("000000000000000000000000" + string(to)),
+		},
+		Data: types.NewHexData(data),
+	}
+}
+
+func erc20BalanceAt(t *testing.T, db *memory.MemoryDB, holder types.Address, block uint64) string {
+	number := new(big.Int).SetUint64(block)
+	options := &types.TokenQueryOptions{BeginBlockNumber: number, EndBlockNumber: number}
+	options.SetDefaults()
+	balances, err := db.GetERC20Balance(erc20TestContract, holder, options)
+	assert.Nil(t, err)
+	return balances[block].String()
+}
+
+func TestERC20Processor_BalanceChanges(t *testing.T) {
+	processor := NewEventERC20Processor(nil, nil, 0)
+	contracts := map[types.Address][]balanceEvent{erc20TestContract: erc20BalanceEvents}
+
+	tx := &types.Transaction{
+		Events: []*types.Event{
+			erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x00000000000000000000000000000000000000000000000000000000000003e8"),
+			erc20TransferEvent(erc20TestRecipient, erc20TestSender, "0x0000000000000000000000000000000000000000000000000000000000000064"),
+			// the zero address tokens are minted from is left out
+			erc20TransferEvent(types.NewAddress(""), erc20TestSender, "0x0000000000000000000000000000000000000000000000000000000000000005"),
+			// events with no amount are ignored
+			erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x"),
+		},
+	}
+
+	changes := processor.BalanceChanges(contracts, tx)
+	assert.Len(t, changes, 1)
+	assert.Len(t, changes[erc20TestContract], 2)
+	assert.Equal(t, "-895", changes[erc20TestContract][erc20TestSender].String())
+	assert.Equal(t, "900", changes[erc20TestContract][erc20TestRecipient].String())
+
+	// events of contracts that are not tokens are not followed
+	assert.Len(t, processor.BalanceChanges(map[types.Address][]balanceEvent{}, tx), 0)
+}
+
+func TestERC20Processor_ProcessBlock_ReplaysEvents(t *testing.T) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(erc20TestContract, erc20TestSender, 1, big.NewInt(1500)))
+	block := &types.BlockWithTransactions{
+		Number: 3,
+		Transactions: []*types.Transaction{
+			{Events: []*types.Event{erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x00000000000000000000000000000000000000000000000000000000000003e8")}},
+			{Events: []*types.Event{erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x0000000000000000000000000000000000000000000000000000000000000064")}},
+		},
+	}
+
+	// only the recipient, who has no balance recorded, is called for, to find
+	// the balance they start from before the block
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x2": types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000064"),
+	})
+	processor := NewEventERC20Processor(db, stubClient, 0)
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{erc20TestContract: erc20AbiString}, block)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "400", erc20BalanceAt(t, db, erc20TestSender, 3))
+	assert.Equal(t, "1200", erc20BalanceAt(t, db, erc20TestRecipient, 3))
+	assert.Equal(t, "1500", erc20BalanceAt(t, db, erc20TestSender, 2))
+}
+
+func TestERC20Processor_ProcessBlock_ReplayCallsBalancesWithoutStartingState(t *testing.T) {
+	db := memory.NewMemoryDB()
+	block := &types.BlockWithTransactions{
+		Number: 3,
+		Transactions: []*types.Transaction{
+			{Events: []*types.Event{erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x00000000000000000000000000000000000000000000000000000000000003e8")}},
+		},
+	}
+	// the node has pruned the state of the block before, so the balances after
+	// the block are called for instead
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x3": types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000005dc"),
+	})
+	processor := NewEventERC20Processor(db, stubClient, 0)
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{erc20TestContract: erc20AbiString}, block)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1500", erc20BalanceAt(t, db, erc20TestSender, 3))
+	assert.Equal(t, "1500", erc20BalanceAt(t, db, erc20TestRecipient, 3))
+}
+
+func TestERC20Processor_ProcessBlock_ReplaySkipsUnknownBalances(t *testing.T) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(erc20TestContract, erc20TestSender, 1, big.NewInt(1500)))
+	block := &types.BlockWithTransactions{
+		Number: 3,
+		Transactions: []*types.Transaction{
+			{Events: []*types.Event{erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x00000000000000000000000000000000000000000000000000000000000003e8")}},
+		},
+	}
+	// no balance can be called for, so the recipient's balance is not known
+	processor := NewEventERC20Processor(db, client.NewStubQuorumClient(nil, nil), 0)
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{erc20TestContract: erc20AbiString}, block)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "500", erc20BalanceAt(t, db, erc20TestSender, 3))
+	holders, err := db.GetAllTokenHolders(erc20TestContract, 3, &types.TokenQueryOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{erc20TestSender}, holders)
+}
+
+func TestERC20Processor_ProcessBlock_ReconcilesReplayedBalances(t *testing.T) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(erc20TestContract, erc20TestSender, 1, big.NewInt(1500)))
+	block := &types.BlockWithTransactions{
+		Number: 4,
+		Transactions: []*types.Transaction{
+			{Events: []*types.Event{erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x00000000000000000000000000000000000000000000000000000000000003e8")}},
+		},
+	}
+	// the contract gives a balance of 1000 to every holder
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x4": types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+	})
+	processor := NewEventERC20Processor(db, stubClient, 2)
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{erc20TestContract: erc20AbiString}, block)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1000", erc20BalanceAt(t, db, erc20TestSender, 4))
+	assert.Equal(t, "1000", erc20BalanceAt(t, db, erc20TestRecipient, 4))
+}
+
+// uniqueBalanceDatabase rejects a second balance for the same holder at the same
+// block, as the Elasticsearch database does
+type uniqueBalanceDatabase struct {
+	*memory.MemoryDB
+	recorded map[string]bool
+}
+
+func (db *uniqueBalanceDatabase) RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	id := fmt.Sprintf("%s-%s-%d", contract.String(), holder.String(), block)
+	if db.recorded[id] {
+		return errors.New("balance already recorded: " + id)
+	}
+	db.recorded[id] = true
+	return db.MemoryDB.RecordNewERC20Balance(contract, holder, block, amount)
+}
+
+func TestERC20Processor_ProcessBlock_ReconcilesBeforeRecording(t *testing.T) {
+	db := &uniqueBalanceDatabase{MemoryDB: memory.NewMemoryDB(), recorded: make(map[string]bool)}
+	other := types.NewAddress("0x0000000000000000000000000000000000000009")
+	assert.Nil(t, db.RecordNewERC20Balance(erc20TestContract, erc20TestSender, 1, big.NewInt(1500)))
+	assert.Nil(t, db.RecordNewERC20Balance(erc20TestContract, other, 1, big.NewInt(1000)))
+	block := &types.BlockWithTransactions{
+		Number: 4,
+		Transactions: []*types.Transaction{
+			{Events: []*types.Event{erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x00000000000000000000000000000000000000000000000000000000000003e8")}},
+		},
+	}
+	// the contract gives a balance of 1000 to every holder, at every block
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x3": types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+		"eth_call<types.EIP165Call Value>0x4": types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+	})
+	processor := NewEventERC20Processor(db, stubClient, 2)
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{erc20TestContract: erc20AbiString}, block)
+
+	// the sender both moved tokens and is reconciled, and has a single balance recorded
+	assert.Nil(t, err)
+	assert.Equal(t, "1000", erc20BalanceAt(t, db.MemoryDB, erc20TestSender, 4))
+	assert.Equal(t, "1000", erc20BalanceAt(t, db.MemoryDB, erc20TestRecipient, 4))
+	assert.Len(t, db.recorded, 4)
+}
+
+func TestERC20Processor_ProcessBlock_ReconcileCallFailureKeepsReplayedBalances(t *testing.T) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.RecordNewERC20Balance(erc20TestContract, erc20TestSender, 1, big.NewInt(1500)))
+	block := &types.BlockWithTransactions{
+		Number: 4,
+		Transactions: []*types.Transaction{
+			{Events: []*types.Event{erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x00000000000000000000000000000000000000000000000000000000000003e8")}},
+		},
+	}
+	// the recipient starts from nothing, but the balances to reconcile against cannot be fetched
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x3": types.NewHexData("0x0000000000000000000000000000000000000000000000000000000000000000"),
+	})
+	processor := NewEventERC20Processor(db, stubClient, 2)
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{erc20TestContract: erc20AbiString}, block)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "500", erc20BalanceAt(t, db, erc20TestSender, 4))
+	assert.Equal(t, "1000", erc20BalanceAt(t, db, erc20TestRecipient, 4))
+}
+
+func TestERC20Processor_ProcessBlock_ReplayDatabaseError(t *testing.T) {
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{Events: []*types.Event{erc20TransferEvent(erc20TestSender, erc20TestRecipient, "0x00000000000000000000000000000000000000000000000000000000000003e8")}},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(errors.New("test error"))
+	processor := NewEventERC20Processor(db, nil, 0)
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{erc20TestContract: erc20AbiString}, block)
+
+	assert.EqualError(t, err, "test error")
+}
+
+func TestERC20Processor_FilterForTokenContracts_ReplayFollowsTransfersOnly(t *testing.T) {
+	// an ERC777 token that is also ERC20 compatible
+	compatibleAbi := erc20AbiString[:len(erc20AbiString)-1] + "," + erc777AbiString[1:]
+	contracts := map[types.Address]string{erc20TestContract: compatibleAbi, erc20TestSender: erc777AbiString}
+
+	tokenContracts := NewERC20Processor(nil, nil).filterForTokenContracts(contracts)
+	assert.Len(t, tokenContracts[erc20TestContract], len(erc20BalanceEvents)+len(erc777BalanceEvents))
+
+	tokenContracts = NewEventERC20Processor(nil, nil, 0).filterForTokenContracts(contracts)
+	assert.Equal(t, erc20BalanceEvents, tokenContracts[erc20TestContract])
+	assert.Equal(t, erc777BalanceEvents, tokenContracts[erc20TestSender])
+}
@@ -0,0 +1,102 @@
+package token
+
+import (
+	"context"
+	"math/big"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+const erc4626AbiString = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"sender","type":"address"},{"indexed":true,"internalType":"address","name":"owner","type":"address"},{"indexed":false,"internalType":"uint256","name":"assets","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"shares","type":"uint256"}],"name":"Deposit","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"sender","type":"address"},{"indexed":true,"internalType":"address","name":"receiver","type":"address"},{"indexed":true,"internalType":"address","name":"owner","type":"address"},{"indexed":false,"internalType":"uint256","name":"assets","type":"uint256"},{"indexed":false,"internalType":"uint256","name":"shares","type":"uint256"}],"name":"Withdraw","type":"event"},{"inputs":[],"name":"asset","outputs":[{"internalType":"address","name":"assetTokenAddress","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"account","type":"address"}],"name":"balanceOf","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"shares","type":"uint256"}],"name":"convertToAssets","outputs":[{"internalType":"uint256","name":"assets","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"assets","type":"uint256"}],"name":"convertToShares","outputs":[{"internalType":"uint256","name":"shares","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"assets","type":"uint256"},{"internalType":"address","name":"receiver","type":"address"}],"name":"deposit","outputs":[{"internalType":"uint256","name":"shares","type":"uint256"}],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"receiver","type":"address"}],"name":"maxDeposit","outputs":[{"internalType":"uint256","name":"maxAssets","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"receiver","type":"address"}],"name":"maxMint","outputs":[{"internalType":"uint256","name":"maxShares","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"owner","type":"address"}],"name":"maxRedeem","outputs":[{"internalType":"uint256","name":"maxShares","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"owner","type":"address"}],"name":"maxWithdraw","outputs":[{"internalType":"uint256","name":"maxAssets","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"shares","type":"uint256"},{"internalType":"address","name":"receiver","type":"address"}],"name":"mint","outputs":[{"internalType":"uint256","name":"assets","type":"uint256"}],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"uint256","name":"assets","type":"uint256"}],"name":"previewDeposit","outputs":[{"internalType":"uint256","name":"shares","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"shares","type":"uint256"}],"name":"previewMint","outputs":[{"internalType":"uint256","name":"assets","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"shares","type":"uint256"}],"name":"previewRedeem","outputs":[{"internalType":"uint256","name":"assets","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"assets","type":"uint256"}],"name":"previewWithdraw","outputs":[{"internalType":"uint256","name":"shares","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"shares","type":"uint256"},{"internalType":"address","name":"receiver","type":"address"},{"internalType":"address","name":"owner","type":"address"}],"name":"redeem","outputs":[{"internalType":"uint256","name":"assets","type":"uint256"}],"stateMutability":"nonpayable","type":"function"},{"inputs":[],"name":"totalAssets","outputs":[{"internalType":"uint256","name":"totalManagedAssets","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"totalSupply","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"assets","type":"uint256"},{"internalType":"address","name":"receiver","type":"address"},{"internalType":"address","name":"owner","type":"address"}],"name":"withdraw","outputs":[{"internalType":"uint256","name":"shares","type":"uint256"}],"stateMutability":"nonpayable","type":"function"}]`
+
+var (
+	// erc4626DepositTopicHash is the topic hash for an ERC4626 Deposit event
+	erc4626DepositTopicHash = types.NewHash("0xdcbc1c05240f31ff3ad067ef1ee35ce4997762752e3a095284754544f4c709d7")
+	// erc4626WithdrawTopicHash is the topic hash for an ERC4626 Withdraw event
+	erc4626WithdrawTopicHash = types.NewHash("0xfbde797d201c681b91056529119e0b02407c7bb96a4a2c75c01fc9667232c8db")
+	erc4626Abi, _            = types.NewABIStructureFromJSON(erc4626AbiString)
+
+	// the holder is the owner of the shares minted or burned, rather than the
+	// account that sent or received the assets. The amount of shares follows the
+	// amount of assets in the non-indexed parameters.
+	erc4626BalanceEvents = []balanceEvent{
+		{topic: erc4626DepositTopicHash, topicCount: 3, toTopic: 2, amountWord: 1},
+		{topic: erc4626WithdrawTopicHash, topicCount: 4, fromTopic: 3, amountWord: 1},
+	}
+)
+
+// ERC4626Processor records the total assets held by each ERC4626 vault and the
+// total supply of its shares at every block, so that shares can be converted to
+// assets at any block. The assets of a vault can grow without any event being
+// emitted, so the totals are fetched whether or not the block changed them.
+// Balances of vault shares are recorded by the ERC20Processor.
+type ERC4626Processor struct {
+	db     TokenFilterDatabase
+	client client.Client
+}
+
+func NewERC4626Processor(database TokenFilterDatabase, client client.Client) *ERC4626Processor {
+	return &ERC4626Processor{db: database, client: client}
+}
+
+func (p *ERC4626Processor) ProcessBlock(ctx context.Context, lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+	vaults := p.filterForErc4626Contracts(lastFilteredWithAbi)
+	if len(vaults) == 0 {
+		return nil
+	}
+
+	totalAssets, totalSupplies, err := client.CallTotalsOfERC4626(ctx, p.client, vaults, block.Number)
+	if err != nil {
+		return err
+	}
+	for i, vault := range vaults {
+		assets := new(big.Int).SetBytes(totalAssets[i].AsBytes())
+		supply := new(big.Int).SetBytes(totalSupplies[i].AsBytes())
+		if err := p.db.RecordERC4626Assets(vault, block.Number, assets, supply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ERC4626Processor) filterForErc4626Contracts(contractsWithAbi map[types.Address]string) []types.Address {
+	vaults := make([]types.Address, 0)
+
+	for address, abi := range contractsWithAbi {
+		contractAbi, _ := types.NewABIStructureFromJSON(abi)
+		if isErc4626(contractAbi) {
+			vaults = append(vaults, address)
+		}
+	}
+
+	return vaults
+}
+
+func isErc4626(contractAbi types.ABIStructure) bool {
+	for _, erc4626Event := range erc4626Abi.ToInternalABI().Events {
+		found := false
+		for _, contractEvent := range contractAbi.ToInternalABI().Events {
+			if erc4626Event.Signature() == contractEvent.Signature() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, erc4626Method := range erc4626Abi.ToInternalABI().Functions {
+		found := false
+		for _, contractMethod := range contractAbi.ToInternalABI().Functions {
+			if erc4626Method.Signature() == contractMethod.Signature() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
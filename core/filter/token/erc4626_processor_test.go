@@ -0,0 +1,107 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+// erc4626EventData holds the assets and shares of a deposit or withdrawal.
+const erc4626EventData = "0x00000000000000000000000000000000000000000000000000000000000003e8" +
+	"00000000000000000000000000000000000000000000000000000000000001f4"
+
+func TestERC4626Processor_TopicHashes(t *testing.T) {
+	assertEventTopics(t, erc4626Abi, erc4626DepositTopicHash, erc4626WithdrawTopicHash)
+
+	selectors := make(map[string]bool)
+	for _, function := range erc4626Abi.ToInternalABI().Functions {
+		selectors[function.Signature()] = true
+	}
+	// the selectors called by client.CallTotalsOfERC4626
+	assert.True(t, selectors["01e1d114"])
+	assert.True(t, selectors["18160ddd"])
+
+	assert.True(t, isErc4626(erc4626Abi))
+	assert.False(t, isErc4626(erc20Abi))
+}
+
+func TestERC4626Processor_ProcessBlock(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+	}
+	// the totals are recorded even if the block has no events of the vault
+	block := &types.BlockWithTransactions{Number: 1}
+	contracts := map[types.Address]string{
+		tokenTestContract: erc4626AbiString,
+		types.NewAddress("0x0000000000000000000000000000000000000001"): erc20AbiString,
+	}
+
+	db := NewFakeTestTokenDatabase(nil)
+	processor := NewERC4626Processor(db, client.NewStubQuorumClient(nil, mockRPC))
+
+	err := processor.ProcessBlock(context.Background(), contracts, block)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{tokenTestContract}, db.RecordedContract)
+	assert.EqualValues(t, 1, db.RecordedBlock)
+	assert.Equal(t, "1000", db.RecordedTotalAssets[0].String())
+	assert.Equal(t, "1000", db.RecordedTotalSupply[0].String())
+}
+
+func TestERC4626Processor_ProcessBlock_NoVaultsDoesNothing(t *testing.T) {
+	db := NewFakeTestTokenDatabase(nil)
+	processor := NewERC4626Processor(db, client.NewStubQuorumClient(nil, nil))
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenTestContract: erc20AbiString}, &types.BlockWithTransactions{Number: 1})
+
+	assert.Nil(t, err)
+	assert.Len(t, db.RecordedContract, 0)
+}
+
+func TestERC4626Processor_ProcessBlock_DatabaseError(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x00000000000000000000000000000000000000000000000000000000000003e8"),
+	}
+
+	db := NewFakeTestTokenDatabase(errors.New("test error"))
+	processor := NewERC4626Processor(db, client.NewStubQuorumClient(nil, mockRPC))
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenTestContract: erc4626AbiString}, &types.BlockWithTransactions{Number: 1})
+
+	assert.EqualError(t, err, "test error")
+}
+
+func TestERC20Processor_ProcessBlock_Erc4626Events(t *testing.T) {
+	owner := tokenTestRecipient
+	receiver := types.NewAddress("0x0000000000000000000000000000000000000007")
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{
+				Events: []*types.Event{
+					// the shares are minted to the owner, not the sender of the assets
+					tokenTestEvent(erc4626DepositTopicHash, erc4626EventData, tokenTestSender, owner),
+					// the shares are burned from the owner, not the receiver of the assets
+					tokenTestEvent(erc4626WithdrawTopicHash, erc4626EventData, tokenTestSender, receiver, owner),
+				},
+			},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(nil)
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x12345"),
+	})
+	processor := NewERC20Processor(db, stubClient)
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenTestContract: erc4626AbiString}, block)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{owner}, db.RecordedHolder)
+	assert.Equal(t, []types.Address{tokenTestContract}, db.RecordedContract)
+}
@@ -0,0 +1,140 @@
+package token
+
+import "quorumengineering/quorum-report/types"
+
+const erc777AbiString = `[{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"operator","type":"address"},{"indexed":true,"internalType":"address","name":"tokenHolder","type":"address"}],"name":"AuthorizedOperator","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"operator","type":"address"},{"indexed":true,"internalType":"address","name":"from","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount","type":"uint256"},{"indexed":false,"internalType":"bytes","name":"data","type":"bytes"},{"indexed":false,"internalType":"bytes","name":"operatorData","type":"bytes"}],"name":"Burned","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"operator","type":"address"},{"indexed":true,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount","type":"uint256"},{"indexed":false,"internalType":"bytes","name":"data","type":"bytes"},{"indexed":false,"internalType":"bytes","name":"operatorData","type":"bytes"}],"name":"Minted","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"operator","type":"address"},{"indexed":true,"internalType":"address","name":"tokenHolder","type":"address"}],"name":"RevokedOperator","type":"event"},{"anonymous":false,"inputs":[{"indexed":true,"internalType":"address","name":"operator","type":"address"},{"indexed":true,"internalType":"address","name":"from","type":"address"},{"indexed":true,"internalType":"address","name":"to","type":"address"},{"indexed":false,"internalType":"uint256","name":"amount","type":"uint256"},{"indexed":false,"internalType":"bytes","name":"data","type":"bytes"},{"indexed":false,"internalType":"bytes","name":"operatorData","type":"bytes"}],"name":"Sent","type":"event"},{"inputs":[{"internalType":"address","name":"operator","type":"address"}],"name":"authorizeOperator","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"amount","type":"uint256"},{"internalType":"bytes","name":"data","type":"bytes"}],"name":"burn","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[],"name":"defaultOperators","outputs":[{"internalType":"address[]","name":"","type":"address[]"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"granularity","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"operator","type":"address"},{"internalType":"address","name":"tokenHolder","type":"address"}],"name":"isOperatorFor","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"name","outputs":[{"internalType":"string","name":"","type":"string"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"address","name":"account","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"},{"internalType":"bytes","name":"data","type":"bytes"},{"internalType":"bytes","name":"operatorData","type":"bytes"}],"name":"operatorBurn","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"sender","type":"address"},{"internalType":"address","name":"recipient","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"},{"internalType":"bytes","name":"data","type":"bytes"},{"internalType":"bytes","name":"operatorData","type":"bytes"}],"name":"operatorSend","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"operator","type":"address"}],"name":"revokeOperator","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"internalType":"address","name":"recipient","type":"address"},{"internalType":"uint256","name":"amount","type":"uint256"},{"internalType":"bytes","name":"data","type":"bytes"}],"name":"send","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[],"name":"symbol","outputs":[{"internalType":"string","name":"","type":"string"}],"stateMutability":"view","type":"function"},{"inputs":[],"name":"totalSupply","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
+
+var (
+	// erc777SentTopicHash is the topic hash for an ERC777 Sent event
+	erc777SentTopicHash = types.NewHash("0x06b541ddaa720db2b10a4d0cdac39b8d360425fc073085fac19bc82614677987")
+	// erc777MintedTopicHash is the topic hash for an ERC777 Minted event
+	erc777MintedTopicHash = types.NewHash("0x2fe5be0146f74c5bce36c0b80911af6c7d86ff27e89d5cfa61fc681327954e5d")
+	// erc777BurnedTopicHash is the topic hash for an ERC777 Burned event
+	erc777BurnedTopicHash = types.NewHash("0xa78a9be3a7b862d26933ad85fb11d80ef66b8f972d7cbba06621d583943a4098")
+	// erc777AuthorizedOperatorTopicHash is the topic hash for an ERC777 AuthorizedOperator event
+	erc777AuthorizedOperatorTopicHash = types.NewHash("0xf4caeb2d6ca8932a215a353d0703c326ec2d81fc68170f320eb2ab49e9df61f9")
+	// erc777RevokedOperatorTopicHash is the topic hash for an ERC777 RevokedOperator event
+	erc777RevokedOperatorTopicHash = types.NewHash("0x50546e66e5f44d728365dc3908c63bc5cfeeab470722c1677e3073a6ac294aa1")
+	erc777Abi, _                   = types.NewABIStructureFromJSON(erc777AbiString)
+
+	// the holders are the sender and recipient of a Sent event, and the account
+	// minted to or burned from, rather than the operator. The amount is the first
+	// non-indexed parameter of each.
+	erc777BalanceEvents = []balanceEvent{
+		{topic: erc777SentTopicHash, topicCount: 4, fromTopic: 2, toTopic: 3, amountWord: 0},
+		{topic: erc777MintedTopicHash, topicCount: 3, toTopic: 2, amountWord: 0},
+		{topic: erc777BurnedTopicHash, topicCount: 3, fromTopic: 2, amountWord: 0},
+	}
+)
+
+// ERC777Operator is an operator a holder of an ERC777 token has authorized
+type ERC777Operator struct {
+	Holder   types.Address
+	Operator types.Address
+}
+
+// ERC777Processor records the operators that holders of ERC777 tokens authorize
+// and revoke. Balances of ERC777 tokens are recorded by the ERC20Processor.
+// Default operators are set when the contract is deployed and are not tracked.
+type ERC777Processor struct {
+	db TokenFilterDatabase
+}
+
+func NewERC777Processor(database TokenFilterDatabase) *ERC777Processor {
+	return &ERC777Processor{db: database}
+}
+
+func (p *ERC777Processor) ProcessBlock(lastFilteredWithAbi map[types.Address]string, block *types.BlockWithTransactions) error {
+	erc777Contracts := p.filterForErc777Contracts(lastFilteredWithAbi)
+
+	// only the last change in the block to each operator is recorded
+	changedOperators := make(map[types.Address]map[ERC777Operator]bool)
+	for _, tx := range block.Transactions {
+		for contract, operators := range p.ChangedOperators(erc777Contracts, tx) {
+			if changedOperators[contract] == nil {
+				changedOperators[contract] = operators
+				continue
+			}
+			for operator, authorized := range operators {
+				changedOperators[contract][operator] = authorized
+			}
+		}
+	}
+
+	for contract, operators := range changedOperators {
+		for operator, authorized := range operators {
+			if err := p.db.RecordERC777Operator(contract, operator.Holder, operator.Operator, block.Number, authorized); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ChangedOperators returns the operators authorized or revoked in the transaction,
+// along with whether each was last authorized
+func (p *ERC777Processor) ChangedOperators(erc777Contracts map[types.Address]bool, tx *types.Transaction) map[types.Address]map[ERC777Operator]bool {
+	changedOperators := make(map[types.Address]map[ERC777Operator]bool)
+
+	for _, event := range tx.Events {
+		if !erc777Contracts[event.Address] || len(event.Topics) != 3 {
+			continue
+		}
+		authorized := event.Topics[0] == erc777AuthorizedOperatorTopicHash
+		if !authorized && event.Topics[0] != erc777RevokedOperatorTopicHash {
+			continue
+		}
+
+		if changedOperators[event.Address] == nil {
+			changedOperators[event.Address] = make(map[ERC777Operator]bool)
+		}
+		operator := ERC777Operator{
+			Operator: types.NewAddress(string(event.Topics[1])[24:64]),
+			Holder:   types.NewAddress(string(event.Topics[2])[24:64]),
+		}
+		changedOperators[event.Address][operator] = authorized
+	}
+
+	return changedOperators
+}
+
+func (p *ERC777Processor) filterForErc777Contracts(contractsWithAbi map[types.Address]string) map[types.Address]bool {
+	erc777Contracts := make(map[types.Address]bool)
+
+	for address, abi := range contractsWithAbi {
+		contractAbi, _ := types.NewABIStructureFromJSON(abi)
+		if isErc777(contractAbi) {
+			erc777Contracts[address] = true
+		}
+	}
+
+	return erc777Contracts
+}
+
+func isErc777(contractAbi types.ABIStructure) bool {
+	for _, erc777Event := range erc777Abi.ToInternalABI().Events {
+		found := false
+		for _, contractEvent := range contractAbi.ToInternalABI().Events {
+			if erc777Event.Signature() == contractEvent.Signature() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, erc777Method := range erc777Abi.ToInternalABI().Functions {
+		found := false
+		for _, contractMethod := range contractAbi.ToInternalABI().Functions {
+			if erc777Method.Signature() == contractMethod.Signature() {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
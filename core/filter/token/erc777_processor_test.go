@@ -0,0 +1,119 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestERC777Processor_TopicHashes(t *testing.T) {
+	assertEventTopics(t, erc777Abi, erc777SentTopicHash, erc777MintedTopicHash, erc777BurnedTopicHash,
+		erc777AuthorizedOperatorTopicHash, erc777RevokedOperatorTopicHash)
+	assert.True(t, isErc777(erc777Abi))
+	assert.False(t, isErc777(erc20Abi))
+}
+
+func TestERC777Processor_ChangedOperators(t *testing.T) {
+	processor := NewERC777Processor(nil)
+	contracts := map[types.Address]bool{tokenTestContract: true}
+
+	tx := &types.Transaction{
+		Events: []*types.Event{
+			tokenTestEvent(erc777AuthorizedOperatorTopicHash, "0x", tokenTestOperator, tokenTestSender),
+			tokenTestEvent(erc777AuthorizedOperatorTopicHash, "0x", tokenTestOperator, tokenTestRecipient),
+			// the last change to an operator in the transaction wins
+			tokenTestEvent(erc777RevokedOperatorTopicHash, "0x", tokenTestOperator, tokenTestRecipient),
+			// balance changes are not operator changes
+			tokenTestEvent(erc777MintedTopicHash, "0x", tokenTestOperator, tokenTestSender),
+		},
+	}
+
+	expected := map[types.Address]map[ERC777Operator]bool{
+		tokenTestContract: {
+			{Holder: tokenTestSender, Operator: tokenTestOperator}:    true,
+			{Holder: tokenTestRecipient, Operator: tokenTestOperator}: false,
+		},
+	}
+	assert.Equal(t, expected, processor.ChangedOperators(contracts, tx))
+
+	// events of contracts that are not ERC777 are not followed
+	assert.Len(t, processor.ChangedOperators(map[types.Address]bool{}, tx), 0)
+}
+
+func TestERC777Processor_ProcessBlock(t *testing.T) {
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{Events: []*types.Event{tokenTestEvent(erc777RevokedOperatorTopicHash, "0x", tokenTestOperator, tokenTestSender)}},
+			{Events: []*types.Event{tokenTestEvent(erc777AuthorizedOperatorTopicHash, "0x", tokenTestOperator, tokenTestSender)}},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(nil)
+	processor := NewERC777Processor(db)
+
+	err := processor.ProcessBlock(map[types.Address]string{tokenTestContract: erc777AbiString}, block)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{tokenTestContract}, db.RecordedContract)
+	assert.Equal(t, []types.Address{tokenTestSender}, db.RecordedHolder)
+	assert.Equal(t, []types.Address{tokenTestOperator}, db.RecordedOperator)
+	assert.Equal(t, []bool{true}, db.RecordedAuthorized)
+	assert.EqualValues(t, 1, db.RecordedBlock)
+}
+
+func TestERC777Processor_ProcessBlock_DatabaseError(t *testing.T) {
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{Events: []*types.Event{tokenTestEvent(erc777AuthorizedOperatorTopicHash, "0x", tokenTestOperator, tokenTestSender)}},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(errors.New("test error"))
+	processor := NewERC777Processor(db)
+
+	err := processor.ProcessBlock(map[types.Address]string{tokenTestContract: erc777AbiString}, block)
+
+	assert.EqualError(t, err, "test error")
+}
+
+func TestERC20Processor_ProcessBlock_Erc777Events(t *testing.T) {
+	minted := types.NewAddress("0x0000000000000000000000000000000000000007")
+	burned := types.NewAddress("0x0000000000000000000000000000000000000008")
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{
+				Events: []*types.Event{
+					tokenTestEvent(erc777SentTopicHash, "0x", tokenTestOperator, tokenTestSender, tokenTestRecipient),
+					tokenTestEvent(erc777MintedTopicHash, "0x", tokenTestOperator, minted),
+					tokenTestEvent(erc777BurnedTopicHash, "0x", tokenTestOperator, burned),
+					// operator changes do not change balances
+					tokenTestEvent(erc777AuthorizedOperatorTopicHash, "0x", tokenTestOperator, tokenTestSender),
+				},
+			},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(nil)
+	stubClient := client.NewStubQuorumClient(nil, map[string]interface{}{
+		"eth_call<types.EIP165Call Value>0x1": types.NewHexData("0x12345"),
+	})
+	processor := NewERC20Processor(db, stubClient)
+
+	err := processor.ProcessBlock(context.Background(), map[types.Address]string{tokenTestContract: erc777AbiString}, block)
+
+	assert.Nil(t, err)
+	// the operator's balance is not changed by sending, minting or burning
+	assert.ElementsMatch(t, []types.Address{tokenTestSender, tokenTestRecipient, minted, burned}, db.RecordedHolder)
+	for i := range db.RecordedHolder {
+		assert.Equal(t, tokenTestContract, db.RecordedContract[i])
+		assert.Equal(t, "4660", db.RecordedToken[i].String())
+	}
+}
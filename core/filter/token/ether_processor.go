@@ -0,0 +1,92 @@
+package token
+
+import (
+	"context"
+	"math/big"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+// EtherProcessor records the native ether balances of registered addresses, and of
+// the accounts they transact with, whenever a transaction may have changed them.
+// Balances are recorded under types.EtherContract, and are fetched from the node
+// rather than worked out from the transfers, so that gas fees are accounted for.
+type EtherProcessor struct {
+	db     TokenFilterDatabase
+	client client.Client
+}
+
+func NewEtherProcessor(database TokenFilterDatabase, client client.Client) *EtherProcessor {
+	return &EtherProcessor{db: database, client: client}
+}
+
+func (p *EtherProcessor) ProcessBlock(ctx context.Context, addresses []types.Address, block *types.BlockWithTransactions) error {
+	registered := make(map[types.Address]bool, len(addresses))
+	for _, address := range addresses {
+		registered[address] = true
+	}
+
+	changed := make(map[types.Address]bool)
+	for _, tx := range block.Transactions {
+		for holder := range p.ChangedHolders(registered, tx) {
+			changed[holder] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	holders := make([]types.Address, 0, len(changed))
+	for holder := range changed {
+		holders = append(holders, holder)
+	}
+	balances, err := client.GetBalances(ctx, p.client, holders, block.Number)
+	if err != nil {
+		return err
+	}
+	for i, holder := range holders {
+		if err := p.db.RecordNewERC20Balance(types.EtherContract, holder, block.Number, balances[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangedHolders returns the accounts whose ether balance the transaction may have
+// changed, out of those it transferred ether between where either side is a
+// registered address. The sender of a transaction to or from a registered address
+// is always included, as it pays for the gas.
+func (p *EtherProcessor) ChangedHolders(registered map[types.Address]bool, tx *types.Transaction) map[types.Address]bool {
+	holders := make(map[types.Address]bool)
+	record := func(from, to types.Address, value *big.Int, payer bool) {
+		if !registered[from] && !registered[to] {
+			return
+		}
+		if payer || value.Sign() > 0 {
+			holders[from] = true
+		}
+		if value.Sign() > 0 && !to.IsEmpty() {
+			holders[to] = true
+		}
+	}
+
+	to := tx.To
+	if to.IsEmpty() {
+		to = tx.CreatedContract
+	}
+	record(tx.From, to, valueOf(tx.Value), true)
+	for _, call := range tx.InternalCalls {
+		if call.Error == "" {
+			record(call.From, call.To, valueOf(call.Value), false)
+		}
+	}
+	return holders
+}
+
+func valueOf(value *big.Int) *big.Int {
+	if value == nil {
+		return new(big.Int)
+	}
+	return value
+}
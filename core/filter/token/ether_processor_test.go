@@ -0,0 +1,115 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	etherTestRegistered = types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	etherTestSender     = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	etherTestRecipient  = types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+)
+
+func TestEtherProcessor_ChangedHolders(t *testing.T) {
+	registered := map[types.Address]bool{etherTestRegistered: true}
+	processor := NewEtherProcessor(nil, nil)
+
+	// the sender pays for gas, even when sending no ether
+	tx := &types.Transaction{From: etherTestSender, To: etherTestRegistered, Value: big.NewInt(0)}
+	assert.Equal(t, map[types.Address]bool{etherTestSender: true}, processor.ChangedHolders(registered, tx))
+
+	tx = &types.Transaction{
+		From:  etherTestSender,
+		To:    etherTestRegistered,
+		Value: big.NewInt(10),
+		InternalCalls: []*types.InternalCall{
+			{From: etherTestRegistered, To: etherTestRecipient, Value: big.NewInt(5)},
+			// failed calls transfer nothing
+			{From: etherTestRegistered, To: types.NewAddress("1"), Value: big.NewInt(5), Error: "execution reverted"},
+			// transfers between unregistered addresses are not followed
+			{From: etherTestRecipient, To: types.NewAddress("2"), Value: big.NewInt(5)},
+		},
+	}
+	expected := map[types.Address]bool{etherTestSender: true, etherTestRegistered: true, etherTestRecipient: true}
+	assert.Equal(t, expected, processor.ChangedHolders(registered, tx))
+
+	// the created contract receives the value of a creation
+	tx = &types.Transaction{From: etherTestSender, CreatedContract: etherTestRegistered, Value: big.NewInt(10)}
+	expected = map[types.Address]bool{etherTestSender: true, etherTestRegistered: true}
+	assert.Equal(t, expected, processor.ChangedHolders(registered, tx))
+
+	tx = &types.Transaction{From: etherTestSender, To: etherTestRecipient, Value: big.NewInt(10)}
+	assert.Len(t, processor.ChangedHolders(registered, tx), 0)
+}
+
+func TestEtherProcessor_ProcessBlock(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_getBalance0x1932c48b2bf8102ba33b4a6b545c32236e342f340x1": types.HexBigNumber(*big.NewInt(990)),
+		"eth_getBalance0x1349f3e1b8d71effb47b840594ff27da7e603d170x1": types.HexBigNumber(*big.NewInt(10)),
+	}
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{From: etherTestSender, To: etherTestRegistered, Value: big.NewInt(10)},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(nil)
+	processor := NewEtherProcessor(db, client.NewStubQuorumClient(nil, mockRPC))
+
+	err := processor.ProcessBlock(context.Background(), []types.Address{etherTestRegistered}, block)
+
+	assert.Nil(t, err)
+	assert.Len(t, db.RecordedHolder, 2)
+	assert.EqualValues(t, 1, db.RecordedBlock)
+	recorded := make(map[types.Address]string)
+	for i, holder := range db.RecordedHolder {
+		assert.Equal(t, types.EtherContract, db.RecordedContract[i])
+		recorded[holder] = db.RecordedToken[i].String()
+	}
+	assert.Equal(t, map[types.Address]string{etherTestSender: "990", etherTestRegistered: "10"}, recorded)
+}
+
+func TestEtherProcessor_ProcessBlock_NoTransfersDoesNothing(t *testing.T) {
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{From: etherTestSender, To: etherTestRecipient, Value: big.NewInt(10)},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(nil)
+	processor := NewEtherProcessor(db, client.NewStubQuorumClient(nil, nil))
+
+	err := processor.ProcessBlock(context.Background(), []types.Address{etherTestRegistered}, block)
+
+	assert.Nil(t, err)
+	assert.Len(t, db.RecordedHolder, 0)
+}
+
+func TestEtherProcessor_ProcessBlock_DatabaseError(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_getBalance0x1932c48b2bf8102ba33b4a6b545c32236e342f340x1": types.HexBigNumber(*big.NewInt(990)),
+	}
+	block := &types.BlockWithTransactions{
+		Number: 1,
+		Transactions: []*types.Transaction{
+			{From: etherTestSender, To: etherTestRegistered, Value: big.NewInt(0)},
+		},
+	}
+
+	db := NewFakeTestTokenDatabase(errors.New("test error"))
+	processor := NewEtherProcessor(db, client.NewStubQuorumClient(nil, mockRPC))
+
+	err := processor.ProcessBlock(context.Background(), []types.Address{etherTestRegistered}, block)
+
+	assert.EqualError(t, err, "test error")
+}
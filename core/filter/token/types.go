@@ -8,5 +8,10 @@ import (
 
 type TokenFilterDatabase interface {
 	RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error
+	GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
+	GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error)
 	RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error
+	RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error
+	RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error
+	RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error
 }
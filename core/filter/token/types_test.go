@@ -2,9 +2,44 @@ package token
 
 import (
 	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
 	"quorumengineering/quorum-report/types"
 )
 
+var (
+	tokenTestContract  = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	tokenTestOperator  = types.NewAddress("0x0000000000000000000000000000000000000009")
+	tokenTestSender    = types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	tokenTestRecipient = types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+)
+
+// tokenTestEvent builds an event of the test contract, padding each account
+// into an indexed topic after the event topic.
+func tokenTestEvent(topic types.Hash, data string, accounts ...types.Address) *types.Event {
+	topics := []types.Hash{topic}
+	for _, account := range accounts {
+		topics = append(topics, types.Hash("000000000000000000000000"+string(account)))
+	}
+	return &types.Event{
+		Address: tokenTestContract,
+		Topics:  topics,
+		Data:    types.NewHexData(data),
+	}
+}
+
+func assertEventTopics(t *testing.T, contractAbi types.ABIStructure, topics ...types.Hash) {
+	signatures := make(map[string]bool)
+	for _, event := range contractAbi.ToInternalABI().Events {
+		signatures[event.Signature()] = true
+	}
+	for _, topic := range topics {
+		assert.True(t, signatures[topic.String()[2:]], "missing event for topic %s", topic)
+	}
+}
+
 func NewFakeTestTokenDatabase(testErr error) *FakeTestTokenDatabase {
 	return &FakeTestTokenDatabase{
 		testErr: testErr,
@@ -18,6 +53,12 @@ type FakeTestTokenDatabase struct {
 	RecordedHolder   []types.Address
 	RecordedBlock    uint64
 	RecordedToken    []*big.Int
+	RecordedAmount   []*big.Int
+
+	RecordedOperator    []types.Address
+	RecordedAuthorized  []bool
+	RecordedTotalAssets []*big.Int
+	RecordedTotalSupply []*big.Int
 }
 
 func (db *FakeTestTokenDatabase) RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
@@ -31,6 +72,20 @@ func (db *FakeTestTokenDatabase) RecordNewERC20Balance(contract types.Address, h
 	return nil
 }
 
+func (db *FakeTestTokenDatabase) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	if db.testErr != nil {
+		return nil, db.testErr
+	}
+	return map[uint64]*big.Int{}, nil
+}
+
+func (db *FakeTestTokenDatabase) GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	if db.testErr != nil {
+		return nil, db.testErr
+	}
+	return nil, nil
+}
+
 func (db *FakeTestTokenDatabase) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
 	if db.testErr != nil {
 		return db.testErr
@@ -41,3 +96,38 @@ func (db *FakeTestTokenDatabase) RecordERC721Token(contract types.Address, holde
 	db.RecordedToken = append(db.RecordedToken, tokenId)
 	return nil
 }
+
+func (db *FakeTestTokenDatabase) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	if db.testErr != nil {
+		return db.testErr
+	}
+	db.RecordedContract = append(db.RecordedContract, contract)
+	db.RecordedHolder = append(db.RecordedHolder, holder)
+	db.RecordedBlock = block
+	db.RecordedToken = append(db.RecordedToken, tokenId)
+	db.RecordedAmount = append(db.RecordedAmount, amount)
+	return nil
+}
+
+func (db *FakeTestTokenDatabase) RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error {
+	if db.testErr != nil {
+		return db.testErr
+	}
+	db.RecordedContract = append(db.RecordedContract, contract)
+	db.RecordedHolder = append(db.RecordedHolder, holder)
+	db.RecordedBlock = block
+	db.RecordedOperator = append(db.RecordedOperator, operator)
+	db.RecordedAuthorized = append(db.RecordedAuthorized, authorized)
+	return nil
+}
+
+func (db *FakeTestTokenDatabase) RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error {
+	if db.testErr != nil {
+		return db.testErr
+	}
+	db.RecordedContract = append(db.RecordedContract, contract)
+	db.RecordedBlock = block
+	db.RecordedTotalAssets = append(db.RecordedTotalAssets, totalAssets)
+	db.RecordedTotalSupply = append(db.RecordedTotalSupply, totalSupply)
+	return nil
+}
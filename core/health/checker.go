@@ -0,0 +1,200 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+var errNotConnected = errors.New("no WebSocket connection to Quorum")
+
+// Service is a background service whose main loop can be checked
+type Service interface {
+	IsRunning() bool
+}
+
+// connectionChecker is implemented by Quorum clients that can report the state
+// of their connection without making a call
+type connectionChecker interface {
+	IsConnected() bool
+}
+
+type HealthDB interface {
+	GetLastPersistedBlockNumber() (uint64, error)
+	GetAddresses() ([]types.Address, error)
+	GetLastFiltered(types.Address) (uint64, error)
+}
+
+// ConnectionStatus is whether a connection is usable, with the error if not
+type ConnectionStatus struct {
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// FilteredAddress is a registered address and how far it has been indexed
+type FilteredAddress struct {
+	Address      types.Address `json:"address"`
+	LastFiltered uint64        `json:"lastFiltered"`
+}
+
+// LivenessReport is the response body of the liveness endpoint, built only from
+// the state of the application itself
+type LivenessReport struct {
+	Status         string           `json:"status"`
+	MonitorRunning bool             `json:"monitorRunning"`
+	FilterRunning  bool             `json:"filterRunning"`
+	Quorum         ConnectionStatus `json:"quorum"`
+}
+
+// Report is the response body of the readiness endpoint
+type Report struct {
+	LivenessReport
+	Database      ConnectionStatus `json:"database"`
+	ChainHead     uint64           `json:"chainHead"`
+	LastPersisted uint64           `json:"lastPersistedBlock"`
+	// BlocksBehind is how far the last persisted block is behind the chain head,
+	// not counting the blocks waiting to reach the confirmation depth
+	BlocksBehind uint64 `json:"blocksBehind"`
+	// SlowestAddress is the registered address indexed the least far
+	SlowestAddress *FilteredAddress `json:"slowestAddress,omitempty"`
+}
+
+// Checker serves the liveness and readiness endpoints. The application is live
+// while the monitor and filter services are running and connected to Quorum,
+// and ready once Quorum and the database can be queried and syncing has caught
+// up to within a set distance of the chain head.
+type Checker struct {
+	db           HealthDB
+	quorumClient client.Client
+	monitor      Service
+	filter       Service
+
+	confirmationDepth uint64
+	maxBlocksBehind   uint64
+}
+
+func NewChecker(db HealthDB, quorumClient client.Client, monitor Service, filter Service, config types.TuningConfig) *Checker {
+	checker := &Checker{
+		db:                db,
+		quorumClient:      quorumClient,
+		monitor:           monitor,
+		filter:            filter,
+		confirmationDepth: config.ConfirmationDepth,
+	}
+	if config.ReadinessMaxBlocksBehind != nil {
+		checker.maxBlocksBehind = *config.ReadinessMaxBlocksBehind
+	}
+	return checker
+}
+
+// Liveness returns whether the application is live. It makes no calls to Quorum
+// or the database, so it stays cheap to probe often.
+func (c *Checker) Liveness() *LivenessReport {
+	report := &LivenessReport{
+		Status:         StatusOK,
+		MonitorRunning: c.monitor.IsRunning(),
+		FilterRunning:  c.filter.IsRunning(),
+		Quorum:         ConnectionStatus{Healthy: true},
+	}
+	if !c.isConnected() {
+		report.Quorum = ConnectionStatus{Error: errNotConnected.Error()}
+	}
+	if !report.MonitorRunning || !report.FilterRunning || !report.Quorum.Healthy {
+		report.Status = StatusUnavailable
+	}
+	return report
+}
+
+// Readiness returns the current report, with the status reflecting whether the
+// application is ready to serve requests. Querying the chain head is given up on
+// once the context ends.
+func (c *Checker) Readiness(ctx context.Context) *Report {
+	report := &Report{
+		LivenessReport: *c.Liveness(),
+		Database:       ConnectionStatus{Healthy: true},
+	}
+
+	if report.Quorum.Healthy {
+		head, err := client.CurrentBlock(ctx, c.quorumClient)
+		if err != nil {
+			report.Quorum = ConnectionStatus{Error: err.Error()}
+		}
+		report.ChainHead = head
+	}
+
+	if err := c.fillSyncStatus(report); err != nil {
+		report.Database = ConnectionStatus{Error: err.Error()}
+	}
+
+	if !report.Quorum.Healthy || !report.Database.Healthy || report.BlocksBehind > c.maxBlocksBehind {
+		report.Status = StatusUnavailable
+	}
+	return report
+}
+
+func (c *Checker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Liveness()
+		writeReport(w, report.Status, report)
+	})
+}
+
+func (c *Checker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Readiness(r.Context())
+		writeReport(w, report.Status, report)
+	})
+}
+
+func (c *Checker) isConnected() bool {
+	if checker, ok := c.quorumClient.(connectionChecker); ok {
+		return checker.IsConnected()
+	}
+	return true
+}
+
+func (c *Checker) fillSyncStatus(report *Report) error {
+	lastPersisted, err := c.db.GetLastPersistedBlockNumber()
+	if err != nil {
+		return err
+	}
+	report.LastPersisted = lastPersisted
+	if report.ChainHead > lastPersisted+c.confirmationDepth {
+		report.BlocksBehind = report.ChainHead - lastPersisted - c.confirmationDepth
+	}
+
+	addresses, err := c.db.GetAddresses()
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		lastFiltered, err := c.db.GetLastFiltered(address)
+		if err != nil {
+			return err
+		}
+		if report.SlowestAddress == nil || lastFiltered < report.SlowestAddress.LastFiltered {
+			report.SlowestAddress = &FilteredAddress{Address: address, LastFiltered: lastFiltered}
+		}
+	}
+	return nil
+}
+
+func writeReport(w http.ResponseWriter, status string, report interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Warn("Writing health report failed", "err", err)
+	}
+}
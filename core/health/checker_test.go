@@ -0,0 +1,163 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	address1 = types.NewAddress("0x0000000000000000000000000000000000000001")
+	address2 = types.NewAddress("0x0000000000000000000000000000000000000002")
+)
+
+type fakeService struct {
+	running bool
+}
+
+func (s *fakeService) IsRunning() bool {
+	return s.running
+}
+
+type fakeConnectedClient struct {
+	*client.StubQuorumClient
+	connected bool
+}
+
+func (c *fakeConnectedClient) IsConnected() bool {
+	return c.connected
+}
+
+// unreachableDB fails every query, as when the database is down
+type unreachableDB struct{}
+
+func (unreachableDB) GetLastPersistedBlockNumber() (uint64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (unreachableDB) GetAddresses() ([]types.Address, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (unreachableDB) GetLastFiltered(types.Address) (uint64, error) {
+	return 0, errors.New("connection refused")
+}
+
+func maxBlocksBehind(blocks uint64) *uint64 {
+	return &blocks
+}
+
+// setup creates a chain with its head at block 0x20, with blocks persisted up
+// to 20 and indexed up to 18 and 20 for the two registered addresses
+func setup(t *testing.T) (*memory.MemoryDB, *fakeConnectedClient) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.AddAddresses([]types.Address{address1, address2}))
+	var blocks []*types.Block
+	for i := uint64(1); i <= 20; i++ {
+		blocks = append(blocks, &types.Block{Number: i})
+	}
+	assert.Nil(t, db.WriteBlocks(blocks))
+	assert.Nil(t, db.IndexBlocks([]types.Address{address1, address2}, []*types.BlockWithTransactions{{Number: 18}}))
+	assert.Nil(t, db.IndexBlocks([]types.Address{address2}, []*types.BlockWithTransactions{{Number: 20}}))
+
+	mockGraphQL := map[string]map[string]interface{}{
+		client.CurrentBlockQuery(): {"block": interface{}(map[string]interface{}{"number": "0x20"})},
+	}
+	return db, &fakeConnectedClient{StubQuorumClient: client.NewStubQuorumClient(mockGraphQL, nil), connected: true}
+}
+
+func TestChecker_Report(t *testing.T) {
+	db, quorumClient := setup(t)
+	checker := NewChecker(db, quorumClient, &fakeService{true}, &fakeService{true}, types.TuningConfig{ReadinessMaxBlocksBehind: maxBlocksBehind(12)})
+
+	assert.Equal(t, &Report{
+		LivenessReport: LivenessReport{
+			Status:         StatusOK,
+			MonitorRunning: true,
+			FilterRunning:  true,
+			Quorum:         ConnectionStatus{Healthy: true},
+		},
+		Database:       ConnectionStatus{Healthy: true},
+		ChainHead:      32,
+		LastPersisted:  20,
+		BlocksBehind:   12,
+		SlowestAddress: &FilteredAddress{Address: address1, LastFiltered: 18},
+	}, checker.Readiness(context.Background()))
+}
+
+func TestChecker_Readiness(t *testing.T) {
+	db, quorumClient := setup(t)
+
+	checker := NewChecker(db, quorumClient, &fakeService{true}, &fakeService{true}, types.TuningConfig{ReadinessMaxBlocksBehind: maxBlocksBehind(10)})
+	assert.Equal(t, StatusUnavailable, checker.Readiness(context.Background()).Status)
+	assert.Equal(t, StatusOK, checker.Liveness().Status)
+
+	// blocks waiting to be confirmed are not counted as behind
+	checker = NewChecker(db, quorumClient, &fakeService{true}, &fakeService{true}, types.TuningConfig{ReadinessMaxBlocksBehind: maxBlocksBehind(10), ConfirmationDepth: 2})
+	report := checker.Readiness(context.Background())
+	assert.Equal(t, StatusOK, report.Status)
+	assert.EqualValues(t, 10, report.BlocksBehind)
+
+	// any distance behind can be refused
+	checker = NewChecker(db, quorumClient, &fakeService{true}, &fakeService{true}, types.TuningConfig{ReadinessMaxBlocksBehind: maxBlocksBehind(0), ConfirmationDepth: 12})
+	assert.Equal(t, StatusOK, checker.Readiness(context.Background()).Status)
+	checker = NewChecker(db, quorumClient, &fakeService{true}, &fakeService{true}, types.TuningConfig{ReadinessMaxBlocksBehind: maxBlocksBehind(0), ConfirmationDepth: 11})
+	assert.Equal(t, StatusUnavailable, checker.Readiness(context.Background()).Status)
+}
+
+func TestChecker_Liveness(t *testing.T) {
+	db, quorumClient := setup(t)
+	monitor := &fakeService{true}
+	filter := &fakeService{true}
+	checker := NewChecker(db, quorumClient, monitor, filter, types.TuningConfig{ReadinessMaxBlocksBehind: maxBlocksBehind(20)})
+	assert.Equal(t, StatusOK, checker.Liveness().Status)
+
+	filter.running = false
+	assert.Equal(t, StatusUnavailable, checker.Liveness().Status)
+	assert.Equal(t, StatusUnavailable, checker.Readiness(context.Background()).Status)
+
+	filter.running = true
+	quorumClient.connected = false
+	report := checker.Liveness()
+	assert.Equal(t, StatusUnavailable, report.Status)
+	assert.Equal(t, ConnectionStatus{Error: "no WebSocket connection to Quorum"}, report.Quorum)
+}
+
+func TestChecker_LivenessIsLocal(t *testing.T) {
+	// neither the database nor Quorum can be queried, but the connection is up
+	quorumClient := &fakeConnectedClient{StubQuorumClient: client.NewStubQuorumClient(nil, nil), connected: true}
+	checker := NewChecker(unreachableDB{}, quorumClient, &fakeService{true}, &fakeService{true}, types.TuningConfig{ReadinessMaxBlocksBehind: maxBlocksBehind(10)})
+	assert.Equal(t, StatusOK, checker.Liveness().Status)
+
+	report := checker.Readiness(context.Background())
+	assert.Equal(t, StatusUnavailable, report.Status)
+	assert.False(t, report.Quorum.Healthy)
+	assert.Equal(t, ConnectionStatus{Error: "connection refused"}, report.Database)
+}
+
+func TestChecker_Handlers(t *testing.T) {
+	db, quorumClient := setup(t)
+	checker := NewChecker(db, quorumClient, &fakeService{true}, &fakeService{true}, types.TuningConfig{ReadinessMaxBlocksBehind: maxBlocksBehind(10)})
+
+	recorder := httptest.NewRecorder()
+	checker.LivenessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	var report map[string]interface{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+	assert.Equal(t, "unavailable", report["status"])
+	assert.EqualValues(t, 12, report["blocksBehind"])
+	assert.Equal(t, map[string]interface{}{"address": "0x0000000000000000000000000000000000000001", "lastFiltered": float64(18)}, report["slowestAddress"])
+}
@@ -1,10 +1,13 @@
 package monitor
 
 import (
+	"context"
 	"time"
 
+	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/metrics"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -25,10 +28,13 @@ type BatchWriter struct {
 	currentTransactionCount int
 
 	BatchWorkChan chan *BlockAndTransactions
-	db            database.Database
+	// ResyncChan is signalled after a chain reorganisation has been rolled back
+	ResyncChan   chan struct{}
+	db           database.Database
+	quorumClient client.Client
 }
 
-func NewBatchWriter(db database.Database, batchWorkChan chan *BlockAndTransactions, flushPeriod int) *BatchWriter {
+func NewBatchWriter(db database.Database, quorumClient client.Client, batchWorkChan chan *BlockAndTransactions, flushPeriod int) *BatchWriter {
 	return &BatchWriter{
 		maxBlocks:               cap(batchWorkChan),
 		maxTransactions:         maxTransactionMultiplier * cap(batchWorkChan),
@@ -36,11 +42,15 @@ func NewBatchWriter(db database.Database, batchWorkChan chan *BlockAndTransactio
 		currentWorkUnits:        make([]*BlockAndTransactions, 0, cap(batchWorkChan)),
 		currentTransactionCount: 0,
 		BatchWorkChan:           batchWorkChan,
+		ResyncChan:              make(chan struct{}, 1),
 		db:                      db,
+		quorumClient:            quorumClient,
 	}
 }
 
-func (bw *BatchWriter) Run(stopChan <-chan struct{}) {
+// Run batch writes blocks until the context is cancelled, which also cancels
+// the calls to Quorum made to check for chain reorganisations.
+func (bw *BatchWriter) Run(ctx context.Context) {
 	log.Info("Starting batch block processor", "timeout period", time.Duration(bw.flushPeriod)*time.Second, "max blocks", bw.maxBlocks, "max txns", bw.maxTransactions)
 
 	ticker := time.NewTicker(time.Duration(bw.flushPeriod) * time.Second)
@@ -57,29 +67,41 @@ func (bw *BatchWriter) Run(stopChan <-chan struct{}) {
 				log.Info("Max batch write limit reached")
 				//if the write fails, keep trying until it succeeds, waiting
 				//the defined timeout period between attempts
-				for err := bw.BatchWrite(); err != nil; err = bw.BatchWrite() {
+				for err := bw.BatchWrite(ctx); err != nil; err = bw.BatchWrite(ctx) {
 					log.Warn("Batch write failed", "err", err)
-					<-ticker.C
+					metrics.BatchWriteErrors.Inc()
+					select {
+					case <-ticker.C:
+					case <-ctx.Done():
+						return
+					}
 				}
 			}
 		case <-ticker.C:
 			log.Debug("Batch writing blocks/transactions from ticker")
 			//if this fails, it will try again on the next run
-			if err := bw.BatchWrite(); err != nil {
+			if err := bw.BatchWrite(ctx); err != nil {
 				log.Warn("Batch write failed", "err", err)
+				metrics.BatchWriteErrors.Inc()
 			}
-		case <-stopChan:
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func (bw *BatchWriter) BatchWrite() error {
+func (bw *BatchWriter) BatchWrite(ctx context.Context) error {
 	if len(bw.currentWorkUnits) == 0 {
 		log.Debug("No blocks/transaction to write")
 		return nil
 	}
 
+	start := time.Now()
+	// drop orphaned blocks and roll back orphaned data before writing
+	if err := bw.checkForReorg(ctx); err != nil {
+		return err
+	}
+
 	allTxns := make([]*types.Transaction, 0, bw.currentTransactionCount)
 	allBlocks := make([]*types.Block, 0, len(bw.currentWorkUnits))
 	for _, workUnit := range bw.currentWorkUnits {
@@ -95,6 +117,9 @@ func (bw *BatchWriter) BatchWrite() error {
 	if err := bw.db.WriteBlocks(allBlocks); err != nil {
 		return err
 	}
+	metrics.BatchWriteDuration.Observe(time.Since(start).Seconds())
+	metrics.BatchWriteBlocks.Observe(float64(len(allBlocks)))
+	metrics.BatchWriteTransactions.Observe(float64(len(allTxns)))
 
 	// reset
 	bw.currentTransactionCount = 0
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+func newTestBlock(number uint64, hash, parentHash string) *types.Block {
+	return &types.Block{
+		Hash:       types.NewHash(hash),
+		ParentHash: types.NewHash(parentHash),
+		Number:     number,
+	}
+}
+
+func TestBatchWriter_BatchWrite_LinkedBlocks(t *testing.T) {
+	db := memory.NewMemoryDB()
+	_ = db.WriteBlocks([]*types.Block{newTestBlock(1, "0x1", "0x0")})
+
+	bw := NewBatchWriter(db, client.NewStubQuorumClient(nil, nil), make(chan *BlockAndTransactions, 10), 1)
+	bw.currentWorkUnits = []*BlockAndTransactions{
+		{block: newTestBlock(3, "0x3", "0x2")},
+		{block: newTestBlock(2, "0x2", "0x1")},
+	}
+
+	err := bw.BatchWrite(context.Background())
+
+	assert.Nil(t, err)
+	lastPersisted, _ := db.GetLastPersistedBlockNumber()
+	assert.EqualValues(t, 3, lastPersisted)
+	assert.Len(t, bw.ResyncChan, 0)
+}
+
+func TestBatchWriter_BatchWrite_DropsOrphanedBlock(t *testing.T) {
+	db := memory.NewMemoryDB()
+	_ = db.WriteBlocks([]*types.Block{newTestBlock(1, "0x1", "0x0"), newTestBlock(2, "0x2", "0x1")})
+
+	mockRPC := map[string]interface{}{
+		"eth_getBlockByNumber0x3<bool Value>": types.RawBlock{Hash: types.NewHash("0x3"), Number: 3},
+	}
+	bw := NewBatchWriter(db, client.NewStubQuorumClient(nil, mockRPC), make(chan *BlockAndTransactions, 10), 1)
+	bw.currentWorkUnits = []*BlockAndTransactions{
+		{block: newTestBlock(3, "0x3b", "0x2b")},
+	}
+
+	err := bw.BatchWrite(context.Background())
+
+	assert.Nil(t, err)
+	_, err = db.ReadBlock(3)
+	assert.EqualError(t, err, "block does not exist")
+	lastPersisted, _ := db.GetLastPersistedBlockNumber()
+	assert.EqualValues(t, 2, lastPersisted)
+	assert.Len(t, bw.ResyncChan, 0)
+}
+
+func TestBatchWriter_BatchWrite_RollsBackReorganisation(t *testing.T) {
+	db := memory.NewMemoryDB()
+	_ = db.WriteBlocks([]*types.Block{
+		newTestBlock(1, "0x1", "0x0"),
+		newTestBlock(2, "0x2", "0x1"),
+		newTestBlock(3, "0x3", "0x2"),
+	})
+
+	mockRPC := map[string]interface{}{
+		"eth_getBlockByNumber0x1<bool Value>": types.RawBlock{Hash: types.NewHash("0x1"), Number: 1},
+		"eth_getBlockByNumber0x2<bool Value>": types.RawBlock{Hash: types.NewHash("0x2b"), Number: 2},
+		"eth_getBlockByNumber0x3<bool Value>": types.RawBlock{Hash: types.NewHash("0x3b"), Number: 3},
+	}
+	bw := NewBatchWriter(db, client.NewStubQuorumClient(nil, mockRPC), make(chan *BlockAndTransactions, 10), 1)
+	bw.currentWorkUnits = []*BlockAndTransactions{
+		{block: newTestBlock(4, "0x4b", "0x3b"), txs: []*types.Transaction{{Hash: types.NewHash("0x4")}}},
+		{block: newTestBlock(3, "0x3b", "0x2b")},
+	}
+
+	err := bw.BatchWrite(context.Background())
+
+	assert.Nil(t, err)
+	lastPersisted, _ := db.GetLastPersistedBlockNumber()
+	assert.EqualValues(t, 1, lastPersisted)
+	_, err = db.ReadBlock(2)
+	assert.EqualError(t, err, "block does not exist")
+	_, err = db.ReadTransaction(types.NewHash("0x4"))
+	assert.EqualError(t, err, "transaction does not exist")
+	assert.Len(t, bw.currentWorkUnits, 0)
+	assert.Len(t, bw.ResyncChan, 1)
+}
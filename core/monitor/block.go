@@ -1,39 +1,74 @@
 package monitor
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/metrics"
 	"quorumengineering/quorum-report/types"
 )
 
 type BlockMonitor interface {
-	ListenToChainHead(cancelChan chan bool, stopChan chan bool) error
-	SyncHistoricBlocks(lastPersisted uint64, cancelChan chan bool, wg *sync.WaitGroup) error
+	ListenToChainHead(ctx context.Context, cancelChan chan bool, stopChan chan bool) error
+	SyncHistoricBlocks(ctx context.Context, lastPersisted uint64, cancelChan chan bool, wg *sync.WaitGroup) error
 }
 
+// TransactionPrefetcher fetches the transactions of a range of historic blocks
+// ahead of the blocks being processed
+type TransactionPrefetcher interface {
+	PrefetchTransactions(ctx context.Context, start, end uint64)
+}
+
+// maxSubscribeFailures is the number of times in a row subscribing to new chain
+// heads can fail before switching to polling
+const maxSubscribeFailures = 3
+
+// syncBatchSize is the number of historic blocks fetched in each batch call
+const syncBatchSize = 100
+
 type DefaultBlockMonitor struct {
 	quorumClient client.Client
 	newBlockChan chan *types.Block
 	consensus    string
+	prefetcher   TransactionPrefetcher
+
+	// chain head tracking
+	chainHeadMode     string
+	pollInterval      time.Duration
+	subscribeFailures int
 }
 
-func NewDefaultBlockMonitor(quorumClient client.Client, newBlockChan chan *types.Block, consensus string) *DefaultBlockMonitor {
+func NewDefaultBlockMonitor(quorumClient client.Client, newBlockChan chan *types.Block, consensus string, prefetcher TransactionPrefetcher, chainHeadMode string, pollInterval time.Duration) *DefaultBlockMonitor {
 	return &DefaultBlockMonitor{
-		quorumClient: quorumClient,
-		newBlockChan: newBlockChan,
-		consensus:    consensus,
+		quorumClient:  quorumClient,
+		newBlockChan:  newBlockChan,
+		consensus:     consensus,
+		prefetcher:    prefetcher,
+		chainHeadMode: chainHeadMode,
+		pollInterval:  pollInterval,
 	}
 }
 
-func (bm *DefaultBlockMonitor) ListenToChainHead(cancelChan chan bool, stopChan chan bool) error {
+func (bm *DefaultBlockMonitor) ListenToChainHead(ctx context.Context, cancelChan chan bool, stopChan chan bool) error {
+	if bm.chainHeadMode == types.PollChainHeadMode {
+		return bm.pollChainHead(ctx, cancelChan, stopChan)
+	}
+
 	// make headers channel buffered so that it doesn't block websocket listener
 	headers := make(chan types.RawHeader, 10)
 	if err := bm.quorumClient.SubscribeChainHead(headers); err != nil {
-		return err
+		bm.subscribeFailures++
+		if bm.subscribeFailures < maxSubscribeFailures {
+			return err
+		}
+		log.Warn("Subscribing to chain head repeatedly failed, switching to polling", "failures", bm.subscribeFailures, "err", err)
+		bm.chainHeadMode = types.PollChainHeadMode
+		return bm.pollChainHead(ctx, cancelChan, stopChan)
 	}
+	bm.subscribeFailures = 0
 
 	go func() {
 		defer close(cancelChan)
@@ -41,7 +76,7 @@ func (bm *DefaultBlockMonitor) ListenToChainHead(cancelChan chan bool, stopChan
 		for {
 			select {
 			case header := <-headers:
-				bm.processChainHead(header)
+				bm.processChainHead(ctx, header)
 			case <-stopChan:
 				log.Info("Stopping chain head listener.")
 				return
@@ -52,31 +87,83 @@ func (bm *DefaultBlockMonitor) ListenToChainHead(cancelChan chan bool, stopChan
 	return nil
 }
 
-func (bm *DefaultBlockMonitor) SyncHistoricBlocks(lastPersisted uint64, cancelChan chan bool, wg *sync.WaitGroup) error {
-	currentBlockNumber, err := client.CurrentBlock(bm.quorumClient)
+// pollChainHead polls the current block number, processing each block since the
+// last poll. Blocks up to the current block at the time polling starts are left
+// to the historical sync.
+func (bm *DefaultBlockMonitor) pollChainHead(ctx context.Context, cancelChan chan bool, stopChan chan bool) error {
+	lastSeen, err := client.CurrentBlock(ctx, bm.quorumClient)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer close(cancelChan)
+		log.Info("Starting chain head poller.", "interval", bm.pollInterval)
+		ticker := time.NewTicker(bm.pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				current, err := client.CurrentBlock(ctx, bm.quorumClient)
+				if err != nil {
+					log.Warn("Polling current block failed", "err", err)
+					continue
+				}
+				lastSeen = bm.processNewChainHeads(ctx, lastSeen, current, stopChan)
+			case <-stopChan:
+				log.Info("Stopping chain head poller.")
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// processNewChainHeads processes the blocks after lastSeen up to current, which
+// may be more than one if blocks were created since the last poll. It returns
+// the last block processed.
+func (bm *DefaultBlockMonitor) processNewChainHeads(ctx context.Context, lastSeen, current uint64, stopChan chan bool) uint64 {
+	for number := lastSeen + 1; number <= current; number++ {
+		select {
+		case <-stopChan:
+			return lastSeen
+		default:
+		}
+		bm.processChainHead(ctx, types.RawHeader{Number: types.HexNumber(number)})
+		lastSeen = number
+	}
+	return lastSeen
+}
+
+func (bm *DefaultBlockMonitor) SyncHistoricBlocks(ctx context.Context, lastPersisted uint64, cancelChan chan bool, wg *sync.WaitGroup) error {
+	currentBlockNumber, err := client.CurrentBlock(ctx, bm.quorumClient)
 	if err != nil {
 		return err
 	}
 	log.Info("Queried current block head from Quorum", "block number", currentBlockNumber)
+	metrics.SetChainHead(currentBlockNumber)
 
 	// Sync is called in a go routine so that it doesn't block main process.
 	go func() {
 		defer log.Info("Returning from historical block processing.")
 		defer wg.Done()
-		err := bm.syncBlocks(lastPersisted+1, currentBlockNumber, cancelChan)
-		for err != nil {
+		err := bm.syncBlocks(ctx, lastPersisted+1, currentBlockNumber, cancelChan)
+		// calls to Quorum are cancelled when shutting down, so stop retrying
+		for err != nil && ctx.Err() == nil {
 			log.Info("Sync historic blocks failed", "end-block", currentBlockNumber, "err", err)
 			time.Sleep(time.Second)
-			err = bm.syncBlocks(err.EndBlockNumber(), currentBlockNumber, cancelChan)
+			err = bm.syncBlocks(ctx, err.EndBlockNumber(), currentBlockNumber, cancelChan)
 		}
 	}()
 
 	return nil
 }
 
-func (bm *DefaultBlockMonitor) processChainHead(header types.RawHeader) {
+func (bm *DefaultBlockMonitor) processChainHead(ctx context.Context, header types.RawHeader) {
 	log.Info("Processing chain head", "block hash", header.Hash.String(), "block number", header.Number)
-	blockOrigin, err := bm.tryFetchingBlock(header.Number.ToUint64(), 10)
+	metrics.SetChainHead(header.Number.ToUint64())
+	blockOrigin, err := bm.tryFetchingBlock(ctx, header.Number.ToUint64(), 10)
 	if err != nil {
 		log.Error("Error - fetching block from Quorum failed", "block hash", header.Hash, "block number", header.Number, "err", err)
 		return
@@ -105,28 +192,37 @@ func (bm *DefaultBlockMonitor) createBlock(block *types.RawBlock) *types.Block {
 	}
 }
 
-func (bm *DefaultBlockMonitor) syncBlocks(start, end uint64, stopChan chan bool) *SyncError {
+func (bm *DefaultBlockMonitor) syncBlocks(ctx context.Context, start, end uint64, stopChan chan bool) *SyncError {
 	if start > end {
 		return nil
 	}
 
 	log.Info("Syncing historic blocks", "start", start, "end", end)
-	for i := start; i <= end; i++ {
+	for batchStart := start; batchStart <= end; batchStart += syncBatchSize {
 		select {
 		case <-stopChan:
 			return nil
 		default:
 		}
 
-		blockOrigin, err := bm.tryFetchingBlock(i, 10)
+		batchEnd := batchStart + syncBatchSize - 1
+		if batchEnd > end {
+			batchEnd = end
+		}
+		blocks, err := bm.tryFetchingBlocks(ctx, batchStart, batchEnd, 10)
 		if err != nil {
-			return NewSyncError(err.Error(), i)
+			return NewSyncError(err.Error(), batchStart)
+		}
+		if bm.prefetcher != nil {
+			bm.prefetcher.PrefetchTransactions(ctx, batchStart, batchEnd)
 		}
 
-		select {
-		case <-stopChan:
-			return nil
-		case bm.newBlockChan <- bm.createBlock(blockOrigin):
+		for i := range blocks {
+			select {
+			case <-stopChan:
+				return nil
+			case bm.newBlockChan <- bm.createBlock(&blocks[i]):
+			}
 		}
 	}
 
@@ -134,15 +230,39 @@ func (bm *DefaultBlockMonitor) syncBlocks(start, end uint64, stopChan chan bool)
 	return nil
 }
 
-func (bm *DefaultBlockMonitor) tryFetchingBlock(number uint64, tryCount int) (*types.RawBlock, error) {
+func (bm *DefaultBlockMonitor) tryFetchingBlocks(ctx context.Context, start, end uint64, tryCount int) ([]types.RawBlock, error) {
+	var err error
+	var blocks []types.RawBlock
+	for tryCount > 0 {
+		blocks, err = client.BlocksByNumber(ctx, bm.quorumClient, start, end)
+		if err == nil {
+			log.Info("fetched blocks", "start", start, "end", end)
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		log.Warn("fetching blocks from Quorum failed, retry", "tryCount", tryCount, "start", start, "end", end, "err", err)
+
+		tryCount--
+		time.Sleep(time.Second)
+	}
+	return blocks, err
+}
+
+func (bm *DefaultBlockMonitor) tryFetchingBlock(ctx context.Context, number uint64, tryCount int) (*types.RawBlock, error) {
 	var err error
 	var block types.RawBlock
 	for tryCount > 0 {
-		block, err = client.BlockByNumber(bm.quorumClient, number)
+		block, err = client.BlockByNumber(ctx, bm.quorumClient, number)
 		if err == nil {
 			log.Info("fetched block", "block number", number)
 			break
 		}
+		if ctx.Err() != nil {
+			break
+		}
 
 		if err != nil {
 			log.Warn("fetching block from Quorum failed, retry", "tryCount", tryCount, "block number", number, "err", err)
@@ -1,7 +1,9 @@
 package monitor
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -84,7 +86,7 @@ func TestCreateBlock(t *testing.T) {
 	}
 
 	for _, tc := range cases {
-		bm := NewDefaultBlockMonitor(client.NewStubQuorumClient(nil, nil), nil, tc.consensus)
+		bm := NewDefaultBlockMonitor(client.NewStubQuorumClient(nil, nil), nil, tc.consensus, nil, types.SubscribeChainHeadMode, time.Second)
 
 		actual := bm.createBlock(tc.originalBlock)
 
@@ -95,3 +97,69 @@ func TestCreateBlock(t *testing.T) {
 		assert.EqualValues(t, len(tc.expectedBlock.Transactions), len(actual.Transactions))
 	}
 }
+
+func TestProcessNewChainHeads(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_getBlockByNumber0x1f<bool Value>": types.RawBlock{Hash: types.NewHash("0x1f"), Number: 31},
+		"eth_getBlockByNumber0x20<bool Value>": types.RawBlock{Hash: types.NewHash("0x20"), Number: 32},
+	}
+	newBlockChan := make(chan *types.Block, 2)
+	bm := NewDefaultBlockMonitor(client.NewStubQuorumClient(nil, mockRPC), newBlockChan, "istanbul", nil, types.PollChainHeadMode, time.Second)
+
+	// each block since the last poll is processed
+	assert.EqualValues(t, 32, bm.processNewChainHeads(context.Background(), 30, 32, make(chan bool)))
+	assert.EqualValues(t, 31, (<-newBlockChan).Number)
+	assert.EqualValues(t, 32, (<-newBlockChan).Number)
+
+	// nothing is processed if there is no new block
+	assert.EqualValues(t, 32, bm.processNewChainHeads(context.Background(), 32, 32, make(chan bool)))
+	assert.Len(t, newBlockChan, 0)
+}
+
+type recordingPrefetcher struct {
+	ranges [][2]uint64
+}
+
+func (p *recordingPrefetcher) PrefetchTransactions(ctx context.Context, start, end uint64) {
+	p.ranges = append(p.ranges, [2]uint64{start, end})
+}
+
+func TestSyncBlocks_PrefetchesTransactions(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"eth_getBlockByNumber0x1f<bool Value>": types.RawBlock{Hash: types.NewHash("0x1f"), Number: 31},
+		"eth_getBlockByNumber0x20<bool Value>": types.RawBlock{Hash: types.NewHash("0x20"), Number: 32},
+	}
+	newBlockChan := make(chan *types.Block, 2)
+	prefetcher := &recordingPrefetcher{}
+	bm := NewDefaultBlockMonitor(client.NewStubQuorumClient(nil, mockRPC), newBlockChan, "istanbul", prefetcher, types.PollChainHeadMode, time.Second)
+
+	// the transactions of each batch are fetched in one query before its blocks are processed
+	assert.Nil(t, bm.syncBlocks(context.Background(), 31, 32, make(chan bool)))
+	assert.Equal(t, [][2]uint64{{31, 32}}, prefetcher.ranges)
+	assert.EqualValues(t, 31, (<-newBlockChan).Number)
+	assert.EqualValues(t, 32, (<-newBlockChan).Number)
+}
+
+func TestListenToChainHead_SwitchesToPolling(t *testing.T) {
+	mockGraphQL := map[string]map[string]interface{}{
+		client.CurrentBlockQuery(): {"block": interface{}(map[string]interface{}{"number": "0x20"})},
+	}
+	// the stub client cannot subscribe to chain heads
+	bm := NewDefaultBlockMonitor(client.NewStubQuorumClient(mockGraphQL, nil), nil, "istanbul", nil, types.SubscribeChainHeadMode, time.Second)
+
+	for i := 1; i < maxSubscribeFailures; i++ {
+		assert.EqualError(t, bm.ListenToChainHead(context.Background(), make(chan bool), make(chan bool)), "not implemented")
+	}
+
+	cancelChan, stopChan := make(chan bool), make(chan bool)
+	assert.Nil(t, bm.ListenToChainHead(context.Background(), cancelChan, stopChan))
+	assert.Equal(t, types.PollChainHeadMode, bm.chainHeadMode)
+
+	// the poller stops when asked to
+	close(stopChan)
+	select {
+	case <-cancelChan:
+	case <-time.After(time.Second):
+		t.Fatal("chain head poller did not stop")
+	}
+}
@@ -0,0 +1,130 @@
+package monitor
+
+import (
+	"context"
+	"sort"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// checkForReorg verifies that every pending block links to its neighbours, both
+// pending and already persisted. When a link is broken, the canonical chain decides
+// which side is orphaned:
+//   - an orphaned pending block is dropped
+//   - orphaned persisted blocks are rolled back to the common ancestor, and a resync
+//     is requested to fetch the canonical blocks above it
+func (bw *BatchWriter) checkForReorg(ctx context.Context) error {
+	sort.SliceStable(bw.currentWorkUnits, func(i, j int) bool {
+		return bw.currentWorkUnits[i].block.Number < bw.currentWorkUnits[j].block.Number
+	})
+
+	accepted := make([]*BlockAndTransactions, 0, len(bw.currentWorkUnits))
+	pending := make(map[uint64]*types.Block)
+	for _, workUnit := range bw.currentWorkUnits {
+		block := workUnit.block
+		if bw.isLinked(block, pending) {
+			accepted = append(accepted, workUnit)
+			pending[block.Number] = block
+			continue
+		}
+
+		canonical, err := client.BlockByNumber(ctx, bw.quorumClient, block.Number)
+		if err != nil {
+			return err
+		}
+		if canonical.Hash != block.Hash {
+			log.Warn("Dropping orphaned block", "block number", block.Number, "block hash", block.Hash.String())
+			continue
+		}
+
+		ancestor, err := bw.findCommonAncestor(ctx, block.Number)
+		if err != nil {
+			return err
+		}
+		log.Warn("Chain reorganisation detected", "block number", block.Number, "common ancestor", ancestor)
+		if err := bw.db.Rollback(ancestor); err != nil {
+			return err
+		}
+
+		// all pending blocks above the common ancestor will be fetched again by the resync
+		remaining := make([]*BlockAndTransactions, 0, len(accepted))
+		for _, acceptedUnit := range accepted {
+			if acceptedUnit.block.Number <= ancestor {
+				remaining = append(remaining, acceptedUnit)
+			}
+		}
+		accepted = remaining
+		select {
+		case bw.ResyncChan <- struct{}{}:
+		default:
+		}
+		break
+	}
+
+	bw.currentWorkUnits = accepted
+	bw.currentTransactionCount = 0
+	for _, workUnit := range accepted {
+		bw.currentTransactionCount += len(workUnit.txs)
+	}
+	return nil
+}
+
+// isLinked checks the given block against its parent, any other block with the same
+// number, and its child, wherever they are known.
+func (bw *BatchWriter) isLinked(block *types.Block, pending map[uint64]*types.Block) bool {
+	if block.Number > 0 {
+		if parent := bw.knownBlock(block.Number-1, pending); parent != nil && parent.Hash != block.ParentHash {
+			return false
+		}
+	}
+	if existing := bw.knownBlock(block.Number, pending); existing != nil && existing.Hash != block.Hash {
+		return false
+	}
+	if child := bw.knownBlock(block.Number+1, pending); child != nil && child.ParentHash != block.Hash {
+		return false
+	}
+	return true
+}
+
+func (bw *BatchWriter) knownBlock(number uint64, pending map[uint64]*types.Block) *types.Block {
+	if block, ok := pending[number]; ok {
+		return block
+	}
+	if block, err := bw.db.ReadBlock(number); err == nil {
+		return block
+	}
+	return nil
+}
+
+// findCommonAncestor walks back from the given block number until a persisted block
+// matches the canonical chain.
+func (bw *BatchWriter) findCommonAncestor(ctx context.Context, number uint64) (uint64, error) {
+	lastPersisted, err := bw.db.GetLastPersistedBlockNumber()
+	if err != nil {
+		return 0, err
+	}
+
+	for number > 0 {
+		number--
+		persisted, err := bw.db.ReadBlock(number)
+		if err != nil {
+			// not persisted so cannot conflict, and all blocks up to the last
+			// persisted block are stored, so skip straight to it
+			if number > lastPersisted {
+				number = lastPersisted + 1
+			}
+			continue
+		}
+		canonical, err := client.BlockByNumber(ctx, bw.quorumClient, number)
+		if err != nil {
+			return 0, err
+		}
+		if canonical.Hash == persisted.Hash {
+			return number, nil
+		}
+		log.Debug("Found orphaned persisted block", "block number", number, "block hash", persisted.Hash.String())
+	}
+	return 0, nil
+}
@@ -0,0 +1,102 @@
+package monitor
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// PendingBlocks holds processed blocks that have not yet reached the confirmation
+// depth behind the chain head, so they are only persisted once they are final.
+type PendingBlocks struct {
+	confirmationDepth uint64
+
+	head      uint64
+	workUnits map[uint64]*BlockAndTransactions
+	txs       map[types.Hash]*types.Transaction
+	// mutex lock
+	mux sync.RWMutex
+}
+
+func NewPendingBlocks(confirmationDepth uint64) *PendingBlocks {
+	return &PendingBlocks{
+		confirmationDepth: confirmationDepth,
+		workUnits:         make(map[uint64]*BlockAndTransactions),
+		txs:               make(map[types.Hash]*types.Transaction),
+	}
+}
+
+// Add stores a processed block, and returns all the pending blocks that are now
+// confirmed, in block order. With no confirmation depth, the block is confirmed
+// immediately.
+func (p *PendingBlocks) Add(workUnit *BlockAndTransactions) []*BlockAndTransactions {
+	if p.confirmationDepth == 0 {
+		return []*BlockAndTransactions{workUnit}
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	number := workUnit.block.Number
+	if existing, ok := p.workUnits[number]; ok && existing.block.Hash != workUnit.block.Hash {
+		// the chain has reorganised, so pending descendants of the old block are orphaned
+		log.Info("Replacing pending block", "block number", number, "old hash", existing.block.Hash.String(), "new hash", workUnit.block.Hash.String())
+		for pendingNumber := range p.workUnits {
+			if pendingNumber >= number {
+				p.remove(pendingNumber)
+			}
+		}
+		p.head = number
+	}
+	p.workUnits[number] = workUnit
+	for _, tx := range workUnit.txs {
+		p.txs[tx.Hash] = tx
+	}
+	if number > p.head {
+		p.head = number
+	}
+
+	if p.head < p.confirmationDepth {
+		return nil
+	}
+	confirmedNumber := p.head - p.confirmationDepth
+	var confirmed []*BlockAndTransactions
+	for pendingNumber, pendingUnit := range p.workUnits {
+		if pendingNumber <= confirmedNumber {
+			confirmed = append(confirmed, pendingUnit)
+			p.remove(pendingNumber)
+		}
+	}
+	sort.Slice(confirmed, func(i, j int) bool {
+		return confirmed[i].block.Number < confirmed[j].block.Number
+	})
+	return confirmed
+}
+
+func (p *PendingBlocks) ReadPendingBlock(number uint64) (*types.Block, error) {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	if workUnit, ok := p.workUnits[number]; ok {
+		return workUnit.block, nil
+	}
+	return nil, errors.New("pending block does not exist")
+}
+
+func (p *PendingBlocks) ReadPendingTransaction(hash types.Hash) (*types.Transaction, error) {
+	p.mux.RLock()
+	defer p.mux.RUnlock()
+	if tx, ok := p.txs[hash]; ok {
+		return tx, nil
+	}
+	return nil, errors.New("pending transaction does not exist")
+}
+
+func (p *PendingBlocks) remove(number uint64) {
+	for _, tx := range p.workUnits[number].txs {
+		delete(p.txs, tx.Hash)
+	}
+	delete(p.workUnits, number)
+}
@@ -0,0 +1,63 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+func TestPendingBlocks_NoConfirmationDepth(t *testing.T) {
+	pending := NewPendingBlocks(0)
+	workUnit := &BlockAndTransactions{block: newTestBlock(1, "0x1", "0x0")}
+
+	confirmed := pending.Add(workUnit)
+
+	assert.Equal(t, []*BlockAndTransactions{workUnit}, confirmed)
+	_, err := pending.ReadPendingBlock(1)
+	assert.EqualError(t, err, "pending block does not exist")
+}
+
+func TestPendingBlocks_ConfirmsBlocksBehindHead(t *testing.T) {
+	pending := NewPendingBlocks(2)
+	tx := &types.Transaction{Hash: types.NewHash("0xabc")}
+	first := &BlockAndTransactions{block: newTestBlock(1, "0x1", "0x0"), txs: []*types.Transaction{tx}}
+	second := &BlockAndTransactions{block: newTestBlock(2, "0x2", "0x1")}
+	third := &BlockAndTransactions{block: newTestBlock(3, "0x3", "0x2")}
+
+	assert.Len(t, pending.Add(second), 0)
+	assert.Len(t, pending.Add(first), 0)
+
+	block, err := pending.ReadPendingBlock(1)
+	assert.Nil(t, err)
+	assert.Equal(t, first.block, block)
+	pendingTx, err := pending.ReadPendingTransaction(tx.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, tx, pendingTx)
+
+	confirmed := pending.Add(third)
+
+	assert.Equal(t, []*BlockAndTransactions{first}, confirmed)
+	_, err = pending.ReadPendingBlock(1)
+	assert.EqualError(t, err, "pending block does not exist")
+	_, err = pending.ReadPendingTransaction(tx.Hash)
+	assert.EqualError(t, err, "pending transaction does not exist")
+}
+
+func TestPendingBlocks_ReplacesOrphanedBlocks(t *testing.T) {
+	pending := NewPendingBlocks(5)
+	pending.Add(&BlockAndTransactions{block: newTestBlock(1, "0x1", "0x0")})
+	pending.Add(&BlockAndTransactions{block: newTestBlock(2, "0x2", "0x1")})
+	pending.Add(&BlockAndTransactions{block: newTestBlock(3, "0x3", "0x2")})
+
+	pending.Add(&BlockAndTransactions{block: newTestBlock(2, "0x2b", "0x1")})
+
+	block, err := pending.ReadPendingBlock(2)
+	assert.Nil(t, err)
+	assert.Equal(t, types.NewHash("0x2b"), block.Hash)
+	_, err = pending.ReadPendingBlock(3)
+	assert.EqualError(t, err, "pending block does not exist")
+	_, err = pending.ReadPendingBlock(1)
+	assert.Nil(t, err)
+}
@@ -1,9 +1,11 @@
 package monitor
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"quorumengineering/quorum-report/client"
@@ -27,6 +29,16 @@ type MonitorService struct {
 	batchWriter    *BatchWriter
 	totalWorkers   int
 
+	// blocks waiting to reach the confirmation depth
+	pendingBlocks *PendingBlocks
+
+	// set while the block syncing loop is running
+	running int32
+
+	// cancelled when shutting down, to give up on calls to Quorum in flight
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	// To check we have actually shut down before returning
 	shutdownChan chan struct{}
 	shutdownWg   sync.WaitGroup
@@ -53,15 +65,20 @@ func NewMonitorService(db database.Database, quorumClient client.Client, consens
 	}
 	newBlockChan := make(chan *types.Block)
 	batchWriteChan := make(chan *BlockAndTransactions, config.Tuning.BlockProcessingQueueSize)
+	ctx, cancel := context.WithCancel(context.Background())
+	transactionMonitor := NewDefaultTransactionMonitor(quorumClient, config.Connection.TracerMode)
 	return &MonitorService{
 		db:                 db,
-		blockMonitor:       NewDefaultBlockMonitor(quorumClient, newBlockChan, consensus),
-		transactionMonitor: NewDefaultTransactionMonitor(quorumClient),
+		blockMonitor:       NewDefaultBlockMonitor(quorumClient, newBlockChan, consensus, transactionMonitor, config.Connection.ChainHeadMode, time.Duration(config.Connection.PollInterval)*time.Second),
+		transactionMonitor: transactionMonitor,
 		tokenMonitor:       NewDefaultTokenMonitor(quorumClient, rules),
 		newBlockChan:       newBlockChan,
 		batchWriteChan:     batchWriteChan,
-		batchWriter:        NewBatchWriter(db, batchWriteChan, config.Tuning.BlockProcessingFlushPeriod),
+		batchWriter:        NewBatchWriter(db, quorumClient, batchWriteChan, config.Tuning.BlockProcessingFlushPeriod),
 		totalWorkers:       3 * runtime.NumCPU(),
+		pendingBlocks:      NewPendingBlocks(config.Tuning.ConfirmationDepth),
+		ctx:                ctx,
+		cancel:             cancel,
 		shutdownChan:       make(chan struct{}),
 	}, nil
 }
@@ -79,16 +96,27 @@ func (m *MonitorService) Start() error {
 }
 
 func (m *MonitorService) Stop() {
+	m.cancel()
 	close(m.shutdownChan)
 	m.shutdownWg.Wait()
 	log.Info("Monitor service stopped")
 }
 
+// IsRunning checks whether the monitor is syncing blocks.
+func (m *MonitorService) IsRunning() bool {
+	return atomic.LoadInt32(&m.running) == 1
+}
+
+// PendingBlocks returns the processed blocks that are not yet confirmed.
+func (m *MonitorService) PendingBlocks() *PendingBlocks {
+	return m.pendingBlocks
+}
+
 func (m *MonitorService) startBatchWriter() {
 	log.Info("Starting batch writer")
 	go func() {
 		m.shutdownWg.Add(1)
-		m.batchWriter.Run(m.shutdownChan)
+		m.batchWriter.Run(m.ctx)
 		m.shutdownWg.Done()
 	}()
 }
@@ -110,7 +138,8 @@ func (m *MonitorService) startWorker(stopChan <-chan struct{}) {
 		case block := <-m.newBlockChan:
 			// Listen to new block channel and process if new block comes.
 			err := m.processBlock(block)
-			for err != nil {
+			// calls to Quorum are cancelled when shutting down, so stop retrying
+			for err != nil && m.ctx.Err() == nil {
 				log.Warn("Error processing block", "block number", block.Number, "err", err)
 				time.Sleep(time.Second)
 				err = m.processBlock(block)
@@ -133,6 +162,8 @@ func (m *MonitorService) run() {
 			b) if an error occurs setting up the historical block sync, cancel the chain head sub, wait and try again
 		2. If we receive a shutdown message, cancel the chain head listener, wait for the historical block sync to finish and return
 		3. If the chain head sub has an error, close the "cancelChan" which will stop the historical sync
+		4. If the batch writer rolled back a chain reorganisation, cancel both and start again, so that
+		   the historical sync fetches the canonical blocks above the common ancestor
 
 		Note: 	errors in the historical sync *after* it is set up will not propagate up to here, but instead be
 				handled internally. If the historical sync is cancelled, it returns without giving an error, allowing
@@ -144,15 +175,25 @@ func (m *MonitorService) run() {
 
 	log.Info("Start to sync blocks...")
 	m.shutdownWg.Add(1)
+	atomic.StoreInt32(&m.running, 1)
+	defer atomic.StoreInt32(&m.running, 0)
 
 	for {
+		// calls to Quorum fail once shutting down, so check before retrying
+		select {
+		case <-m.shutdownChan:
+			m.shutdownWg.Done()
+			return
+		default:
+		}
+
 		chStopChan := make(chan bool)
 		cancelChan := make(chan bool)
 		var wg sync.WaitGroup
 		wg.Add(1)
 
 		// listen to chain head
-		if err := m.blockMonitor.ListenToChainHead(cancelChan, chStopChan); err != nil {
+		if err := m.blockMonitor.ListenToChainHead(m.ctx, cancelChan, chStopChan); err != nil {
 			log.Error("Subscribe to chain head event error, retrying in 1 second", "err", err)
 			time.Sleep(time.Second)
 			continue
@@ -169,7 +210,7 @@ func (m *MonitorService) run() {
 
 		log.Info("Queried last persisted block", "block number", lastPersisted)
 		// sync historic blocks
-		if err := m.blockMonitor.SyncHistoricBlocks(lastPersisted, cancelChan, &wg); err != nil {
+		if err := m.blockMonitor.SyncHistoricBlocks(m.ctx, lastPersisted, cancelChan, &wg); err != nil {
 			log.Error("Sync historic blocks error, retrying in 1 second", "err", err)
 			close(chStopChan)
 			time.Sleep(time.Second)
@@ -187,20 +228,25 @@ func (m *MonitorService) run() {
 			wg.Wait()
 			log.Info("Retry in 1 second...")
 			time.Sleep(time.Second)
+		case <-m.batchWriter.ResyncChan:
+			log.Info("Resyncing blocks after chain reorganisation")
+			close(chStopChan)
+			<-cancelChan
+			wg.Wait()
 		}
 	}
 }
 
 func (m *MonitorService) processBlock(block *types.Block) error {
 	// Transaction monitor pulls all transactions for the given block.
-	fetchedTxns, err := m.transactionMonitor.PullTransactions(block)
+	fetchedTxns, err := m.transactionMonitor.PullTransactions(m.ctx, block)
 	if err != nil {
 		return err
 	}
 
 	// Token monitor checks if transaction deploys a contract matching auto registration rules.
 	for _, tx := range fetchedTxns {
-		tokenContracts, err := m.tokenMonitor.InspectTransaction(tx)
+		tokenContracts, err := m.tokenMonitor.InspectTransaction(m.ctx, tx)
 		if err != nil {
 			return err
 		}
@@ -211,12 +257,14 @@ func (m *MonitorService) processBlock(block *types.Block) error {
 		}
 	}
 
-	// batch write txs and blocks
+	// batch write txs and blocks once they are confirmed
 	workUnit := &BlockAndTransactions{
 		block: block,
 		txs:   fetchedTxns,
 	}
-	m.batchWriteChan <- workUnit
+	for _, confirmed := range m.pendingBlocks.Add(workUnit) {
+		m.batchWriteChan <- confirmed
+	}
 
 	return nil
 }
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"context"
 	"encoding/hex"
 	"strings"
 
@@ -30,7 +31,7 @@ type AddressWithMeta struct {
 }
 
 type TokenMonitor interface {
-	InspectTransaction(tx *types.Transaction) (map[types.Address]string, error)
+	InspectTransaction(ctx context.Context, tx *types.Transaction) (map[types.Address]string, error)
 }
 
 type DefaultTokenMonitor struct {
@@ -45,7 +46,7 @@ func NewDefaultTokenMonitor(quorumClient client.Client, rules []TokenRule) *Defa
 	}
 }
 
-func (tm *DefaultTokenMonitor) InspectTransaction(tx *types.Transaction) (map[types.Address]string, error) {
+func (tm *DefaultTokenMonitor) InspectTransaction(ctx context.Context, tx *types.Transaction) (map[types.Address]string, error) {
 	var addresses []AddressWithMeta
 	if !tx.CreatedContract.IsEmpty() {
 		addresses = append(addresses, AddressWithMeta{
@@ -61,7 +62,7 @@ func (tm *DefaultTokenMonitor) InspectTransaction(tx *types.Transaction) (map[ty
 			addressBytes := event.Data.AsBytes()[12:32]
 			address := types.NewAddress(hex.EncodeToString(addressBytes))
 
-			code, err := client.GetCode(tm.quorumClient, address, tx.BlockNumber-1)
+			code, err := client.GetCode(ctx, tm.quorumClient, address, tx.BlockNumber-1)
 			if err != nil {
 				return nil, err
 			}
@@ -94,7 +95,7 @@ func (tm *DefaultTokenMonitor) InspectTransaction(tx *types.Transaction) (map[ty
 				continue
 			}
 			// EIP165
-			contractType, err := tm.checkEIP165(rule, addressWithMeta.address, tx.BlockNumber)
+			contractType, err := tm.checkEIP165(ctx, rule, addressWithMeta.address, tx.BlockNumber)
 			if err != nil {
 				return nil, err
 			}
@@ -105,7 +106,7 @@ func (tm *DefaultTokenMonitor) InspectTransaction(tx *types.Transaction) (map[ty
 			}
 
 			// Check contract bytecode directly for all 4bytes presented in abi
-			contractBytecode, err := client.GetCode(tm.quorumClient, addressWithMeta.address, tx.BlockNumber)
+			contractBytecode, err := client.GetCode(ctx, tm.quorumClient, addressWithMeta.address, tx.BlockNumber)
 			if err != nil {
 				return nil, err
 			}
@@ -134,10 +135,10 @@ func (tm *DefaultTokenMonitor) checkRuleMeta(rule TokenRule, meta AddressWithMet
 	return true
 }
 
-func (tm *DefaultTokenMonitor) checkEIP165(rule TokenRule, address types.Address, blockNum uint64) (string, error) {
+func (tm *DefaultTokenMonitor) checkEIP165(ctx context.Context, rule TokenRule, address types.Address, blockNum uint64) (string, error) {
 	if rule.eip165 != "" {
 		//check if the contract implements EIP165
-		eip165Call, err := client.CallEIP165(tm.quorumClient, address, eip165Sig, blockNum)
+		eip165Call, err := client.CallEIP165(ctx, tm.quorumClient, address, eip165Sig, blockNum)
 		if err != nil {
 			return "", err
 		}
@@ -145,7 +146,7 @@ func (tm *DefaultTokenMonitor) checkEIP165(rule TokenRule, address types.Address
 			return "", nil
 		}
 
-		eip165CallCheck, err := client.CallEIP165(tm.quorumClient, address, eip165Check, blockNum)
+		eip165CallCheck, err := client.CallEIP165(ctx, tm.quorumClient, address, eip165Check, blockNum)
 		if err != nil {
 			return "", err
 		}
@@ -154,11 +155,12 @@ func (tm *DefaultTokenMonitor) checkEIP165(rule TokenRule, address types.Address
 		}
 
 		//now we know it implements EIP165, so lets check the interfaces
-		funcSig, err := hex.DecodeString(rule.eip165)
+		//interface ids may be given with or without a 0x prefix
+		funcSig, err := hex.DecodeString(strings.TrimPrefix(rule.eip165, "0x"))
 		if err != nil {
 			return "", err
 		}
-		detected, err := client.CallEIP165(tm.quorumClient, address, funcSig, blockNum)
+		detected, err := client.CallEIP165(ctx, tm.quorumClient, address, funcSig, blockNum)
 		if err != nil {
 			return "", err
 		}
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"testing"
@@ -16,7 +17,7 @@ type CustomEIP165StubClient struct {
 	implementedInterface string
 }
 
-func (stub *CustomEIP165StubClient) RPCCall(result interface{}, method string, args ...interface{}) error {
+func (stub *CustomEIP165StubClient) RPCCall(ctx context.Context, result interface{}, method string, args ...interface{}) error {
 	if method == "eth_call" {
 		msg := args[0].(types.EIP165Call)
 		if msg.Data[8:16] == "ffffffff" {
@@ -32,7 +33,7 @@ func (stub *CustomEIP165StubClient) RPCCall(result interface{}, method string, a
 			return nil
 		}
 	}
-	return stub.StubQuorumClient.RPCCall(result, method, args)
+	return stub.StubQuorumClient.RPCCall(ctx, result, method, args)
 }
 
 func TestDefaultTokenMonitor_InspectTransaction_EIP165WithERC20_External(t *testing.T) {
@@ -52,13 +53,34 @@ func TestDefaultTokenMonitor_InspectTransaction_EIP165WithERC20_External(t *test
 	}
 
 	tokenMonitor := NewDefaultTokenMonitor(stubClient, []TokenRule{{scope: types.AllScope, templateName: "ERC20", eip165: "36372b07"}})
-	res, err := tokenMonitor.InspectTransaction(tx)
+	res, err := tokenMonitor.InspectTransaction(context.Background(), tx)
 
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(res))
 	assert.Equal(t, res[types.NewAddress("987")], "ERC20")
 }
 
+func TestDefaultTokenMonitor_InspectTransaction_EIP165WithERC1155_PrefixedInterfaceId(t *testing.T) {
+	stubClient := &CustomEIP165StubClient{
+		client.NewStubQuorumClient(nil, nil),
+		"d9b67a26",
+	}
+
+	tx := &types.Transaction{
+		Hash:            types.NewHash("0xf4f803b8d6c6b38e0b15d6cfe80fd1dcea4270ad24e93385fca36512bb9c2c59"),
+		BlockHash:       types.NewHash("0xefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36"),
+		BlockNumber:     1,
+		CreatedContract: types.NewAddress("987"),
+	}
+
+	tokenMonitor := NewDefaultTokenMonitor(stubClient, []TokenRule{{scope: types.AllScope, templateName: "ERC1155", eip165: "0xd9b67a26"}})
+	res, err := tokenMonitor.InspectTransaction(context.Background(), tx)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, res[types.NewAddress("987")], "ERC1155")
+}
+
 func TestDefaultTokenMonitor_InspectTransaction_EIP165WithERC20_Internal(t *testing.T) {
 	stubClient := &CustomEIP165StubClient{
 		client.NewStubQuorumClient(nil, nil),
@@ -106,7 +128,7 @@ func TestDefaultTokenMonitor_InspectTransaction_EIP165WithERC20_Internal(t *test
 
 	for _, tst := range testMatrix {
 		tokenMonitor := NewDefaultTokenMonitor(stubClient, []TokenRule{tst.rule})
-		res, err := tokenMonitor.InspectTransaction(tx)
+		res, err := tokenMonitor.InspectTransaction(context.Background(), tx)
 
 		assert.Nil(t, err)
 		assert.Equal(t, len(res), len(tst.result))
@@ -167,7 +189,7 @@ func TestDefaultTokenMonitor_InspectTransaction_EIP165WithERC20_ContractExtensio
 
 	for _, tst := range testMatrix {
 		tokenMonitor := NewDefaultTokenMonitor(stubClient, []TokenRule{tst.rule})
-		res, err := tokenMonitor.InspectTransaction(tx)
+		res, err := tokenMonitor.InspectTransaction(context.Background(), tx)
 
 		assert.Nil(t, err)
 		assert.Equal(t, len(res), len(tst.result))
@@ -189,7 +211,7 @@ func TestDefaultTokenMonitor_InspectTransaction_EIP165WithERC721_External(t *tes
 	}
 
 	tokenMonitor := NewDefaultTokenMonitor(stubClient, []TokenRule{{scope: types.AllScope, templateName: "ERC721", eip165: "80ac58cd"}})
-	res, err := tokenMonitor.InspectTransaction(tx)
+	res, err := tokenMonitor.InspectTransaction(context.Background(), tx)
 
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(res))
@@ -243,7 +265,7 @@ func TestDefaultTokenMonitor_InspectTransaction_EIP165WithERC721(t *testing.T) {
 
 	for _, tst := range testMatrix {
 		tokenMonitor := NewDefaultTokenMonitor(stubClient, []TokenRule{tst.rule})
-		res, err := tokenMonitor.InspectTransaction(tx)
+		res, err := tokenMonitor.InspectTransaction(context.Background(), tx)
 
 		assert.Nil(t, err)
 		assert.Equal(t, len(res), len(tst.result))
@@ -307,7 +329,7 @@ func TestDefaultTokenMonitor_InspectTransaction_BytecodeInspection(t *testing.T)
 
 	for _, tst := range testMatrix {
 		tokenMonitor := NewDefaultTokenMonitor(stubClient, []TokenRule{tst.rule})
-		res, err := tokenMonitor.InspectTransaction(tx)
+		res, err := tokenMonitor.InspectTransaction(context.Background(), tx)
 
 		assert.Nil(t, err)
 		assert.Equal(t, len(tst.result), len(res))
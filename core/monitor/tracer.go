@@ -0,0 +1,287 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+// Tracer finds the internal calls of the transactions of a block, recording on
+// each transaction how they were found
+type Tracer interface {
+	Trace(ctx context.Context, block *types.Block, txs []*types.Transaction) error
+}
+
+func NewTracer(quorumClient client.Client, mode string) Tracer {
+	switch mode {
+	case types.NativeCallTracerMode:
+		return &callTracer{quorumClient: quorumClient, mode: mode, trace: client.TraceTransactionNative}
+	case types.BlockTracerMode:
+		return &blockTracer{quorumClient: quorumClient}
+	case types.StructLogTracerMode:
+		return &structLogTracer{quorumClient: quorumClient}
+	case types.NoTracerMode:
+		return &noTracer{}
+	default:
+		return &callTracer{quorumClient: quorumClient, mode: types.CallTracerMode, trace: client.TraceTransaction}
+	}
+}
+
+// callTracer traces each transaction with a call tracer
+type callTracer struct {
+	quorumClient client.Client
+	mode         string
+	trace        func(ctx context.Context, c client.Client, txHash types.Hash) (types.RawOuterCall, error)
+}
+
+func (t *callTracer) Trace(ctx context.Context, block *types.Block, txs []*types.Transaction) error {
+	for _, tx := range txs {
+		traceResp, err := t.trace(ctx, t.quorumClient, tx.Hash)
+		if err != nil {
+			return err
+		}
+		tx.InternalCalls = toInternalCalls(traceResp.Calls)
+		tx.TracerMode = t.mode
+		setRevert(tx, traceResp.Error, traceResp.Output)
+	}
+	return nil
+}
+
+// blockTracer traces all the transactions of the block with a single call
+type blockTracer struct {
+	quorumClient client.Client
+}
+
+func (t *blockTracer) Trace(ctx context.Context, block *types.Block, txs []*types.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+	traces, err := client.TraceBlock(ctx, t.quorumClient, block.Number)
+	if err != nil {
+		return err
+	}
+	if len(traces) != len(txs) {
+		return fmt.Errorf("block %d traced with %d transactions, expected %d", block.Number, len(traces), len(txs))
+	}
+	for i, tx := range txs {
+		tx.InternalCalls = toInternalCalls(traces[i].Calls)
+		tx.TracerMode = types.BlockTracerMode
+		setRevert(tx, traces[i].Error, traces[i].Output)
+	}
+	return nil
+}
+
+// structLogTracer finds the calls made by each transaction from the opcodes it
+// executed, for nodes where no call tracer is available. The input, output and gas
+// used of calls are not found.
+type structLogTracer struct {
+	quorumClient client.Client
+}
+
+func (t *structLogTracer) Trace(ctx context.Context, block *types.Block, txs []*types.Transaction) error {
+	for _, tx := range txs {
+		trace, err := client.TraceTransactionStructLogs(ctx, t.quorumClient, tx.Hash)
+		if err != nil {
+			return err
+		}
+		address := tx.To
+		if address.IsEmpty() {
+			address = tx.CreatedContract
+		}
+		tx.InternalCalls = callsFromStructLogs(address, trace.StructLogs)
+		tx.TracerMode = types.StructLogTracerMode
+		setRevert(tx, structLogsError(trace.StructLogs), types.NewHexData(trace.ReturnValue))
+	}
+	return nil
+}
+
+// noTracer skips tracing, leaving transactions without internal calls
+type noTracer struct{}
+
+func (t *noTracer) Trace(ctx context.Context, block *types.Block, txs []*types.Transaction) error {
+	for _, tx := range txs {
+		tx.InternalCalls = []*types.InternalCall{}
+		tx.TracerMode = types.NoTracerMode
+	}
+	return nil
+}
+
+func toInternalCalls(respCalls []types.RawInnerCall) []*types.InternalCall {
+	calls := flattenCalls(respCalls)
+	internalCalls := make([]*types.InternalCall, len(calls))
+	for i, respCall := range calls {
+		internalCalls[i] = &types.InternalCall{
+			From:    respCall.From,
+			To:      respCall.To,
+			Gas:     respCall.Gas.ToUint64(),
+			GasUsed: respCall.GasUsed.ToUint64(),
+			Value:   respCall.Value.ToBigInt(),
+			Input:   respCall.Input,
+			Output:  respCall.Output,
+			Type:    respCall.Type,
+			Error:   respCall.Error,
+		}
+		if respCall.Error != "" {
+			internalCalls[i].RevertReason = types.RevertReason(respCall.Error, respCall.Output)
+		}
+	}
+	return internalCalls
+}
+
+// setRevert records why the transaction failed, if it did
+func setRevert(tx *types.Transaction, traceErr string, output types.HexData) {
+	if tx.Status {
+		return
+	}
+	tx.RevertData = output
+	tx.RevertReason = types.RevertReason(traceErr, output)
+}
+
+// flattens the list of internal calls to a single list
+// e.g [1 [2 3 [4 5] 6 [7]]] -> [1 2 3 4 5 6 7]
+func flattenCalls(calls []types.RawInnerCall) []types.RawInnerCall {
+	if len(calls) == 0 {
+		return []types.RawInnerCall{}
+	}
+
+	var results []types.RawInnerCall
+	for _, c := range calls {
+		results = append(results, c)
+		results = append(results, flattenCalls(c.Calls)...)
+	}
+	return results
+}
+
+// structLogsError returns the error the transaction stopped with, which is on the
+// last opcode run in its own code
+func structLogsError(structLogs []types.RawStructLog) string {
+	for i := len(structLogs) - 1; i >= 0; i-- {
+		if structLogs[i].Depth == 1 {
+			return structLogs[i].Error
+		}
+	}
+	return ""
+}
+
+// callFrame is the code being run at a call depth
+type callFrame struct {
+	address types.Address
+	// the call creating the contract, if the frame runs its constructor
+	create *types.InternalCall
+	// the calls made as a contract being created, whose address is only known
+	// once its constructor returns
+	pending *pendingCalls
+}
+
+type pendingCalls struct {
+	calls []*types.InternalCall
+}
+
+// callsFromStructLogs finds the calls made by the opcodes executed, in the order
+// they are made, starting in the code at the address
+func callsFromStructLogs(address types.Address, structLogs []types.RawStructLog) []*types.InternalCall {
+	calls := []*types.InternalCall{}
+	frames := []*callFrame{{address: address}}
+	var entering *types.InternalCall
+	for _, structLog := range structLogs {
+		if entering != nil {
+			if structLog.Depth > len(frames) {
+				frames = append(frames, enterCall(frames[len(frames)-1], entering))
+			} else if isCreate(entering.Type) {
+				// created without running any code
+				entering.To = stackAddress(topOfStack(structLog))
+			}
+			entering = nil
+		}
+		for len(frames) > 1 && structLog.Depth < len(frames) {
+			frame := frames[len(frames)-1]
+			frames = frames[:len(frames)-1]
+			// the address of the contract created is left on the stack of its creator
+			if frame.create != nil {
+				frame.create.To = stackAddress(topOfStack(structLog))
+				for _, call := range frame.pending.calls {
+					call.From = frame.create.To
+				}
+			}
+		}
+
+		call := callFromStructLog(structLog)
+		if call == nil {
+			continue
+		}
+		frame := frames[len(frames)-1]
+		call.From = frame.address
+		if frame.pending != nil {
+			frame.pending.calls = append(frame.pending.calls, call)
+		}
+		calls = append(calls, call)
+		entering = call
+	}
+	return calls
+}
+
+// enterCall returns the frame of the code run by the call
+func enterCall(caller *callFrame, call *types.InternalCall) *callFrame {
+	switch call.Type {
+	case "CREATE", "CREATE2":
+		return &callFrame{create: call, pending: &pendingCalls{}}
+	case "DELEGATECALL", "CALLCODE":
+		// the code of the callee is run as the caller
+		return &callFrame{address: caller.address, pending: caller.pending}
+	default:
+		return &callFrame{address: call.To}
+	}
+}
+
+// callFromStructLog returns the call made by the opcode, or nil if it makes none
+func callFromStructLog(structLog types.RawStructLog) *types.InternalCall {
+	// arguments are taken from the top of the stack, which is at the end
+	arg := func(n int) *big.Int {
+		if len(structLog.Stack) <= n {
+			return new(big.Int)
+		}
+		return stackWord(structLog.Stack[len(structLog.Stack)-1-n])
+	}
+	switch structLog.Op {
+	case "CALL", "CALLCODE":
+		return &types.InternalCall{Type: structLog.Op, Gas: arg(0).Uint64(), To: stackAddress(arg(1)), Value: arg(2)}
+	case "DELEGATECALL", "STATICCALL":
+		return &types.InternalCall{Type: structLog.Op, Gas: arg(0).Uint64(), To: stackAddress(arg(1)), Value: new(big.Int)}
+	case "CREATE", "CREATE2":
+		return &types.InternalCall{Type: structLog.Op, Value: arg(0)}
+	}
+	return nil
+}
+
+func isCreate(callType string) bool {
+	return callType == "CREATE" || callType == "CREATE2"
+}
+
+func topOfStack(structLog types.RawStructLog) *big.Int {
+	if len(structLog.Stack) == 0 {
+		return new(big.Int)
+	}
+	return stackWord(structLog.Stack[len(structLog.Stack)-1])
+}
+
+// stackWord parses a stack item, which older nodes give as 32 bytes of hex and
+// newer nodes give as a 0x-prefixed hex number
+func stackWord(item string) *big.Int {
+	if len(item) >= 2 && item[0] == '0' && (item[1] == 'x' || item[1] == 'X') {
+		item = item[2:]
+	}
+	word, ok := new(big.Int).SetString(item, 16)
+	if !ok {
+		return new(big.Int)
+	}
+	return word
+}
+
+// stackAddress takes the address held in the lowest 20 bytes of a stack word
+func stackAddress(word *big.Int) types.Address {
+	hexWord := fmt.Sprintf("%064x", word)
+	return types.NewAddress(hexWord[len(hexWord)-40:])
+}
@@ -0,0 +1,115 @@
+package monitor
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestBlockTracer(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"debug_traceBlockByNumber0x2<*client.TraceConfig Value>": []types.RawTransactionTrace{
+			{Result: types.RawOuterCall{}},
+			{Result: types.RawOuterCall{Calls: []types.RawInnerCall{
+				{Type: "CALL", From: "1349f3e1b8d71effb47b840594ff27da7e603d17", To: "1932c48b2bf8102ba33b4a6b545c32236e342f34", Calls: []types.RawInnerCall{
+					{Type: "STATICCALL", From: "1932c48b2bf8102ba33b4a6b545c32236e342f34", To: "9d13c6d3afe1721beef56b55d303b09e021e27ab"},
+				}},
+			}}},
+		},
+	}
+	block := &types.Block{Number: 2}
+	txs := []*types.Transaction{{Hash: types.NewHash("0x01")}, {Hash: types.NewHash("0x02")}}
+
+	tracer := NewTracer(client.NewStubQuorumClient(nil, mockRPC), types.BlockTracerMode)
+	assert.Nil(t, tracer.Trace(context.Background(), block, txs))
+
+	assert.Len(t, txs[0].InternalCalls, 0)
+	assert.Len(t, txs[1].InternalCalls, 2)
+	assert.Equal(t, "STATICCALL", txs[1].InternalCalls[1].Type)
+	assert.Equal(t, types.BlockTracerMode, txs[0].TracerMode)
+	assert.Equal(t, types.BlockTracerMode, txs[1].TracerMode)
+
+	// the trace must be of the transactions of the block
+	err := tracer.Trace(context.Background(), block, txs[:1])
+	assert.EqualError(t, err, "block 2 traced with 2 transactions, expected 1")
+}
+
+func TestStructLogTracer(t *testing.T) {
+	mockRPC := map[string]interface{}{
+		"debug_traceTransaction0x0000000000000000000000000000000000000000000000000000000000000001<*client.StructLogConfig Value>": types.RawStructLogTrace{
+			StructLogs: []types.RawStructLog{
+				{Op: "PUSH1", Depth: 1},
+				{Op: "CALL", Depth: 1, Stack: []string{"0x0", "0x0", "0x0", "0x0", "0x5", "0xbb", "0x1000"}},
+				{Op: "PUSH1", Depth: 2},
+				{Op: "DELEGATECALL", Depth: 2, Stack: []string{"0x0", "0x0", "0x0", "0x0", "0xcc", "0x800"}},
+				{Op: "STOP", Depth: 3},
+				// a contract is created, which calls another during construction
+				{Op: "CREATE", Depth: 2, Stack: []string{"0x20", "0x0", "0x7"}},
+				{Op: "CALL", Depth: 3, Stack: []string{"0x0", "0x0", "0x0", "0x0", "0x0", "0xdd", "0x400"}},
+				{Op: "STOP", Depth: 4},
+				{Op: "RETURN", Depth: 3},
+				{Op: "POP", Depth: 2, Stack: []string{"00000000000000000000000000000000000000000000000000000000000000ee"}},
+				{Op: "STOP", Depth: 2},
+				{Op: "STOP", Depth: 1},
+			},
+		},
+	}
+	tx := &types.Transaction{Hash: types.NewHash("0x01"), To: types.NewAddress("0xaa")}
+
+	tracer := NewTracer(client.NewStubQuorumClient(nil, mockRPC), types.StructLogTracerMode)
+	assert.Nil(t, tracer.Trace(context.Background(), &types.Block{Number: 2}, []*types.Transaction{tx}))
+
+	expected := []*types.InternalCall{
+		{Type: "CALL", From: types.NewAddress("0xaa"), To: types.NewAddress("0xbb"), Gas: 0x1000},
+		{Type: "DELEGATECALL", From: types.NewAddress("0xbb"), To: types.NewAddress("0xcc"), Gas: 0x800},
+		{Type: "CREATE", From: types.NewAddress("0xbb"), To: types.NewAddress("0xee")},
+		{Type: "CALL", From: types.NewAddress("0xee"), To: types.NewAddress("0xdd"), Gas: 0x400},
+	}
+	// values are compared separately, as equal numbers can differ in representation
+	for i, value := range []int64{5, 0, 7, 0} {
+		assert.Equal(t, big.NewInt(value).String(), tx.InternalCalls[i].Value.String())
+		tx.InternalCalls[i].Value = nil
+	}
+	assert.Equal(t, expected, tx.InternalCalls)
+	assert.Equal(t, types.StructLogTracerMode, tx.TracerMode)
+}
+
+func TestNoTracer(t *testing.T) {
+	tx := &types.Transaction{Hash: types.NewHash("0x01")}
+
+	tracer := NewTracer(client.NewStubQuorumClient(nil, nil), types.NoTracerMode)
+	assert.Nil(t, tracer.Trace(context.Background(), &types.Block{Number: 2}, []*types.Transaction{tx}))
+
+	assert.Empty(t, tx.InternalCalls)
+	assert.Equal(t, types.NoTracerMode, tx.TracerMode)
+}
+
+func TestCallTracer_RecordsRevert(t *testing.T) {
+	revertData := "0x08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000a4e6f7420656e6f75676800000000000000000000000000000000000000000000"
+	mockRPC := map[string]interface{}{
+		"debug_traceTransaction0x0000000000000000000000000000000000000000000000000000000000000001<*client.TraceConfig Value>": types.RawOuterCall{
+			Error:  "execution reverted",
+			Output: types.NewHexData(revertData),
+			Calls: []types.RawInnerCall{
+				{Type: "CALL", From: "1349f3e1b8d71effb47b840594ff27da7e603d17", To: "1932c48b2bf8102ba33b4a6b545c32236e342f34", Error: "out of gas"},
+			},
+		},
+		"debug_traceTransaction0x0000000000000000000000000000000000000000000000000000000000000002<*client.TraceConfig Value>": types.RawOuterCall{},
+	}
+	failed := &types.Transaction{Hash: types.NewHash("0x01")}
+	succeeded := &types.Transaction{Hash: types.NewHash("0x02"), Status: true}
+
+	tracer := NewTracer(client.NewStubQuorumClient(nil, mockRPC), types.CallTracerMode)
+	assert.Nil(t, tracer.Trace(context.Background(), &types.Block{Number: 2}, []*types.Transaction{failed, succeeded}))
+
+	assert.Equal(t, "Not enough", failed.RevertReason)
+	assert.Equal(t, types.NewHexData(revertData), failed.RevertData)
+	assert.Equal(t, "out of gas", failed.InternalCalls[0].Error)
+	assert.Equal(t, "out of gas", failed.InternalCalls[0].RevertReason)
+	assert.Equal(t, "", succeeded.RevertReason)
+}
@@ -1,32 +1,145 @@
 package monitor
 
 import (
+	"context"
+	"fmt"
+	"sync"
+
 	"quorumengineering/quorum-report/client"
 	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
 )
 
 type TransactionMonitor interface {
-	PullTransactions(block *types.Block) ([]*types.Transaction, error)
+	PullTransactions(ctx context.Context, block *types.Block) ([]*types.Transaction, error)
 }
 
 type DefaultTransactionMonitor struct {
 	quorumClient client.Client
+	tracer       Tracer
+
+	// transactions of historic blocks fetched ahead of the blocks being processed,
+	// removed as each block is processed
+	prefetchMux    sync.Mutex
+	prefetched     map[uint64]client.BlockTransactions
+	prefetchedFrom uint64
 }
 
-func NewDefaultTransactionMonitor(quorumClient client.Client) *DefaultTransactionMonitor {
+func NewDefaultTransactionMonitor(quorumClient client.Client, tracerMode string) *DefaultTransactionMonitor {
 	return &DefaultTransactionMonitor{
 		quorumClient: quorumClient,
+		tracer:       NewTracer(quorumClient, tracerMode),
+		prefetched:   make(map[uint64]client.BlockTransactions),
 	}
 }
 
-func (tm *DefaultTransactionMonitor) PullTransactions(block *types.Block) ([]*types.Transaction, error) {
+// PrefetchTransactions fetches the transactions of the blocks from start to end
+// inclusive in a single query, to be used when each block is processed. Blocks
+// not prefetched are queried on their own.
+func (tm *DefaultTransactionMonitor) PrefetchTransactions(ctx context.Context, start, end uint64) {
+	blocks, err := client.BlockTransactionsInRange(ctx, tm.quorumClient, start, end)
+	if err != nil {
+		log.Warn("Fetching transactions of blocks failed, fetching each block instead", "start", start, "end", end, "err", err)
+		return
+	}
+
+	tm.prefetchMux.Lock()
+	defer tm.prefetchMux.Unlock()
+	// drop blocks left over from before the previous range, e.g. by a cancelled sync
+	for number := range tm.prefetched {
+		if number < tm.prefetchedFrom {
+			delete(tm.prefetched, number)
+		}
+	}
+	tm.prefetchedFrom = start
+	for _, block := range blocks {
+		if len(block.Transactions) > 0 {
+			tm.prefetched[block.Number.ToUint64()] = block
+		}
+	}
+}
+
+func (tm *DefaultTransactionMonitor) PullTransactions(ctx context.Context, block *types.Block) ([]*types.Transaction, error) {
 	log.Info("Fetching transactions", "block", block.Hash.String(), "blockNumber", block.Number)
 
+	if len(block.Transactions) == 0 {
+		return []*types.Transaction{}, nil
+	}
+
+	fetchedTransactions, err := tm.fetchTransactions(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+	// Find the internal calls of the transactions.
+	if err := tm.tracer.Trace(ctx, block, fetchedTransactions); err != nil {
+		return nil, err
+	}
+	return fetchedTransactions, nil
+}
+
+func (tm *DefaultTransactionMonitor) fetchTransactions(ctx context.Context, block *types.Block) ([]*types.Transaction, error) {
+	// Query all the transactions of the block at once by graphql, falling back
+	// to querying each transaction if that fails.
+	txOrigins, err := tm.fetchBlockTransactions(ctx, block)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		log.Warn("Fetching transactions of block failed, fetching each transaction instead", "blockNumber", block.Number, "err", err)
+		return tm.pullEachTransaction(ctx, block)
+	}
+
+	fetchedTransactions := make([]*types.Transaction, 0, len(txOrigins))
+	for i := range txOrigins {
+		fetchedTransactions = append(fetchedTransactions, tm.createTransaction(block, block.Transactions[i], &txOrigins[i]))
+	}
+	return fetchedTransactions, nil
+}
+
+// fetchBlockTransactions queries all the transactions of the block, checking
+// they are the transactions the block was seen with
+func (tm *DefaultTransactionMonitor) fetchBlockTransactions(ctx context.Context, block *types.Block) ([]client.Transaction, error) {
+	if blockOrigin, ok := tm.takePrefetched(block.Number); ok && sameTransactions(&blockOrigin, block) {
+		return blockOrigin.Transactions, nil
+	}
+
+	blockOrigin, err := client.BlockTransactionsByNumber(ctx, tm.quorumClient, block.Number)
+	if err != nil {
+		return nil, err
+	}
+	// the block may have been replaced by a chain reorganisation since it was seen
+	if !sameTransactions(&blockOrigin, block) {
+		return nil, fmt.Errorf("block %d changed since it was fetched", block.Number)
+	}
+	return blockOrigin.Transactions, nil
+}
+
+func (tm *DefaultTransactionMonitor) takePrefetched(number uint64) (client.BlockTransactions, bool) {
+	tm.prefetchMux.Lock()
+	defer tm.prefetchMux.Unlock()
+	blockOrigin, ok := tm.prefetched[number]
+	delete(tm.prefetched, number)
+	return blockOrigin, ok
+}
+
+// sameTransactions checks the fetched block is the block seen, with the same transactions
+func sameTransactions(blockOrigin *client.BlockTransactions, block *types.Block) bool {
+	if blockOrigin.Hash != block.Hash || len(blockOrigin.Transactions) != len(block.Transactions) {
+		return false
+	}
+	for i, txOrigin := range blockOrigin.Transactions {
+		if txOrigin.Hash != block.Transactions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (tm *DefaultTransactionMonitor) pullEachTransaction(ctx context.Context, block *types.Block) ([]*types.Transaction, error) {
 	fetchedTransactions := make([]*types.Transaction, 0, len(block.Transactions))
 	for _, txHash := range block.Transactions {
 		// Query transaction details by graphql.
-		tx, err := tm.fetchTransaction(block, txHash)
+		tx, err := tm.fetchTransaction(ctx, block, txHash)
 		if err != nil {
 			return nil, err
 		}
@@ -35,14 +148,18 @@ func (tm *DefaultTransactionMonitor) PullTransactions(block *types.Block) ([]*ty
 	return fetchedTransactions, nil
 }
 
-func (tm *DefaultTransactionMonitor) fetchTransaction(block *types.Block, hash types.Hash) (*types.Transaction, error) {
+func (tm *DefaultTransactionMonitor) fetchTransaction(ctx context.Context, block *types.Block, hash types.Hash) (*types.Transaction, error) {
 	log.Debug("Processing transaction", "hash", hash.String())
 
-	txOrigin, err := client.TransactionWithReceipt(tm.quorumClient, hash)
+	txOrigin, err := client.TransactionWithReceipt(ctx, tm.quorumClient, hash)
 	if err != nil {
 		return nil, err
 	}
+	return tm.createTransaction(block, hash, &txOrigin), nil
+}
 
+// createTransaction builds the transaction from its graphql result
+func (tm *DefaultTransactionMonitor) createTransaction(block *types.Block, hash types.Hash, txOrigin *client.Transaction) *types.Transaction {
 	tx := &types.Transaction{
 		Hash:              hash,
 		Status:            txOrigin.Status == "0x1",
@@ -52,10 +169,10 @@ func (tm *DefaultTransactionMonitor) fetchTransaction(block *types.Block, hash t
 		Nonce:             txOrigin.Nonce.ToUint64(),
 		From:              txOrigin.From.Address,
 		To:                txOrigin.To.Address,
-		Value:             txOrigin.Value.ToUint64(),
+		Value:             txOrigin.Value.ToBigInt(),
 		Gas:               txOrigin.Gas.ToUint64(),
 		GasUsed:           txOrigin.GasUsed.ToUint64(),
-		GasPrice:          txOrigin.GasPrice.ToUint64(),
+		GasPrice:          txOrigin.GasPrice.ToBigInt(),
 		CumulativeGasUsed: txOrigin.CumulativeGasUsed.ToUint64(),
 		CreatedContract:   txOrigin.CreatedContract.Address,
 		Data:              txOrigin.InputData,
@@ -78,40 +195,5 @@ func (tm *DefaultTransactionMonitor) fetchTransaction(block *types.Block, hash t
 			Timestamp:        block.Timestamp,
 		}
 	}
-
-	traceResp, err := client.TraceTransaction(tm.quorumClient, tx.Hash)
-	if err != nil {
-		return nil, err
-	}
-
-	calls := flattenCalls(traceResp.Calls)
-	tx.InternalCalls = make([]*types.InternalCall, len(calls))
-	for i, respCall := range calls {
-		tx.InternalCalls[i] = &types.InternalCall{
-			From:    respCall.From,
-			To:      respCall.To,
-			Gas:     respCall.Gas.ToUint64(),
-			GasUsed: respCall.GasUsed.ToUint64(),
-			Value:   respCall.Value.ToUint64(),
-			Input:   respCall.Input,
-			Output:  respCall.Output,
-			Type:    respCall.Type,
-		}
-	}
-	return tx, nil
-}
-
-//flattens the list of internal calls to a single list
-//e.g [1 [2 3 [4 5] 6 [7]]] -> [1 2 3 4 5 6 7]
-func flattenCalls(calls []types.RawInnerCall) []types.RawInnerCall {
-	if len(calls) == 0 {
-		return []types.RawInnerCall{}
-	}
-
-	var results []types.RawInnerCall
-	for _, c := range calls {
-		results = append(results, c)
-		results = append(results, flattenCalls(c.Calls)...)
-	}
-	return results
+	return tx
 }
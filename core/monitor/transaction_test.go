@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,7 +18,7 @@ var graphqlResp = map[string]interface{}{
 	"nonce":             "0x1",
 	"from":              map[string]interface{}{"address": "0xed9d02e382b34818e88b88a309c7fe71e65f419d"},
 	"to":                nil,
-	"value":             "0x0",
+	"value":             "0x3635c9adc5dea00000",
 	"gasPrice":          "0x0",
 	"gas":               "0x47b760",
 	"gasUsed":           "0x280a7",
@@ -57,21 +58,23 @@ func TestCreateTransaction(t *testing.T) {
 					Output:  "",
 					To:      "1932c48b2bf8102ba33b4a6b545c32236e342f34",
 					Type:    "CALL",
-					Value:   types.HexNumber(0),
+					Value:   types.HexBigNumber{},
 				},
 			},
 		},
 	}
 
-	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC))
-	tx, err := tm.fetchTransaction(testBlock, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))
+	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC), types.CallTracerMode)
+	tx, err := tm.fetchTransaction(context.Background(), testBlock, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"))
 	assert.Nil(t, err)
+	assert.Nil(t, tm.tracer.Trace(context.Background(), testBlock, []*types.Transaction{tx}))
 	assert.EqualValues(t, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"), tx.Hash)
 	assert.True(t, tx.Status)
 	assert.EqualValues(t, 2, tx.BlockNumber)
 	assert.EqualValues(t, 0, tx.Index)
 	assert.EqualValues(t, types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d"), tx.From)
 	assert.EqualValues(t, 4700000, tx.Gas)
+	assert.Equal(t, "1000000000000000000000", tx.Value.String())
 	assert.EqualValues(t, "0x608060405234801561001057600080fd5b506040516020806101a18339810180604052602081101561003057600080fd5b81019080805190602001909291905050508060008190555050610149806100586000396000f3fe608060405234801561001057600080fd5b506004361061005e576000357c0100000000000000000000000000000000000000000000000000000000900480632a1afcd91461006357806360fe47b1146100815780636d4ce63c146100af575b600080fd5b61006b6100cd565b6040518082815260200191505060405180910390f35b6100ad6004803603602081101561009757600080fd5b81019080803590602001909291905050506100d3565b005b6100b7610114565b6040518082815260200191505060405180910390f35b60005481565b806000819055507fefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36816040518082815260200191505060405180910390a150565b6000805490509056fea165627a7a7230582061f6956b053dbf99873b363ab3ba7bca70853ba5efbaff898cd840d71c54fc1d0029000000000000000000000000000000000000000000000000000000000000002a", tx.Data.String())
 	assert.EqualValues(t, "0x", tx.PrivateData.String())
 	assert.False(t, tx.IsPrivate)
@@ -98,7 +101,7 @@ func TestTransactionMonitor_PullTransactions(t *testing.T) {
 					Output:  "",
 					To:      "1932c48b2bf8102ba33b4a6b545c32236e342f34",
 					Type:    "CALL",
-					Value:   types.HexNumber(0),
+					Value:   types.HexBigNumber{},
 				},
 			},
 		},
@@ -111,9 +114,9 @@ func TestTransactionMonitor_PullTransactions(t *testing.T) {
 		},
 	}
 
-	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC))
+	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC), types.CallTracerMode)
 
-	txs, err := tm.PullTransactions(block)
+	txs, err := tm.PullTransactions(context.Background(), block)
 	assert.Nil(t, err, "unexpected error")
 	assert.Len(t, txs, 1)
 
@@ -131,4 +134,147 @@ func TestTransactionMonitor_PullTransactions(t *testing.T) {
 	assert.Len(t, tx.Events, 1)
 	assert.EqualValues(t, types.NewHash("0xefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36"), tx.Events[0].Topics[0])
 	assert.Len(t, tx.InternalCalls, 1)
+	assert.Equal(t, types.CallTracerMode, tx.TracerMode)
+}
+
+func TestTransactionMonitor_PullTransactions_WholeBlock(t *testing.T) {
+	mockGraphQL := map[string]map[string]interface{}{
+		client.BlockTransactionsQuery(2): {
+			"block": interface{}(map[string]interface{}{
+				"number":       "0x2",
+				"hash":         "0xd3b57e8a791a134ddf47772f12fdddbf67480377e633bf55f411166d3be7d66f",
+				"transactions": []interface{}{graphqlResp},
+			}),
+		},
+	}
+	mockRPC := map[string]interface{}{
+		"debug_traceTransaction0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8<*client.TraceConfig Value>": types.RawOuterCall{},
+	}
+	block := &types.Block{
+		Hash:   types.NewHash("0xd3b57e8a791a134ddf47772f12fdddbf67480377e633bf55f411166d3be7d66f"),
+		Number: 2,
+		Transactions: []types.Hash{
+			types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"),
+		},
+	}
+
+	// no per-transaction query is mocked, so the block query must be used
+	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC), types.CallTracerMode)
+
+	txs, err := tm.PullTransactions(context.Background(), block)
+	assert.Nil(t, err, "unexpected error")
+	assert.Len(t, txs, 1)
+	assert.EqualValues(t, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"), txs[0].Hash)
+	assert.EqualValues(t, 2, txs[0].BlockNumber)
+	assert.Len(t, txs[0].Events, 1)
+}
+
+func TestTransactionMonitor_PullTransactions_BlockChanged(t *testing.T) {
+	mockGraphQL := map[string]map[string]interface{}{
+		client.BlockTransactionsQuery(2): {
+			"block": interface{}(map[string]interface{}{
+				"number":       "0x2",
+				"hash":         "0x0000000000000000000000000000000000000000000000000000000000000001",
+				"transactions": []interface{}{},
+			}),
+		},
+		client.TransactionDetailQuery(types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8")): {
+			"transaction": interface{}(graphqlResp),
+		},
+	}
+	mockRPC := map[string]interface{}{
+		"debug_traceTransaction0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8<*client.TraceConfig Value>": types.RawOuterCall{},
+	}
+	block := &types.Block{
+		Hash:   types.NewHash("0xd3b57e8a791a134ddf47772f12fdddbf67480377e633bf55f411166d3be7d66f"),
+		Number: 2,
+		Transactions: []types.Hash{
+			types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"),
+		},
+	}
+
+	// the block fetched no longer matches, so each transaction is fetched by hash
+	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC), types.CallTracerMode)
+
+	txs, err := tm.PullTransactions(context.Background(), block)
+	assert.Nil(t, err, "unexpected error")
+	assert.Len(t, txs, 1)
+	assert.EqualValues(t, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"), txs[0].Hash)
+}
+
+func TestTransactionMonitor_PrefetchTransactions(t *testing.T) {
+	mockGraphQL := map[string]map[string]interface{}{
+		client.BlockRangeTransactionsQuery(1, 2): {
+			"blocks": []interface{}{
+				map[string]interface{}{"number": "0x1", "hash": "0x0000000000000000000000000000000000000000000000000000000000000001", "transactions": []interface{}{}},
+				map[string]interface{}{
+					"number":       "0x2",
+					"hash":         "0xd3b57e8a791a134ddf47772f12fdddbf67480377e633bf55f411166d3be7d66f",
+					"transactions": []interface{}{graphqlResp},
+				},
+			},
+		},
+	}
+	mockRPC := map[string]interface{}{
+		"debug_traceTransaction0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8<*client.TraceConfig Value>": types.RawOuterCall{},
+	}
+	block := &types.Block{
+		Hash:   types.NewHash("0xd3b57e8a791a134ddf47772f12fdddbf67480377e633bf55f411166d3be7d66f"),
+		Number: 2,
+		Transactions: []types.Hash{
+			types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"),
+		},
+	}
+
+	// no block or per-transaction query is mocked, so the range query must be used
+	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC), types.CallTracerMode)
+	tm.PrefetchTransactions(context.Background(), 1, 2)
+	assert.Len(t, tm.prefetched, 1)
+
+	txs, err := tm.PullTransactions(context.Background(), block)
+	assert.Nil(t, err, "unexpected error")
+	assert.Len(t, txs, 1)
+	assert.EqualValues(t, types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"), txs[0].Hash)
+	assert.Len(t, txs[0].Events, 1)
+	assert.Empty(t, tm.prefetched)
+}
+
+func TestTransactionMonitor_PrefetchTransactions_BlockChanged(t *testing.T) {
+	mockGraphQL := map[string]map[string]interface{}{
+		client.BlockRangeTransactionsQuery(2, 2): {
+			"blocks": []interface{}{
+				map[string]interface{}{
+					"number":       "0x2",
+					"hash":         "0x0000000000000000000000000000000000000000000000000000000000000001",
+					"transactions": []interface{}{graphqlResp},
+				},
+			},
+		},
+		client.BlockTransactionsQuery(2): {
+			"block": interface{}(map[string]interface{}{
+				"number":       "0x2",
+				"hash":         "0xd3b57e8a791a134ddf47772f12fdddbf67480377e633bf55f411166d3be7d66f",
+				"transactions": []interface{}{graphqlResp},
+			}),
+		},
+	}
+	mockRPC := map[string]interface{}{
+		"debug_traceTransaction0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8<*client.TraceConfig Value>": types.RawOuterCall{},
+	}
+	block := &types.Block{
+		Hash:   types.NewHash("0xd3b57e8a791a134ddf47772f12fdddbf67480377e633bf55f411166d3be7d66f"),
+		Number: 2,
+		Transactions: []types.Hash{
+			types.NewHash("0xe625ba9f14eed0671508966080fb01374d0a3a16b9cee545a324179b75f30aa8"),
+		},
+	}
+
+	// the prefetched block no longer matches, so the block is queried again
+	tm := NewDefaultTransactionMonitor(client.NewStubQuorumClient(mockGraphQL, mockRPC), types.CallTracerMode)
+	tm.PrefetchTransactions(context.Background(), 2, 2)
+
+	txs, err := tm.PullTransactions(context.Background(), block)
+	assert.Nil(t, err, "unexpected error")
+	assert.Len(t, txs, 1)
+	assert.EqualValues(t, 2, txs[0].BlockNumber)
 }
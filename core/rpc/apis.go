@@ -4,18 +4,27 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"quorumengineering/quorum-report/core/auth"
 	"quorumengineering/quorum-report/core/storageparsing"
+	"quorumengineering/quorum-report/core/webhook"
 	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/types"
 )
 
+// PendingBlockReader reads blocks that have not yet reached the confirmation depth
+type PendingBlockReader interface {
+	ReadPendingBlock(uint64) (*types.Block, error)
+	ReadPendingTransaction(types.Hash) (*types.Transaction, error)
+}
+
 type RPCAPIs struct {
 	db                      database.Database
+	pending                 PendingBlockReader
 	contractTemplateManager ContractTemplateManager
 }
 
-func NewRPCAPIs(db database.Database, contractTemplateManager ContractTemplateManager) *RPCAPIs {
-	return &RPCAPIs{db, contractTemplateManager}
+func NewRPCAPIs(db database.Database, pending PendingBlockReader, contractTemplateManager ContractTemplateManager) *RPCAPIs {
+	return &RPCAPIs{db, pending, contractTemplateManager}
 }
 
 func (r *RPCAPIs) GetLastPersistedBlockNumber(req *http.Request, args *NullArgs, reply *uint64) error {
@@ -36,23 +45,60 @@ func (r *RPCAPIs) GetLastFiltered(req *http.Request, args *types.Address, reply
 	return nil
 }
 
-func (r *RPCAPIs) GetBlock(req *http.Request, blockNumber *uint64, reply *types.Block) error {
-	block, err := r.db.ReadBlock(*blockNumber)
+func (r *RPCAPIs) GetBlock(req *http.Request, args *BlockNumberWithOptions, reply *types.Block) error {
+	block, err := r.db.ReadBlock(args.BlockNumber)
+	if err != nil && args.IncludePending && r.pending != nil {
+		if pendingBlock, pendingErr := r.pending.ReadPendingBlock(args.BlockNumber); pendingErr == nil {
+			block, err = pendingBlock, nil
+		}
+	}
 	if err != nil {
 		return err
 	}
+
+	// restricted clients only see the transactions that involve their addresses
+	if id := auth.FromContext(req.Context()); id != nil && id.IsRestricted() {
+		visible := *block
+		visible.Transactions = make([]types.Hash, 0, len(block.Transactions))
+		for _, hash := range block.Transactions {
+			tx, err := r.readTransaction(hash, args.IncludePending)
+			if err != nil {
+				return err
+			}
+			if _, ok := visibleTransaction(id, tx); ok {
+				visible.Transactions = append(visible.Transactions, hash)
+			}
+		}
+		block = &visible
+	}
 	*reply = *block
 	return nil
 }
 
-func (r *RPCAPIs) GetTransaction(req *http.Request, hash *types.Hash, reply *types.ParsedTransaction) error {
-	if hash.IsEmpty() {
+// readTransaction reads a persisted transaction, or a pending one if asked for
+// and the transaction has not been persisted
+func (r *RPCAPIs) readTransaction(hash types.Hash, includePending bool) (*types.Transaction, error) {
+	tx, err := r.db.ReadTransaction(hash)
+	if err != nil && includePending && r.pending != nil {
+		if pendingTx, pendingErr := r.pending.ReadPendingTransaction(hash); pendingErr == nil {
+			tx, err = pendingTx, nil
+		}
+	}
+	return tx, err
+}
+
+func (r *RPCAPIs) GetTransaction(req *http.Request, args *HashWithOptions, reply *types.ParsedTransaction) error {
+	if args.Hash.IsEmpty() {
 		return errors.New("no transaction hash given")
 	}
-	tx, err := r.db.ReadTransaction(*hash)
+	tx, err := r.readTransaction(args.Hash, args.IncludePending)
 	if err != nil {
 		return err
 	}
+	tx, ok := visibleTransaction(auth.FromContext(req.Context()), tx)
+	if !ok {
+		return ErrAddressNotPermitted
+	}
 	address := tx.To
 	if address.IsEmpty() {
 		address = tx.CreatedContract
@@ -84,6 +130,24 @@ func (r *RPCAPIs) GetTransaction(req *http.Request, hash *types.Hash, reply *typ
 			}
 		}
 	}
+	for _, call := range parsedTx.RawTransaction.InternalCalls {
+		if call.Error == "" {
+			continue
+		}
+		parsedCall := &types.ParsedInternalCall{
+			RawCall: call,
+		}
+		contractABI, err := r.db.GetContractABI(call.To)
+		if err != nil {
+			return err
+		}
+		if contractABI != "" {
+			if err := parsedCall.ParseRevert(contractABI); err != nil {
+				return err
+			}
+		}
+		parsedTx.FailedInternalCalls = append(parsedTx.FailedInternalCalls, parsedCall)
+	}
 	*reply = *parsedTx
 	return nil
 }
@@ -152,6 +216,32 @@ func (r *RPCAPIs) GetAllTransactionsInternalToAddress(req *http.Request, args *A
 	return nil
 }
 
+func (r *RPCAPIs) GetAllFailedTransactionsToAddress(req *http.Request, args *AddressWithOptions, reply *TransactionsResp) error {
+	if args.Address == nil {
+		return ErrNoAddress
+	}
+	if args.Options == nil {
+		args.Options = &types.QueryOptions{}
+	}
+	args.Options.SetDefaults()
+
+	total, err := r.db.GetFailedTransactionsToAddressTotal(*args.Address, args.Options)
+	if err != nil {
+		return err
+	}
+	txs, err := r.db.GetAllFailedTransactionsToAddress(*args.Address, args.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = TransactionsResp{
+		Transactions: txs,
+		Total:        total,
+		Options:      args.Options,
+	}
+	return nil
+}
+
 func (r *RPCAPIs) GetAllEventsFromAddress(req *http.Request, args *AddressWithOptions, reply *EventsResp) error {
 	if args.Address == nil {
 		return ErrNoAddress
@@ -312,7 +402,7 @@ func (r *RPCAPIs) GetAddresses(req *http.Request, args *NullArgs, reply *[]types
 	if err != nil {
 		return err
 	}
-	*reply = result
+	*reply = visibleAddresses(auth.FromContext(req.Context()), result)
 	return nil
 }
 
@@ -404,3 +494,63 @@ func (r *RPCAPIs) GetTemplateDetails(req *http.Request, templateName *string, re
 	*reply = *template
 	return nil
 }
+
+func (r *RPCAPIs) AddWebhook(req *http.Request, args *WebhookArgs, reply *string) error {
+	if args.Address == nil {
+		return ErrNoAddress
+	}
+	id, err := webhook.Register(r.db, &types.WebhookConfig{
+		Address:        *args.Address,
+		Kind:           args.Kind,
+		EventSignature: args.EventSignature,
+		URL:            args.URL,
+		Secret:         args.Secret,
+	})
+	if err != nil {
+		return err
+	}
+	*reply = id
+	return nil
+}
+
+func (r *RPCAPIs) DeleteWebhook(req *http.Request, id *string, reply *NullArgs) error {
+	if identity := auth.FromContext(req.Context()); identity != nil && identity.IsRestricted() {
+		webhooks, err := r.db.GetWebhooks()
+		if err != nil {
+			return err
+		}
+		permitted := false
+		for _, webhook := range webhooks {
+			if webhook.ID == *id {
+				permitted = identity.CanView(webhook.Address)
+			}
+		}
+		if !permitted {
+			return errors.New("webhook does not exist")
+		}
+	}
+	err := r.db.DeleteWebhook(*id)
+	if err == database.ErrNotFound {
+		return errors.New("webhook does not exist")
+	}
+	return err
+}
+
+func (r *RPCAPIs) GetWebhooks(req *http.Request, args *NullArgs, reply *[]*types.Webhook) error {
+	webhooks, err := r.db.GetWebhooks()
+	if err != nil {
+		return err
+	}
+	identity := auth.FromContext(req.Context())
+	visible := make([]*types.Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if identity != nil && !identity.CanView(webhook.Address) {
+			continue
+		}
+		// secrets are never given out
+		webhook.Secret = ""
+		visible = append(visible, webhook)
+	}
+	*reply = visible
+	return nil
+}
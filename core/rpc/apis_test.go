@@ -1,6 +1,8 @@
 package rpc
 
 import (
+	"encoding/json"
+	"errors"
 	"math/big"
 	"net/http"
 	"testing"
@@ -85,7 +87,7 @@ var (
 
 func TestAPIValidation(t *testing.T) {
 	db := memory.NewMemoryDB()
-	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	apis := NewRPCAPIs(db, nil, NewDefaultContractManager(db))
 
 	err := apis.AddAddress(dummyReq, &AddressWithOptionalBlock{}, nil)
 	assert.EqualError(t, err, "address not provided")
@@ -93,7 +95,7 @@ func TestAPIValidation(t *testing.T) {
 
 func TestAPIParsing(t *testing.T) {
 	db := memory.NewMemoryDB()
-	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	apis := NewRPCAPIs(db, nil, NewDefaultContractManager(db))
 	err := apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &addr}, nil)
 	assert.Nil(t, err)
 
@@ -112,20 +114,20 @@ func TestAPIParsing(t *testing.T) {
 	assert.Nil(t, err)
 	// Test GetTransaction parse transaction data.
 	parsedTx1 := &types.ParsedTransaction{}
-	err = apis.GetTransaction(dummyReq, &tx1.Hash, parsedTx1)
+	err = apis.GetTransaction(dummyReq, &HashWithOptions{Hash: tx1.Hash}, parsedTx1)
 	assert.Nil(t, err)
 	assert.Equal(t, "constructor(uint256 _initVal)", parsedTx1.Sig)
 	assert.Equal(t, big.NewInt(42), parsedTx1.ParsedData["_initVal"])
 
 	parsedTx2 := &types.ParsedTransaction{}
-	err = apis.GetTransaction(dummyReq, &tx2.Hash, parsedTx2)
+	err = apis.GetTransaction(dummyReq, &HashWithOptions{Hash: tx2.Hash}, parsedTx2)
 	assert.Nil(t, err)
 	assert.Equal(t, "set(uint256 _x)", parsedTx2.Sig)
 	assert.Equal(t, big.NewInt(999), parsedTx2.ParsedData["_x"])
 	assert.Equal(t, "0x60fe47b1", parsedTx2.Func4Bytes.String())
 
 	parsedTx3 := &types.ParsedTransaction{}
-	err = apis.GetTransaction(dummyReq, &tx3.Hash, parsedTx3)
+	err = apis.GetTransaction(dummyReq, &HashWithOptions{Hash: tx3.Hash}, parsedTx3)
 	assert.Nil(t, err)
 	assert.Equal(t, "event valueSet(uint256 _value)", parsedTx3.ParsedEvents[0].Sig)
 	assert.Equal(t, big.NewInt(1000), parsedTx3.ParsedEvents[0].ParsedData["_value"])
@@ -141,9 +143,75 @@ func TestAPIParsing(t *testing.T) {
 	assert.Equal(t, big.NewInt(1000), eventsResp.Events[0].ParsedData["_value"])
 }
 
+func TestGetAllFailedTransactionsToAddress(t *testing.T) {
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, nil, NewDefaultContractManager(db))
+	assert.Nil(t, apis.AddAddress(dummyReq, &AddressWithOptionalBlock{Address: &addr}, nil))
+	errorABI := `[{"inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}],"name":"InsufficientBalance","type":"error"}]`
+	assert.Nil(t, apis.AddABI(dummyReq, &AddressWithData{&addr, errorABI}, nil))
+
+	succeeded := &types.Transaction{
+		Hash:        tx2.Hash,
+		BlockNumber: 1,
+		To:          addr,
+		Data:        types.NewHexData("0xa9059cbb"),
+		Status:      true,
+	}
+	failed := &types.Transaction{
+		Hash:         tx3.Hash,
+		BlockNumber:  1,
+		To:           addr,
+		Data:         types.NewHexData("0xa9059cbb"),
+		RevertData:   types.NewHexData("0xcf47918100000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002"),
+		RevertReason: "custom error 0xcf479181",
+		InternalCalls: []*types.InternalCall{
+			{From: addr, To: addr, Output: types.NewHexData("0xcf47918100000000000000000000000000000000000000000000000000000000000000030000000000000000000000000000000000000000000000000000000000000004"), Error: "execution reverted", RevertReason: "custom error 0xcf479181"},
+			{From: addr, To: addr},
+		},
+	}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{succeeded, failed}))
+	assert.Nil(t, db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{{Number: 1, Transactions: []*types.Transaction{succeeded, failed}}}))
+
+	txsResp := &TransactionsResp{}
+	err := apis.GetAllFailedTransactionsToAddress(dummyReq, &AddressWithOptions{Address: &addr}, txsResp)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{failed.Hash}, txsResp.Transactions)
+	assert.EqualValues(t, 1, txsResp.Total)
+
+	// Test GetTransaction parse custom error.
+	parsedTx := &types.ParsedTransaction{}
+	err = apis.GetTransaction(dummyReq, &HashWithOptions{Hash: failed.Hash}, parsedTx)
+	assert.Nil(t, err)
+	assert.Equal(t, "error InsufficientBalance(uint256 available,uint256 required)", parsedTx.RevertSig)
+	assert.Equal(t, big.NewInt(1), parsedTx.ParsedRevertData["available"])
+	assert.Equal(t, big.NewInt(2), parsedTx.ParsedRevertData["required"])
+
+	// custom errors of failed internal calls are decoded against the called contract
+	if assert.Len(t, parsedTx.FailedInternalCalls, 1) {
+		assert.Equal(t, "error InsufficientBalance(uint256 available,uint256 required)", parsedTx.FailedInternalCalls[0].RevertSig)
+		assert.Equal(t, big.NewInt(3), parsedTx.FailedInternalCalls[0].ParsedRevertData["available"])
+		assert.Equal(t, big.NewInt(4), parsedTx.FailedInternalCalls[0].ParsedRevertData["required"])
+	}
+
+	// revert data that cannot be decoded is given raw
+	truncated := &types.Transaction{
+		Hash:        types.NewHash("0x4"),
+		BlockNumber: 1,
+		To:          addr,
+		Data:        types.NewHexData("0xa9059cbb"),
+		RevertData:  types.NewHexData("0xcf4791810000000000000000000000000000000000000000000000000000000000000001"),
+	}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{truncated}))
+	parsedTx = &types.ParsedTransaction{}
+	err = apis.GetTransaction(dummyReq, &HashWithOptions{Hash: truncated.Hash}, parsedTx)
+	assert.Nil(t, err)
+	assert.Equal(t, "error InsufficientBalance(uint256 available,uint256 required)", parsedTx.RevertSig)
+	assert.Equal(t, "0x0000000000000000000000000000000000000000000000000000000000000001", parsedTx.ParsedRevertData["data"])
+}
+
 func TestAddAddressWithFrom(t *testing.T) {
 	db := memory.NewMemoryDB()
-	apis := NewRPCAPIs(db, NewDefaultContractManager(db))
+	apis := NewRPCAPIs(db, nil, NewDefaultContractManager(db))
 	from := uint64(100)
 
 	params := &AddressWithOptionalBlock{
@@ -158,3 +226,83 @@ func TestAddAddressWithFrom(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, from-1, lastFiltered)
 }
+
+func TestWebhooks(t *testing.T) {
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, nil, NewDefaultContractManager(db))
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+
+	var id string
+	err := apis.AddWebhook(dummyReq, &WebhookArgs{URL: "http://localhost/hook"}, &id)
+	assert.Equal(t, ErrNoAddress, err)
+	err = apis.AddWebhook(dummyReq, &WebhookArgs{Address: &addr, URL: "http://localhost/hook", Secret: "secret"}, &id)
+	assert.Nil(t, err)
+
+	var webhooks []*types.Webhook
+	err = apis.GetWebhooks(dummyReq, nil, &webhooks)
+	assert.Nil(t, err)
+	assert.Len(t, webhooks, 1)
+	assert.Equal(t, id, webhooks[0].ID)
+	assert.Equal(t, "", webhooks[0].Secret)
+
+	err = apis.DeleteWebhook(dummyReq, &id, nil)
+	assert.Nil(t, err)
+	err = apis.DeleteWebhook(dummyReq, &id, nil)
+	assert.EqualError(t, err, "webhook does not exist")
+}
+
+type stubPendingBlocks struct {
+	block *types.Block
+	tx    *types.Transaction
+}
+
+func (p *stubPendingBlocks) ReadPendingBlock(number uint64) (*types.Block, error) {
+	if p.block != nil && p.block.Number == number {
+		return p.block, nil
+	}
+	return nil, errors.New("pending block does not exist")
+}
+
+func (p *stubPendingBlocks) ReadPendingTransaction(hash types.Hash) (*types.Transaction, error) {
+	if p.tx != nil && p.tx.Hash == hash {
+		return p.tx, nil
+	}
+	return nil, errors.New("pending transaction does not exist")
+}
+
+func TestGetBlockAndTransaction_IncludePending(t *testing.T) {
+	db := memory.NewMemoryDB()
+	apis := NewRPCAPIs(db, &stubPendingBlocks{block: block, tx: tx2}, NewDefaultContractManager(db))
+
+	var retrievedBlock types.Block
+	err := apis.GetBlock(dummyReq, &BlockNumberWithOptions{BlockNumber: 1}, &retrievedBlock)
+	assert.EqualError(t, err, "block does not exist")
+	err = apis.GetBlock(dummyReq, &BlockNumberWithOptions{BlockNumber: 1, IncludePending: true}, &retrievedBlock)
+	assert.Nil(t, err)
+	assert.Equal(t, *block, retrievedBlock)
+
+	parsedTx := &types.ParsedTransaction{}
+	err = apis.GetTransaction(dummyReq, &HashWithOptions{Hash: tx2.Hash}, parsedTx)
+	assert.EqualError(t, err, "transaction does not exist")
+	err = apis.GetTransaction(dummyReq, &HashWithOptions{Hash: tx2.Hash, IncludePending: true}, parsedTx)
+	assert.Nil(t, err)
+	assert.Equal(t, tx2, parsedTx.RawTransaction)
+}
+
+func TestBlockNumberWithOptions_UnmarshalJSON(t *testing.T) {
+	var args BlockNumberWithOptions
+	err := json.Unmarshal([]byte(`5`), &args)
+	assert.Nil(t, err)
+	assert.Equal(t, BlockNumberWithOptions{BlockNumber: 5}, args)
+
+	args = BlockNumberWithOptions{}
+	err = json.Unmarshal([]byte(`{"blockNumber": 6, "includePending": true}`), &args)
+	assert.Nil(t, err)
+	assert.Equal(t, BlockNumberWithOptions{BlockNumber: 6, IncludePending: true}, args)
+
+	var hashArgs HashWithOptions
+	err = json.Unmarshal([]byte(`"0x1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7"`), &hashArgs)
+	assert.Nil(t, err)
+	assert.Equal(t, types.NewHash("0x1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7"), hashArgs.Hash)
+	assert.False(t, hashArgs.IncludePending)
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gorilla/rpc/v2"
+
+	"quorumengineering/quorum-report/core/auth"
+	"quorumengineering/quorum-report/types"
+)
+
+var ErrAddressNotPermitted = errors.New("address not permitted")
+
+// adminMethods change what is indexed and how, and need the admin role. All
+// other methods only need the read role.
+var adminMethods = map[string]bool{
+	"reporting.AddAddress":     true,
+	"reporting.DeleteAddress":  true,
+	"reporting.AddABI":         true,
+	"reporting.AddStorageABI":  true,
+	"reporting.AddTemplate":    true,
+	"reporting.AssignTemplate": true,
+	"reporting.AddWebhook":     true,
+	"reporting.DeleteWebhook":  true,
+	"reporting.GetWebhooks":    true,
+}
+
+// authorize checks the authenticated client has the role needed for the method,
+// and can see any address it is given.
+func authorize(info *rpc.RequestInfo, args interface{}) error {
+	id := auth.FromContext(info.Request.Context())
+	if id == nil {
+		return auth.ErrNoCredentials
+	}
+	role := types.ReadRole
+	if adminMethods[info.Method] {
+		role = types.AdminRole
+	}
+	if !id.HasRole(role) {
+		return fmt.Errorf("permission denied: %s role required", role)
+	}
+	if address := requestAddress(args); address != nil && !id.CanView(*address) {
+		return ErrAddressNotPermitted
+	}
+	return nil
+}
+
+// requestAddress returns the registered address a request is for, if any. Token
+// holders are not registered addresses, so are not checked, but ether holders are
+// registered addresses or the accounts they transact with.
+func requestAddress(args interface{}) *types.Address {
+	switch a := args.(type) {
+	case *types.Address:
+		return a
+	case *AddressWithOptions:
+		return a.Address
+	case *AddressWithData:
+		return a.Address
+	case *AddressWithOptionalBlock:
+		return a.Address
+	case *AddressWithBlockRange:
+		return a.Address
+	case *WebhookArgs:
+		return a.Address
+	case *ERC20TokenQuery:
+		return a.Contract
+	case *ERC721TokenQuery:
+		return a.Contract
+	case *ERC1155TokenQuery:
+		return a.Contract
+	case *ERC777OperatorQuery:
+		return a.Contract
+	case *ERC4626VaultQuery:
+		return a.Contract
+	case *EtherBalanceQuery:
+		return a.Holder
+	}
+	return nil
+}
+
+// visibleTransaction filters the events and internal calls of a transaction down
+// to those of addresses the client making the request can see. It returns false
+// if the client can see no part of the transaction.
+func visibleTransaction(id *auth.Identity, tx *types.Transaction) (*types.Transaction, bool) {
+	if id == nil || !id.IsRestricted() {
+		return tx, true
+	}
+	visible := *tx
+	visible.Events = make([]*types.Event, 0, len(tx.Events))
+	for _, event := range tx.Events {
+		if id.CanView(event.Address) {
+			visible.Events = append(visible.Events, event)
+		}
+	}
+	visible.InternalCalls = make([]*types.InternalCall, 0, len(tx.InternalCalls))
+	for _, call := range tx.InternalCalls {
+		if id.CanView(call.From) || id.CanView(call.To) {
+			visible.InternalCalls = append(visible.InternalCalls, call)
+		}
+	}
+
+	involved := id.CanView(tx.From) || id.CanView(tx.To) || id.CanView(tx.CreatedContract)
+	if !involved && len(visible.Events) == 0 && len(visible.InternalCalls) == 0 {
+		return nil, false
+	}
+	return &visible, true
+}
+
+// visibleAddresses filters out the addresses the client making the request
+// cannot see
+func visibleAddresses(id *auth.Identity, addresses []types.Address) []types.Address {
+	if id == nil || !id.IsRestricted() {
+		return addresses
+	}
+	visible := make([]types.Address, 0, len(addresses))
+	for _, address := range addresses {
+		if id.CanView(address) {
+			visible = append(visible, address)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,168 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/client"
+	"quorumengineering/quorum-report/core/auth"
+	"quorumengineering/quorum-report/core/health"
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+const testAuthHttpAddr = "http://localhost:30001"
+
+var otherAddr = types.NewAddress("0x0000000000000000000000000000000000000009")
+
+func doAuthenticatedRequest(t *testing.T, apiKey string, method string, params string) (int, rpcMessage) {
+	buf := new(bytes.Buffer)
+	assert.Nil(t, json.NewEncoder(buf).Encode(rpcMessage{Version: "2.0", ID: "1", Method: method, Params: json.RawMessage(params)}))
+	req, _ := http.NewRequest(http.MethodPost, testAuthHttpAddr, buf)
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	var rpcResponse rpcMessage
+	_ = json.NewDecoder(resp.Body).Decode(&rpcResponse)
+	return resp.StatusCode, rpcResponse
+}
+
+type runningService struct{}
+
+func (runningService) IsRunning() bool {
+	return true
+}
+
+func getEndpoint(t *testing.T, apiKey string, path string) int {
+	req, _ := http.NewRequest(http.MethodGet, testAuthHttpAddr+path, nil)
+	if apiKey != "" {
+		req.Header.Set(auth.APIKeyHeader, apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAuthorization(t *testing.T) {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.AddAddresses([]types.Address{addr, otherAddr}))
+
+	var config types.ReportingConfig
+	config.Server.RPCAddr = "localhost:30001"
+	config.Server.Auth = &types.AuthConfig{
+		APIKeys: []*types.APIKeyConfig{
+			{Name: "reader", Key: "read-key", Roles: []string{types.ReadRole}},
+			{Name: "restricted", Key: "restricted-key", Roles: []string{types.ReadRole}, Addresses: []types.Address{addr}},
+			{Name: "admin", Key: "admin-key", Roles: []string{types.AdminRole}},
+		},
+	}
+	checker := health.NewChecker(db, client.NewStubQuorumClient(nil, nil), runningService{}, runningService{}, config.Tuning)
+	server := NewRPCService(db, nil, nil, checker, config, make(chan error))
+	assert.Nil(t, server.Start())
+	defer server.Stop()
+	// wait for the server to start listening
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", config.Server.RPCAddr); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// metrics and readiness list the registered addresses, so are only given to admins
+	for _, path := range []string{"/metrics", "/readyz"} {
+		assert.Equal(t, http.StatusUnauthorized, getEndpoint(t, "", path), path)
+		assert.Equal(t, http.StatusForbidden, getEndpoint(t, "read-key", path), path)
+		assert.NotEqual(t, http.StatusUnauthorized, getEndpoint(t, "admin-key", path), path)
+		assert.NotEqual(t, http.StatusForbidden, getEndpoint(t, "admin-key", path), path)
+	}
+	assert.Equal(t, http.StatusOK, getEndpoint(t, "", "/healthz"))
+
+	status, _ := doAuthenticatedRequest(t, "", "reporting.GetAddresses", "[]")
+	assert.Equal(t, http.StatusUnauthorized, status)
+	status, _ = doAuthenticatedRequest(t, "wrong-key", "reporting.GetAddresses", "[]")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// readers can query but not make changes
+	_, resp := doAuthenticatedRequest(t, "read-key", "reporting.GetAddresses", "[]")
+	assert.Equal(t, "null", string(resp.Error))
+	assert.JSONEq(t, `["0x0000000000000000000000000000000000000001","0x0000000000000000000000000000000000000009"]`, string(resp.Result))
+	_, resp = doAuthenticatedRequest(t, "read-key", "reporting.DeleteAddress", `["0x0000000000000000000000000000000000000009"]`)
+	assert.Equal(t, `"permission denied: admin role required"`, string(resp.Error))
+
+	// restricted clients only see their addresses
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "reporting.GetAddresses", "[]")
+	assert.JSONEq(t, `["0x0000000000000000000000000000000000000001"]`, string(resp.Result))
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "reporting.GetLastFiltered", `["0x0000000000000000000000000000000000000001"]`)
+	assert.Equal(t, "null", string(resp.Error))
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "reporting.GetLastFiltered", `["0x0000000000000000000000000000000000000009"]`)
+	assert.Equal(t, `"address not permitted"`, string(resp.Error))
+
+	// ether balances are only seen for permitted holders
+	assert.Nil(t, db.RecordNewERC20Balance(types.EtherContract, addr, 1, big.NewInt(10)))
+	assert.Nil(t, db.RecordNewERC20Balance(types.EtherContract, otherAddr, 1, big.NewInt(20)))
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "token.GetEtherBalance", `[{"holder": "0x0000000000000000000000000000000000000001", "block": 1}]`)
+	assert.Equal(t, "null", string(resp.Error))
+	assert.Equal(t, "10", string(resp.Result))
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "token.GetEtherBalance", `[{"holder": "0x0000000000000000000000000000000000000009", "block": 1}]`)
+	assert.Equal(t, `"address not permitted"`, string(resp.Error))
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "token.GetEtherBalanceHistory", `[{"holder": "0x0000000000000000000000000000000000000009"}]`)
+	assert.Equal(t, `"address not permitted"`, string(resp.Error))
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "token.GetEtherHoldersAtBlock", `[{"block": 1}]`)
+	assert.JSONEq(t, `["0x0000000000000000000000000000000000000001"]`, string(resp.Result))
+
+	// restricted clients only see the transactions, events and internal calls of their addresses
+	mine := &types.Transaction{
+		Hash:        types.NewHash("0x1"),
+		BlockNumber: 1,
+		To:          otherAddr,
+		Events:      []*types.Event{{Address: addr}, {Address: otherAddr}},
+		InternalCalls: []*types.InternalCall{
+			{From: otherAddr, To: addr},
+			{From: otherAddr, To: types.NewAddress("0x2")},
+		},
+	}
+	theirs := &types.Transaction{Hash: types.NewHash("0x2"), BlockNumber: 1, To: otherAddr, Events: []*types.Event{{Address: otherAddr}}}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{mine, theirs}))
+	assert.Nil(t, db.WriteBlocks([]*types.Block{{Number: 1, Transactions: []types.Hash{mine.Hash, theirs.Hash}}}))
+
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "reporting.GetTransaction", `[{"hash": "0x0000000000000000000000000000000000000000000000000000000000000002"}]`)
+	assert.Equal(t, `"address not permitted"`, string(resp.Error))
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "reporting.GetTransaction", `[{"hash": "0x0000000000000000000000000000000000000000000000000000000000000001"}]`)
+	assert.Equal(t, "null", string(resp.Error))
+	var parsedTx types.ParsedTransaction
+	assert.Nil(t, json.Unmarshal(resp.Result, &parsedTx))
+	if assert.Len(t, parsedTx.RawTransaction.Events, 1) {
+		assert.Equal(t, addr, parsedTx.RawTransaction.Events[0].Address)
+	}
+	assert.Len(t, parsedTx.RawTransaction.InternalCalls, 1)
+	_, resp = doAuthenticatedRequest(t, "read-key", "reporting.GetTransaction", `[{"hash": "0x0000000000000000000000000000000000000000000000000000000000000001"}]`)
+	assert.Nil(t, json.Unmarshal(resp.Result, &parsedTx))
+	assert.Len(t, parsedTx.RawTransaction.Events, 2)
+
+	var block types.Block
+	_, resp = doAuthenticatedRequest(t, "restricted-key", "reporting.GetBlock", `[{"blockNumber": 1}]`)
+	assert.Nil(t, json.Unmarshal(resp.Result, &block))
+	assert.Equal(t, []types.Hash{mine.Hash}, block.Transactions)
+	_, resp = doAuthenticatedRequest(t, "read-key", "reporting.GetBlock", `[{"blockNumber": 1}]`)
+	assert.Nil(t, json.Unmarshal(resp.Result, &block))
+	assert.Equal(t, []types.Hash{mine.Hash, theirs.Hash}, block.Transactions)
+
+	_, resp = doAuthenticatedRequest(t, "admin-key", "reporting.DeleteAddress", `["0x0000000000000000000000000000000000000009"]`)
+	assert.Equal(t, "null", string(resp.Error))
+	addresses, _ := db.GetAddresses()
+	assert.Equal(t, []types.Address{addr}, addresses)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"testing"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"quorumengineering/quorum-report/core/subscription"
 	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/database/memory"
 	"quorumengineering/quorum-report/types"
@@ -26,8 +28,10 @@ type rpcMessage struct {
 }
 
 var (
-	apiDatabase  = memory.NewMemoryDB()
-	testHttpAddr = "http://localhost:30000"
+	apiDatabase     = memory.NewMemoryDB()
+	subscriptionHub = subscription.NewHub()
+	testHttpAddr    = "http://localhost:30000"
+	testWsAddr      = "ws://localhost:30000/ws"
 )
 
 func TestMain(m *testing.M) {
@@ -54,20 +58,11 @@ func TestMain(m *testing.M) {
 
 func SetupRpcServer(db database.Database) *RPCService {
 	errorChan := make(chan error)
-	serverConfig := struct {
-		RPCAddr     string   `toml:"rpcAddr"`
-		RPCCorsList []string `toml:"rpcCorsList,omitempty"`
-		RPCVHosts   []string `toml:"rpcvHosts,omitempty"`
-		UIPort      int      `toml:"uiPort,omitempty"`
-	}{
-		RPCAddr:     "localhost:30000",
-		RPCCorsList: []string{"*"},
-		RPCVHosts:   nil,
-		UIPort:      0,
-	}
-	config := types.ReportingConfig{Server: serverConfig}
+	var config types.ReportingConfig
+	config.Server.RPCAddr = "localhost:30000"
+	config.Server.RPCCorsList = []string{"*"}
 
-	return NewRPCService(db, config, errorChan)
+	return NewRPCService(db, nil, subscriptionHub, nil, config, errorChan)
 }
 
 //TODO: error case
@@ -303,6 +298,27 @@ func TestNewRPCAPIs_AddAddress(t *testing.T) {
 	assert.NotContains(t, resultAfterDelete, types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17"))
 }
 
+func TestMetrics_RecordsRequests(t *testing.T) {
+	msg := rpcMessage{
+		Version: "2.0",
+		ID:      "67",
+		Method:  "reporting.GetBlock",
+		Params:  json.RawMessage("[2]"),
+	}
+	_, err := doRequest(msg)
+	assert.Nil(t, err)
+
+	resp, err := http.Get(testHttpAddr + "/metrics")
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `reporting_api_request_duration_seconds_count{method="reporting.GetBlock"}`)
+	assert.Contains(t, string(body), `reporting_api_request_errors_total{method="reporting.GetBlock"}`)
+}
+
 func doRequest(request rpcMessage) (rpcMessage, error) {
 	buf := new(bytes.Buffer)
 	if err := json.NewEncoder(buf).Encode(request); err != nil {
@@ -0,0 +1,30 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+
+	"quorumengineering/quorum-report/metrics"
+)
+
+type requestStartKey struct{}
+
+// instrument records the duration and errors of each JSON-RPC request by
+// method. Only requests for registered methods reach the hooks, so the number
+// of methods recorded is bounded.
+func instrument(server *rpc.Server) {
+	server.RegisterInterceptFunc(func(i *rpc.RequestInfo) *http.Request {
+		return i.Request.WithContext(context.WithValue(i.Request.Context(), requestStartKey{}, time.Now()))
+	})
+	server.RegisterAfterFunc(func(i *rpc.RequestInfo) {
+		if start, ok := i.Request.Context().Value(requestStartKey{}).(time.Time); ok {
+			metrics.APIRequestDuration.WithLabelValues(i.Method).Observe(time.Since(start).Seconds())
+		}
+		if i.Error != nil {
+			metrics.APIRequestErrors.WithLabelValues(i.Method).Inc()
+		}
+	})
+}
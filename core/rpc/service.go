@@ -11,8 +11,13 @@ import (
 	"github.com/gorilla/rpc/v2/json"
 	"github.com/rs/cors"
 
+	"quorumengineering/quorum-report/core/auth"
+	"quorumengineering/quorum-report/core/certs"
+	"quorumengineering/quorum-report/core/health"
+	"quorumengineering/quorum-report/core/subscription"
 	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/metrics"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -26,18 +31,32 @@ type RPCService struct {
 	cors        []string
 	httpAddress string
 	db          database.Database
+	pending     PendingBlockReader
+	hub         *subscription.Hub
+	health      *health.Checker
+	authConfig  *types.AuthConfig
+	tlsConfig   *types.TLSConfig
 
-	httpServer *http.Server
+	httpServer       *http.Server
+	websocketHandler *WebSocketHandler
 
 	httpServerErrorChannel chan error
 	shutdownWg             sync.WaitGroup
 }
 
-func NewRPCService(db database.Database, config types.ReportingConfig, backendErrorChan chan error) *RPCService {
+// NewRPCService creates the JSON-RPC server. If a subscription hub is given,
+// subscriptions to newly indexed data are served over WebSocket at /ws, and if
+// a health checker is given, liveness and readiness are served at /healthz and /readyz.
+func NewRPCService(db database.Database, pending PendingBlockReader, hub *subscription.Hub, healthChecker *health.Checker, config types.ReportingConfig, backendErrorChan chan error) *RPCService {
 	return &RPCService{
 		cors:        config.Server.RPCCorsList,
 		httpAddress: config.Server.RPCAddr,
 		db:          db,
+		pending:     pending,
+		hub:         hub,
+		health:      healthChecker,
+		authConfig:  config.Server.Auth,
+		tlsConfig:   config.Server.TLS,
 
 		httpServerErrorChannel: backendErrorChan,
 	}
@@ -46,16 +65,55 @@ func NewRPCService(db database.Database, config types.ReportingConfig, backendEr
 func (r *RPCService) Start() error {
 	log.Info("Starting JSON-RPC server")
 
+	authenticators, err := auth.NewAuthenticators(r.authConfig)
+	if err != nil {
+		return err
+	}
+
 	jsonrpcServer := rpc.NewServer()
 	jsonrpcServer.RegisterCodec(json.NewCodec(), "application/json")
-	if err := jsonrpcServer.RegisterService(NewRPCAPIs(r.db, NewDefaultContractManager(r.db)), "reporting"); err != nil {
+	instrument(jsonrpcServer)
+	if err := jsonrpcServer.RegisterService(NewRPCAPIs(r.db, r.pending, NewDefaultContractManager(r.db)), "reporting"); err != nil {
 		return err
 	}
 	if err := jsonrpcServer.RegisterService(NewTokenRPCAPIs(r.db), "token"); err != nil {
 		return err
 	}
 
-	serverWithCors := cors.New(cors.Options{AllowedOrigins: r.cors}).Handler(jsonrpcServer)
+	var rpcHandler http.Handler = jsonrpcServer
+	if len(authenticators) > 0 {
+		log.Info("Authentication of JSON-RPC clients enabled", "authenticators", len(authenticators))
+		jsonrpcServer.RegisterValidateRequestFunc(authorize)
+		rpcHandler = auth.Middleware(authenticators, jsonrpcServer)
+	}
+
+	// metrics and readiness list the registered addresses, so are only given to admins
+	metricsHandler := metrics.Handler()
+	if len(authenticators) > 0 {
+		metricsHandler = auth.Middleware(authenticators, auth.RequireRole(types.AdminRole, metricsHandler))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", rpcHandler)
+	mux.Handle("/metrics", metricsHandler)
+	if r.health != nil {
+		readinessHandler := r.health.ReadinessHandler()
+		if len(authenticators) > 0 {
+			readinessHandler = auth.Middleware(authenticators, auth.RequireRole(types.AdminRole, readinessHandler))
+		}
+		mux.Handle("/healthz", r.health.LivenessHandler())
+		mux.Handle("/readyz", readinessHandler)
+	}
+	if r.hub != nil {
+		r.websocketHandler = NewWebSocketHandler(r.hub, r.cors)
+		var wsHandler http.Handler = r.websocketHandler
+		if len(authenticators) > 0 {
+			wsHandler = auth.Middleware(authenticators, wsHandler)
+		}
+		mux.Handle("/ws", wsHandler)
+	}
+
+	serverWithCors := cors.New(cors.Options{AllowedOrigins: r.cors}).Handler(mux)
 	r.httpServer = &http.Server{
 		Addr:    r.httpAddress,
 		Handler: serverWithCors,
@@ -64,17 +122,37 @@ func (r *RPCService) Start() error {
 		WriteTimeout: WriteTimeout,
 		IdleTimeout:  IdleTimeout,
 	}
+	httpScheme, wsScheme := "http", "ws"
+	if r.tlsConfig != nil {
+		reloader, err := certs.NewReloader(r.tlsConfig)
+		if err != nil {
+			return err
+		}
+		r.httpServer.TLSConfig = reloader.TLSConfig()
+		httpScheme, wsScheme = "https", "wss"
+	}
 
 	r.shutdownWg.Add(1)
 	go func() {
 		defer r.shutdownWg.Done()
-		if err := r.httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		var err error
+		if r.httpServer.TLSConfig != nil {
+			// the certificate is given by the TLS config
+			err = r.httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = r.httpServer.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
 			log.Error("Unable to start JSON-RPC server", "err", err)
 			r.httpServerErrorChannel <- err
 		}
 	}()
 
-	log.Info("JSON-RPC HTTP endpoint opened", "url", fmt.Sprintf("http://%s", r.httpServer.Addr))
+	log.Info("JSON-RPC HTTP endpoint opened", "url", fmt.Sprintf("%s://%s", httpScheme, r.httpServer.Addr))
+	log.Info("Metrics endpoint opened", "url", fmt.Sprintf("%s://%s/metrics", httpScheme, r.httpServer.Addr))
+	if r.websocketHandler != nil {
+		log.Info("Subscription WebSocket endpoint opened", "url", fmt.Sprintf("%s://%s/ws", wsScheme, r.httpServer.Addr))
+	}
 	return nil
 }
 
@@ -88,8 +166,12 @@ func (r *RPCService) Stop() {
 			log.Error("JSON-RPC server shutdown failed", "err", err)
 		}
 		r.shutdownWg.Wait()
+		// hijacked WebSocket connections are not closed by the server shutdown
+		if r.websocketHandler != nil {
+			r.websocketHandler.Close()
+		}
 
-		log.Info("RPC HTTP endpoint closed", "url", fmt.Sprintf("http://%s", r.httpServer.Addr))
+		log.Info("RPC HTTP endpoint closed", "address", r.httpServer.Addr)
 	}
 
 	log.Info("RPC service stopped")
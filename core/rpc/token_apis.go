@@ -5,6 +5,7 @@ import (
 	"math/big"
 	"net/http"
 
+	"quorumengineering/quorum-report/core/auth"
 	"quorumengineering/quorum-report/database"
 	"quorumengineering/quorum-report/types"
 )
@@ -59,6 +60,68 @@ func (r *TokenRPCAPIs) GetERC20TokenHoldersAtBlock(req *http.Request, query *ERC
 	return nil
 }
 
+// GetEtherBalance fetches the ether balance of a holder at a block, from the last
+// change to it at or before the block
+func (r *TokenRPCAPIs) GetEtherBalance(req *http.Request, query *EtherBalanceQuery, reply *big.Int) error {
+	if query.Holder == nil {
+		return errors.New("no ether holder provided")
+	}
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+	block := new(big.Int).SetUint64(query.Block)
+	options := &types.TokenQueryOptions{BeginBlockNumber: block, EndBlockNumber: block}
+	options.SetDefaults()
+
+	bal, err := r.db.GetERC20Balance(types.EtherContract, *query.Holder, options)
+	if err != nil {
+		return err
+	}
+	balance, ok := bal[query.Block]
+	if !ok {
+		return database.ErrNotFound
+	}
+
+	reply.Set(balance)
+	return nil
+}
+
+func (r *TokenRPCAPIs) GetEtherBalanceHistory(req *http.Request, query *EtherBalanceQuery, reply *map[uint64]*big.Int) error {
+	if query.Holder == nil {
+		return errors.New("no ether holder provided")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	bal, err := r.db.GetERC20Balance(types.EtherContract, *query.Holder, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = bal
+	return nil
+}
+
+func (r *TokenRPCAPIs) GetEtherHoldersAtBlock(req *http.Request, query *EtherBalanceQuery, reply *[]types.Address) error {
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	holders, err := r.db.GetAllTokenHolders(types.EtherContract, query.Block, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = visibleAddresses(auth.FromContext(req.Context()), holders)
+	return nil
+}
+
 func (r *TokenRPCAPIs) GetHolderForERC721TokenAtBlock(req *http.Request, query *ERC721TokenQuery, reply *types.Address) error {
 	if query.Contract == nil {
 		return errors.New("no token contract provided")
@@ -144,3 +207,145 @@ func (r *TokenRPCAPIs) AllERC721HoldersAtBlock(req *http.Request, query *ERC721T
 	*reply = results
 	return nil
 }
+
+func (r *TokenRPCAPIs) GetERC1155TokenBalance(req *http.Request, query *ERC1155TokenQuery, reply *map[uint64]*big.Int) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Holder == nil {
+		return errors.New("no token holder provided")
+	}
+	if query.TokenId == nil {
+		return errors.New("no token ID provided")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	bal, err := r.db.GetERC1155Balance(*query.Contract, *query.Holder, query.TokenId, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = bal
+	return nil
+}
+
+func (r *TokenRPCAPIs) GetERC1155TokenHoldersAtBlock(req *http.Request, query *ERC1155TokenQuery, reply *[]types.Address) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.TokenId == nil {
+		return errors.New("no token ID provided")
+	}
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	holders, err := r.db.ERC1155HoldersAtBlock(*query.Contract, query.TokenId, query.Block, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = holders
+	return nil
+}
+
+func (r *TokenRPCAPIs) ERC1155TokensForAccountAtBlock(req *http.Request, query *ERC1155TokenQuery, reply *[]types.ERC1155Token) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Holder == nil {
+		return errors.New("no token holder provided")
+	}
+	if query.Block == 0 {
+		return errors.New("no block given")
+	}
+	if query.Options == nil {
+		query.Options = &types.TokenQueryOptions{}
+	}
+	query.Options.SetDefaults()
+
+	results, err := r.db.ERC1155TokensForAccountAtBlock(*query.Contract, *query.Holder, query.Block, query.Options)
+	if err != nil {
+		return err
+	}
+
+	*reply = results
+	return nil
+}
+
+func (r *TokenRPCAPIs) GetERC777OperatorsAtBlock(req *http.Request, query *ERC777OperatorQuery, reply *[]types.Address) error {
+	if query.Contract == nil {
+		return errors.New("no token contract provided")
+	}
+	if query.Holder == nil {
+		return errors.New("no token holder provided")
+	}
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+
+	operators, err := r.db.ERC777OperatorsAtBlock(*query.Contract, *query.Holder, query.Block)
+	if err != nil {
+		return err
+	}
+
+	*reply = operators
+	return nil
+}
+
+func (r *TokenRPCAPIs) GetERC4626AssetsAtBlock(req *http.Request, query *ERC4626VaultQuery, reply *types.ERC4626Assets) error {
+	if query.Contract == nil {
+		return errors.New("no vault contract provided")
+	}
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+
+	assets, err := r.db.GetERC4626AssetsAtBlock(*query.Contract, query.Block)
+	if err != nil {
+		return err
+	}
+
+	*reply = *assets
+	return nil
+}
+
+// GetERC4626AssetBalance returns the amount of the underlying asset the vault shares
+// of the holder are worth at the block
+func (r *TokenRPCAPIs) GetERC4626AssetBalance(req *http.Request, query *ERC4626VaultQuery, reply *big.Int) error {
+	if query.Contract == nil {
+		return errors.New("no vault contract provided")
+	}
+	if query.Holder == nil {
+		return errors.New("no vault shareholder provided")
+	}
+	if query.Block == 0 {
+		return errors.New("block must be provided and not 0")
+	}
+	block := new(big.Int).SetUint64(query.Block)
+	options := &types.TokenQueryOptions{BeginBlockNumber: block, EndBlockNumber: block}
+	options.SetDefaults()
+
+	bal, err := r.db.GetERC20Balance(*query.Contract, *query.Holder, options)
+	if err != nil {
+		return err
+	}
+	shares, ok := bal[query.Block]
+	if !ok {
+		return database.ErrNotFound
+	}
+	assets, err := r.db.GetERC4626AssetsAtBlock(*query.Contract, query.Block)
+	if err != nil {
+		return err
+	}
+
+	reply.Set(assets.ConvertToAssets(shares))
+	return nil
+}
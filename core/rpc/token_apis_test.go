@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestEtherBalanceAPIs(t *testing.T) {
+	db := memory.NewMemoryDB()
+	holder := types.NewAddress("0x0000000000000000000000000000000000000009")
+	assert.Nil(t, db.RecordNewERC20Balance(types.EtherContract, holder, 2, big.NewInt(100)))
+	assert.Nil(t, db.RecordNewERC20Balance(types.EtherContract, holder, 5, big.NewInt(40)))
+	apis := NewTokenRPCAPIs(db)
+
+	// test GetEtherBalance
+	balance := new(big.Int)
+	err := apis.GetEtherBalance(dummyReq, &EtherBalanceQuery{Holder: &holder}, balance)
+	assert.EqualError(t, err, "block must be provided and not 0")
+	err = apis.GetEtherBalance(dummyReq, &EtherBalanceQuery{Holder: &holder, Block: 1}, balance)
+	assert.Equal(t, database.ErrNotFound, err)
+	err = apis.GetEtherBalance(dummyReq, &EtherBalanceQuery{Holder: &holder, Block: 4}, balance)
+	assert.Nil(t, err)
+	assert.Equal(t, "100", balance.String())
+	err = apis.GetEtherBalance(dummyReq, &EtherBalanceQuery{Holder: &holder, Block: 5}, balance)
+	assert.Nil(t, err)
+	assert.Equal(t, "40", balance.String())
+
+	// test GetEtherBalanceHistory
+	var history map[uint64]*big.Int
+	err = apis.GetEtherBalanceHistory(dummyReq, &EtherBalanceQuery{}, &history)
+	assert.EqualError(t, err, "no ether holder provided")
+	options := &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(3), EndBlockNumber: big.NewInt(10)}
+	err = apis.GetEtherBalanceHistory(dummyReq, &EtherBalanceQuery{Holder: &holder, Options: options}, &history)
+	assert.Nil(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "100", history[3].String())
+	assert.Equal(t, "40", history[5].String())
+
+	// test GetEtherHoldersAtBlock
+	var holders []types.Address
+	err = apis.GetEtherHoldersAtBlock(dummyReq, &EtherBalanceQuery{Block: 1}, &holders)
+	assert.Nil(t, err)
+	assert.Len(t, holders, 0)
+	err = apis.GetEtherHoldersAtBlock(dummyReq, &EtherBalanceQuery{Block: 2}, &holders)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder}, holders)
+}
+
+func TestERC1155TokenAPIs(t *testing.T) {
+	db := memory.NewMemoryDB()
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holder := types.NewAddress("0x0000000000000000000000000000000000000009")
+	tokenId := big.NewInt(5)
+	assert.Nil(t, db.RecordERC1155Balance(contract, holder, 2, tokenId, big.NewInt(100)))
+	assert.Nil(t, db.RecordERC1155Balance(contract, holder, 4, tokenId, big.NewInt(0)))
+	apis := NewTokenRPCAPIs(db)
+
+	// test GetERC1155TokenBalance
+	var history map[uint64]*big.Int
+	err := apis.GetERC1155TokenBalance(dummyReq, &ERC1155TokenQuery{Contract: &contract, Holder: &holder}, &history)
+	assert.EqualError(t, err, "no token ID provided")
+	err = apis.GetERC1155TokenBalance(dummyReq, &ERC1155TokenQuery{Contract: &contract, Holder: &holder, TokenId: tokenId}, &history)
+	assert.Nil(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "100", history[2].String())
+	assert.Equal(t, "0", history[4].String())
+
+	// test GetERC1155TokenHoldersAtBlock
+	var holders []types.Address
+	err = apis.GetERC1155TokenHoldersAtBlock(dummyReq, &ERC1155TokenQuery{Contract: &contract, TokenId: tokenId}, &holders)
+	assert.EqualError(t, err, "block must be provided and not 0")
+	err = apis.GetERC1155TokenHoldersAtBlock(dummyReq, &ERC1155TokenQuery{Contract: &contract, TokenId: tokenId, Block: 3}, &holders)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder}, holders)
+	err = apis.GetERC1155TokenHoldersAtBlock(dummyReq, &ERC1155TokenQuery{Contract: &contract, TokenId: tokenId, Block: 4}, &holders)
+	assert.Nil(t, err)
+	assert.Len(t, holders, 0)
+
+	// test ERC1155TokensForAccountAtBlock
+	var tokens []types.ERC1155Token
+	err = apis.ERC1155TokensForAccountAtBlock(dummyReq, &ERC1155TokenQuery{Contract: &contract, Block: 3}, &tokens)
+	assert.EqualError(t, err, "no token holder provided")
+	err = apis.ERC1155TokensForAccountAtBlock(dummyReq, &ERC1155TokenQuery{Contract: &contract, Holder: &holder, Block: 3}, &tokens)
+	assert.Nil(t, err)
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, "5", tokens[0].Token)
+		assert.Equal(t, "100", tokens[0].Amount.String())
+		assert.EqualValues(t, 2, tokens[0].HeldFrom)
+	}
+}
+
+func TestERC777TokenAPIs(t *testing.T) {
+	db := memory.NewMemoryDB()
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holder := types.NewAddress("0x0000000000000000000000000000000000000009")
+	operator := types.NewAddress("0x0000000000000000000000000000000000000008")
+	assert.Nil(t, db.RecordERC777Operator(contract, holder, operator, 2, true))
+	assert.Nil(t, db.RecordERC777Operator(contract, holder, operator, 4, false))
+	apis := NewTokenRPCAPIs(db)
+
+	// test GetERC777OperatorsAtBlock
+	var operators []types.Address
+	err := apis.GetERC777OperatorsAtBlock(dummyReq, &ERC777OperatorQuery{Contract: &contract, Block: 3}, &operators)
+	assert.EqualError(t, err, "no token holder provided")
+	err = apis.GetERC777OperatorsAtBlock(dummyReq, &ERC777OperatorQuery{Contract: &contract, Holder: &holder}, &operators)
+	assert.EqualError(t, err, "block must be provided and not 0")
+	err = apis.GetERC777OperatorsAtBlock(dummyReq, &ERC777OperatorQuery{Contract: &contract, Holder: &holder, Block: 3}, &operators)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{operator}, operators)
+	err = apis.GetERC777OperatorsAtBlock(dummyReq, &ERC777OperatorQuery{Contract: &contract, Holder: &holder, Block: 4}, &operators)
+	assert.Nil(t, err)
+	assert.Len(t, operators, 0)
+}
+
+func TestERC4626TokenAPIs(t *testing.T) {
+	db := memory.NewMemoryDB()
+	vault := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holder := types.NewAddress("0x0000000000000000000000000000000000000009")
+	assert.Nil(t, db.RecordNewERC20Balance(vault, holder, 2, big.NewInt(50)))
+	assert.Nil(t, db.RecordERC4626Assets(vault, 2, big.NewInt(1000), big.NewInt(500)))
+	assert.Nil(t, db.RecordERC4626Assets(vault, 4, big.NewInt(1100), big.NewInt(500)))
+	apis := NewTokenRPCAPIs(db)
+
+	// test GetERC4626AssetsAtBlock
+	var assets types.ERC4626Assets
+	err := apis.GetERC4626AssetsAtBlock(dummyReq, &ERC4626VaultQuery{Block: 3}, &assets)
+	assert.EqualError(t, err, "no vault contract provided")
+	err = apis.GetERC4626AssetsAtBlock(dummyReq, &ERC4626VaultQuery{Contract: &vault, Block: 1}, &assets)
+	assert.Equal(t, database.ErrNotFound, err)
+	err = apis.GetERC4626AssetsAtBlock(dummyReq, &ERC4626VaultQuery{Contract: &vault, Block: 3}, &assets)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, assets.BlockNumber)
+	assert.Equal(t, "1000", assets.TotalAssets.String())
+
+	// test GetERC4626AssetBalance
+	balance := new(big.Int)
+	err = apis.GetERC4626AssetBalance(dummyReq, &ERC4626VaultQuery{Contract: &vault, Block: 3}, balance)
+	assert.EqualError(t, err, "no vault shareholder provided")
+	err = apis.GetERC4626AssetBalance(dummyReq, &ERC4626VaultQuery{Contract: &vault, Holder: &holder, Block: 1}, balance)
+	assert.Equal(t, database.ErrNotFound, err)
+	err = apis.GetERC4626AssetBalance(dummyReq, &ERC4626VaultQuery{Contract: &vault, Holder: &holder, Block: 3}, balance)
+	assert.Nil(t, err)
+	assert.Equal(t, "100", balance.String())
+	// the same shares are worth more once the vault's assets grow
+	err = apis.GetERC4626AssetBalance(dummyReq, &ERC4626VaultQuery{Contract: &vault, Holder: &holder, Block: 4}, balance)
+	assert.Nil(t, err)
+	assert.Equal(t, "110", balance.String())
+}
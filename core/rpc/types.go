@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"encoding/json"
 	"errors"
 	"math/big"
 
@@ -13,6 +14,34 @@ var ErrNoAddress = errors.New("address not provided")
 
 type NullArgs struct{}
 
+// BlockNumberWithOptions can also be given as just the block number
+type BlockNumberWithOptions struct {
+	BlockNumber    uint64
+	IncludePending bool
+}
+
+func (args *BlockNumberWithOptions) UnmarshalJSON(input []byte) error {
+	if err := json.Unmarshal(input, &args.BlockNumber); err == nil {
+		return nil
+	}
+	type withOptions BlockNumberWithOptions
+	return json.Unmarshal(input, (*withOptions)(args))
+}
+
+// HashWithOptions can also be given as just the hash
+type HashWithOptions struct {
+	Hash           types.Hash
+	IncludePending bool
+}
+
+func (args *HashWithOptions) UnmarshalJSON(input []byte) error {
+	if err := json.Unmarshal(input, &args.Hash); err == nil {
+		return nil
+	}
+	type withOptions HashWithOptions
+	return json.Unmarshal(input, (*withOptions)(args))
+}
+
 type AddressWithOptions struct {
 	Address *types.Address
 	Options *types.QueryOptions
@@ -29,6 +58,14 @@ type TemplateArgs struct {
 	StorageLayout string
 }
 
+type WebhookArgs struct {
+	Address        *types.Address
+	Kind           string
+	EventSignature string
+	URL            string
+	Secret         string
+}
+
 type AddressWithOptionalBlock struct {
 	Address     *types.Address
 	BlockNumber *uint64
@@ -46,6 +83,12 @@ type ERC20TokenQuery struct {
 	Options  *types.TokenQueryOptions
 }
 
+type EtherBalanceQuery struct {
+	Holder  *types.Address
+	Block   uint64
+	Options *types.TokenQueryOptions
+}
+
 type ERC721TokenQuery struct {
 	Contract *types.Address
 	Holder   *types.Address
@@ -54,6 +97,26 @@ type ERC721TokenQuery struct {
 	Options  *types.TokenQueryOptions
 }
 
+type ERC1155TokenQuery struct {
+	Contract *types.Address
+	Holder   *types.Address
+	TokenId  *big.Int
+	Block    uint64
+	Options  *types.TokenQueryOptions
+}
+
+type ERC777OperatorQuery struct {
+	Contract *types.Address
+	Holder   *types.Address
+	Block    uint64
+}
+
+type ERC4626VaultQuery struct {
+	Contract *types.Address
+	Holder   *types.Address
+	Block    uint64
+}
+
 //Outputs
 
 type TransactionsResp struct {
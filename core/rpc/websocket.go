@@ -0,0 +1,280 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"quorumengineering/quorum-report/core/auth"
+	"quorumengineering/quorum-report/core/subscription"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+const (
+	SubscribeMethod    = "reporting.subscribe"
+	UnsubscribeMethod  = "reporting.unsubscribe"
+	NotificationMethod = "reporting.subscription"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	errCodeParse          = -32700
+	errCodeMethodNotFound = -32601
+	errCodeInvalidParams  = -32602
+)
+
+type wsRequest struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type wsResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *wsError        `json:"error,omitempty"`
+}
+
+type wsError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type wsNotification struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+// WebSocketHandler serves subscriptions to newly indexed data over WebSocket
+// connections, using JSON-RPC 2.0 messages.
+type WebSocketHandler struct {
+	hub      *subscription.Hub
+	upgrader websocket.Upgrader
+
+	conns map[*wsConnection]struct{}
+	mux   sync.Mutex
+	wg    sync.WaitGroup
+}
+
+func NewWebSocketHandler(hub *subscription.Hub, allowedOrigins []string) *WebSocketHandler {
+	return &WebSocketHandler{
+		hub: hub,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				if origin == "" {
+					return true
+				}
+				for _, allowed := range allowedOrigins {
+					if allowed == "*" || allowed == origin {
+						return true
+					}
+				}
+				return false
+			},
+		},
+		conns: make(map[*wsConnection]struct{}),
+	}
+}
+
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	identity := auth.FromContext(r.Context())
+	if identity != nil && !identity.HasRole(types.ReadRole) {
+		http.Error(w, fmt.Sprintf("permission denied: %s role required", types.ReadRole), http.StatusForbidden)
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already replied to the client
+		log.Debug("WebSocket upgrade failed", "err", err)
+		return
+	}
+	wsConn := &wsConnection{
+		conn:          conn,
+		hub:           h.hub,
+		identity:      identity,
+		subscriptions: make(map[string]*subscription.Subscription),
+	}
+
+	h.mux.Lock()
+	h.conns[wsConn] = struct{}{}
+	h.wg.Add(1)
+	h.mux.Unlock()
+
+	go func() {
+		defer h.wg.Done()
+		wsConn.serve()
+
+		h.mux.Lock()
+		delete(h.conns, wsConn)
+		h.mux.Unlock()
+	}()
+}
+
+// Close disconnects all clients, ending their subscriptions
+func (h *WebSocketHandler) Close() {
+	h.mux.Lock()
+	for wsConn := range h.conns {
+		wsConn.conn.Close()
+	}
+	h.mux.Unlock()
+	h.wg.Wait()
+}
+
+type wsConnection struct {
+	conn     *websocket.Conn
+	hub      *subscription.Hub
+	writeMux sync.Mutex
+	// identity is the authenticated client, if authentication is enabled
+	identity *auth.Identity
+
+	subscriptions map[string]*subscription.Subscription
+	subMux        sync.Mutex
+	forwarders    sync.WaitGroup
+}
+
+func (c *wsConnection) serve() {
+	defer func() {
+		c.subMux.Lock()
+		for _, sub := range c.subscriptions {
+			sub.Unsubscribe()
+		}
+		c.subMux.Unlock()
+		c.forwarders.Wait()
+		c.conn.Close()
+	}()
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req wsRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.write(&wsResponse{Version: "2.0", Error: &wsError{errCodeParse, err.Error()}})
+			continue
+		}
+		resp := &wsResponse{Version: "2.0", ID: req.ID}
+		result, rpcErr := c.handle(&req)
+		if rpcErr != nil {
+			resp.Error = rpcErr
+		} else {
+			resp.Result = result
+		}
+		c.write(resp)
+	}
+}
+
+func (c *wsConnection) handle(req *wsRequest) (interface{}, *wsError) {
+	switch req.Method {
+	case SubscribeMethod:
+		criteria, err := parseCriteria(req.Params)
+		if err != nil {
+			return nil, &wsError{errCodeInvalidParams, err.Error()}
+		}
+		if !c.canView(criteria) {
+			return nil, &wsError{errCodeInvalidParams, ErrAddressNotPermitted.Error()}
+		}
+		sub, err := c.hub.Subscribe(*criteria)
+		if err != nil {
+			return nil, &wsError{errCodeInvalidParams, err.Error()}
+		}
+		c.subMux.Lock()
+		c.subscriptions[sub.ID] = sub
+		c.subMux.Unlock()
+
+		c.forwarders.Add(1)
+		go c.forward(sub)
+		return sub.ID, nil
+	case UnsubscribeMethod:
+		var params []string
+		if err := json.Unmarshal(req.Params, &params); err != nil || len(params) != 1 {
+			return nil, &wsError{errCodeInvalidParams, "expected a subscription id"}
+		}
+		c.subMux.Lock()
+		sub, ok := c.subscriptions[params[0]]
+		delete(c.subscriptions, params[0])
+		c.subMux.Unlock()
+		if !ok {
+			return nil, &wsError{errCodeInvalidParams, subscription.ErrNotSubscribed.Error()}
+		}
+		sub.Unsubscribe()
+		return true, nil
+	default:
+		return nil, &wsError{errCodeMethodNotFound, "method not found: " + req.Method}
+	}
+}
+
+// forward writes the notifications of a subscription to the client until it is
+// unsubscribed. If the hub drops the subscription, the client is disconnected
+// so it knows to resubscribe.
+func (c *wsConnection) forward(sub *subscription.Subscription) {
+	defer c.forwarders.Done()
+	for notification := range sub.Notifications() {
+		c.write(&wsResponse{
+			Version: "2.0",
+			Method:  NotificationMethod,
+			Params:  &wsNotification{Subscription: sub.ID, Result: notification},
+		})
+	}
+	if err := sub.Err(); err != nil {
+		c.writeMux.Lock()
+		_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()), time.Now().Add(WriteTimeout))
+		c.writeMux.Unlock()
+		c.conn.Close()
+	}
+}
+
+func (c *wsConnection) write(msg *wsResponse) {
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(WriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		log.Debug("Writing to WebSocket client failed", "err", err)
+	}
+}
+
+// parseCriteria reads subscription parameters of the form [kind] or
+// [kind, {criteria}]
+func parseCriteria(params json.RawMessage) (*subscription.Criteria, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(params, &raw); err != nil || len(raw) == 0 || len(raw) > 2 {
+		return nil, errors.New("expected a subscription kind and optional criteria")
+	}
+	criteria := &subscription.Criteria{}
+	if err := json.Unmarshal(raw[0], &criteria.Kind); err != nil {
+		return nil, err
+	}
+	if len(raw) == 2 {
+		if err := json.Unmarshal(raw[1], criteria); err != nil {
+			return nil, err
+		}
+	}
+	return criteria, nil
+}
+
+// canView checks a client restricted to some addresses only subscribes to the
+// blocks, or to the events, tokens and ether balances of addresses, it can see
+func (c *wsConnection) canView(criteria *subscription.Criteria) bool {
+	if c.identity == nil || !c.identity.IsRestricted() || criteria.Kind == subscription.NewBlocks {
+		return true
+	}
+	address := criteria.Contract
+	switch criteria.Kind {
+	case subscription.Events:
+		address = criteria.Address
+	case subscription.EtherBalances:
+		address = criteria.Holder
+	}
+	return address != nil && c.identity.CanView(*address)
+}
@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/core/auth"
+	"quorumengineering/quorum-report/core/subscription"
+	"quorumengineering/quorum-report/types"
+)
+
+type wsTestMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *wsError        `json:"error"`
+}
+
+func dialWebSocket(t *testing.T) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(testWsAddr, nil)
+	assert.Nil(t, err)
+	return conn
+}
+
+func wsCall(t *testing.T, conn *websocket.Conn, method string, params string) *wsTestMessage {
+	err := conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"`+method+`","params":`+params+`}`))
+	assert.Nil(t, err)
+	return wsRead(t, conn)
+}
+
+func wsRead(t *testing.T, conn *websocket.Conn) *wsTestMessage {
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg wsTestMessage
+	assert.Nil(t, conn.ReadJSON(&msg))
+	return &msg
+}
+
+func TestWebSocket_SubscribeEvents(t *testing.T) {
+	conn := dialWebSocket(t)
+	defer conn.Close()
+
+	resp := wsCall(t, conn, SubscribeMethod, `["events", {"address": "0x0000000000000000000000000000000000000001", "eventSignature": "Transfer(address,address,uint256)"}]`)
+	assert.Nil(t, resp.Error)
+	var id string
+	assert.Nil(t, json.Unmarshal(resp.Result, &id))
+
+	transferTopic := types.NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	subscriptionHub.PublishEvents([]*types.ParsedEvent{
+		{RawEvent: &types.Event{Address: addr, Topics: []types.Hash{types.NewHash("0x1")}, BlockNumber: 4}},
+		{RawEvent: &types.Event{Address: addr, Topics: []types.Hash{transferTopic}, BlockNumber: 5}},
+	})
+
+	notification := wsRead(t, conn)
+	assert.Equal(t, NotificationMethod, notification.Method)
+	assert.Equal(t, id, notification.Params.Subscription)
+	var event types.ParsedEvent
+	assert.Nil(t, json.Unmarshal(notification.Params.Result, &event))
+	assert.EqualValues(t, 5, event.RawEvent.BlockNumber)
+
+	resp = wsCall(t, conn, UnsubscribeMethod, `["`+id+`"]`)
+	assert.Nil(t, resp.Error)
+	assert.Equal(t, "true", string(resp.Result))
+}
+
+func TestWebSocket_SubscribeNewBlocks(t *testing.T) {
+	conn := dialWebSocket(t)
+	defer conn.Close()
+
+	resp := wsCall(t, conn, SubscribeMethod, `["newBlocks"]`)
+	assert.Nil(t, resp.Error)
+
+	subscriptionHub.PublishBlocks([]*types.BlockWithTransactions{{Number: 100, Hash: types.NewHash("0x64")}})
+
+	notification := wsRead(t, conn)
+	assert.Equal(t, NotificationMethod, notification.Method)
+	assert.JSONEq(t, `{"number": 100, "hash": "0x0000000000000000000000000000000000000000000000000000000000000064", "timestamp": 0}`, string(notification.Params.Result))
+}
+
+func TestWebSocket_Errors(t *testing.T) {
+	conn := dialWebSocket(t)
+	defer conn.Close()
+
+	resp := wsCall(t, conn, SubscribeMethod, `["erc20Balances"]`)
+	assert.Equal(t, &wsError{errCodeInvalidParams, "holder not provided"}, resp.Error)
+
+	resp = wsCall(t, conn, UnsubscribeMethod, `["0xffff"]`)
+	assert.Equal(t, &wsError{errCodeInvalidParams, "subscription not found"}, resp.Error)
+
+	resp = wsCall(t, conn, "reporting.getBlock", `[1]`)
+	assert.Equal(t, &wsError{errCodeMethodNotFound, "method not found: reporting.getBlock"}, resp.Error)
+}
+
+func TestWebSocket_RestrictedCanView(t *testing.T) {
+	c := &wsConnection{identity: &auth.Identity{Roles: []string{types.ReadRole}, Addresses: []types.Address{addr}}}
+	other := types.NewAddress("0x0000000000000000000000000000000000000009")
+
+	assert.True(t, c.canView(&subscription.Criteria{Kind: subscription.NewBlocks}))
+	assert.True(t, c.canView(&subscription.Criteria{Kind: subscription.Events, Address: &addr}))
+	assert.False(t, c.canView(&subscription.Criteria{Kind: subscription.Events, Address: &other}))
+	assert.True(t, c.canView(&subscription.Criteria{Kind: subscription.ERC20Balances, Contract: &addr, Holder: &other}))
+	assert.False(t, c.canView(&subscription.Criteria{Kind: subscription.ERC20Balances, Holder: &addr}))
+	// ether is not held in a contract, so the holder must be visible
+	assert.True(t, c.canView(&subscription.Criteria{Kind: subscription.EtherBalances, Holder: &addr}))
+	assert.False(t, c.canView(&subscription.Criteria{Kind: subscription.EtherBalances, Holder: &other}))
+}
@@ -0,0 +1,231 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// The kinds of newly indexed data that can be subscribed to
+const (
+	NewBlocks       = "newBlocks"
+	Events          = "events"
+	ERC20Balances   = "erc20Balances"
+	ERC721Transfers = "erc721Transfers"
+	EtherBalances   = "etherBalances"
+)
+
+// notificationBuffer is the number of notifications held for a subscriber before
+// it is considered too slow and dropped, so the filter service is never blocked
+const notificationBuffer = 1000
+
+var (
+	ErrUnknownKind    = errors.New("unknown subscription kind")
+	ErrNoAddress      = errors.New("address not provided")
+	ErrNoHolder       = errors.New("holder not provided")
+	ErrNotSubscribed  = errors.New("subscription not found")
+	ErrSubscriberSlow = errors.New("subscriber too slow, subscription dropped")
+)
+
+// Criteria selects the notifications a subscription receives. Fields that are
+// not set match everything.
+type Criteria struct {
+	Kind string `json:"-"`
+	// Address is the contract events are emitted from
+	Address *types.Address `json:"address,omitempty"`
+	// EventSignature is either the event topic hash, or the event signature,
+	// e.g. "Transfer(address,address,uint256)"
+	EventSignature string `json:"eventSignature,omitempty"`
+	// Contract and Holder filter token notifications
+	Contract *types.Address `json:"contract,omitempty"`
+	Holder   *types.Address `json:"holder,omitempty"`
+
+	topic types.Hash
+}
+
+// NewBlock is sent once a block has been indexed for all registered addresses
+type NewBlock struct {
+	Number    uint64     `json:"number"`
+	Hash      types.Hash `json:"hash"`
+	Timestamp uint64     `json:"timestamp"`
+}
+
+// ERC20BalanceChange is sent when the balance of a token holder has changed
+type ERC20BalanceChange struct {
+	Contract types.Address `json:"contract"`
+	Holder   types.Address `json:"holder"`
+	Block    uint64        `json:"block"`
+	Balance  *big.Int      `json:"balance"`
+}
+
+// EtherBalanceChange is sent when the ether balance of a holder has changed
+type EtherBalanceChange struct {
+	Holder  types.Address `json:"holder"`
+	Block   uint64        `json:"block"`
+	Balance *big.Int      `json:"balance"`
+}
+
+// Subscription receives the notifications matching its criteria until it is
+// unsubscribed. If the subscriber falls too far behind, the notification channel
+// is closed and Err returns ErrSubscriberSlow.
+type Subscription struct {
+	ID       string
+	criteria Criteria
+
+	notifications chan interface{}
+	err           error
+	hub           *Hub
+}
+
+func (s *Subscription) Notifications() <-chan interface{} {
+	return s.notifications
+}
+
+// Err returns why the subscription was ended by the hub, if it was
+func (s *Subscription) Err() error {
+	s.hub.mux.RLock()
+	defer s.hub.mux.RUnlock()
+	return s.err
+}
+
+func (s *Subscription) Unsubscribe() {
+	_ = s.hub.Unsubscribe(s.ID)
+}
+
+// Hub fans out newly indexed data to all the subscriptions interested in it.
+type Hub struct {
+	idCounter     uint64
+	subscriptions map[string]*Subscription
+	// lastBlock is the highest block published, as blocks can be indexed more
+	// than once when a new address is registered from an earlier block
+	lastBlock    uint64
+	hasPublished bool
+	// mutex lock
+	mux sync.RWMutex
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscriptions: make(map[string]*Subscription),
+	}
+}
+
+func (h *Hub) Subscribe(criteria Criteria) (*Subscription, error) {
+	switch criteria.Kind {
+	case NewBlocks, ERC721Transfers:
+	case Events:
+		if criteria.Address == nil {
+			return nil, ErrNoAddress
+		}
+		if criteria.EventSignature != "" {
+			criteria.topic = types.EventTopic(criteria.EventSignature)
+		}
+	case ERC20Balances, EtherBalances:
+		if criteria.Holder == nil {
+			return nil, ErrNoHolder
+		}
+	default:
+		return nil, ErrUnknownKind
+	}
+
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.idCounter++
+	sub := &Subscription{
+		ID:            fmt.Sprintf("0x%x", h.idCounter),
+		criteria:      criteria,
+		notifications: make(chan interface{}, notificationBuffer),
+		hub:           h,
+	}
+	h.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+func (h *Hub) Unsubscribe(id string) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	sub, ok := h.subscriptions[id]
+	if !ok {
+		return ErrNotSubscribed
+	}
+	h.remove(sub, nil)
+	return nil
+}
+
+// PublishBlocks notifies subscribers of the given indexed blocks, skipping any
+// that have been published before.
+func (h *Hub) PublishBlocks(blocks []*types.BlockWithTransactions) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for _, block := range blocks {
+		if h.hasPublished && block.Number <= h.lastBlock {
+			continue
+		}
+		h.lastBlock, h.hasPublished = block.Number, true
+		h.publish(NewBlocks, &NewBlock{Number: block.Number, Hash: block.Hash, Timestamp: block.Timestamp}, func(Criteria) bool {
+			return true
+		})
+	}
+}
+
+func (h *Hub) PublishEvents(events []*types.ParsedEvent) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for _, event := range events {
+		h.publish(Events, event, func(criteria Criteria) bool {
+			return *criteria.Address == event.RawEvent.Address &&
+				(criteria.topic == "" || (len(event.RawEvent.Topics) > 0 && event.RawEvent.Topics[0] == criteria.topic))
+		})
+	}
+}
+
+func (h *Hub) PublishERC20Balance(change *ERC20BalanceChange) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.publish(ERC20Balances, change, func(criteria Criteria) bool {
+		return *criteria.Holder == change.Holder && (criteria.Contract == nil || *criteria.Contract == change.Contract)
+	})
+}
+
+func (h *Hub) PublishEtherBalance(change *EtherBalanceChange) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.publish(EtherBalances, change, func(criteria Criteria) bool {
+		return *criteria.Holder == change.Holder
+	})
+}
+
+func (h *Hub) PublishERC721Transfer(token *types.ERC721Token) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.publish(ERC721Transfers, token, func(criteria Criteria) bool {
+		return (criteria.Contract == nil || *criteria.Contract == token.Contract) &&
+			(criteria.Holder == nil || *criteria.Holder == token.Holder)
+	})
+}
+
+// internal functions
+
+func (h *Hub) publish(kind string, notification interface{}, matches func(Criteria) bool) {
+	for _, sub := range h.subscriptions {
+		if sub.criteria.Kind != kind || !matches(sub.criteria) {
+			continue
+		}
+		select {
+		case sub.notifications <- notification:
+		default:
+			log.Warn("Dropping slow subscriber", "subscription", sub.ID, "kind", kind)
+			h.remove(sub, ErrSubscriberSlow)
+		}
+	}
+}
+
+func (h *Hub) remove(sub *Subscription, err error) {
+	delete(h.subscriptions, sub.ID)
+	sub.err = err
+	close(sub.notifications)
+}
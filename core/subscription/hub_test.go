@@ -0,0 +1,125 @@
+package subscription
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	contract = types.NewAddress("0x0000000000000000000000000000000000000001")
+	holder   = types.NewAddress("0x0000000000000000000000000000000000000002")
+	other    = types.NewAddress("0x0000000000000000000000000000000000000003")
+
+	// keccak256("Transfer(address,address,uint256)")
+	transferTopic = types.NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+)
+
+func TestHub_Subscribe_InvalidCriteria(t *testing.T) {
+	hub := NewHub()
+
+	_, err := hub.Subscribe(Criteria{Kind: "unknown"})
+	assert.Equal(t, ErrUnknownKind, err)
+	_, err = hub.Subscribe(Criteria{Kind: Events})
+	assert.Equal(t, ErrNoAddress, err)
+	_, err = hub.Subscribe(Criteria{Kind: ERC20Balances})
+	assert.Equal(t, ErrNoHolder, err)
+}
+
+func TestHub_PublishBlocks_SkipsPublishedBlocks(t *testing.T) {
+	hub := NewHub()
+	sub, err := hub.Subscribe(Criteria{Kind: NewBlocks})
+	assert.Nil(t, err)
+
+	hub.PublishBlocks([]*types.BlockWithTransactions{{Number: 1, Hash: types.NewHash("0x1")}, {Number: 2, Hash: types.NewHash("0x2")}})
+	hub.PublishBlocks([]*types.BlockWithTransactions{{Number: 2, Hash: types.NewHash("0x2")}, {Number: 3, Hash: types.NewHash("0x3")}})
+
+	assert.Equal(t, &NewBlock{Number: 1, Hash: types.NewHash("0x1")}, <-sub.Notifications())
+	assert.Equal(t, &NewBlock{Number: 2, Hash: types.NewHash("0x2")}, <-sub.Notifications())
+	assert.Equal(t, &NewBlock{Number: 3, Hash: types.NewHash("0x3")}, <-sub.Notifications())
+	assert.Len(t, sub.Notifications(), 0)
+}
+
+func TestHub_PublishEvents_FiltersByAddressAndSignature(t *testing.T) {
+	hub := NewHub()
+	all, _ := hub.Subscribe(Criteria{Kind: Events, Address: &contract})
+	bySignature, _ := hub.Subscribe(Criteria{Kind: Events, Address: &contract, EventSignature: "Transfer(address, address, uint256)"})
+	byTopic, _ := hub.Subscribe(Criteria{Kind: Events, Address: &contract, EventSignature: transferTopic.String()})
+	otherContract, _ := hub.Subscribe(Criteria{Kind: Events, Address: &other})
+
+	transfer := &types.ParsedEvent{RawEvent: &types.Event{Address: contract, Topics: []types.Hash{transferTopic}}}
+	approval := &types.ParsedEvent{RawEvent: &types.Event{Address: contract, Topics: []types.Hash{types.NewHash("0x1")}}}
+	hub.PublishEvents([]*types.ParsedEvent{transfer, approval})
+
+	assert.Equal(t, transfer, <-all.Notifications())
+	assert.Equal(t, approval, <-all.Notifications())
+	assert.Equal(t, transfer, <-bySignature.Notifications())
+	assert.Len(t, bySignature.Notifications(), 0)
+	assert.Equal(t, transfer, <-byTopic.Notifications())
+	assert.Len(t, byTopic.Notifications(), 0)
+	assert.Len(t, otherContract.Notifications(), 0)
+}
+
+func TestHub_PublishTokens_FiltersByContractAndHolder(t *testing.T) {
+	hub := NewHub()
+	balances, _ := hub.Subscribe(Criteria{Kind: ERC20Balances, Holder: &holder})
+	otherBalances, _ := hub.Subscribe(Criteria{Kind: ERC20Balances, Holder: &holder, Contract: &other})
+	transfers, _ := hub.Subscribe(Criteria{Kind: ERC721Transfers, Contract: &contract})
+
+	change := &ERC20BalanceChange{Contract: contract, Holder: holder, Block: 5, Balance: big.NewInt(10)}
+	hub.PublishERC20Balance(change)
+	token := &types.ERC721Token{Contract: contract, Holder: holder, Token: "7", HeldFrom: 5}
+	hub.PublishERC721Transfer(token)
+	hub.PublishERC721Transfer(&types.ERC721Token{Contract: other, Holder: holder, Token: "1", HeldFrom: 5})
+
+	assert.Equal(t, change, <-balances.Notifications())
+	assert.Len(t, otherBalances.Notifications(), 0)
+	assert.Equal(t, token, <-transfers.Notifications())
+	assert.Len(t, transfers.Notifications(), 0)
+}
+
+func TestHub_PublishEtherBalance_FiltersByHolder(t *testing.T) {
+	hub := NewHub()
+	_, err := hub.Subscribe(Criteria{Kind: EtherBalances})
+	assert.Equal(t, ErrNoHolder, err)
+	balances, _ := hub.Subscribe(Criteria{Kind: EtherBalances, Holder: &holder})
+	otherBalances, _ := hub.Subscribe(Criteria{Kind: EtherBalances, Holder: &other})
+	erc20Balances, _ := hub.Subscribe(Criteria{Kind: ERC20Balances, Holder: &holder})
+
+	change := &EtherBalanceChange{Holder: holder, Block: 5, Balance: big.NewInt(10)}
+	hub.PublishEtherBalance(change)
+
+	assert.Equal(t, change, <-balances.Notifications())
+	assert.Len(t, otherBalances.Notifications(), 0)
+	assert.Len(t, erc20Balances.Notifications(), 0)
+}
+
+func TestHub_Unsubscribe(t *testing.T) {
+	hub := NewHub()
+	sub, _ := hub.Subscribe(Criteria{Kind: NewBlocks})
+
+	sub.Unsubscribe()
+
+	_, open := <-sub.Notifications()
+	assert.False(t, open)
+	assert.Nil(t, sub.Err())
+	assert.Equal(t, ErrNotSubscribed, hub.Unsubscribe(sub.ID))
+}
+
+func TestHub_DropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	sub, _ := hub.Subscribe(Criteria{Kind: NewBlocks})
+
+	blocks := make([]*types.BlockWithTransactions, notificationBuffer+1)
+	for i := range blocks {
+		blocks[i] = &types.BlockWithTransactions{Number: uint64(i + 1)}
+	}
+	hub.PublishBlocks(blocks)
+
+	assert.Equal(t, ErrSubscriberSlow, sub.Err())
+	assert.Len(t, sub.Notifications(), notificationBuffer)
+	assert.Equal(t, ErrNotSubscribed, hub.Unsubscribe(sub.ID))
+}
@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+// Headers sent with each delivery
+const (
+	WebhookHeader   = "X-Reporting-Webhook"
+	DeliveryHeader  = "X-Reporting-Delivery"
+	KindHeader      = "X-Reporting-Kind"
+	SignatureHeader = "X-Reporting-Signature"
+)
+
+// transferTopicHash is the topic hash for both ERC20 and ERC721 Transfer events
+var transferTopicHash = types.NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+
+// TokenTransfer is sent to webhooks for ERC20 and ERC721 transfers. Value is
+// set for ERC20 transfers, and TokenId for ERC721 transfers.
+type TokenTransfer struct {
+	Contract        types.Address `json:"contract"`
+	From            types.Address `json:"from"`
+	To              types.Address `json:"to"`
+	Value           *big.Int      `json:"value,omitempty"`
+	TokenId         *big.Int      `json:"tokenId,omitempty"`
+	BlockNumber     uint64        `json:"blockNumber"`
+	TransactionHash types.Hash    `json:"transactionHash"`
+	Timestamp       uint64        `json:"timestamp"`
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the payload, which is sent in the
+// signature header as "sha256=<signature>" so receivers can verify it.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newPayload returns what is sent to the webhook for the event, or nil if the
+// webhook is not interested in it.
+func newPayload(webhook *types.Webhook, event *types.Event, topic types.Hash, contractABI string) interface{} {
+	if topic != "" && (len(event.Topics) == 0 || event.Topics[0] != topic) {
+		return nil
+	}
+	isTransfer := len(event.Topics) > 0 && event.Topics[0] == transferTopicHash
+
+	switch webhook.Kind {
+	case types.WebhookERC20Transfers:
+		if !isTransfer || len(event.Topics) != 3 {
+			return nil
+		}
+		transfer := newTokenTransfer(event)
+		transfer.Value = new(big.Int).SetBytes(event.Data.AsBytes())
+		return transfer
+	case types.WebhookERC721Transfers:
+		if !isTransfer || len(event.Topics) != 4 {
+			return nil
+		}
+		transfer := newTokenTransfer(event)
+		tokenId := types.NewHexData(event.Topics[3].String())
+		transfer.TokenId = new(big.Int).SetBytes(tokenId.AsBytes())
+		return transfer
+	default:
+		parsedEvent := &types.ParsedEvent{RawEvent: event}
+		if contractABI != "" {
+			if err := parsedEvent.ParseEvent(contractABI); err != nil {
+				log.Warn("Parsing event for webhook failed", "webhook", webhook.ID, "err", err)
+			}
+		}
+		return parsedEvent
+	}
+}
+
+func newTokenTransfer(event *types.Event) *TokenTransfer {
+	return &TokenTransfer{
+		Contract:        event.Address,
+		From:            types.NewAddress(string(event.Topics[1])[24:64]),
+		To:              types.NewAddress(string(event.Topics[2])[24:64]),
+		BlockNumber:     event.BlockNumber,
+		TransactionHash: event.TransactionHash,
+		Timestamp:       event.Timestamp,
+	}
+}
+
+// post sends the payload to the webhook, signing it if the webhook has a
+// secret. Any response other than 2xx is a failed delivery.
+func (ws *WebhookService) post(webhook *types.Webhook, event *types.Event, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeader, webhook.ID)
+	req.Header.Set(KindHeader, webhook.Kind)
+	// identifies the notification, so receivers can discard any duplicates
+	req.Header.Set(DeliveryHeader, fmt.Sprintf("%s-%d-%d-%d", webhook.ID, event.BlockNumber, event.TransactionIndex, event.Index))
+	if webhook.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, body))
+	}
+
+	resp, err := ws.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
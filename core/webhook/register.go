@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"errors"
+	"net/url"
+
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	ErrAddressNotRegistered = errors.New("address is not registered")
+	ErrInvalidKind          = errors.New("invalid webhook kind")
+	ErrInvalidURL           = errors.New("webhook url must be an absolute http or https url")
+)
+
+type RegistrationDB interface {
+	GetAddresses() ([]types.Address, error)
+	GetLastFiltered(types.Address) (uint64, error)
+	AddWebhook(*types.Webhook) error
+}
+
+// Register adds a webhook for a registered address, returning its ID. A new
+// webhook is only sent what is indexed after it is registered, while registering
+// an existing webhook again updates it without changing what has been delivered.
+func Register(db RegistrationDB, config *types.WebhookConfig) (string, error) {
+	kind := config.Kind
+	if kind == "" {
+		kind = types.WebhookEvents
+	}
+	if kind != types.WebhookEvents && kind != types.WebhookERC20Transfers && kind != types.WebhookERC721Transfers {
+		return "", ErrInvalidKind
+	}
+	parsed, err := url.Parse(config.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", ErrInvalidURL
+	}
+
+	addresses, err := db.GetAddresses()
+	if err != nil {
+		return "", err
+	}
+	registered := false
+	for _, address := range addresses {
+		if address == config.Address {
+			registered = true
+			break
+		}
+	}
+	if !registered {
+		return "", ErrAddressNotRegistered
+	}
+	lastFiltered, err := db.GetLastFiltered(config.Address)
+	if err != nil {
+		return "", err
+	}
+
+	webhook := &types.Webhook{
+		ID:             types.WebhookID(config.Address, kind, config.EventSignature, config.URL),
+		Address:        config.Address,
+		Kind:           kind,
+		EventSignature: config.EventSignature,
+		URL:            config.URL,
+		Secret:         config.Secret,
+		Cursor:         types.WebhookCursor{BlockNumber: lastFiltered + 1},
+	}
+	if err := db.AddWebhook(webhook); err != nil {
+		return "", err
+	}
+	return webhook.ID, nil
+}
@@ -0,0 +1,224 @@
+package webhook
+
+import (
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+const (
+	// blocksPerRound limits how many blocks of events are fetched for a webhook at once
+	blocksPerRound = 100
+	// eventPageSize is the number of events fetched per query
+	eventPageSize = 1000
+	// maxRetryInterval caps the backoff between retries of a failed delivery
+	maxRetryInterval = 5 * time.Minute
+	deliveryTimeout  = 10 * time.Second
+)
+
+type WebhookServiceDB interface {
+	GetWebhooks() ([]*types.Webhook, error)
+	SetWebhookCursor(string, types.WebhookCursor) error
+
+	GetLastFiltered(types.Address) (uint64, error)
+	GetAllEventsFromAddress(types.Address, *types.QueryOptions) ([]*types.Event, error)
+	GetContractABI(types.Address) (string, error)
+}
+
+// retryState tracks the failed attempts to deliver the next notification of a webhook
+type retryState struct {
+	attempts int
+	retryAt  time.Time
+}
+
+// WebhookService delivers the events and token transfers of registered
+// addresses to webhooks once they have been indexed. Deliveries are made from
+// the indexed data in order, and each webhook's cursor is persisted after every
+// delivery, so no notifications are lost or repeated over a restart.
+type WebhookService struct {
+	db         WebhookServiceDB
+	httpClient *http.Client
+
+	maxRetries    int
+	retryInterval time.Duration
+	retries       map[string]*retryState
+
+	// To check we have actually shut down before returning
+	shutdownChan chan struct{}
+	shutdownWg   sync.WaitGroup
+}
+
+func NewWebhookService(db WebhookServiceDB, config types.TuningConfig) *WebhookService {
+	return &WebhookService{
+		db:            db,
+		httpClient:    &http.Client{Timeout: deliveryTimeout},
+		maxRetries:    config.WebhookMaxRetries,
+		retryInterval: time.Duration(config.WebhookRetryInterval) * time.Second,
+		retries:       make(map[string]*retryState),
+		shutdownChan:  make(chan struct{}),
+	}
+}
+
+func (ws *WebhookService) Start() error {
+	log.Info("Starting webhook service")
+
+	ws.shutdownWg.Add(1)
+	go func() {
+		defer ws.shutdownWg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ws.deliverAll()
+			case <-ws.shutdownChan:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (ws *WebhookService) Stop() {
+	close(ws.shutdownChan)
+	ws.shutdownWg.Wait()
+	log.Info("Webhook service stopped")
+}
+
+func (ws *WebhookService) deliverAll() {
+	webhooks, err := ws.db.GetWebhooks()
+	if err != nil {
+		log.Warn("Fetching webhooks failed", "err", err)
+		return
+	}
+	now := time.Now()
+	for _, webhook := range webhooks {
+		if state, ok := ws.retries[webhook.ID]; ok && now.Before(state.retryAt) {
+			continue
+		}
+		if err := ws.deliver(webhook); err != nil {
+			log.Warn("Delivering to webhook failed", "webhook", webhook.ID, "err", err)
+		}
+	}
+}
+
+// deliver sends the notifications of the next range of indexed blocks to the
+// webhook, stopping at the first delivery that fails so it can be retried.
+func (ws *WebhookService) deliver(webhook *types.Webhook) error {
+	lastFiltered, err := ws.db.GetLastFiltered(webhook.Address)
+	if err != nil {
+		return err
+	}
+	if lastFiltered < webhook.Cursor.BlockNumber {
+		return nil
+	}
+	endBlock := webhook.Cursor.BlockNumber + blocksPerRound - 1
+	if endBlock > lastFiltered {
+		endBlock = lastFiltered
+	}
+
+	events, err := ws.fetchEvents(webhook.Address, webhook.Cursor.BlockNumber, endBlock)
+	if err != nil {
+		return err
+	}
+	contractABI, err := ws.db.GetContractABI(webhook.Address)
+	if err != nil {
+		return err
+	}
+	topic := types.Hash("")
+	if webhook.EventSignature != "" {
+		topic = types.EventTopic(webhook.EventSignature)
+	}
+
+	for _, event := range events {
+		if !webhook.Cursor.Includes(event) {
+			continue
+		}
+		select {
+		case <-ws.shutdownChan:
+			return nil
+		default:
+		}
+		if payload := newPayload(webhook, event, topic, contractABI); payload != nil {
+			if err := ws.post(webhook, event, payload); err != nil {
+				if !ws.recordFailure(webhook, err) {
+					return nil
+				}
+			} else {
+				delete(ws.retries, webhook.ID)
+			}
+			// persisted straight away, so a delivered notification is never sent again
+			webhook.Cursor = types.WebhookCursorAfter(event)
+			if err := ws.db.SetWebhookCursor(webhook.ID, webhook.Cursor); err != nil {
+				return err
+			}
+		}
+	}
+
+	webhook.Cursor = types.WebhookCursor{BlockNumber: endBlock + 1}
+	return ws.db.SetWebhookCursor(webhook.ID, webhook.Cursor)
+}
+
+// recordFailure schedules the delivery to be retried with an exponential backoff,
+// returning true once all retries have been used up and the notification should
+// be skipped.
+func (ws *WebhookService) recordFailure(webhook *types.Webhook, err error) bool {
+	state, ok := ws.retries[webhook.ID]
+	if !ok {
+		state = &retryState{}
+		ws.retries[webhook.ID] = state
+	}
+	state.attempts++
+	if state.attempts > ws.maxRetries {
+		log.Error("Dropping webhook notification after all retries failed", "webhook", webhook.ID, "url", webhook.URL, "err", err)
+		delete(ws.retries, webhook.ID)
+		return true
+	}
+
+	backoff := ws.retryInterval << uint(state.attempts-1)
+	if backoff > maxRetryInterval || backoff <= 0 {
+		backoff = maxRetryInterval
+	}
+	state.retryAt = time.Now().Add(backoff)
+	log.Warn("Webhook delivery failed, retrying", "webhook", webhook.ID, "url", webhook.URL, "attempt", state.attempts, "backoff", backoff, "err", err)
+	return false
+}
+
+// fetchEvents returns all the events of the address within the block range, in
+// the order they were emitted.
+func (ws *WebhookService) fetchEvents(address types.Address, startBlock uint64, endBlock uint64) ([]*types.Event, error) {
+	var events []*types.Event
+	for page := 0; ; page++ {
+		options := &types.QueryOptions{
+			BeginBlockNumber: new(big.Int).SetUint64(startBlock),
+			EndBlockNumber:   new(big.Int).SetUint64(endBlock),
+			PageSize:         eventPageSize,
+			PageNumber:       page,
+		}
+		options.SetDefaults()
+		pageEvents, err := ws.db.GetAllEventsFromAddress(address, options)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, pageEvents...)
+		if len(pageEvents) < eventPageSize {
+			break
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].BlockNumber != events[j].BlockNumber {
+			return events[i].BlockNumber < events[j].BlockNumber
+		}
+		if events[i].TransactionIndex != events[j].TransactionIndex {
+			return events[i].TransactionIndex < events[j].TransactionIndex
+		}
+		return events[i].Index < events[j].Index
+	})
+	return events, nil
+}
@@ -0,0 +1,240 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	contract = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	from     = types.NewAddress("0x0000000000000000000000000000000000000a01")
+	to       = types.NewAddress("0x0000000000000000000000000000000000000b02")
+	valueSet = types.NewHash("0xefe5cb8d23d632b5d2cdd9f0a151c4b1a84ccb7afa1c57331009aa922d5e4f36")
+)
+
+// receiver is a local stand-in for a webhook endpoint
+type receiver struct {
+	server *httptest.Server
+
+	mux      sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	// statuses are returned in order for each request, then 200
+	statuses []int
+}
+
+func newReceiver(statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		if status == http.StatusOK {
+			r.requests = append(r.requests, req)
+			r.bodies = append(r.bodies, body)
+		}
+		w.WriteHeader(status)
+	}))
+	return r
+}
+
+func (r *receiver) delivered() [][]byte {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.bodies
+}
+
+func transferEvent(block uint64, index uint64, amount int64) *types.Event {
+	return &types.Event{
+		Index:   index,
+		Address: contract,
+		Topics: []types.Hash{
+			types.NewHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+			types.NewHash("0x" + string(from)),
+			types.NewHash("0x" + string(to)),
+		},
+		Data:            types.NewHexData("0x" + string(types.NewHash(big.NewInt(amount).Text(16)))),
+		BlockNumber:     block,
+		TransactionHash: types.NewHash("0xabc"),
+	}
+}
+
+func valueSetEvent(block uint64, index uint64) *types.Event {
+	return &types.Event{Index: index, Address: contract, Topics: []types.Hash{valueSet}, BlockNumber: block}
+}
+
+func indexBlock(t *testing.T, db *memory.MemoryDB, number uint64, events ...*types.Event) {
+	tx := &types.Transaction{Hash: types.NewHash("0xabc" + strconv.FormatUint(number, 10)), BlockNumber: number, To: contract, Events: events}
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+	block := &types.BlockWithTransactions{Number: number, Transactions: []*types.Transaction{tx}}
+	assert.Nil(t, db.IndexBlocks([]types.Address{contract}, []*types.BlockWithTransactions{block}))
+}
+
+func newTestService(db WebhookServiceDB) *WebhookService {
+	ws := NewWebhookService(db, types.TuningConfig{WebhookMaxRetries: 2, WebhookRetryInterval: 1})
+	ws.retryInterval = time.Millisecond
+	return ws
+}
+
+func setup(t *testing.T) *memory.MemoryDB {
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.AddAddresses([]types.Address{contract}))
+	indexBlock(t, db, 1, valueSetEvent(1, 0))
+	return db
+}
+
+func TestRegister(t *testing.T) {
+	db := setup(t)
+
+	_, err := Register(db, &types.WebhookConfig{Address: from, URL: "http://localhost/hook"})
+	assert.Equal(t, ErrAddressNotRegistered, err)
+	_, err = Register(db, &types.WebhookConfig{Address: contract, URL: "localhost/hook"})
+	assert.Equal(t, ErrInvalidURL, err)
+	_, err = Register(db, &types.WebhookConfig{Address: contract, URL: "http://localhost/hook", Kind: "unknown"})
+	assert.Equal(t, ErrInvalidKind, err)
+
+	id, err := Register(db, &types.WebhookConfig{Address: contract, URL: "http://localhost/hook"})
+	assert.Nil(t, err)
+	webhooks, _ := db.GetWebhooks()
+	assert.Equal(t, []*types.Webhook{{
+		ID:      id,
+		Address: contract,
+		Kind:    types.WebhookEvents,
+		URL:     "http://localhost/hook",
+		Cursor:  types.WebhookCursor{BlockNumber: 2},
+	}}, webhooks)
+}
+
+func TestWebhookService_DeliversNewEventsInOrder(t *testing.T) {
+	db := setup(t)
+	receiver := newReceiver()
+	defer receiver.server.Close()
+	id, err := Register(db, &types.WebhookConfig{Address: contract, URL: receiver.server.URL, Secret: "secret"})
+	assert.Nil(t, err)
+
+	indexBlock(t, db, 2, valueSetEvent(2, 1), transferEvent(2, 0, 10))
+	indexBlock(t, db, 3, valueSetEvent(3, 0))
+	ws := newTestService(db)
+	ws.deliverAll()
+
+	bodies := receiver.delivered()
+	assert.Len(t, bodies, 3)
+	var blocks, indexes []uint64
+	for _, body := range bodies {
+		var event types.ParsedEvent
+		assert.Nil(t, json.Unmarshal(body, &event))
+		blocks = append(blocks, event.RawEvent.BlockNumber)
+		indexes = append(indexes, event.RawEvent.Index)
+	}
+	assert.Equal(t, []uint64{2, 2, 3}, blocks)
+	assert.Equal(t, []uint64{0, 1, 0}, indexes)
+
+	req := receiver.requests[0]
+	assert.Equal(t, id, req.Header.Get(WebhookHeader))
+	assert.Equal(t, types.WebhookEvents, req.Header.Get(KindHeader))
+	assert.Equal(t, id+"-2-0-0", req.Header.Get(DeliveryHeader))
+	assert.Equal(t, "sha256="+Sign("secret", bodies[0]), req.Header.Get(SignatureHeader))
+
+	webhooks, _ := db.GetWebhooks()
+	assert.Equal(t, types.WebhookCursor{BlockNumber: 4}, webhooks[0].Cursor)
+
+	// a restarted service carries on from the persisted cursor
+	indexBlock(t, db, 4, valueSetEvent(4, 0))
+	newTestService(db).deliverAll()
+	assert.Len(t, receiver.delivered(), 4)
+}
+
+func TestWebhookService_FiltersBySignatureAndKind(t *testing.T) {
+	db := setup(t)
+	receiver := newReceiver()
+	defer receiver.server.Close()
+	_, err := Register(db, &types.WebhookConfig{Address: contract, URL: receiver.server.URL + "/events", EventSignature: "Transfer(address,address,uint256)"})
+	assert.Nil(t, err)
+	_, err = Register(db, &types.WebhookConfig{Address: contract, URL: receiver.server.URL + "/transfers", Kind: types.WebhookERC20Transfers})
+	assert.Nil(t, err)
+
+	indexBlock(t, db, 2, valueSetEvent(2, 0), transferEvent(2, 1, 10))
+	newTestService(db).deliverAll()
+
+	bodies := receiver.delivered()
+	assert.Len(t, bodies, 2)
+	transfers := map[string][]byte{}
+	for i, req := range receiver.requests {
+		transfers[req.URL.Path] = bodies[i]
+	}
+	var event types.ParsedEvent
+	assert.Nil(t, json.Unmarshal(transfers["/events"], &event))
+	assert.EqualValues(t, 1, event.RawEvent.Index)
+
+	var transfer TokenTransfer
+	assert.Nil(t, json.Unmarshal(transfers["/transfers"], &transfer))
+	assert.Equal(t, TokenTransfer{
+		Contract:        contract,
+		From:            from,
+		To:              to,
+		Value:           big.NewInt(10),
+		BlockNumber:     2,
+		TransactionHash: types.NewHash("0xabc"),
+	}, transfer)
+}
+
+func TestWebhookService_RetriesFailedDelivery(t *testing.T) {
+	db := setup(t)
+	receiver := newReceiver(http.StatusInternalServerError, http.StatusServiceUnavailable)
+	defer receiver.server.Close()
+	_, err := Register(db, &types.WebhookConfig{Address: contract, URL: receiver.server.URL})
+	assert.Nil(t, err)
+	indexBlock(t, db, 2, valueSetEvent(2, 0))
+	ws := newTestService(db)
+
+	ws.deliverAll()
+	assert.Len(t, receiver.delivered(), 0)
+	webhooks, _ := db.GetWebhooks()
+	assert.Equal(t, types.WebhookCursor{BlockNumber: 2}, webhooks[0].Cursor)
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		ws.deliverAll()
+	}
+	assert.Len(t, receiver.delivered(), 1)
+	webhooks, _ = db.GetWebhooks()
+	assert.Equal(t, types.WebhookCursor{BlockNumber: 3}, webhooks[0].Cursor)
+}
+
+func TestWebhookService_DropsNotificationAfterRetries(t *testing.T) {
+	db := setup(t)
+	receiver := newReceiver(http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	defer receiver.server.Close()
+	_, err := Register(db, &types.WebhookConfig{Address: contract, URL: receiver.server.URL})
+	assert.Nil(t, err)
+	indexBlock(t, db, 2, valueSetEvent(2, 0), valueSetEvent(2, 1))
+	ws := newTestService(db)
+
+	for i := 0; i < 3; i++ {
+		ws.deliverAll()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the first event is dropped, and the second delivered
+	bodies := receiver.delivered()
+	assert.Len(t, bodies, 1)
+	var event types.ParsedEvent
+	assert.Nil(t, json.Unmarshal(bodies[0], &event))
+	assert.EqualValues(t, 1, event.RawEvent.Index)
+}
@@ -0,0 +1,515 @@
+package boltdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	// registered contract data
+	addressBucket          = []byte("addresses")
+	contractTemplateBucket = []byte("contractTemplates")
+	templateBucket         = []byte("templates")
+	creationTxBucket       = []byte("creationTransactions")
+	lastFilteredBucket     = []byte("lastFiltered")
+	// blockchain data
+	blockBucket       = []byte("blocks")
+	transactionBucket = []byte("transactions")
+	metaBucket        = []byte("meta")
+	// index data, each holds a nested bucket per address
+	txToBucket         = []byte("transactionsTo")
+	txInternalToBucket = []byte("transactionsInternalTo")
+	txFailedToBucket   = []byte("failedTransactionsTo")
+	eventBucket        = []byte("events")
+	storageRootBucket  = []byte("storageRoots")
+	storageBucket      = []byte("storage")
+	// token data, each holds a nested bucket per contract, then per holder/token, and
+	// for ERC1155 per token then holder, and for ERC777 operators per holder then operator
+	erc20Bucket   = []byte("erc20")
+	erc721Bucket  = []byte("erc721")
+	erc1155Bucket = []byte("erc1155")
+	erc777Bucket  = []byte("erc777Operators")
+	erc4626Bucket = []byte("erc4626Assets")
+	// webhooks, keyed by ID
+	webhookBucket = []byte("webhooks")
+
+	lastPersistedKey = []byte("lastPersisted")
+
+	allBuckets = [][]byte{
+		addressBucket, contractTemplateBucket, templateBucket, creationTxBucket, lastFilteredBucket,
+		blockBucket, transactionBucket, metaBucket,
+		txToBucket, txInternalToBucket, txFailedToBucket, eventBucket, storageRootBucket, storageBucket,
+		erc20Bucket, erc721Bucket, erc1155Bucket, erc777Bucket, erc4626Bucket, webhookBucket,
+	}
+	// buckets holding per-address data that is removed when the address is deleted
+	addressIndexBuckets = [][]byte{txToBucket, txInternalToBucket, txFailedToBucket, eventBucket, storageRootBucket, storageBucket}
+)
+
+// BoltDB is an embedded, on-disk database for single node deployments.
+type BoltDB struct {
+	db *bolt.DB
+}
+
+// indexedTransaction is the value stored for each transaction indexed against an address
+type indexedTransaction struct {
+	Hash      types.Hash `json:"hash"`
+	Timestamp uint64     `json:"timestamp"`
+}
+
+func New(config *types.BoltConfig) (*BoltDB, error) {
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(config.Path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltDB{db: db}, nil
+}
+
+func (bdb *BoltDB) AddAddresses(addresses []types.Address) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		for _, address := range addresses {
+			if err := tx.Bucket(addressBucket).Put(addressKey(address), []byte{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) AddAddressFrom(address types.Address, from uint64) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		if isRegistered(tx, address) {
+			return nil
+		}
+		if err := tx.Bucket(addressBucket).Put(addressKey(address), []byte{}); err != nil {
+			return err
+		}
+		if from == 0 {
+			return nil
+		}
+		return tx.Bucket(lastFilteredBucket).Put(addressKey(address), encodeUint64(from-1))
+	})
+}
+
+func (bdb *BoltDB) DeleteAddress(address types.Address) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		if !isRegistered(tx, address) {
+			return errors.New("address does not exist")
+		}
+		key := addressKey(address)
+		for _, name := range addressIndexBuckets {
+			if tx.Bucket(name).Bucket(key) == nil {
+				continue
+			}
+			if err := tx.Bucket(name).DeleteBucket(key); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(creationTxBucket).Delete(key); err != nil {
+			return err
+		}
+		if err := tx.Bucket(lastFilteredBucket).Delete(key); err != nil {
+			return err
+		}
+		return tx.Bucket(addressBucket).Delete(key)
+	})
+}
+
+func (bdb *BoltDB) GetAddresses() ([]types.Address, error) {
+	addresses := []types.Address{}
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(addressBucket).ForEach(func(k, _ []byte) error {
+			addresses = append(addresses, types.NewAddress(string(k)))
+			return nil
+		})
+	})
+	return addresses, err
+}
+
+func (bdb *BoltDB) GetContractTemplate(address types.Address) (string, error) {
+	var templateName string
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		templateName = string(tx.Bucket(contractTemplateBucket).Get(addressKey(address)))
+		return nil
+	})
+	return templateName, err
+}
+
+func (bdb *BoltDB) GetContractABI(address types.Address) (string, error) {
+	template, err := bdb.getContractTemplateDetails(address)
+	if err != nil {
+		return "", err
+	}
+	return template.ABI, nil
+}
+
+func (bdb *BoltDB) GetStorageLayout(address types.Address) (string, error) {
+	template, err := bdb.getContractTemplateDetails(address)
+	if err != nil {
+		return "", err
+	}
+	return template.StorageLayout, nil
+}
+
+func (bdb *BoltDB) AddTemplate(name string, abi string, layout string) error {
+	template := types.Template{
+		TemplateName:  name,
+		ABI:           abi,
+		StorageLayout: layout,
+	}
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(templateBucket), []byte(name), template)
+	})
+}
+
+func (bdb *BoltDB) AssignTemplate(address types.Address, name string) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(contractTemplateBucket).Put(addressKey(address), []byte(name))
+	})
+}
+
+func (bdb *BoltDB) GetTemplates() ([]string, error) {
+	templateNames := make([]string, 0)
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(templateBucket).ForEach(func(k, _ []byte) error {
+			templateNames = append(templateNames, string(k))
+			return nil
+		})
+	})
+	return templateNames, err
+}
+
+func (bdb *BoltDB) GetTemplateDetails(templateName string) (*types.Template, error) {
+	var template types.Template
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(templateBucket).Get([]byte(templateName))
+		if value == nil {
+			return database.ErrNotFound
+		}
+		return json.Unmarshal(value, &template)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (bdb *BoltDB) WriteBlocks(blocks []*types.Block) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		blockDB := tx.Bucket(blockBucket)
+		lastPersisted := decodeUint64(tx.Bucket(metaBucket).Get(lastPersistedKey))
+		for _, block := range blocks {
+			if block == nil {
+				return errors.New("block is nil")
+			}
+			if err := putJSON(blockDB, encodeUint64(block.Number), block); err != nil {
+				return err
+			}
+			// Update last persisted block number.
+			if block.Number == lastPersisted+1 {
+				lastPersisted = block.Number
+				for blockDB.Get(encodeUint64(lastPersisted+1)) != nil {
+					lastPersisted++
+				}
+			}
+			log.Debug("Block stored", "number", block.Number, "hash", block.Hash.String())
+			log.Debug("Last persisted block", "number", lastPersisted)
+		}
+		return tx.Bucket(metaBucket).Put(lastPersistedKey, encodeUint64(lastPersisted))
+	})
+}
+
+func (bdb *BoltDB) ReadBlock(blockNumber uint64) (*types.Block, error) {
+	var block types.Block
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(blockBucket).Get(encodeUint64(blockNumber))
+		if value == nil {
+			return errors.New("block does not exist")
+		}
+		return json.Unmarshal(value, &block)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (bdb *BoltDB) GetLastPersistedBlockNumber() (uint64, error) {
+	var lastPersisted uint64
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		lastPersisted = decodeUint64(tx.Bucket(metaBucket).Get(lastPersistedKey))
+		return nil
+	})
+	return lastPersisted, err
+}
+
+func (bdb *BoltDB) WriteTransactions(transactions []*types.Transaction) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		for _, transaction := range transactions {
+			if transaction == nil {
+				return errors.New("transaction is nil")
+			}
+			if err := putJSON(tx.Bucket(transactionBucket), []byte(transaction.Hash.String()), transaction); err != nil {
+				return err
+			}
+			log.Debug("Transaction stored", "hash", transaction.Hash.Hex())
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) ReadTransaction(hash types.Hash) (*types.Transaction, error) {
+	var transaction types.Transaction
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(transactionBucket).Get([]byte(hash.String()))
+		if value == nil {
+			return errors.New("transaction does not exist")
+		}
+		return json.Unmarshal(value, &transaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (bdb *BoltDB) Rollback(blockNumber uint64) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		// remove blocks and their transactions
+		if err := deleteAbove(tx.Bucket(blockBucket), blockNumber); err != nil {
+			return err
+		}
+		var removedTxs [][]byte
+		err := tx.Bucket(transactionBucket).ForEach(func(k, v []byte) error {
+			var transaction struct {
+				BlockNumber uint64 `json:"blockNumber"`
+			}
+			if err := json.Unmarshal(v, &transaction); err != nil {
+				return err
+			}
+			if transaction.BlockNumber > blockNumber {
+				removedTxs = append(removedTxs, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range removedTxs {
+			if err := tx.Bucket(transactionBucket).Delete(key); err != nil {
+				return err
+			}
+		}
+		if decodeUint64(tx.Bucket(metaBucket).Get(lastPersistedKey)) > blockNumber {
+			if err := tx.Bucket(metaBucket).Put(lastPersistedKey, encodeUint64(blockNumber)); err != nil {
+				return err
+			}
+		}
+
+		// remove indexed data, which is keyed by block number first
+		for _, name := range [][]byte{txToBucket, txInternalToBucket, txFailedToBucket, eventBucket, storageRootBucket} {
+			err := forEachBucket(tx.Bucket(name), func(addressDB *bolt.Bucket) error {
+				return deleteAbove(addressDB, blockNumber)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		var clamped [][]byte
+		err = tx.Bucket(lastFilteredBucket).ForEach(func(k, v []byte) error {
+			if decodeUint64(v) > blockNumber {
+				clamped = append(clamped, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range clamped {
+			if err := tx.Bucket(lastFilteredBucket).Put(key, encodeUint64(blockNumber)); err != nil {
+				return err
+			}
+		}
+
+		// remove token records, re-opening any that were closed by a removed record
+		if err := bdb.rollbackERC20(tx, blockNumber); err != nil {
+			return err
+		}
+		if err := bdb.rollbackERC721(tx, blockNumber); err != nil {
+			return err
+		}
+		if err := bdb.rollbackERC1155(tx, blockNumber); err != nil {
+			return err
+		}
+		if err := bdb.rollbackERC777(tx, blockNumber); err != nil {
+			return err
+		}
+		if err := bdb.rollbackERC4626(tx, blockNumber); err != nil {
+			return err
+		}
+
+		log.Info("Rolled back database", "block number", blockNumber, "removed txs", len(removedTxs))
+		return nil
+	})
+}
+
+func (bdb *BoltDB) Stop() {
+	if err := bdb.db.Close(); err != nil {
+		log.Error("Failed to close database", "err", err)
+	}
+}
+
+// internal functions
+
+func (bdb *BoltDB) getContractTemplateDetails(address types.Address) (*types.Template, error) {
+	var template types.Template
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		templateName := tx.Bucket(contractTemplateBucket).Get(addressKey(address))
+		if templateName == nil {
+			return nil
+		}
+		value := tx.Bucket(templateBucket).Get(templateName)
+		if value == nil {
+			return nil
+		}
+		return json.Unmarshal(value, &template)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func isRegistered(tx *bolt.Tx, address types.Address) bool {
+	return tx.Bucket(addressBucket).Get(addressKey(address)) != nil
+}
+
+func addressKey(address types.Address) []byte {
+	return []byte(address.String())
+}
+
+// encodeUint64 encodes numbers big-endian, so that keys sort in numerical order
+func encodeUint64(number uint64) []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, number)
+	return encoded
+}
+
+func decodeUint64(encoded []byte) uint64 {
+	if len(encoded) < 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(encoded[:8])
+}
+
+func putJSON(bucket *bolt.Bucket, key []byte, value interface{}) error {
+	marshalled, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, marshalled)
+}
+
+// nestedBucket returns the bucket at the given path below the root bucket,
+// creating any that do not exist yet.
+func nestedBucket(root *bolt.Bucket, path ...[]byte) (*bolt.Bucket, error) {
+	bucket := root
+	for _, name := range path {
+		var err error
+		if bucket, err = bucket.CreateBucketIfNotExists(name); err != nil {
+			return nil, err
+		}
+	}
+	return bucket, nil
+}
+
+// readNestedBucket returns the bucket at the given path below the root bucket,
+// or nil if it does not exist.
+func readNestedBucket(root *bolt.Bucket, path ...[]byte) *bolt.Bucket {
+	bucket := root
+	for _, name := range path {
+		if bucket = bucket.Bucket(name); bucket == nil {
+			return nil
+		}
+	}
+	return bucket
+}
+
+// forEachBucket calls fn for every nested bucket of the given bucket.
+func forEachBucket(bucket *bolt.Bucket, fn func(*bolt.Bucket) error) error {
+	// collect the names first, as fn may modify the nested buckets
+	var names [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			names = append(names, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := fn(bucket.Bucket(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAbove removes every entry from a bucket keyed by block number that is
+// above the given block.
+func deleteAbove(bucket *bolt.Bucket, blockNumber uint64) error {
+	if blockNumber == math.MaxUint64 {
+		return nil
+	}
+	var keys [][]byte
+	c := bucket.Cursor()
+	for k, _ := c.Seek(encodeUint64(blockNumber + 1)); k != nil; k, _ = c.Next() {
+		keys = append(keys, k)
+	}
+	for _, key := range keys {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockRange converts the optional begin and end block numbers of a query,
+// where an end block of -1 means no upper limit.
+func blockRange(begin, end *big.Int) (uint64, uint64) {
+	from, to := uint64(0), uint64(math.MaxUint64)
+	if begin != nil && begin.Sign() > 0 {
+		from = begin.Uint64()
+	}
+	if end != nil && end.Sign() >= 0 {
+		to = end.Uint64()
+	}
+	return from, to
+}
@@ -0,0 +1,618 @@
+package boltdb
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/dbtest"
+	"quorumengineering/quorum-report/types"
+)
+
+const jsondata = `
+[
+	{ "type" : "function", "name" : "balance", "constant" : true },
+	{ "type" : "function", "name" : "send", "constant" : false, "inputs" : [ { "name" : "amount", "type" : "uint256" } ] }
+]`
+
+var (
+	addr           = types.NewAddress("0x0000000000000000000000000000000000000001")
+	uselessAddress = types.NewAddress("0x0000000000000000000000000000000000000002")
+
+	tx1 = &types.Transaction{
+		Hash:            types.NewHash("0x1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7"),
+		BlockNumber:     1,
+		From:            types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:              "",
+		Value:           big.NewInt(666),
+		CreatedContract: addr,
+	}
+	tx2 = &types.Transaction{
+		Hash:        types.NewHash("0xbc77a72b3409ba3e098cb45bac1b7727b59dae9a05f37a0dbc61007949c8cede"),
+		BlockNumber: 1,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
+		To:          uselessAddress,
+		Value:       big.NewInt(666),
+		InternalCalls: []*types.InternalCall{
+			{
+				To: addr,
+			},
+		},
+	}
+	tx3 = &types.Transaction{
+		Hash:        types.NewHash("0xb2d58900a820afddd1d926845e7655d445885524b9af1cc946b45949be74cc08"),
+		BlockNumber: 1,
+		From:        types.NewAddress("0x0000000000000000000000000000000000000010"),
+		To:          addr,
+		Value:       big.NewInt(666),
+		Events: []*types.Event{
+			{}, // dummy event
+			{Address: addr},
+		},
+	}
+	block = &types.Block{
+		Hash:   types.NewHash("0xb1"),
+		Number: 1,
+		Transactions: []types.Hash{
+			types.NewHash("0x1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7"),
+			types.NewHash("0xbc77a72b3409ba3e098cb45bac1b7727b59dae9a05f37a0dbc61007949c8cede"),
+			types.NewHash("0xb2d58900a820afddd1d926845e7655d445885524b9af1cc946b45949be74cc08"),
+		},
+	}
+	blockWithTransactions = &types.BlockWithTransactions{
+		Hash:         types.NewHash("0xb1"),
+		Number:       1,
+		Transactions: []*types.Transaction{tx1, tx2, tx3},
+	}
+)
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boltdb")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	dbtest.RunConformanceTests(t, func(t *testing.T) database.Database {
+		db, err := New(&types.BoltConfig{Path: filepath.Join(dir, filepath.Base(t.Name())+".db")})
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		return db
+	})
+}
+
+func newTestDB(t *testing.T) (*BoltDB, func()) {
+	dir, err := ioutil.TempDir("", "boltdb")
+	assert.Nil(t, err)
+	db, err := New(&types.BoltConfig{Path: filepath.Join(dir, "test.db")})
+	assert.Nil(t, err)
+	return db, func() {
+		db.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+// testReadTransaction compares the fields that are kept exactly when stored, as empty
+// addresses and hashes are read back as zero values
+func testReadTransaction(t *testing.T, db *BoltDB, expected *types.Transaction) {
+	tx, err := db.ReadTransaction(expected.Hash)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Hash, tx.Hash)
+	assert.Equal(t, expected.From, tx.From)
+	assert.Equal(t, types.NewAddress(expected.To.String()), tx.To)
+	assert.Equal(t, expected.Value, tx.Value)
+	assert.Len(t, tx.Events, len(expected.Events))
+	assert.Len(t, tx.InternalCalls, len(expected.InternalCalls))
+}
+
+func TestBoltDB_WriteTransactions(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	err := db.WriteTransactions([]*types.Transaction{tx1, tx2, tx3})
+	assert.Nil(t, err, "unexpected err")
+
+	for _, expected := range []*types.Transaction{tx1, tx2, tx3} {
+		testReadTransaction(t, db, expected)
+	}
+
+	_, err = db.ReadTransaction(types.NewHash("0x1"))
+	assert.EqualError(t, err, "transaction does not exist")
+}
+
+func TestBoltDB_WriteBlocks(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+
+	err := db.WriteBlocks([]*types.Block{block})
+	assert.Nil(t, err, "unexpected err")
+
+	retrievedBlock, err := db.ReadBlock(block.Number)
+	assert.Nil(t, err, "unexpected err")
+	assert.Equal(t, block.Hash, retrievedBlock.Hash, "unexpected block from db: %s", retrievedBlock)
+	assert.Equal(t, block.Transactions, retrievedBlock.Transactions)
+
+	_, err = db.ReadBlock(2)
+	assert.EqualError(t, err, "block does not exist")
+}
+
+func TestBoltDB(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	rawStorage := map[types.Address]*types.AccountState{
+		addr: {
+			Storage: map[types.Hash]string{
+				types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000"): "2a",
+				types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000001"): "2b",
+			},
+		},
+	}
+
+	testTemplateName := "test template name"
+	testTemplateStorage := "test template storage"
+	// 1. Add an address and get it.
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	addresses, err := db.GetAddresses()
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{addr}, addresses)
+	// 2. Add template, assign template, get templates
+	assert.Nil(t, db.AddTemplate(testTemplateName, jsondata, testTemplateStorage))
+	assert.Nil(t, db.AssignTemplate(addr, testTemplateName))
+	templates, err := db.GetTemplates()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{testTemplateName}, templates)
+	layout, err := db.GetStorageLayout(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, testTemplateStorage, layout)
+	abi, err := db.GetContractABI(addr)
+	assert.Nil(t, err)
+	assert.Equal(t, jsondata, abi)
+	// 3. Write transaction and get it.
+	assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx1, tx2, tx3}))
+	testReadTransaction(t, db, tx1)
+	// 4. Write block and get it. Check last persisted block number.
+	lastPersisted, err := db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, lastPersisted)
+	assert.Nil(t, db.WriteBlocks([]*types.Block{block}))
+	lastPersisted, err = db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, lastPersisted)
+	// 5. Index block and check last filtered. Retrieve all transactions/ events.
+	lastFiltered, err := db.GetLastFiltered(addr)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, lastFiltered)
+	assert.Nil(t, db.IndexStorage(rawStorage, 1))
+	assert.Nil(t, db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{blockWithTransactions}))
+	lastFiltered, err = db.GetLastFiltered(addr)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, lastFiltered)
+
+	txs, err := db.GetAllTransactionsToAddress(addr, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx3.Hash}, txs)
+	total, err := db.GetTransactionsToAddressTotal(addr, nil)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+	txs, err = db.GetAllTransactionsInternalToAddress(addr, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx2.Hash}, txs)
+	total, err = db.GetTransactionsInternalToAddressTotal(addr, nil)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+	events, err := db.GetAllEventsFromAddress(addr, nil)
+	assert.Nil(t, err)
+	assert.Len(t, events, 1)
+	total, err = db.GetEventsFromAddressTotal(addr, nil)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+
+	storage, err := db.GetStorage(addr, 1)
+	assert.Nil(t, err)
+	assert.Len(t, storage.Storage, 2)
+	storageUnknown, err := db.GetStorage(addr, 2)
+	assert.Nil(t, err)
+	assert.Len(t, storageUnknown.Storage, 0)
+	assert.EqualValues(t, types.NewHash(""), storageUnknown.StorageRoot)
+	total, err = db.GetStorageTotal(addr, &types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(1)})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+	total, err = db.GetStorageTotal(addr, &types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(-1)})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+	storageList, err := db.GetStorageWithOptions(addr, &types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(1)})
+	assert.Nil(t, err)
+	assert.Len(t, storageList, 1)
+	// 6. Delete address and check last filtered
+	assert.Nil(t, db.DeleteAddress(addr))
+	lastFiltered, err = db.GetLastFiltered(addr)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0, lastFiltered)
+	_, err = db.GetAllTransactionsToAddress(addr, nil)
+	assert.EqualError(t, err, "address is not registered")
+	assert.EqualError(t, db.DeleteAddress(addr), "address does not exist")
+}
+
+func TestBoltDB_QueryOptions(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	_ = db.AddAddresses([]types.Address{addr})
+
+	var blocks []*types.BlockWithTransactions
+	for i := uint64(1); i <= 5; i++ {
+		blocks = append(blocks, &types.BlockWithTransactions{
+			Number: i,
+			Transactions: []*types.Transaction{
+				{Hash: types.NewHash(big.NewInt(int64(i)).Text(16)), To: addr, Timestamp: i * 10},
+			},
+		})
+	}
+	assert.Nil(t, db.IndexBlocks([]types.Address{addr}, blocks))
+
+	options := &types.QueryOptions{}
+	options.SetDefaults()
+	options.BeginBlockNumber = big.NewInt(2)
+	options.EndBlockNumber = big.NewInt(4)
+	options.PageSize = 2
+	txs, err := db.GetAllTransactionsToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{types.NewHash("4"), types.NewHash("3")}, txs)
+
+	options.PageNumber = 1
+	txs, err = db.GetAllTransactionsToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{types.NewHash("2")}, txs)
+
+	total, err := db.GetTransactionsToAddressTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, total)
+
+	options = &types.QueryOptions{}
+	options.SetDefaults()
+	options.BeginTimestamp = big.NewInt(40)
+	total, err = db.GetTransactionsToAddressTotal(addr, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, total)
+}
+
+func TestBoltDB_Persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "boltdb")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	config := &types.BoltConfig{Path: filepath.Join(dir, "nested", "test.db")}
+
+	db, err := New(config)
+	assert.Nil(t, err)
+	_ = db.AddAddresses([]types.Address{addr})
+	_ = db.WriteBlocks([]*types.Block{block})
+	db.Stop()
+
+	db, err = New(config)
+	assert.Nil(t, err)
+	defer db.Stop()
+	addresses, err := db.GetAddresses()
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{addr}, addresses)
+	lastPersisted, err := db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, lastPersisted)
+}
+
+func TestBoltDB_ContractCreationTransactions(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	_ = db.AddAddresses([]types.Address{
+		"1932c48b2bf8102ba33b4a6b545c32236e342f34",
+		"ed9d02e382b34818e88b88a309c7fe71e65f419d",
+		"8a5e2a6343108babed07899510fb42297938d41f",
+	})
+	creationTxns := map[types.Hash][]types.Address{
+		"1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7": {
+			"1932c48b2bf8102ba33b4a6b545c32236e342f34",
+			"ed9d02e382b34818e88b88a309c7fe71e65f419d",
+		},
+		"86835cbb6c0502b5e67a30b20c4ad79a169d13782f74557775557f52307f0bdb": {
+			"8a5e2a6343108babed07899510fb42297938d41f",
+		},
+	}
+
+	err := db.SetContractCreationTransaction(creationTxns)
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		contractAddress types.Address
+		txHash          types.Hash
+	}{
+		{
+			"1932c48b2bf8102ba33b4a6b545c32236e342f34",
+			"1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7",
+		}, {
+			"ed9d02e382b34818e88b88a309c7fe71e65f419d",
+			"1a6f4292bac138df9a7854a07c93fd14ca7de53265e8fe01b6c986f97d6c1ee7",
+		}, {
+			"8a5e2a6343108babed07899510fb42297938d41f",
+			"86835cbb6c0502b5e67a30b20c4ad79a169d13782f74557775557f52307f0bdb",
+		},
+	}
+
+	for _, testCase := range testCases {
+		actualTxHash, err := db.GetContractCreationTransaction(testCase.contractAddress)
+		assert.Nil(t, err)
+		assert.EqualValues(t, testCase.txHash, actualTxHash)
+	}
+}
+
+func TestBoltDB_ContractCreationTransactions_DeletedAddress(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	sampleAddress := types.NewAddress("8a5e2a6343108babed07899510fb42297938d41f")
+	creationTxns := map[types.Hash][]types.Address{
+		"86835cbb6c0502b5e67a30b20c4ad79a169d13782f74557775557f52307f0bdb": {
+			sampleAddress,
+		},
+	}
+
+	err := db.SetContractCreationTransaction(creationTxns)
+	assert.Nil(t, err)
+
+	actualTxHash, err := db.GetContractCreationTransaction(sampleAddress)
+	assert.EqualError(t, err, "address is not registered")
+	assert.EqualValues(t, "", actualTxHash)
+}
+
+func TestBoltDB_GetStorageRanges(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	contract := types.NewAddress("0x8a5e2a6343108babed07899510fb42297938d41f")
+	db.AddAddressFrom(contract, 0)
+
+	for i := uint64(1); i < 4500; i += 2 {
+		storageMap := map[types.Address]*types.AccountState{
+			contract: {Root: "0x73607aa4f228bd19dc95575d08adacede9550df70b9ca9253cb3abf7d8115990"},
+		}
+		db.IndexStorage(storageMap, i)
+	}
+
+	//every odd block num has storage
+
+	testCases := []struct {
+		options        types.PageOptions
+		expectedResult []types.RangeResult
+	}{
+		{
+			options:        types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(0)},
+			expectedResult: []types.RangeResult{{Start: 0, End: 0, ResultCount: 0}},
+		},
+		{
+			options:        types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(800)},
+			expectedResult: []types.RangeResult{{Start: 0, End: 800, ResultCount: 400}},
+		},
+		{
+			options:        types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(1500)},
+			expectedResult: []types.RangeResult{{Start: 0, End: 1500, ResultCount: 750}},
+		},
+		{
+			options: types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(4499)},
+			expectedResult: []types.RangeResult{
+				{Start: 2501, End: 4499, ResultCount: 1000},
+				{Start: 501, End: 2500, ResultCount: 1000},
+				{Start: 0, End: 500, ResultCount: 250},
+			},
+		},
+		{
+			options: types.PageOptions{BeginBlockNumber: big.NewInt(1300), EndBlockNumber: big.NewInt(3500)},
+			expectedResult: []types.RangeResult{
+				{Start: 1501, End: 3500, ResultCount: 1000},
+				{Start: 1300, End: 1500, ResultCount: 100},
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		res, err := db.GetStorageRanges(contract, &test.options)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expectedResult, res)
+	}
+}
+
+func TestBoltDB_erc20Balance(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	contrAddr := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	contrAddr1 := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f55")
+	holder0 := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	holder1 := types.NewAddress("0xca843569e3427144cead5e4d5999a3d0ccf92b8e")
+	holder2 := types.NewAddress("0xca843569e3427144cead5e4d5999a3d0ccf92bac")
+	var balances = []struct {
+		contract types.Address
+		holder   types.Address
+		block    uint64
+		amount   int64
+	}{
+		{contrAddr, holder0, 1, 1000},
+		{contrAddr, holder0, 2, 900},
+		{contrAddr, holder1, 2, 100},
+		{contrAddr, holder1, 3, 150},
+		{contrAddr, holder0, 3, 850},
+		{contrAddr1, holder2, 4, 850},
+		{contrAddr, holder0, 7, 77},
+	}
+
+	for _, b := range balances {
+		err := db.RecordNewERC20Balance(b.contract, b.holder, b.block, big.NewInt(b.amount))
+		assert.Nil(t, err)
+	}
+
+	var result, err = db.GetERC20Balance(contrAddr, holder0, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(1), EndBlockNumber: big.NewInt(1)})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000)}, result)
+
+	result, err = db.GetERC20Balance(contrAddr, holder0, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(1), EndBlockNumber: big.NewInt(2)})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(1000), 2: big.NewInt(900)}, result)
+
+	result, err = db.GetERC20Balance(contrAddr, holder1, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(2), EndBlockNumber: big.NewInt(3)})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{2: big.NewInt(100), 3: big.NewInt(150)}, result)
+
+	holdrArr, err := db.GetAllTokenHolders(contrAddr, 1, &types.TokenQueryOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, holdrArr)
+
+	holdrArr, err = db.GetAllTokenHolders(contrAddr, 2, &types.TokenQueryOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder1, holder0}, holdrArr)
+
+	holdrArr, err = db.GetAllTokenHolders(contrAddr, 2, &types.TokenQueryOptions{After: holder1.String(), PageSize: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder0}, holdrArr)
+
+	// balance before begin block
+	result, err = db.GetERC20Balance(contrAddr, holder0, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(5), EndBlockNumber: big.NewInt(5)})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{5: big.NewInt(850)}, result)
+
+	result, err = db.GetERC20Balance(contrAddr, holder0, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(5), EndBlockNumber: big.NewInt(7)})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{5: big.NewInt(850), 7: big.NewInt(77)}, result)
+}
+
+func TestBoltDB_erc721Balance(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	contrAddr := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holder0 := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	holder1 := types.NewAddress("0xca843569e3427144cead5e4d5999a3d0ccf92b8e")
+	holder2 := types.NewAddress("0xee843569e3427144cead5e4d5999a3d0ccf92bdc")
+	var balances = []types.ERC721Token{
+		{Contract: contrAddr, Holder: holder0, Token: "1", HeldFrom: 1},
+		{Contract: contrAddr, Holder: holder1, Token: "2", HeldFrom: 3},
+		{Contract: contrAddr, Holder: holder2, Token: "3", HeldFrom: 5},
+		{Contract: contrAddr, Holder: holder1, Token: "1", HeldFrom: 6},
+	}
+	for _, b := range balances {
+		tokenId, _ := new(big.Int).SetString(b.Token, 10)
+		err := db.RecordERC721Token(b.Contract, b.Holder, b.HeldFrom, tokenId)
+		assert.Nil(t, err)
+	}
+
+	token, err := db.ERC721TokenByTokenID(contrAddr, 1, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, holder0, token.Holder)
+	assert.EqualValues(t, 5, *token.HeldUntil)
+
+	token, err = db.ERC721TokenByTokenID(contrAddr, 3, big.NewInt(2))
+	assert.Nil(t, err)
+	assert.Equal(t, holder1, token.Holder)
+	assert.Nil(t, token.HeldUntil)
+
+	tokenArr, err := db.ERC721TokensForAccountAtBlock(contrAddr, holder0, 2, &types.TokenQueryOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, tokenArr, 1)
+	assert.Equal(t, holder0, tokenArr[0].Holder)
+	assert.Equal(t, "1", tokenArr[0].Token)
+	assert.Equal(t, uint64(1), tokenArr[0].HeldFrom)
+
+	tokenArr, err = db.AllERC721TokensAtBlock(contrAddr, 3, &types.TokenQueryOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, tokenArr, 2)
+	assert.Equal(t, holder0, tokenArr[0].Holder)
+	assert.Equal(t, "1", tokenArr[0].Token)
+	assert.Equal(t, uint64(1), tokenArr[0].HeldFrom)
+	assert.Equal(t, holder1, tokenArr[1].Holder)
+	assert.Equal(t, "2", tokenArr[1].Token)
+	assert.Equal(t, uint64(3), tokenArr[1].HeldFrom)
+
+	tokenArr, err = db.AllERC721TokensAtBlock(contrAddr, 6, &types.TokenQueryOptions{After: "1", PageSize: 1})
+	assert.Nil(t, err)
+	assert.Len(t, tokenArr, 1)
+	assert.Equal(t, "2", tokenArr[0].Token)
+
+	holdrArr, err := db.AllHoldersAtBlock(contrAddr, 3, &types.TokenQueryOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder1, holder0}, holdrArr)
+
+	holdrArr, err = db.AllHoldersAtBlock(contrAddr, 6, &types.TokenQueryOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holder1, holder2}, holdrArr)
+}
+
+func TestBoltDB_Rollback(t *testing.T) {
+	db, cleanup := newTestDB(t)
+	defer cleanup()
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holder := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+
+	// block 1 is kept, block 2 is orphaned
+	orphanedTx := &types.Transaction{
+		Hash:        types.NewHash("0x5c83fa5955aff33c61813105851777bcd2adc85deb9af6286ba42c05cd768de0"),
+		BlockNumber: 2,
+		To:          addr,
+		Events:      []*types.Event{{Address: addr, BlockNumber: 2}},
+	}
+	orphanedBlock := &types.Block{
+		Hash:         types.NewHash("0xb2"),
+		ParentHash:   block.Hash,
+		Number:       2,
+		Transactions: []types.Hash{orphanedTx.Hash},
+	}
+	_ = db.AddAddresses([]types.Address{addr})
+	_ = db.WriteTransactions([]*types.Transaction{tx1, tx2, tx3, orphanedTx})
+	_ = db.WriteBlocks([]*types.Block{block, orphanedBlock})
+	_ = db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{
+		blockWithTransactions,
+		{Hash: orphanedBlock.Hash, Number: 2, Transactions: []*types.Transaction{orphanedTx}},
+	})
+	_ = db.IndexStorage(map[types.Address]*types.AccountState{addr: {Root: types.NewHash("0x1")}}, 1)
+	_ = db.IndexStorage(map[types.Address]*types.AccountState{addr: {Root: types.NewHash("0x2")}}, 2)
+	_ = db.RecordNewERC20Balance(contract, holder, 1, big.NewInt(100))
+	_ = db.RecordNewERC20Balance(contract, holder, 2, big.NewInt(50))
+	_ = db.RecordERC721Token(contract, holder, 1, big.NewInt(1))
+	_ = db.RecordERC721Token(contract, addr, 2, big.NewInt(1))
+
+	total, _ := db.GetTransactionsToAddressTotal(addr, nil)
+	assert.EqualValues(t, 2, total)
+
+	err := db.Rollback(1)
+	assert.Nil(t, err)
+
+	// chain data
+	lastPersisted, _ := db.GetLastPersistedBlockNumber()
+	assert.EqualValues(t, 1, lastPersisted)
+	_, err = db.ReadBlock(1)
+	assert.Nil(t, err)
+	_, err = db.ReadBlock(2)
+	assert.EqualError(t, err, "block does not exist")
+	_, err = db.ReadTransaction(orphanedTx.Hash)
+	assert.EqualError(t, err, "transaction does not exist")
+	testReadTransaction(t, db, tx3)
+
+	// index data
+	lastFiltered, _ := db.GetLastFiltered(addr)
+	assert.EqualValues(t, 1, lastFiltered)
+	txs, err := db.GetAllTransactionsToAddress(addr, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{tx3.Hash}, txs)
+	total, _ = db.GetTransactionsInternalToAddressTotal(addr, nil)
+	assert.EqualValues(t, 1, total)
+	total, _ = db.GetEventsFromAddressTotal(addr, nil)
+	assert.EqualValues(t, 1, total)
+	storage, err := db.GetStorageWithOptions(addr, &types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(-1)})
+	assert.Nil(t, err)
+	assert.Len(t, storage, 1)
+	assert.Equal(t, uint64(1), storage[0].BlockNumber)
+
+	// token data
+	balances, err := db.GetERC20Balance(contract, holder, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(-1)})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(100)}, balances)
+
+	token, err := db.ERC721TokenByTokenID(contract, 5, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, holder, token.Holder)
+	assert.Nil(t, token.HeldUntil)
+}
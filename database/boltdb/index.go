@@ -0,0 +1,474 @@
+package boltdb
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+
+	bolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+var errAddressNotRegistered = errors.New("address is not registered")
+
+func (bdb *BoltDB) IndexStorage(rawStorage map[types.Address]*types.AccountState, blockNumber uint64) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		for address, dumpAccount := range rawStorage {
+			roots, err := nestedBucket(tx.Bucket(storageRootBucket), addressKey(address))
+			if err != nil {
+				return err
+			}
+			storage, err := nestedBucket(tx.Bucket(storageBucket), addressKey(address))
+			if err != nil {
+				return err
+			}
+			root := []byte(dumpAccount.Root.String())
+			if err := roots.Put(encodeUint64(blockNumber), root); err != nil {
+				return err
+			}
+			if storage.Get(root) == nil {
+				if err := putJSON(storage, root, dumpAccount.Storage); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) IndexBlocks(addresses []types.Address, blocks []*types.BlockWithTransactions) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		for _, block := range blocks {
+			if err := bdb.indexBlock(tx, addresses, block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) SetContractCreationTransaction(creationTxns map[types.Hash][]types.Address) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		for txHash, addresses := range creationTxns {
+			for _, createdAddress := range addresses {
+				if !isRegistered(tx, createdAddress) {
+					//tried to index a deleted address, do nothing
+					log.Debug("Ignored deleted address contract creation", "tx", txHash.Hex(), "contract", createdAddress)
+					continue
+				}
+				if err := tx.Bucket(creationTxBucket).Put(addressKey(createdAddress), []byte(txHash.String())); err != nil {
+					return err
+				}
+				log.Debug("Indexed address of contract creation", "tx", txHash.Hex(), "contract", createdAddress)
+			}
+		}
+		return nil
+	})
+}
+
+func (bdb *BoltDB) GetContractCreationTransaction(address types.Address) (types.Hash, error) {
+	var hash types.Hash
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		if !isRegistered(tx, address) {
+			return errAddressNotRegistered
+		}
+		if value := tx.Bucket(creationTxBucket).Get(addressKey(address)); value != nil {
+			hash = types.NewHash(string(value))
+		}
+		return nil
+	})
+	return hash, err
+}
+
+func (bdb *BoltDB) GetAllTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	return bdb.getIndexedTransactions(txToBucket, address, options)
+}
+
+func (bdb *BoltDB) GetTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	txs, err := bdb.getIndexedTransactions(txToBucket, address, withoutPaging(options))
+	return uint64(len(txs)), err
+}
+
+func (bdb *BoltDB) GetAllTransactionsInternalToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	return bdb.getIndexedTransactions(txInternalToBucket, address, options)
+}
+
+func (bdb *BoltDB) GetTransactionsInternalToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	txs, err := bdb.getIndexedTransactions(txInternalToBucket, address, withoutPaging(options))
+	return uint64(len(txs)), err
+}
+
+func (bdb *BoltDB) GetAllFailedTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	return bdb.getIndexedTransactions(txFailedToBucket, address, options)
+}
+
+func (bdb *BoltDB) GetFailedTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	txs, err := bdb.getIndexedTransactions(txFailedToBucket, address, withoutPaging(options))
+	return uint64(len(txs)), err
+}
+
+func (bdb *BoltDB) GetAllEventsFromAddress(address types.Address, options *types.QueryOptions) ([]*types.Event, error) {
+	var events []*types.Event
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		if !isRegistered(tx, address) {
+			return errAddressNotRegistered
+		}
+		return forEachIndexed(tx, eventBucket, address, options, func(v []byte) (uint64, func(), error) {
+			var event types.Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				return 0, nil, err
+			}
+			return event.Timestamp, func() { events = append(events, &event) }, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (bdb *BoltDB) GetEventsFromAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	events, err := bdb.GetAllEventsFromAddress(address, withoutPaging(options))
+	return uint64(len(events)), err
+}
+
+func (bdb *BoltDB) GetStorage(address types.Address, blockNumber uint64) (*types.StorageResult, error) {
+	result := &types.StorageResult{
+		Storage:     make(map[types.Hash]string),
+		StorageRoot: types.NewHash(""),
+		BlockNumber: blockNumber,
+	}
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		if !isRegistered(tx, address) {
+			return errAddressNotRegistered
+		}
+		roots := readNestedBucket(tx.Bucket(storageRootBucket), addressKey(address))
+		if roots == nil {
+			return nil
+		}
+		root := roots.Get(encodeUint64(blockNumber))
+		if root == nil {
+			return nil
+		}
+		result.StorageRoot = types.NewHash(string(root))
+		return readStorage(tx, address, root, &result.Storage)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (bdb *BoltDB) GetStorageWithOptions(address types.Address, options *types.PageOptions) ([]*types.StorageResult, error) {
+	var results []*types.StorageResult
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		if !isRegistered(tx, address) {
+			return errAddressNotRegistered
+		}
+		return forEachStorageRoot(tx, address, options, func(blockNumber uint64, root []byte) error {
+			result := &types.StorageResult{
+				StorageRoot: types.NewHash(string(root)),
+				BlockNumber: blockNumber,
+			}
+			if err := readStorage(tx, address, root, &result.Storage); err != nil {
+				return err
+			}
+			results = append(results, result)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (bdb *BoltDB) GetStorageTotal(address types.Address, options *types.PageOptions) (uint64, error) {
+	var total uint64
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		if !isRegistered(tx, address) {
+			return errAddressNotRegistered
+		}
+		unpaged := &types.PageOptions{BeginBlockNumber: options.BeginBlockNumber, EndBlockNumber: options.EndBlockNumber}
+		return forEachStorageRoot(tx, address, unpaged, func(uint64, []byte) error {
+			total++
+			return nil
+		})
+	})
+	return total, err
+}
+
+func (bdb *BoltDB) GetStorageRanges(contract types.Address, options *types.PageOptions) ([]types.RangeResult, error) {
+	var results []types.RangeResult
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		if !isRegistered(tx, contract) {
+			return errAddressNotRegistered
+		}
+		start, end := blockRange(options.BeginBlockNumber, options.EndBlockNumber)
+		if big.NewInt(-1).Cmp(options.EndBlockNumber) == 0 {
+			end = decodeUint64(tx.Bucket(lastFilteredBucket).Get(addressKey(contract)))
+		}
+
+		// split the range into chunks holding at most 1000 results, newest first
+		currentCount := 0
+		lastEnd := end
+		unpaged := &types.PageOptions{BeginBlockNumber: new(big.Int).SetUint64(start), EndBlockNumber: new(big.Int).SetUint64(end)}
+		err := forEachStorageRoot(tx, contract, unpaged, func(blockNumber uint64, _ []byte) error {
+			currentCount++
+			if currentCount == 1000 {
+				results = append(results, types.RangeResult{Start: blockNumber, End: lastEnd, ResultCount: 1000})
+				currentCount = 0
+				lastEnd = blockNumber - 1
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		results = append(results, types.RangeResult{Start: start, End: lastEnd, ResultCount: currentCount})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (bdb *BoltDB) GetLastFiltered(address types.Address) (uint64, error) {
+	var lastFiltered uint64
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		lastFiltered = decodeUint64(tx.Bucket(lastFilteredBucket).Get(addressKey(address)))
+		return nil
+	})
+	return lastFiltered, err
+}
+
+// internal functions
+
+func (bdb *BoltDB) indexBlock(tx *bolt.Tx, addresses []types.Address, block *types.BlockWithTransactions) error {
+	// filter out registered and unfiltered address only
+	filteredAddresses := map[types.Address]bool{}
+	for _, address := range addresses {
+		lastFiltered := decodeUint64(tx.Bucket(lastFilteredBucket).Get(addressKey(address)))
+		if isRegistered(tx, address) && lastFiltered < block.Number {
+			filteredAddresses[address] = true
+			log.Info("Index registered address ", "address", address.Hex(), "blocknumber", block.Number)
+		}
+	}
+
+	// index transactions and events
+	for _, transaction := range block.Transactions {
+		if err := indexTransaction(tx, filteredAddresses, block.Number, transaction); err != nil {
+			return err
+		}
+	}
+
+	for address := range filteredAddresses {
+		if err := tx.Bucket(lastFilteredBucket).Put(addressKey(address), encodeUint64(block.Number)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexTransaction(tx *bolt.Tx, filteredAddresses map[types.Address]bool, blockNumber uint64, transaction *types.Transaction) error {
+	indexed := indexedTransaction{Hash: transaction.Hash, Timestamp: transaction.Timestamp}
+	if filteredAddresses[transaction.To] {
+		if err := appendIndexed(tx, txToBucket, transaction.To, blockNumber, indexed); err != nil {
+			return err
+		}
+		log.Debug("Indexed tx recipient", "tx", transaction.Hash.Hex(), "recipient", transaction.To.Hex())
+		if !transaction.Status {
+			if err := appendIndexed(tx, txFailedToBucket, transaction.To, blockNumber, indexed); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, internalCall := range transaction.InternalCalls {
+		if filteredAddresses[internalCall.To] {
+			if err := appendIndexed(tx, txInternalToBucket, internalCall.To, blockNumber, indexed); err != nil {
+				return err
+			}
+			log.Debug("Indexed transactions internal calls", "tx", transaction.Hash.Hex(), "internal-recipient", internalCall.To.Hex())
+		}
+	}
+	// Index events emitted by the given address
+	for _, event := range transaction.Events {
+		if filteredAddresses[event.Address] {
+			if err := appendIndexed(tx, eventBucket, event.Address, blockNumber, event); err != nil {
+				return err
+			}
+			log.Debug("Indexed emitted event", "tx", event.TransactionHash.Hex(), "address", event.Address.Hex())
+		}
+	}
+	return nil
+}
+
+// appendIndexed stores an entry in the address's index, keyed by the block number
+// followed by a sequence number, so that entries keep the order they were indexed in.
+func appendIndexed(tx *bolt.Tx, name []byte, address types.Address, blockNumber uint64, value interface{}) error {
+	bucket, err := nestedBucket(tx.Bucket(name), addressKey(address))
+	if err != nil {
+		return err
+	}
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	key := append(encodeUint64(blockNumber), encodeUint64(sequence)...)
+	return putJSON(bucket, key, value)
+}
+
+func (bdb *BoltDB) getIndexedTransactions(name []byte, address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	var txs []types.Hash
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		if !isRegistered(tx, address) {
+			return errAddressNotRegistered
+		}
+		return forEachIndexed(tx, name, address, options, func(v []byte) (uint64, func(), error) {
+			var indexed indexedTransaction
+			if err := json.Unmarshal(v, &indexed); err != nil {
+				return 0, nil, err
+			}
+			if options.HasValueRange() {
+				value, err := transactionValue(tx, indexed.Hash)
+				if err != nil {
+					return 0, nil, err
+				}
+				if !options.InValueRange(value) {
+					return indexed.Timestamp, nil, nil
+				}
+			}
+			return indexed.Timestamp, func() { txs = append(txs, indexed.Hash) }, nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// forEachIndexed walks an address's index in descending block order, applying the
+// query options. The decode function returns the entry's timestamp, and a function
+// to collect the entry if it is in the requested page, which is nil if the entry
+// does not otherwise match the query.
+func forEachIndexed(tx *bolt.Tx, name []byte, address types.Address, options *types.QueryOptions, decode func([]byte) (uint64, func(), error)) error {
+	bucket := readNestedBucket(tx.Bucket(name), addressKey(address))
+	if bucket == nil {
+		return nil
+	}
+
+	var fromBlock, toBlock, fromTime, toTime uint64
+	var skip, limit int
+	if options == nil {
+		fromBlock, toBlock = blockRange(nil, nil)
+		fromTime, toTime = blockRange(nil, nil)
+	} else {
+		fromBlock, toBlock = blockRange(options.BeginBlockNumber, options.EndBlockNumber)
+		fromTime, toTime = blockRange(options.BeginTimestamp, options.EndTimestamp)
+		skip, limit = options.PageSize*options.PageNumber, options.PageSize
+	}
+
+	// position the cursor on the last entry at or below the end block
+	c := bucket.Cursor()
+	var k, v []byte
+	if toBlock == math.MaxUint64 {
+		k, v = c.Last()
+	} else if k, _ = c.Seek(encodeUint64(toBlock + 1)); k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+	matched := 0
+	for ; k != nil && decodeUint64(k) >= fromBlock; k, v = c.Prev() {
+		timestamp, collect, err := decode(v)
+		if err != nil {
+			return err
+		}
+		if collect == nil || timestamp < fromTime || timestamp > toTime {
+			continue
+		}
+		matched++
+		if matched <= skip {
+			continue
+		}
+		collect()
+		if limit > 0 && matched == skip+limit {
+			break
+		}
+	}
+	return nil
+}
+
+// transactionValue reads the value sent by a stored transaction, which is not
+// kept in the index as it is only needed when searching by value
+func transactionValue(tx *bolt.Tx, hash types.Hash) (*big.Int, error) {
+	var transaction struct {
+		Value *big.Int `json:"value"`
+	}
+	if data := tx.Bucket(transactionBucket).Get([]byte(hash.String())); data != nil {
+		if err := json.Unmarshal(data, &transaction); err != nil {
+			return nil, err
+		}
+	}
+	return transaction.Value, nil
+}
+
+// forEachStorageRoot walks the contract's storage roots within the block range in
+// descending block order, applying the page size if set.
+func forEachStorageRoot(tx *bolt.Tx, address types.Address, options *types.PageOptions, fn func(uint64, []byte) error) error {
+	roots := readNestedBucket(tx.Bucket(storageRootBucket), addressKey(address))
+	if roots == nil {
+		return nil
+	}
+	fromBlock, toBlock := blockRange(options.BeginBlockNumber, options.EndBlockNumber)
+	skip, limit := options.PageSize*options.PageNumber, options.PageSize
+
+	c := roots.Cursor()
+	k, v := c.Seek(encodeUint64(toBlock))
+	if k == nil {
+		k, v = c.Last()
+	} else if decodeUint64(k) > toBlock {
+		k, v = c.Prev()
+	}
+	count := 0
+	for ; k != nil && decodeUint64(k) >= fromBlock; k, v = c.Prev() {
+		count++
+		if count <= skip {
+			continue
+		}
+		if err := fn(decodeUint64(k), v); err != nil {
+			return err
+		}
+		if limit > 0 && count == skip+limit {
+			break
+		}
+	}
+	return nil
+}
+
+func readStorage(tx *bolt.Tx, address types.Address, root []byte, storage *map[types.Hash]string) error {
+	bucket := readNestedBucket(tx.Bucket(storageBucket), addressKey(address))
+	if bucket == nil {
+		return nil
+	}
+	if value := bucket.Get(root); value != nil {
+		return json.Unmarshal(value, storage)
+	}
+	return nil
+}
+
+// withoutPaging copies the query options with paging removed, for counting totals
+func withoutPaging(options *types.QueryOptions) *types.QueryOptions {
+	if options == nil {
+		return nil
+	}
+	unpaged := *options
+	unpaged.PageSize = 0
+	unpaged.PageNumber = 0
+	return &unpaged
+}
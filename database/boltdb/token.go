@@ -0,0 +1,557 @@
+package boltdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+// ERC20 balances are stored per contract and holder, keyed by the block the balance
+// changed at, with the amount as the value.
+// ERC721 tokens are stored per contract and token ID, keyed by the block the token
+// was received at, with the full ERC721Token as the value.
+// ERC1155 balances are stored per contract, token ID and holder, keyed by the block
+// the balance changed at, with the amount as the value.
+// ERC777 operators are stored per contract, holder and operator, keyed by the block
+// the operator was authorized or revoked at, with whether it was authorized as the value.
+// ERC4626 vault totals are stored per contract, keyed by block, with the full
+// ERC4626Assets as the value.
+
+var zeroAddress = types.NewAddress("")
+
+func (bdb *BoltDB) RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		balances, err := nestedBucket(tx.Bucket(erc20Bucket), addressKey(contract), addressKey(holder))
+		if err != nil {
+			return err
+		}
+		return balances.Put(encodeUint64(block), []byte(amount.String()))
+	})
+}
+
+func (bdb *BoltDB) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	var balanceMap map[uint64]*big.Int
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		var err error
+		balanceMap, err = balanceHistory(readNestedBucket(tx.Bucket(erc20Bucket), addressKey(contract), addressKey(holder)), options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balanceMap, nil
+}
+
+func (bdb *BoltDB) GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	var holders []types.Address
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		contractDB := readNestedBucket(tx.Bucket(erc20Bucket), addressKey(contract))
+		if contractDB == nil {
+			return nil
+		}
+		return contractDB.ForEach(func(k, _ []byte) error {
+			holder := types.NewAddress(string(k))
+			if holder == zeroAddress {
+				return nil
+			}
+			first, _ := contractDB.Bucket(k).Cursor().First()
+			if first != nil && decodeUint64(first) <= block {
+				holders = append(holders, holder)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pageHolders(holders, options), nil
+}
+
+func (bdb *BoltDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		tokenDB, err := nestedBucket(tx.Bucket(erc721Bucket), addressKey(contract), []byte(tokenId.String()))
+		if err != nil {
+			return err
+		}
+
+		// close off the previous holder's ownership
+		if block > 0 {
+			if k, existing, err := tokenAtBlock(tokenDB, block-1); err != nil {
+				return err
+			} else if existing != nil {
+				heldUntil := block - 1
+				existing.HeldUntil = &heldUntil
+				if err := putJSON(tokenDB, k, existing); err != nil {
+					return err
+				}
+			}
+		}
+
+		tokenHolderInfo := types.ERC721Token{
+			Contract:  contract,
+			Holder:    holder,
+			Token:     tokenId.String(),
+			HeldFrom:  block,
+			HeldUntil: nil,
+		}
+		return putJSON(tokenDB, encodeUint64(block), tokenHolderInfo)
+	})
+}
+
+func (bdb *BoltDB) ERC721TokenByTokenID(contract types.Address, block uint64, tokenId *big.Int) (*types.ERC721Token, error) {
+	var token *types.ERC721Token
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		tokenDB := readNestedBucket(tx.Bucket(erc721Bucket), addressKey(contract), []byte(tokenId.String()))
+		if tokenDB == nil {
+			return database.ErrNotFound
+		}
+		var err error
+		if _, token, err = tokenAtBlock(tokenDB, block); err != nil {
+			return err
+		}
+		if token == nil {
+			return database.ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (bdb *BoltDB) ERC721TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return bdb.erc721TokensAtBlock(contract, &holder, block, options)
+}
+
+func (bdb *BoltDB) AllERC721TokensAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return bdb.erc721TokensAtBlock(contract, nil, block, options)
+}
+
+func (bdb *BoltDB) AllHoldersAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	tokens, err := bdb.erc721TokensAtBlock(contract, nil, block, &types.TokenQueryOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	holderMap := make(map[types.Address]bool)
+	holders := make([]types.Address, 0)
+	for _, token := range tokens {
+		if !holderMap[token.Holder] {
+			holderMap[token.Holder] = true
+			holders = append(holders, token.Holder)
+		}
+	}
+	return pageHolders(holders, options), nil
+}
+
+func (bdb *BoltDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		balances, err := nestedBucket(tx.Bucket(erc1155Bucket), addressKey(contract), []byte(tokenId.String()), addressKey(holder))
+		if err != nil {
+			return err
+		}
+		return balances.Put(encodeUint64(block), []byte(amount.String()))
+	})
+}
+
+func (bdb *BoltDB) GetERC1155Balance(contract types.Address, holder types.Address, tokenId *big.Int, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	var balanceMap map[uint64]*big.Int
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		var err error
+		balanceMap, err = balanceHistory(readNestedBucket(tx.Bucket(erc1155Bucket), addressKey(contract), []byte(tokenId.String()), addressKey(holder)), options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balanceMap, nil
+}
+
+func (bdb *BoltDB) ERC1155HoldersAtBlock(contract types.Address, tokenId *big.Int, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	var holders []types.Address
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		tokenDB := readNestedBucket(tx.Bucket(erc1155Bucket), addressKey(contract), []byte(tokenId.String()))
+		if tokenDB == nil {
+			return nil
+		}
+		return tokenDB.ForEach(func(k, _ []byte) error {
+			amount, _, err := balanceAtBlock(tokenDB.Bucket(k), block)
+			if err != nil {
+				return err
+			}
+			if amount != nil && amount.Sign() != 0 {
+				holders = append(holders, types.NewAddress(string(k)))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pageHolders(holders, options), nil
+}
+
+func (bdb *BoltDB) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	result := make([]types.ERC1155Token, 0)
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		contractDB := readNestedBucket(tx.Bucket(erc1155Bucket), addressKey(contract))
+		if contractDB == nil {
+			return nil
+		}
+		return contractDB.ForEach(func(k, _ []byte) error {
+			balances := contractDB.Bucket(k).Bucket(addressKey(holder))
+			if balances == nil {
+				return nil
+			}
+			amount, heldFrom, err := balanceAtBlock(balances, block)
+			if err != nil || amount == nil || amount.Sign() == 0 {
+				return err
+			}
+			tokenId, success := new(big.Int).SetString(string(k), 10)
+			if !success {
+				return errors.New(`could not parse "erc1155" token ID`)
+			}
+			if tokenId.Cmp(startTokenId) > 0 {
+				result = append(result, types.ERC1155Token{
+					Contract: contract,
+					Holder:   holder,
+					Token:    tokenId.String(),
+					Amount:   amount,
+					HeldFrom: heldFrom,
+				})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		first, _ := new(big.Int).SetString(result[i].Token, 10)
+		second, _ := new(big.Int).SetString(result[j].Token, 10)
+		return first.Cmp(second) < 0
+	})
+	if options.PageSize > 0 {
+		from := options.PageSize * options.PageNumber
+		if from > len(result) {
+			from = len(result)
+		}
+		to := from + options.PageSize
+		if to > len(result) {
+			to = len(result)
+		}
+		result = result[from:to]
+	}
+	return result, nil
+}
+
+func (bdb *BoltDB) RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		changes, err := nestedBucket(tx.Bucket(erc777Bucket), addressKey(contract), addressKey(holder), addressKey(operator))
+		if err != nil {
+			return err
+		}
+		value := []byte{0}
+		if authorized {
+			value = []byte{1}
+		}
+		return changes.Put(encodeUint64(block), value)
+	})
+}
+
+func (bdb *BoltDB) ERC777OperatorsAtBlock(contract types.Address, holder types.Address, block uint64) ([]types.Address, error) {
+	var operators []types.Address
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		holderDB := readNestedBucket(tx.Bucket(erc777Bucket), addressKey(contract), addressKey(holder))
+		if holderDB == nil {
+			return nil
+		}
+		return holderDB.ForEach(func(k, _ []byte) error {
+			c := holderDB.Bucket(k).Cursor()
+			changedAt, authorized := c.Seek(encodeUint64(block))
+			if changedAt == nil || decodeUint64(changedAt) != block {
+				changedAt, authorized = previous(c, changedAt)
+			}
+			if changedAt != nil && authorized[0] == 1 {
+				operators = append(operators, types.NewAddress(string(k)))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pageHolders(operators, &types.TokenQueryOptions{}), nil
+}
+
+func (bdb *BoltDB) RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		contractDB, err := nestedBucket(tx.Bucket(erc4626Bucket), addressKey(contract))
+		if err != nil {
+			return err
+		}
+		assets := types.ERC4626Assets{
+			Contract:    contract,
+			BlockNumber: block,
+			TotalAssets: totalAssets,
+			TotalSupply: totalSupply,
+		}
+		return putJSON(contractDB, encodeUint64(block), assets)
+	})
+}
+
+func (bdb *BoltDB) GetERC4626AssetsAtBlock(contract types.Address, block uint64) (*types.ERC4626Assets, error) {
+	var assets types.ERC4626Assets
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		contractDB := readNestedBucket(tx.Bucket(erc4626Bucket), addressKey(contract))
+		if contractDB == nil {
+			return database.ErrNotFound
+		}
+		c := contractDB.Cursor()
+		k, v := c.Seek(encodeUint64(block))
+		if k == nil || decodeUint64(k) != block {
+			k, v = previous(c, k)
+		}
+		if k == nil {
+			return database.ErrNotFound
+		}
+		return json.Unmarshal(v, &assets)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &assets, nil
+}
+
+// internal functions
+
+func (bdb *BoltDB) erc721TokensAtBlock(contract types.Address, holder *types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	result := make([]types.ERC721Token, 0)
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		contractDB := readNestedBucket(tx.Bucket(erc721Bucket), addressKey(contract))
+		if contractDB == nil {
+			return nil
+		}
+		return forEachBucket(contractDB, func(tokenDB *bolt.Bucket) error {
+			_, token, err := tokenAtBlock(tokenDB, block)
+			if err != nil || token == nil {
+				return err
+			}
+			if holder != nil && token.Holder != *holder {
+				return nil
+			}
+			if token.HeldUntil != nil && *token.HeldUntil < block {
+				return nil
+			}
+			tokenId, success := new(big.Int).SetString(token.Token, 10)
+			if !success {
+				return errors.New(`could not parse "erc721" token ID`)
+			}
+			if tokenId.Cmp(startTokenId) > 0 {
+				result = append(result, *token)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		first, _ := new(big.Int).SetString(result[i].Token, 10)
+		second, _ := new(big.Int).SetString(result[j].Token, 10)
+		return first.Cmp(second) < 0
+	})
+	if options.PageSize > 0 {
+		from := options.PageSize * options.PageNumber
+		if from > len(result) {
+			from = len(result)
+		}
+		to := from + options.PageSize
+		if to > len(result) {
+			to = len(result)
+		}
+		result = result[from:to]
+	}
+	return result, nil
+}
+
+func (bdb *BoltDB) rollbackERC20(tx *bolt.Tx, blockNumber uint64) error {
+	return forEachBucket(tx.Bucket(erc20Bucket), func(contractDB *bolt.Bucket) error {
+		return forEachBucket(contractDB, func(balances *bolt.Bucket) error {
+			return deleteAbove(balances, blockNumber)
+		})
+	})
+}
+
+func (bdb *BoltDB) rollbackERC1155(tx *bolt.Tx, blockNumber uint64) error {
+	return forEachBucket(tx.Bucket(erc1155Bucket), func(contractDB *bolt.Bucket) error {
+		return forEachBucket(contractDB, func(tokenDB *bolt.Bucket) error {
+			return forEachBucket(tokenDB, func(balances *bolt.Bucket) error {
+				return deleteAbove(balances, blockNumber)
+			})
+		})
+	})
+}
+
+func (bdb *BoltDB) rollbackERC777(tx *bolt.Tx, blockNumber uint64) error {
+	return forEachBucket(tx.Bucket(erc777Bucket), func(contractDB *bolt.Bucket) error {
+		return forEachBucket(contractDB, func(holderDB *bolt.Bucket) error {
+			return forEachBucket(holderDB, func(changes *bolt.Bucket) error {
+				return deleteAbove(changes, blockNumber)
+			})
+		})
+	})
+}
+
+func (bdb *BoltDB) rollbackERC4626(tx *bolt.Tx, blockNumber uint64) error {
+	return forEachBucket(tx.Bucket(erc4626Bucket), func(contractDB *bolt.Bucket) error {
+		return deleteAbove(contractDB, blockNumber)
+	})
+}
+
+func (bdb *BoltDB) rollbackERC721(tx *bolt.Tx, blockNumber uint64) error {
+	return forEachBucket(tx.Bucket(erc721Bucket), func(contractDB *bolt.Bucket) error {
+		return forEachBucket(contractDB, func(tokenDB *bolt.Bucket) error {
+			if err := deleteAbove(tokenDB, blockNumber); err != nil {
+				return err
+			}
+			k, token, err := tokenAtBlock(tokenDB, blockNumber)
+			if err != nil || token == nil {
+				return err
+			}
+			if token.HeldUntil != nil && *token.HeldUntil >= blockNumber {
+				token.HeldUntil = nil
+				return putJSON(tokenDB, k, token)
+			}
+			return nil
+		})
+	})
+}
+
+// tokenAtBlock returns the key and record of the latest holder of a token at the given block
+func tokenAtBlock(tokenDB *bolt.Bucket, block uint64) ([]byte, *types.ERC721Token, error) {
+	c := tokenDB.Cursor()
+	k, v := c.Seek(encodeUint64(block))
+	if k == nil || decodeUint64(k) != block {
+		k, v = previous(c, k)
+	}
+	if k == nil {
+		return nil, nil, nil
+	}
+	var token types.ERC721Token
+	if err := json.Unmarshal(v, &token); err != nil {
+		return nil, nil, err
+	}
+	return k, &token, nil
+}
+
+// balanceHistory returns the balances in a bucket of balance changes within the
+// block range of the options
+func balanceHistory(balances *bolt.Bucket, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	balanceMap := make(map[uint64]*big.Int)
+	if balances == nil {
+		return balanceMap, nil
+	}
+	fromBlock, toBlock := blockRange(options.BeginBlockNumber, options.EndBlockNumber)
+
+	c := balances.Cursor()
+	k, v := c.Seek(encodeUint64(fromBlock))
+	// the balance at the start of the range comes from the last change before it
+	if k == nil || decodeUint64(k) != fromBlock {
+		if previousKey, previousValue := previous(c, k); previousKey != nil {
+			amount, err := parseAmount(previousValue)
+			if err != nil {
+				return nil, err
+			}
+			balanceMap[fromBlock] = amount
+		}
+		// the cursor moved, so position it back at the start of the range
+		k, v = c.Seek(encodeUint64(fromBlock))
+	}
+	for ; k != nil && decodeUint64(k) <= toBlock; k, v = c.Next() {
+		amount, err := parseAmount(v)
+		if err != nil {
+			return nil, err
+		}
+		balanceMap[decodeUint64(k)] = amount
+	}
+	return balanceMap, nil
+}
+
+// balanceAtBlock returns the balance in a bucket of balance changes at the given
+// block, along with the block it changed at, or nil if there is none
+func balanceAtBlock(balances *bolt.Bucket, block uint64) (*big.Int, uint64, error) {
+	c := balances.Cursor()
+	k, v := c.Seek(encodeUint64(block))
+	if k == nil || decodeUint64(k) != block {
+		k, v = previous(c, k)
+	}
+	if k == nil {
+		return nil, 0, nil
+	}
+	amount, err := parseAmount(v)
+	return amount, decodeUint64(k), err
+}
+
+// previous returns the entry before the cursor position given by the result of a
+// Seek, which is the last entry if the seek went past the end.
+func previous(c *bolt.Cursor, seeked []byte) ([]byte, []byte) {
+	if seeked == nil {
+		return c.Last()
+	}
+	return c.Prev()
+}
+
+func parseAmount(value []byte) (*big.Int, error) {
+	amount, success := new(big.Int).SetString(string(value), 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return amount, nil
+}
+
+// pageHolders sorts the holders, and returns the page after the requested holder
+func pageHolders(holders []types.Address, options *types.TokenQueryOptions) []types.Address {
+	sort.Slice(holders, func(i, j int) bool {
+		return bytes.Compare(addressKey(holders[i]), addressKey(holders[j])) < 0
+	})
+	result := make([]types.Address, 0, len(holders))
+	for _, holder := range holders {
+		if options.After != "" && bytes.Compare(addressKey(holder), addressKey(types.NewAddress(options.After))) <= 0 {
+			continue
+		}
+		result = append(result, holder)
+		if options.PageSize > 0 && len(result) == options.PageSize {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package boltdb
+
+import (
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+func (bdb *BoltDB) AddWebhook(webhook *types.Webhook) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		stored := *webhook
+		existing, err := readWebhook(tx, webhook.ID)
+		if err != nil && err != database.ErrNotFound {
+			return err
+		}
+		if existing != nil {
+			stored.Cursor = existing.Cursor
+		}
+		return putJSON(tx.Bucket(webhookBucket), []byte(webhook.ID), stored)
+	})
+}
+
+func (bdb *BoltDB) DeleteWebhook(id string) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(webhookBucket).Get([]byte(id)) == nil {
+			return database.ErrNotFound
+		}
+		return tx.Bucket(webhookBucket).Delete([]byte(id))
+	})
+}
+
+func (bdb *BoltDB) GetWebhooks() ([]*types.Webhook, error) {
+	webhooks := make([]*types.Webhook, 0)
+	err := bdb.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(webhookBucket).ForEach(func(_, v []byte) error {
+			var webhook types.Webhook
+			if err := json.Unmarshal(v, &webhook); err != nil {
+				return err
+			}
+			webhooks = append(webhooks, &webhook)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (bdb *BoltDB) SetWebhookCursor(id string, cursor types.WebhookCursor) error {
+	return bdb.db.Update(func(tx *bolt.Tx) error {
+		webhook, err := readWebhook(tx, id)
+		if err != nil {
+			return err
+		}
+		webhook.Cursor = cursor
+		return putJSON(tx.Bucket(webhookBucket), []byte(id), webhook)
+	})
+}
+
+// internal functions
+
+func readWebhook(tx *bolt.Tx, id string) (*types.Webhook, error) {
+	value := tx.Bucket(webhookBucket).Get([]byte(id))
+	if value == nil {
+		return nil, database.ErrNotFound
+	}
+	var webhook types.Webhook
+	if err := json.Unmarshal(value, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
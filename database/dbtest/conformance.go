@@ -0,0 +1,893 @@
+// Package dbtest provides a conformance suite that checks a database.Database
+// implementation behaves the same as every other backend.
+package dbtest
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+// Factory creates a new, empty database for a single test in the suite.
+type Factory func(t *testing.T) database.Database
+
+var (
+	contract   = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	unused     = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f55")
+	sender     = types.NewAddress("0x0000000000000000000000000000000000000009")
+	holderA    = types.NewAddress("0xa0000000000000000000000000000000000000a1")
+	holderB    = types.NewAddress("0xb0000000000000000000000000000000000000b2")
+	holderC    = types.NewAddress("0xc0000000000000000000000000000000000000c3")
+	zeroHolder = types.NewAddress("")
+)
+
+// RunConformanceTests runs the whole suite, creating a new database for each test.
+func RunConformanceTests(t *testing.T, newDB Factory) {
+	tests := []struct {
+		name string
+		test func(*testing.T, database.Database)
+	}{
+		{"Addresses", testAddresses},
+		{"Templates", testTemplates},
+		{"Blocks", testBlocks},
+		{"Transactions", testTransactions},
+		{"IndexBlocks", testIndexBlocks},
+		{"IndexStorage", testIndexStorage},
+		{"ContractCreationTransaction", testContractCreationTransaction},
+		{"ERC20", testERC20},
+		{"ERC721", testERC721},
+		{"ERC1155", testERC1155},
+		{"ERC777", testERC777},
+		{"ERC4626", testERC4626},
+		{"Rollback", testRollback},
+		{"Webhooks", testWebhooks},
+	}
+	for _, tc := range tests {
+		test := tc.test
+		t.Run(tc.name, func(t *testing.T) {
+			db := newDB(t)
+			defer db.Stop()
+			test(t, db)
+		})
+	}
+}
+
+func testAddresses(t *testing.T, db database.Database) {
+	addresses, err := db.GetAddresses()
+	assert.Nil(t, err)
+	assert.Empty(t, addresses)
+
+	assert.Nil(t, db.AddAddresses([]types.Address{contract, unused}))
+	// adding an existing address is not an error
+	assert.Nil(t, db.AddAddresses([]types.Address{contract}))
+	assert.Nil(t, db.AddAddressFrom(holderA, 100))
+
+	addresses, err = db.GetAddresses()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []types.Address{contract, unused, holderA}, addresses)
+	assertLastFiltered(t, db, contract, 0)
+	assertLastFiltered(t, db, holderA, 99)
+
+	deleteAddress(t, db, unused)
+	deleteAddress(t, db, holderA)
+	addresses, err = db.GetAddresses()
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{contract}, addresses)
+	assertLastFiltered(t, db, holderA, 0)
+}
+
+func testTemplates(t *testing.T, db database.Database) {
+	const (
+		name   = "SimpleStorage"
+		abi    = `[{"type":"function","name":"get","constant":true}]`
+		layout = `{"storage":[]}`
+	)
+	assert.Nil(t, db.AddAddresses([]types.Address{contract}))
+	assert.Nil(t, db.AddTemplate(name, abi, layout))
+	assert.Nil(t, db.AssignTemplate(contract, name))
+
+	templates, err := db.GetTemplates()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{name}, templates)
+
+	templateName, err := db.GetContractTemplate(contract)
+	assert.Nil(t, err)
+	assert.Equal(t, name, templateName)
+	contractABI, err := db.GetContractABI(contract)
+	assert.Nil(t, err)
+	assert.Equal(t, abi, contractABI)
+	storageLayout, err := db.GetStorageLayout(contract)
+	assert.Nil(t, err)
+	assert.Equal(t, layout, storageLayout)
+
+	details, err := db.GetTemplateDetails(name)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.Template{TemplateName: name, ABI: abi, StorageLayout: layout}, details)
+	_, err = db.GetTemplateDetails("unknown")
+	assert.NotNil(t, err)
+
+	// adding a template with the same name replaces it
+	assert.Nil(t, db.AddTemplate(name, "[]", layout))
+	contractABI, err = db.GetContractABI(contract)
+	assert.Nil(t, err)
+	assert.Equal(t, "[]", contractABI)
+}
+
+func testBlocks(t *testing.T, db database.Database) {
+	assertLastPersisted(t, db, 0)
+
+	assert.Nil(t, db.WriteBlocks([]*types.Block{newBlock(1), newBlock(2)}))
+	assertLastPersisted(t, db, 2)
+
+	// the last persisted block only moves past a gap once it is filled
+	assert.Nil(t, db.WriteBlocks([]*types.Block{newBlock(4)}))
+	assertLastPersisted(t, db, 2)
+	assert.Nil(t, db.WriteBlocks([]*types.Block{newBlock(3)}))
+	assertLastPersisted(t, db, 4)
+
+	for number := uint64(1); number <= 4; number++ {
+		expected := newBlock(number)
+		block, err := db.ReadBlock(number)
+		assert.Nil(t, err)
+		if assert.NotNil(t, block) {
+			assert.Equal(t, expected.Number, block.Number)
+			assert.Equal(t, expected.Hash, block.Hash)
+			assert.Equal(t, expected.ParentHash, block.ParentHash)
+			assert.Equal(t, expected.Transactions, block.Transactions)
+		}
+	}
+	_, err := db.ReadBlock(5)
+	assert.NotNil(t, err)
+}
+
+func testTransactions(t *testing.T, db database.Database) {
+	txs := []*types.Transaction{txTo(1), txTo(2), txInternal(1), txCreation(1)}
+	assert.Nil(t, db.WriteTransactions(txs))
+
+	for _, expected := range txs {
+		assertTransaction(t, db, expected)
+	}
+	_, err := db.ReadTransaction(types.NewHash("0xdead"))
+	assert.NotNil(t, err)
+}
+
+func testIndexBlocks(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{contract}))
+	writeChain(t, db, 3)
+	assertLastFiltered(t, db, contract, 3)
+
+	// transactions sent to the address
+	assertTransactionsTo(t, db, defaultQueryOptions(), txTo(3).Hash, txTo(2).Hash, txTo(1).Hash)
+	assertTransactionsToTotal(t, db, defaultQueryOptions(), 3)
+
+	options := defaultQueryOptions()
+	options.BeginBlockNumber, options.EndBlockNumber = big.NewInt(2), big.NewInt(2)
+	assertTransactionsTo(t, db, options, txTo(2).Hash)
+	assertTransactionsToTotal(t, db, options, 1)
+
+	options = defaultQueryOptions()
+	options.BeginTimestamp = big.NewInt(int64(timestamp(2)))
+	assertTransactionsTo(t, db, options, txTo(3).Hash, txTo(2).Hash)
+
+	options = defaultQueryOptions()
+	options.PageSize = 2
+	assertTransactionsTo(t, db, options, txTo(3).Hash, txTo(2).Hash)
+	// totals are not affected by paging
+	assertTransactionsToTotal(t, db, options, 3)
+	options.PageNumber = 1
+	assertTransactionsTo(t, db, options, txTo(1).Hash)
+
+	options = defaultQueryOptions()
+	options.MinValue = value(2)
+	assertTransactionsTo(t, db, options, txTo(3).Hash, txTo(2).Hash)
+	assertTransactionsToTotal(t, db, options, 2)
+	options.MaxValue = value(2)
+	assertTransactionsTo(t, db, options, txTo(2).Hash)
+	assertTransactionsToTotal(t, db, options, 1)
+
+	// transactions sent to the address that failed
+	failedTxs, err := db.GetAllFailedTransactionsToAddress(contract, defaultQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{txTo(2).Hash}, failedTxs)
+	failedTotal, err := db.GetFailedTransactionsToAddressTotal(contract, defaultQueryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, failedTotal)
+
+	options = defaultQueryOptions()
+	options.BeginBlockNumber = big.NewInt(3)
+	failedTxs, err = db.GetAllFailedTransactionsToAddress(contract, options)
+	assert.Nil(t, err)
+	assert.Empty(t, failedTxs)
+
+	// transactions that call the address internally
+	internalTxs, err := db.GetAllTransactionsInternalToAddress(contract, defaultQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{txInternal(3).Hash, txInternal(2).Hash, txInternal(1).Hash}, internalTxs)
+	total, err := db.GetTransactionsInternalToAddressTotal(contract, defaultQueryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, total)
+
+	options = defaultQueryOptions()
+	options.EndBlockNumber = big.NewInt(1)
+	internalTxs, err = db.GetAllTransactionsInternalToAddress(contract, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{txInternal(1).Hash}, internalTxs)
+
+	// events emitted by the address
+	events, err := db.GetAllEventsFromAddress(contract, defaultQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{3, 2, 1}, eventBlockNumbers(events))
+	total, err = db.GetEventsFromAddressTotal(contract, defaultQueryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, total)
+
+	options = defaultQueryOptions()
+	options.PageSize, options.PageNumber = 1, 1
+	events, err = db.GetAllEventsFromAddress(contract, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{2}, eventBlockNumbers(events))
+
+	// blocks that have already been filtered are not indexed twice
+	assert.Nil(t, db.IndexBlocks([]types.Address{contract}, []*types.BlockWithTransactions{newBlockWithTransactions(2)}))
+	assertTransactionsToTotal(t, db, defaultQueryOptions(), 3)
+
+	// unregistered addresses can't be queried
+	_, err = db.GetAllTransactionsToAddress(unused, defaultQueryOptions())
+	assert.NotNil(t, err)
+	_, err = db.GetAllEventsFromAddress(unused, defaultQueryOptions())
+	assert.NotNil(t, err)
+
+	// deleting an address removes its indexed data
+	deleteAddress(t, db, contract)
+	assertLastFiltered(t, db, contract, 0)
+	assert.Nil(t, db.AddAddresses([]types.Address{contract}))
+	assertTransactionsToTotal(t, db, defaultQueryOptions(), 0)
+}
+
+func testIndexStorage(t *testing.T, db database.Database) {
+	firstRoot := types.NewHash("0x73607aa4f228bd19dc95575d08adacede9550df70b9ca9253cb3abf7d8115990")
+	secondRoot := types.NewHash("0x73607aa4f228bd19dc95575d08adacede9550df70b9ca9253cb3abf7d8115991")
+	firstStorage := map[types.Hash]string{
+		types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000"): "2a",
+	}
+	secondStorage := map[types.Hash]string{
+		types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000000"): "2b",
+		types.NewHash("0x0000000000000000000000000000000000000000000000000000000000000001"): "01",
+	}
+
+	assert.Nil(t, db.AddAddresses([]types.Address{contract}))
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{contract: {Root: firstRoot, Storage: firstStorage}}, 1))
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{contract: {Root: secondRoot, Storage: secondStorage}}, 3))
+
+	storage, err := db.GetStorage(contract, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, firstRoot, storage.StorageRoot)
+	assert.Equal(t, firstStorage, storage.Storage)
+	storage, err = db.GetStorage(contract, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, secondRoot, storage.StorageRoot)
+	assert.Equal(t, secondStorage, storage.Storage)
+
+	// there is no storage before the contract was indexed
+	storage, err = db.GetStorage(contract, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, storage.Storage)
+
+	total, err := db.GetStorageTotal(contract, defaultPageOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, total)
+	options := defaultPageOptions()
+	options.BeginBlockNumber, options.EndBlockNumber = big.NewInt(2), big.NewInt(3)
+	total, err = db.GetStorageTotal(contract, options)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, total)
+
+	results, err := db.GetStorageWithOptions(contract, defaultPageOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{3, 1}, storageBlockNumbers(results))
+	options = defaultPageOptions()
+	options.PageSize, options.PageNumber = 1, 1
+	results, err = db.GetStorageWithOptions(contract, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{1}, storageBlockNumbers(results))
+
+	options = defaultPageOptions()
+	options.EndBlockNumber = big.NewInt(3)
+	ranges, err := db.GetStorageRanges(contract, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.RangeResult{{Start: 0, End: 3, ResultCount: 2}}, ranges)
+}
+
+func testContractCreationTransaction(t *testing.T, db database.Database) {
+	creationTx := txCreation(1)
+	assert.Nil(t, db.AddAddresses([]types.Address{contract}))
+
+	// unregistered addresses are ignored
+	err := db.SetContractCreationTransaction(map[types.Hash][]types.Address{creationTx.Hash: {unused, contract}})
+	assert.Nil(t, err)
+
+	hash, err := db.GetContractCreationTransaction(contract)
+	assert.Nil(t, err)
+	assert.Equal(t, creationTx.Hash, hash)
+	_, err = db.GetContractCreationTransaction(unused)
+	assert.NotNil(t, err)
+}
+
+func testERC20(t *testing.T, db database.Database) {
+	balances := []struct {
+		holder types.Address
+		block  uint64
+		amount int64
+	}{
+		{zeroHolder, 1, 0},
+		{holderA, 1, 1000},
+		{holderA, 2, 900},
+		{holderB, 2, 100},
+		{holderB, 3, 150},
+		{holderC, 3, 50},
+		{holderA, 7, 77},
+	}
+	for _, balance := range balances {
+		assert.Nil(t, db.RecordNewERC20Balance(contract, balance.holder, balance.block, big.NewInt(balance.amount)))
+	}
+	// balances of other contracts are kept separate
+	assert.Nil(t, db.RecordNewERC20Balance(unused, holderA, 2, big.NewInt(5)))
+
+	assertERC20Balance(t, db, holderA, 1, 2, map[uint64]*big.Int{1: big.NewInt(1000), 2: big.NewInt(900)})
+	assertERC20Balance(t, db, holderB, 3, 3, map[uint64]*big.Int{3: big.NewInt(150)})
+	// the balance at the start of the range is the last balance before it
+	assertERC20Balance(t, db, holderA, 5, 5, map[uint64]*big.Int{5: big.NewInt(900)})
+	assertERC20Balance(t, db, holderA, 5, -1, map[uint64]*big.Int{5: big.NewInt(900), 7: big.NewInt(77)})
+	assertERC20Balance(t, db, holderC, 1, 2, map[uint64]*big.Int{})
+
+	// the zero address is never a holder
+	assertTokenHolders(t, db, 1, defaultTokenQueryOptions(), holderA)
+	assertTokenHolders(t, db, 3, defaultTokenQueryOptions(), holderA, holderB, holderC)
+
+	options := defaultTokenQueryOptions()
+	options.PageSize = 2
+	assertTokenHolders(t, db, 3, options, holderA, holderB)
+	options.After = holderB.String()
+	assertTokenHolders(t, db, 3, options, holderC)
+}
+
+func testERC721(t *testing.T, db database.Database) {
+	transfers := []struct {
+		holder types.Address
+		block  uint64
+		token  int64
+	}{
+		{holderA, 1, 1},
+		{holderB, 3, 2},
+		{holderC, 5, 3},
+		{holderB, 6, 1},
+	}
+	for _, transfer := range transfers {
+		assert.Nil(t, db.RecordERC721Token(contract, transfer.holder, transfer.block, big.NewInt(transfer.token)))
+	}
+
+	token, err := db.ERC721TokenByTokenID(contract, 1, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, holderA, token.Holder)
+	assert.EqualValues(t, 1, token.HeldFrom)
+	// a transfer closes off the previous holder's ownership
+	token, err = db.ERC721TokenByTokenID(contract, 5, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, holderA, token.Holder)
+	if assert.NotNil(t, token.HeldUntil) {
+		assert.EqualValues(t, 5, *token.HeldUntil)
+	}
+	token, err = db.ERC721TokenByTokenID(contract, 6, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, holderB, token.Holder)
+	assert.Nil(t, token.HeldUntil)
+	_, err = db.ERC721TokenByTokenID(contract, 2, big.NewInt(2))
+	assert.Equal(t, database.ErrNotFound, err)
+
+	tokens, err := db.ERC721TokensForAccountAtBlock(contract, holderA, 5, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1"}, tokenIDs(tokens))
+	tokens, err = db.ERC721TokensForAccountAtBlock(contract, holderA, 6, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, tokens)
+	tokens, err = db.ERC721TokensForAccountAtBlock(contract, holderB, 6, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, tokenIDs(tokens))
+
+	tokens, err = db.AllERC721TokensAtBlock(contract, 3, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, tokenIDs(tokens))
+	assert.Equal(t, []types.Address{holderA, holderB}, tokenHolders(tokens))
+	tokens, err = db.AllERC721TokensAtBlock(contract, 6, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, tokenIDs(tokens))
+	assert.Equal(t, []types.Address{holderB, holderB, holderC}, tokenHolders(tokens))
+
+	options := defaultTokenQueryOptions()
+	options.After = "1"
+	tokens, err = db.AllERC721TokensAtBlock(contract, 6, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2", "3"}, tokenIDs(tokens))
+	options = defaultTokenQueryOptions()
+	options.PageSize, options.PageNumber = 2, 1
+	tokens, err = db.AllERC721TokensAtBlock(contract, 6, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3"}, tokenIDs(tokens))
+
+	holders, err := db.AllHoldersAtBlock(contract, 5, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderA, holderB, holderC}, holders)
+	holders, err = db.AllHoldersAtBlock(contract, 6, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderB, holderC}, holders)
+	options = defaultTokenQueryOptions()
+	options.After, options.PageSize = holderA.String(), 1
+	holders, err = db.AllHoldersAtBlock(contract, 5, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderB}, holders)
+}
+
+func testERC1155(t *testing.T, db database.Database) {
+	balances := []struct {
+		holder types.Address
+		block  uint64
+		token  int64
+		amount int64
+	}{
+		{holderA, 1, 1, 100},
+		{holderA, 1, 2, 5},
+		{holderB, 2, 1, 20},
+		{holderA, 3, 1, 80},
+		{holderC, 3, 3, 1},
+		{holderA, 5, 2, 0},
+	}
+	for _, balance := range balances {
+		assert.Nil(t, db.RecordERC1155Balance(contract, balance.holder, balance.block, big.NewInt(balance.token), big.NewInt(balance.amount)))
+	}
+	// balances of other contracts are kept separate
+	assert.Nil(t, db.RecordERC1155Balance(unused, holderB, 2, big.NewInt(2), big.NewInt(7)))
+
+	options := defaultTokenQueryOptions()
+	options.BeginBlockNumber = big.NewInt(2)
+	history, err := db.GetERC1155Balance(contract, holderA, big.NewInt(1), options)
+	assert.Nil(t, err)
+	// the balance at the start of the range is the last balance before it
+	assert.Equal(t, map[uint64]*big.Int{2: big.NewInt(100), 3: big.NewInt(80)}, history)
+	history, err = db.GetERC1155Balance(contract, holderB, big.NewInt(2), defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, history)
+
+	holders, err := db.ERC1155HoldersAtBlock(contract, big.NewInt(1), 1, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderA}, holders)
+	holders, err = db.ERC1155HoldersAtBlock(contract, big.NewInt(1), 3, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderA, holderB}, holders)
+	options = defaultTokenQueryOptions()
+	options.After = holderA.String()
+	holders, err = db.ERC1155HoldersAtBlock(contract, big.NewInt(1), 3, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderB}, holders)
+	// holders with a balance of zero are left out
+	holders, err = db.ERC1155HoldersAtBlock(contract, big.NewInt(2), 5, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Empty(t, holders)
+
+	tokens, err := db.ERC1155TokensForAccountAtBlock(contract, holderA, 4, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	if assert.Len(t, tokens, 2) {
+		assert.Equal(t, "1", tokens[0].Token)
+		assert.Equal(t, "80", tokens[0].Amount.String())
+		assert.EqualValues(t, 3, tokens[0].HeldFrom)
+		assert.Equal(t, "2", tokens[1].Token)
+		assert.Equal(t, "5", tokens[1].Amount.String())
+	}
+	options = defaultTokenQueryOptions()
+	options.After = "1"
+	tokens, err = db.ERC1155TokensForAccountAtBlock(contract, holderA, 4, options)
+	assert.Nil(t, err)
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, "2", tokens[0].Token)
+	}
+	tokens, err = db.ERC1155TokensForAccountAtBlock(contract, holderA, 5, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, "1", tokens[0].Token)
+	}
+}
+
+func testERC777(t *testing.T, db database.Database) {
+	changes := []struct {
+		operator   types.Address
+		block      uint64
+		authorized bool
+	}{
+		{holderB, 1, true},
+		{holderC, 2, true},
+		{holderB, 4, false},
+		{holderB, 6, true},
+	}
+	for _, change := range changes {
+		assert.Nil(t, db.RecordERC777Operator(contract, holderA, change.operator, change.block, change.authorized))
+	}
+	// operators of other holders and contracts are kept separate
+	assert.Nil(t, db.RecordERC777Operator(contract, holderB, holderC, 1, true))
+	assert.Nil(t, db.RecordERC777Operator(unused, holderA, holderC, 1, true))
+
+	operators, err := db.ERC777OperatorsAtBlock(contract, holderA, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, operators)
+	operators, err = db.ERC777OperatorsAtBlock(contract, holderA, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderB, holderC}, operators)
+	// revoked operators are left out
+	operators, err = db.ERC777OperatorsAtBlock(contract, holderA, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderC}, operators)
+	operators, err = db.ERC777OperatorsAtBlock(contract, holderA, 6)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderB, holderC}, operators)
+}
+
+func testERC4626(t *testing.T, db database.Database) {
+	assert.Nil(t, db.RecordERC4626Assets(contract, 2, big.NewInt(1000), big.NewInt(500)))
+	assert.Nil(t, db.RecordERC4626Assets(contract, 4, big.NewInt(1500), big.NewInt(600)))
+	assert.Nil(t, db.RecordERC4626Assets(unused, 3, big.NewInt(1), big.NewInt(1)))
+
+	_, err := db.GetERC4626AssetsAtBlock(contract, 1)
+	assert.Equal(t, database.ErrNotFound, err)
+	assets, err := db.GetERC4626AssetsAtBlock(contract, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, contract, assets.Contract)
+	assert.EqualValues(t, 2, assets.BlockNumber)
+	assert.Equal(t, "1000", assets.TotalAssets.String())
+	assert.Equal(t, "500", assets.TotalSupply.String())
+	assets, err = db.GetERC4626AssetsAtBlock(contract, 4)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 4, assets.BlockNumber)
+	assert.Equal(t, "1500", assets.TotalAssets.String())
+	assert.Equal(t, "600", assets.TotalSupply.String())
+}
+
+func testRollback(t *testing.T, db database.Database) {
+	assert.Nil(t, db.AddAddresses([]types.Address{contract}))
+	writeChain(t, db, 3)
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{contract: {Root: types.NewHash("0x01")}}, 1))
+	assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{contract: {Root: types.NewHash("0x03")}}, 3))
+	assert.Nil(t, db.RecordNewERC20Balance(contract, holderA, 1, big.NewInt(100)))
+	assert.Nil(t, db.RecordNewERC20Balance(contract, holderA, 3, big.NewInt(50)))
+	assert.Nil(t, db.RecordERC721Token(contract, holderA, 1, big.NewInt(1)))
+	assert.Nil(t, db.RecordERC721Token(contract, holderB, 3, big.NewInt(1)))
+	assert.Nil(t, db.RecordERC1155Balance(contract, holderA, 1, big.NewInt(1), big.NewInt(10)))
+	assert.Nil(t, db.RecordERC1155Balance(contract, holderA, 3, big.NewInt(1), big.NewInt(0)))
+	assert.Nil(t, db.RecordERC777Operator(contract, holderA, holderB, 1, true))
+	assert.Nil(t, db.RecordERC777Operator(contract, holderA, holderB, 3, false))
+	assert.Nil(t, db.RecordERC4626Assets(contract, 1, big.NewInt(100), big.NewInt(10)))
+	assert.Nil(t, db.RecordERC4626Assets(contract, 3, big.NewInt(200), big.NewInt(10)))
+
+	assert.Nil(t, db.Rollback(2))
+
+	// chain data
+	assertLastPersisted(t, db, 2)
+	_, err := db.ReadBlock(3)
+	assert.NotNil(t, err)
+	_, err = db.ReadTransaction(txTo(3).Hash)
+	assert.NotNil(t, err)
+	assertTransaction(t, db, txTo(2))
+
+	// index data
+	assertLastFiltered(t, db, contract, 2)
+	assertTransactionsTo(t, db, defaultQueryOptions(), txTo(2).Hash, txTo(1).Hash)
+	assertTransactionsToTotal(t, db, defaultQueryOptions(), 2)
+	total, err := db.GetEventsFromAddressTotal(contract, defaultQueryOptions())
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, total)
+	results, err := db.GetStorageWithOptions(contract, defaultPageOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{1}, storageBlockNumbers(results))
+
+	// token data
+	assertERC20Balance(t, db, holderA, 0, -1, map[uint64]*big.Int{1: big.NewInt(100)})
+	token, err := db.ERC721TokenByTokenID(contract, 5, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, holderA, token.Holder)
+	assert.Nil(t, token.HeldUntil)
+	holders, err := db.ERC1155HoldersAtBlock(contract, big.NewInt(1), 5, defaultTokenQueryOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderA}, holders)
+	operators, err := db.ERC777OperatorsAtBlock(contract, holderA, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{holderB}, operators)
+	assets, err := db.GetERC4626AssetsAtBlock(contract, 5)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, assets.BlockNumber)
+
+	// the removed blocks can be written and indexed again
+	writeChain(t, db, 3)
+	assertLastPersisted(t, db, 3)
+	assertTransactionsToTotal(t, db, defaultQueryOptions(), 3)
+}
+
+func testWebhooks(t *testing.T, db database.Database) {
+	webhooks, err := db.GetWebhooks()
+	assert.Nil(t, err)
+	assert.Empty(t, webhooks)
+
+	events := &types.Webhook{ID: "a1", Address: contract, Kind: types.WebhookEvents, URL: "http://localhost/events",
+		EventSignature: "Transfer(address,address,uint256)", Secret: "secret", Cursor: types.WebhookCursor{BlockNumber: 5}}
+	transfers := &types.Webhook{ID: "b2", Address: contract, Kind: types.WebhookERC20Transfers, URL: "http://localhost/transfers"}
+	assert.Nil(t, db.AddWebhook(transfers))
+	assert.Nil(t, db.AddWebhook(events))
+
+	webhooks, err = db.GetWebhooks()
+	assert.Nil(t, err)
+	assert.Equal(t, []*types.Webhook{events, transfers}, webhooks)
+
+	cursor := types.WebhookCursor{BlockNumber: 7, TransactionIndex: 1, EventIndex: 3}
+	assert.Nil(t, db.SetWebhookCursor(events.ID, cursor))
+	assert.Equal(t, database.ErrNotFound, db.SetWebhookCursor("unknown", cursor))
+
+	// adding the webhook again updates it, but keeps its cursor
+	updated := *events
+	updated.Secret = "new secret"
+	assert.Nil(t, db.AddWebhook(&updated))
+	webhooks, err = db.GetWebhooks()
+	assert.Nil(t, err)
+	assert.Len(t, webhooks, 2)
+	assert.Equal(t, "new secret", webhooks[0].Secret)
+	assert.Equal(t, cursor, webhooks[0].Cursor)
+
+	assert.Nil(t, db.DeleteWebhook(transfers.ID))
+	assert.Equal(t, database.ErrNotFound, db.DeleteWebhook(transfers.ID))
+	webhooks, err = db.GetWebhooks()
+	assert.Nil(t, err)
+	assert.Len(t, webhooks, 1)
+	assert.Equal(t, events.ID, webhooks[0].ID)
+}
+
+// fixtures
+
+func timestamp(block uint64) uint64 {
+	return 1000 + block
+}
+
+func hash(prefix string, block uint64) types.Hash {
+	return types.NewHash(prefix + new(big.Int).SetUint64(block).Text(16))
+}
+
+func newBlock(number uint64) *types.Block {
+	return &types.Block{
+		Hash:         hash("0xb0", number),
+		ParentHash:   hash("0xb0", number-1),
+		Number:       number,
+		Timestamp:    timestamp(number),
+		Transactions: []types.Hash{txTo(number).Hash, txInternal(number).Hash},
+	}
+}
+
+func newBlockWithTransactions(number uint64) *types.BlockWithTransactions {
+	block := newBlock(number)
+	return &types.BlockWithTransactions{
+		Hash:         block.Hash,
+		ParentHash:   block.ParentHash,
+		Number:       block.Number,
+		Timestamp:    block.Timestamp,
+		Transactions: []*types.Transaction{txTo(number), txInternal(number)},
+	}
+}
+
+// value is the amount of wei sent to the contract in the block, which is more than
+// fits in 64 bits
+func value(block uint64) *big.Int {
+	ether := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	return new(big.Int).Mul(ether, new(big.Int).SetUint64(100*block))
+}
+
+// txTo returns a transaction sent directly to the contract, emitting an event.
+// The transaction of block 2 fails.
+func txTo(block uint64) *types.Transaction {
+	tx := &types.Transaction{
+		Hash:        hash("0xa0", block),
+		BlockNumber: block,
+		BlockHash:   hash("0xb0", block),
+		Index:       0,
+		Timestamp:   timestamp(block),
+		From:        sender,
+		To:          contract,
+		Value:       value(block),
+		Status:      true,
+	}
+	tx.Events = []*types.Event{{
+		Address:         contract,
+		BlockNumber:     block,
+		BlockHash:       tx.BlockHash,
+		TransactionHash: tx.Hash,
+		Timestamp:       tx.Timestamp,
+		Data:            types.NewHexData("0x2a"),
+		Topics:          []types.Hash{types.NewHash("0x01")},
+	}}
+	if block == 2 {
+		tx.Status = false
+		tx.RevertReason = "insufficient balance"
+	}
+	return tx
+}
+
+// txInternal returns a transaction that calls the contract internally
+func txInternal(block uint64) *types.Transaction {
+	return &types.Transaction{
+		Hash:          hash("0xc0", block),
+		BlockNumber:   block,
+		BlockHash:     hash("0xb0", block),
+		Index:         1,
+		Timestamp:     timestamp(block),
+		From:          sender,
+		To:            unused,
+		Status:        true,
+		InternalCalls: []*types.InternalCall{{From: unused, To: contract, Type: "CALL"}},
+	}
+}
+
+// txCreation returns a transaction that creates the contract
+func txCreation(block uint64) *types.Transaction {
+	return &types.Transaction{
+		Hash:            hash("0xd0", block),
+		BlockNumber:     block,
+		BlockHash:       hash("0xb0", block),
+		Timestamp:       timestamp(block),
+		From:            sender,
+		CreatedContract: contract,
+		Status:          true,
+	}
+}
+
+// writeChain writes and indexes blocks 1 to n, in the order the monitor does
+func writeChain(t *testing.T, db database.Database, n uint64) {
+	var blocks []*types.Block
+	var blocksWithTxs []*types.BlockWithTransactions
+	for number := uint64(1); number <= n; number++ {
+		assert.Nil(t, db.WriteTransactions([]*types.Transaction{txTo(number), txInternal(number)}))
+		blocks = append(blocks, newBlock(number))
+		blocksWithTxs = append(blocksWithTxs, newBlockWithTransactions(number))
+	}
+	assert.Nil(t, db.WriteBlocks(blocks))
+	assert.Nil(t, db.IndexBlocks([]types.Address{contract}, blocksWithTxs))
+}
+
+func defaultQueryOptions() *types.QueryOptions {
+	options := &types.QueryOptions{}
+	options.SetDefaults()
+	return options
+}
+
+func defaultPageOptions() *types.PageOptions {
+	options := &types.PageOptions{}
+	options.SetDefaults()
+	return options
+}
+
+func defaultTokenQueryOptions() *types.TokenQueryOptions {
+	options := &types.TokenQueryOptions{}
+	options.SetDefaults()
+	return options
+}
+
+// helpers
+
+// deleteAddress deletes an address, allowing for backends that defer the
+// deletion until the last persisted block number is next read, as the monitor
+// does between batches.
+func deleteAddress(t *testing.T, db database.Database, address types.Address) {
+	done := make(chan error, 1)
+	go func() {
+		done <- db.DeleteAddress(address)
+	}()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case err := <-done:
+			assert.Nil(t, err)
+			return
+		case <-timeout:
+			t.Fatalf("timed out deleting address %s", address.Hex())
+		case <-time.After(10 * time.Millisecond):
+			_, _ = db.GetLastPersistedBlockNumber()
+		}
+	}
+}
+
+func assertLastPersisted(t *testing.T, db database.Database, expected uint64) {
+	lastPersisted, err := db.GetLastPersistedBlockNumber()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, lastPersisted, "last persisted block")
+}
+
+func assertLastFiltered(t *testing.T, db database.Database, address types.Address, expected uint64) {
+	lastFiltered, err := db.GetLastFiltered(address)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, lastFiltered, "last filtered block of %s", address.Hex())
+}
+
+func assertTransaction(t *testing.T, db database.Database, expected *types.Transaction) {
+	tx, err := db.ReadTransaction(expected.Hash)
+	assert.Nil(t, err)
+	if assert.NotNil(t, tx) {
+		assert.Equal(t, expected.Hash, tx.Hash)
+		assert.Equal(t, expected.BlockNumber, tx.BlockNumber)
+		assert.Equal(t, expected.Index, tx.Index)
+		assert.Equal(t, expected.Status, tx.Status)
+		assert.Equal(t, expected.RevertReason, tx.RevertReason)
+		if expected.Value != nil {
+			assert.Equal(t, expected.Value.String(), tx.Value.String())
+		}
+		assert.Equal(t, expected.From, tx.From)
+		// an empty address may be stored as the zero address
+		assert.Equal(t, types.NewAddress(string(expected.To)), types.NewAddress(string(tx.To)))
+		assert.Equal(t, types.NewAddress(string(expected.CreatedContract)), types.NewAddress(string(tx.CreatedContract)))
+		assert.Equal(t, len(expected.Events), len(tx.Events))
+		assert.Equal(t, len(expected.InternalCalls), len(tx.InternalCalls))
+	}
+}
+
+func assertTransactionsTo(t *testing.T, db database.Database, options *types.QueryOptions, expected ...types.Hash) {
+	txs, err := db.GetAllTransactionsToAddress(contract, options)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, txs)
+}
+
+func assertTransactionsToTotal(t *testing.T, db database.Database, options *types.QueryOptions, expected uint64) {
+	total, err := db.GetTransactionsToAddressTotal(contract, options)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, total)
+}
+
+func assertERC20Balance(t *testing.T, db database.Database, holder types.Address, begin, end int64, expected map[uint64]*big.Int) {
+	options := defaultTokenQueryOptions()
+	options.BeginBlockNumber, options.EndBlockNumber = big.NewInt(begin), big.NewInt(end)
+	balances, err := db.GetERC20Balance(contract, holder, options)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, balances)
+}
+
+func assertTokenHolders(t *testing.T, db database.Database, block uint64, options *types.TokenQueryOptions, expected ...types.Address) {
+	holders, err := db.GetAllTokenHolders(contract, block, options)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, holders)
+}
+
+func eventBlockNumbers(events []*types.Event) []uint64 {
+	numbers := make([]uint64, len(events))
+	for i, event := range events {
+		numbers[i] = event.BlockNumber
+	}
+	return numbers
+}
+
+func storageBlockNumbers(results []*types.StorageResult) []uint64 {
+	numbers := make([]uint64, len(results))
+	for i, result := range results {
+		numbers[i] = result.BlockNumber
+	}
+	return numbers
+}
+
+func tokenIDs(tokens []types.ERC721Token) []string {
+	ids := make([]string, len(tokens))
+	for i, token := range tokens {
+		ids[i] = token.Token
+	}
+	return ids
+}
+
+func tokenHolders(tokens []types.ERC721Token) []types.Address {
+	holders := make([]types.Address, len(tokens))
+	for i, token := range tokens {
+		holders[i] = token.Holder
+	}
+	return holders
+}
@@ -592,3 +592,103 @@ func TestElasticsearchDB_GetLastPersistedBlockNumber_DeletingContractData_WithEr
 	assert.EqualValues(t, 0, lastNum)
 	assert.Len(t, db.deleteQueue, 1)
 }
+
+func TestElasticsearchDB_Rollback(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+
+	deletions := map[string]string{
+		BlockIndex:          "number",
+		TransactionIndex:    "blockNumber",
+		EventIndex:          "blockNumber",
+		StorageIndex:        "blockNumber",
+		ERC20TokenIndex:     "blockNumber",
+		ERC721TokenIndex:    "heldFrom",
+		ERC1155TokenIndex:   "blockNumber",
+		ERC777OperatorIndex: "blockNumber",
+		ERC4626AssetsIndex:  "blockNumber",
+	}
+	reopenReq := esapi.UpdateByQueryRequest{
+		Index: []string{ERC20TokenIndex, ERC721TokenIndex, ERC1155TokenIndex, ERC777OperatorIndex},
+		Body:  strings.NewReader(fmt.Sprintf(QueryRollbackHeldUntil, 10)),
+	}
+	lastFilteredReq := esapi.UpdateByQueryRequest{
+		Index: []string{ContractIndex},
+		Body:  strings.NewReader(fmt.Sprintf(QueryRollbackLastFiltered, 10, 10)),
+	}
+	lastPersistedRequest := esapi.GetRequest{
+		Index:      MetaIndex,
+		DocumentID: "lastPersisted",
+	}
+	lastPersistedIndexRequest := esapi.IndexRequest{
+		Index:      MetaIndex,
+		DocumentID: "lastPersisted",
+		Body:       strings.NewReader(`{"lastPersisted": 10}`),
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	for index, field := range deletions {
+		deleteReq := esapi.DeleteByQueryRequest{
+			Index: []string{index},
+			Body:  strings.NewReader(fmt.Sprintf(QueryRollbackAboveBlock, field, 10)),
+		}
+		mockedClient.EXPECT().DoRequest(NewDeleteByQueryRequestMatcher(deleteReq)).Return(nil, nil)
+	}
+	mockedClient.EXPECT().DoRequest(NewUpdateByQueryRequestMatcher(reopenReq)).Return(nil, nil)
+	mockedClient.EXPECT().DoRequest(NewUpdateByQueryRequestMatcher(lastFilteredReq)).Return(nil, nil)
+	mockedClient.EXPECT().
+		DoRequest(NewGetRequestMatcher(lastPersistedRequest)).
+		Return([]byte(`{"_source": {"lastPersisted": 12}}`), nil)
+	mockedClient.EXPECT().DoRequest(NewIndexRequestMatcher(lastPersistedIndexRequest)).Return(nil, nil)
+
+	db, _ := New(mockedClient)
+
+	err := db.Rollback(10)
+
+	assert.Nil(t, err, "unexpected error")
+}
+
+func TestElasticsearchDB_Rollback_BelowLastPersisted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+
+	lastPersistedRequest := esapi.GetRequest{
+		Index:      MetaIndex,
+		DocumentID: "lastPersisted",
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.DeleteByQueryRequest{})).Return(nil, nil).Times(9)
+	mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.UpdateByQueryRequest{})).Return(nil, nil).Times(2)
+	mockedClient.EXPECT().
+		DoRequest(NewGetRequestMatcher(lastPersistedRequest)).
+		Return([]byte(`{"_source": {"lastPersisted": 8}}`), nil)
+
+	db, _ := New(mockedClient)
+
+	err := db.Rollback(10)
+
+	assert.Nil(t, err, "unexpected error")
+}
+
+func TestElasticsearchDB_Rollback_WithError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().
+		DoRequest(gomock.AssignableToTypeOf(esapi.DeleteByQueryRequest{})).
+		Return(nil, errors.New("test error"))
+
+	db, _ := New(mockedClient)
+
+	err := db.Rollback(10)
+
+	assert.EqualError(t, err, "test error", "unexpected error message")
+}
@@ -0,0 +1,49 @@
+package elasticsearch
+
+import (
+	"os"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/dbtest"
+	"quorumengineering/quorum-report/types"
+)
+
+// TestConformance runs against a real ElasticSearch cluster, given by a URL in
+// the ELASTICSEARCH_TEST_URL environment variable. All indices used by the
+// reporting tool are removed before each test.
+func TestConformance(t *testing.T) {
+	url := os.Getenv("ELASTICSEARCH_TEST_URL")
+	if url == "" {
+		t.Skip("ELASTICSEARCH_TEST_URL not set")
+	}
+
+	dbtest.RunConformanceTests(t, func(t *testing.T) database.Database {
+		config, err := NewConfig(&types.ElasticsearchConfig{Addresses: []string{url}})
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		client, err := NewClient(config)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		apiClient, err := NewAPIClient(client)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		ignoreUnavailable := true
+		deleteRequest := esapi.IndicesDeleteRequest{Index: AllIndexes, IgnoreUnavailable: &ignoreUnavailable}
+		if _, err := apiClient.DoRequest(deleteRequest); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		db, err := New(apiClient)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		return db
+	})
+}
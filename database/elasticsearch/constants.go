@@ -4,19 +4,23 @@ import "errors"
 
 // indices
 const (
-	MetaIndex        = "meta"
-	ContractIndex    = "contract"
-	TemplateIndex    = "template"
-	BlockIndex       = "block"
-	StorageIndex     = "storage"
-	TransactionIndex = "transaction"
-	EventIndex       = "event"
-	ERC20TokenIndex  = "erc20token"
-	ERC721TokenIndex = "erc721token"
+	MetaIndex           = "meta"
+	ContractIndex       = "contract"
+	TemplateIndex       = "template"
+	BlockIndex          = "block"
+	StorageIndex        = "storage"
+	TransactionIndex    = "transaction"
+	EventIndex          = "event"
+	ERC20TokenIndex     = "erc20token"
+	ERC721TokenIndex    = "erc721token"
+	ERC1155TokenIndex   = "erc1155token"
+	ERC777OperatorIndex = "erc777operator"
+	ERC4626AssetsIndex  = "erc4626assets"
+	WebhookIndex        = "webhook"
 )
 
 var (
-	AllIndexes = []string{MetaIndex, ContractIndex, TemplateIndex, BlockIndex, StorageIndex, TransactionIndex, EventIndex, ERC20TokenIndex, ERC721TokenIndex}
+	AllIndexes = []string{MetaIndex, ContractIndex, TemplateIndex, BlockIndex, StorageIndex, TransactionIndex, EventIndex, ERC20TokenIndex, ERC721TokenIndex, ERC1155TokenIndex, ERC777OperatorIndex, ERC4626AssetsIndex, WebhookIndex}
 	// errors
 	ErrCouldNotResolveResp     = errors.New("could not resolve response body")
 	ErrIndexNotFound           = errors.New("index not found")
@@ -51,10 +51,9 @@ func NewWithDeps(client APIClient, dataDeleter DeletionCoordinator) (*Elasticsea
 }
 
 func (es *ElasticsearchDB) init() error {
-	mapping := `{"mappings":{"properties": {"internalCalls": {"type": "nested" }}}}`
 	createRequest := esapi.IndicesCreateRequest{
 		Index: TransactionIndex,
-		Body:  strings.NewReader(mapping),
+		Body:  strings.NewReader(transactionMapping),
 	}
 
 	//TODO: check error scenarios
@@ -67,6 +66,10 @@ func (es *ElasticsearchDB) init() error {
 	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: MetaIndex})
 	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ERC20TokenIndex})
 	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ERC721TokenIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ERC1155TokenIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ERC777OperatorIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: ERC4626AssetsIndex})
+	es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: WebhookIndex})
 
 	req := esapi.IndexRequest{
 		Index:      MetaIndex,
@@ -77,7 +80,7 @@ func (es *ElasticsearchDB) init() error {
 	}
 	es.apiClient.DoRequest(req)
 
-	return nil
+	return es.writeSchemaVersion()
 }
 
 //AddressDB
@@ -382,12 +385,79 @@ func (es *ElasticsearchDB) GetLastPersistedBlockNumber() (uint64, error) {
 	return lastPersisted.Source.LastPersisted, nil
 }
 
+func (es *ElasticsearchDB) Rollback(blockNumber uint64) error {
+	// delete all chain and index data above the given block
+	rollbackFields := []struct {
+		index string
+		field string
+	}{
+		{BlockIndex, "number"},
+		{TransactionIndex, "blockNumber"},
+		{EventIndex, "blockNumber"},
+		{StorageIndex, "blockNumber"},
+		{ERC20TokenIndex, "blockNumber"},
+		{ERC721TokenIndex, "heldFrom"},
+		{ERC1155TokenIndex, "blockNumber"},
+		{ERC777OperatorIndex, "blockNumber"},
+		{ERC4626AssetsIndex, "blockNumber"},
+	}
+	for _, rollback := range rollbackFields {
+		deleteReq := esapi.DeleteByQueryRequest{
+			Index:             []string{rollback.index},
+			Body:              strings.NewReader(fmt.Sprintf(QueryRollbackAboveBlock, rollback.field, blockNumber)),
+			Refresh:           &RequestParameterTrue,
+			WaitForCompletion: &RequestParameterTrue,
+		}
+		if _, err := es.apiClient.DoRequest(deleteReq); err != nil {
+			return err
+		}
+		log.Debug("Rolled back index", "index", rollback.index, "block number", blockNumber)
+	}
+
+	// token records closed off by a deleted record are held again
+	reopenReq := esapi.UpdateByQueryRequest{
+		Index:             []string{ERC20TokenIndex, ERC721TokenIndex, ERC1155TokenIndex, ERC777OperatorIndex},
+		Body:              strings.NewReader(fmt.Sprintf(QueryRollbackHeldUntil, blockNumber)),
+		Refresh:           &RequestParameterTrue,
+		WaitForCompletion: &RequestParameterTrue,
+	}
+	if _, err := es.apiClient.DoRequest(reopenReq); err != nil {
+		return err
+	}
+
+	lastFilteredReq := esapi.UpdateByQueryRequest{
+		Index:             []string{ContractIndex},
+		Body:              strings.NewReader(fmt.Sprintf(QueryRollbackLastFiltered, blockNumber, blockNumber)),
+		Refresh:           &RequestParameterTrue,
+		WaitForCompletion: &RequestParameterTrue,
+	}
+	if _, err := es.apiClient.DoRequest(lastFilteredReq); err != nil {
+		return err
+	}
+
+	lastPersisted, err := es.GetLastPersistedBlockNumber()
+	if err != nil {
+		return err
+	}
+	if lastPersisted <= blockNumber {
+		return nil
+	}
+	req := esapi.IndexRequest{
+		Index:      MetaIndex,
+		DocumentID: "lastPersisted",
+		Body:       strings.NewReader(fmt.Sprintf(`{"lastPersisted": %d}`, blockNumber)),
+		Refresh:    "true",
+	}
+	_, err = es.apiClient.DoRequest(req)
+	return err
+}
+
 // TransactionDB
 func (es *ElasticsearchDB) WriteTransaction(transaction *types.Transaction) error {
 	req := esapi.IndexRequest{
 		Index:      TransactionIndex,
 		DocumentID: transaction.Hash.String(),
-		Body:       esutil.NewJSONReader(transaction),
+		Body:       esutil.NewJSONReader(NewTransactionDocument(transaction)),
 		Refresh:    "true",
 	}
 
@@ -416,7 +486,7 @@ func (es *ElasticsearchDB) WriteTransactions(transactions []*types.Transaction)
 			esutil.BulkIndexerItem{
 				Action:     "create",
 				DocumentID: transaction.Hash.String(),
-				Body:       esutil.NewJSONReader(transaction),
+				Body:       esutil.NewJSONReader(NewTransactionDocument(transaction)),
 				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, item2 esutil.BulkIndexerResponseItem) {
 					wg.Done()
 				},
@@ -604,6 +674,48 @@ func (es *ElasticsearchDB) GetTransactionsInternalToAddressTotal(address types.A
 	return results.Count, nil
 }
 
+func (es *ElasticsearchDB) GetAllFailedTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	queryString := fmt.Sprintf(QueryFailedByToAddressWithOptionsTemplate(options), address.String())
+
+	from := options.PageSize * options.PageNumber
+	if from+options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+	req := esapi.SearchRequest{
+		Index: []string{TransactionIndex},
+		Body:  strings.NewReader(queryString),
+		From:  &from,
+		Size:  &options.PageSize,
+		Sort:  []string{"blockNumber:desc", "index:asc"},
+	}
+	results, err := es.doSearchRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.Hash, len(results.Hits.Hits))
+	for i, result := range results.Hits.Hits {
+		hsh := result.Source["hash"].(string)
+		converted[i] = types.NewHash(hsh)
+	}
+
+	return converted, nil
+}
+
+func (es *ElasticsearchDB) GetFailedTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	queryString := fmt.Sprintf(QueryFailedByToAddressWithOptionsTemplate(options), address.String())
+
+	req := esapi.CountRequest{
+		Index: []string{TransactionIndex},
+		Body:  strings.NewReader(queryString),
+	}
+	results, err := es.doCountRequest(req)
+	if err != nil {
+		return 0, err
+	}
+	return results.Count, nil
+}
+
 func (es *ElasticsearchDB) GetAllEventsFromAddress(address types.Address, options *types.QueryOptions) ([]*types.Event, error) {
 	queryString := fmt.Sprintf(QueryByAddressWithOptionsTemplate(options), address.String())
 
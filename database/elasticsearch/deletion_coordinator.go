@@ -36,10 +36,10 @@ func (coordinator *DefaultDeletionCoordinator) Delete(contract types.Address) er
 	deleteByAddressQuery := fmt.Sprintf(DeleteQueryAddress, contract.String())
 	deleteByContractQuery := fmt.Sprintf(DeleteQueryContract, contract.String())
 
-	// delete ERC20 & ERC721 tokens
-	log.Debug("Deleting ERC20/ERC721 token data", "contract", contract.String())
+	// delete ERC20, ERC721, ERC1155, ERC777 & ERC4626 token data
+	log.Debug("Deleting token data", "contract", contract.String())
 	erc20Req := esapi.DeleteByQueryRequest{
-		Index:             []string{ERC20TokenIndex, ERC721TokenIndex},
+		Index:             []string{ERC20TokenIndex, ERC721TokenIndex, ERC1155TokenIndex, ERC777OperatorIndex, ERC4626AssetsIndex},
 		Body:              strings.NewReader(deleteByContractQuery),
 		Refresh:           &RequestParameterTrue,
 		WaitForCompletion: &RequestParameterTrue,
@@ -48,7 +48,7 @@ func (coordinator *DefaultDeletionCoordinator) Delete(contract types.Address) er
 	if err != nil {
 		return err
 	}
-	log.Debug("Deleted ERC20/ERC721 token data", "contract", contract.String())
+	log.Debug("Deleted token data", "contract", contract.String())
 
 	//delete event
 	log.Debug("Deleting contract events", "contract", contract.String())
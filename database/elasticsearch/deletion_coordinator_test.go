@@ -23,7 +23,7 @@ func TestDefaultDeletionCoordinator_Delete(t *testing.T) {
 	addressToDelete := types.NewAddress("1")
 
 	ercDelete := esapi.DeleteByQueryRequest{
-		Index: []string{ERC20TokenIndex, ERC721TokenIndex},
+		Index: []string{ERC20TokenIndex, ERC721TokenIndex, ERC1155TokenIndex, ERC777OperatorIndex, ERC4626AssetsIndex},
 		Body:  strings.NewReader(`{ "query": { "match": { "contract": "0x0000000000000000000000000000000000000001" } } }`),
 	}
 	mockedClient.EXPECT().DoRequest(NewDeleteByQueryRequestMatcher(ercDelete)).Return(nil, nil)
@@ -1,8 +1,10 @@
 package elasticsearch
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 	"testing"
 
@@ -193,6 +195,17 @@ func TestElasticsearchDB_GetAllTransactionsToAddress_SingleResult(t *testing.T)
 	assert.Nil(t, err, "unexpected error")
 }
 
+func TestQueryByToAddressWithOptionsTemplate_ValueRange(t *testing.T) {
+	options := &types.QueryOptions{MinValue: big.NewInt(1000)}
+	options.SetDefaults()
+
+	query := QueryByToAddressWithOptionsTemplate(options)
+	assert.Contains(t, query, `{ "range": { "paddedValue": { "gte": "`+strings.Repeat("0", 74)+`1000" } } }`)
+
+	var parsed map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(fmt.Sprintf(query, "0x1932c48b2bf8102ba33b4a6b545c32236e342f34")), &parsed))
+}
+
 func TestElasticsearchDB_GetAllTransactionsToAddress_NoResults(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
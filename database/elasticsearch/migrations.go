@@ -0,0 +1,141 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
+)
+
+// schemaVersion is the version of the layout of the stored documents. It is raised
+// whenever documents stored by an earlier version need to be migrated.
+const schemaVersion = 4
+
+// transactionMapping maps the values of transactions as keywords, as they can be
+// larger than a long
+const transactionMapping = `
+{
+	"mappings": {
+		"properties": {
+			"internalCalls": { "type": "nested", "properties": { "value": { "type": "keyword" } } },
+			"value": { "type": "keyword" },
+			"gasPrice": { "type": "keyword" },
+			"paddedValue": { "type": "keyword" }
+		}
+	}
+}
+`
+
+// migratedTransactionIndex holds the transactions of databases created before
+// values were mapped as keywords, and is given the name of the old index as an alias
+const migratedTransactionIndex = "transaction_v2"
+
+// Migrate brings the documents of a database created by an earlier version up to
+// the current layout
+func (es *ElasticsearchDB) Migrate() error {
+	version, err := es.readSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version >= schemaVersion {
+		return nil
+	}
+	if version < 2 {
+		log.Info("Migrating transactions to store values of any size")
+		if err := es.migrateTransactionValues(); err != nil {
+			return err
+		}
+	}
+	if version < 3 {
+		log.Info("Creating the ERC1155 token index")
+		createRequest := esapi.IndicesCreateRequest{Index: ERC1155TokenIndex}
+		if _, err := es.apiClient.DoRequest(createRequest); err != nil {
+			return err
+		}
+	}
+	if version < 4 {
+		log.Info("Creating the ERC777 operator and ERC4626 assets indices")
+		for _, index := range []string{ERC777OperatorIndex, ERC4626AssetsIndex} {
+			if _, err := es.apiClient.DoRequest(esapi.IndicesCreateRequest{Index: index}); err != nil {
+				return err
+			}
+		}
+	}
+	return es.writeSchemaVersion()
+}
+
+func (es *ElasticsearchDB) readSchemaVersion() (int, error) {
+	body, err := es.apiClient.DoRequest(esapi.GetRequest{Index: MetaIndex, DocumentID: "schemaVersion"})
+	if err == database.ErrNotFound {
+		// databases created before the schema was versioned
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var result SchemaVersionResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, err
+	}
+	return result.Source.SchemaVersion, nil
+}
+
+func (es *ElasticsearchDB) writeSchemaVersion() error {
+	req := esapi.IndexRequest{
+		Index:      MetaIndex,
+		DocumentID: "schemaVersion",
+		Body:       strings.NewReader(fmt.Sprintf(`{"schemaVersion": %d}`, schemaVersion)),
+		Refresh:    "true",
+	}
+	_, err := es.apiClient.DoRequest(req)
+	return err
+}
+
+// migrateTransactionValues copies the transactions to an index mapping their values
+// as keywords, as the mapping of an existing field cannot be changed, and replaces
+// the old index with an alias to the new one.
+func (es *ElasticsearchDB) migrateTransactionValues() error {
+	body, err := es.apiClient.DoRequest(esapi.IndicesGetRequest{Index: []string{TransactionIndex}})
+	if err != nil {
+		return err
+	}
+	var indices map[string]interface{}
+	if err := json.Unmarshal(body, &indices); err != nil {
+		return err
+	}
+	if _, ok := indices[TransactionIndex]; !ok {
+		// the index was already replaced by an earlier, interrupted migration
+		return nil
+	}
+
+	// remove any copy left by an earlier, interrupted migration
+	ignoreUnavailable := true
+	deleteRequest := esapi.IndicesDeleteRequest{Index: []string{migratedTransactionIndex}, IgnoreUnavailable: &ignoreUnavailable}
+	if _, err := es.apiClient.DoRequest(deleteRequest); err != nil {
+		return err
+	}
+	createRequest := esapi.IndicesCreateRequest{Index: migratedTransactionIndex, Body: strings.NewReader(transactionMapping)}
+	if _, err := es.apiClient.DoRequest(createRequest); err != nil {
+		return err
+	}
+
+	refresh, waitForCompletion := true, true
+	reindexRequest := esapi.ReindexRequest{
+		Body:              strings.NewReader(fmt.Sprintf(QueryReindexTransactionValues, TransactionIndex, migratedTransactionIndex)),
+		Refresh:           &refresh,
+		WaitForCompletion: &waitForCompletion,
+	}
+	if _, err := es.apiClient.DoRequest(reindexRequest); err != nil {
+		return err
+	}
+
+	aliasRequest := esapi.IndicesUpdateAliasesRequest{
+		Body: strings.NewReader(fmt.Sprintf(QueryReplaceTransactionIndex, migratedTransactionIndex, TransactionIndex, TransactionIndex)),
+	}
+	_, err = es.apiClient.DoRequest(aliasRequest)
+	return err
+}
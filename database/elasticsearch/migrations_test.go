@@ -0,0 +1,127 @@
+package elasticsearch
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	elasticsearch_mocks "quorumengineering/quorum-report/database/elasticsearch/mocks"
+)
+
+func TestElasticsearchDB_Migrate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+
+	versionReq := esapi.GetRequest{Index: MetaIndex, DocumentID: "schemaVersion"}
+	var reindexBody string
+	gomock.InOrder(
+		mockedClient.EXPECT().DoRequest(NewGetRequestMatcher(versionReq)).Return(nil, database.ErrNotFound),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesGetRequest{})).Return([]byte(`{"transaction": {}}`), nil),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesDeleteRequest{})).Return(nil, nil),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesCreateRequest{})).Return(nil, nil),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.ReindexRequest{})).DoAndReturn(func(req esapi.Request) ([]byte, error) {
+			body, _ := ioutil.ReadAll(req.(esapi.ReindexRequest).Body)
+			reindexBody = string(body)
+			return nil, nil
+		}),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesUpdateAliasesRequest{})).Return(nil, nil),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesCreateRequest{})).Return(nil, nil),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesCreateRequest{})).Return(nil, nil).Times(2),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndexRequest{})).Return(nil, nil),
+	)
+
+	db, _ := New(mockedClient)
+	err := db.Migrate()
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(reindexBody, `"dest": { "index": "transaction_v2" }`))
+}
+
+func TestElasticsearchDB_Migrate_IndexAlreadyReplaced(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+
+	versionReq := esapi.GetRequest{Index: MetaIndex, DocumentID: "schemaVersion"}
+	gomock.InOrder(
+		mockedClient.EXPECT().DoRequest(NewGetRequestMatcher(versionReq)).Return(nil, database.ErrNotFound),
+		// the migration was interrupted before the version was recorded
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesGetRequest{})).Return([]byte(`{"transaction_v2": {}}`), nil),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesCreateRequest{})).Return(nil, nil),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesCreateRequest{})).Return(nil, nil).Times(2),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndexRequest{})).Return(nil, nil),
+	)
+
+	db, _ := New(mockedClient)
+	err := db.Migrate()
+	assert.Nil(t, err)
+}
+
+func TestElasticsearchDB_Migrate_CreatesERC1155Index(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+
+	versionReq := esapi.GetRequest{Index: MetaIndex, DocumentID: "schemaVersion"}
+	gomock.InOrder(
+		mockedClient.EXPECT().DoRequest(NewGetRequestMatcher(versionReq)).Return([]byte(`{"_source": {"schemaVersion": 2}}`), nil),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesCreateRequest{})).Do(func(req esapi.IndicesCreateRequest) {
+			assert.Equal(t, ERC1155TokenIndex, req.Index)
+		}),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesCreateRequest{})).Return(nil, nil).Times(2),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndexRequest{})).Return(nil, nil),
+	)
+
+	db, _ := New(mockedClient)
+	err := db.Migrate()
+	assert.Nil(t, err)
+}
+
+func TestElasticsearchDB_Migrate_CreatesERC777AndERC4626Indices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+
+	versionReq := esapi.GetRequest{Index: MetaIndex, DocumentID: "schemaVersion"}
+	var created []string
+	gomock.InOrder(
+		mockedClient.EXPECT().DoRequest(NewGetRequestMatcher(versionReq)).Return([]byte(`{"_source": {"schemaVersion": 3}}`), nil),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndicesCreateRequest{})).Do(func(req esapi.IndicesCreateRequest) {
+			created = append(created, req.Index)
+		}).Times(2),
+		mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.IndexRequest{})).Return(nil, nil),
+	)
+
+	db, _ := New(mockedClient)
+	err := db.Migrate()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{ERC777OperatorIndex, ERC4626AssetsIndex}, created)
+}
+
+func TestElasticsearchDB_Migrate_UpToDate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearch_mocks.NewMockAPIClient(ctrl)
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+
+	versionReq := esapi.GetRequest{Index: MetaIndex, DocumentID: "schemaVersion"}
+	mockedClient.EXPECT().DoRequest(NewGetRequestMatcher(versionReq)).Return([]byte(`{"_source": {"schemaVersion": 4}}`), nil)
+
+	db, _ := New(mockedClient)
+	err := db.Migrate()
+	assert.Nil(t, err)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/big"
 	"strconv"
+	"strings"
 
 	"quorumengineering/quorum-report/types"
 )
@@ -27,6 +28,14 @@ const QueryAllTemplateNamesTemplate = `
 }
 `
 
+const QueryAllWebhooksTemplate = `
+{
+	"query": {
+		"match_all": {}
+	}
+}
+`
+
 func QueryByToAddressWithOptionsTemplate(options *types.QueryOptions) string {
 	return `
 {
@@ -35,7 +44,24 @@ func QueryByToAddressWithOptionsTemplate(options *types.QueryOptions) string {
 			"must": [
 				{ "match": { "to": "%s" } },
 ` + createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber) + `,
-` + createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp) + `
+` + createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp) + createValueRangeQuery(options) + `
+			]
+		}
+	}
+}
+`
+}
+
+func QueryFailedByToAddressWithOptionsTemplate(options *types.QueryOptions) string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "to": "%s" } },
+				{ "match": { "status": false } },
+` + createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber) + `,
+` + createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp) + createValueRangeQuery(options) + `
 			]
 		}
 	}
@@ -113,7 +139,7 @@ func QueryInternalTransactionsWithOptionsTemplate(options *types.QueryOptions) s
 					}
 				},
 ` + createRangeQuery("blockNumber", options.BeginBlockNumber, options.EndBlockNumber) + `,
-` + createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp) + `
+` + createRangeQuery("timestamp", options.BeginTimestamp, options.EndTimestamp) + createValueRangeQuery(options) + `
 			]
 		}
 	}
@@ -124,6 +150,41 @@ func QueryInternalTransactionsWithOptionsTemplate(options *types.QueryOptions) s
 // This query will get all the balances between a certain block range, as well as the
 // last balance before the starting block IF there was no balance update ON the starting block
 func QueryTokenBalanceAtBlockRange(options *types.TokenQueryOptions) string {
+	return `
+{
+  "query": {
+    "bool": {
+` + createBalanceRangeFilter(options) + `
+      "must": [
+        {"match": {"contract": "%s"}},
+        {"match": {"holder": "%s"}}
+      ]
+    }
+  }
+}
+`
+}
+
+func QueryERC1155TokenBalanceAtBlockRange(options *types.TokenQueryOptions) string {
+	return `
+{
+  "query": {
+    "bool": {
+` + createBalanceRangeFilter(options) + `
+      "must": [
+        {"match": {"contract": "%s"}},
+        {"match": {"token": "%s"}},
+        {"match": {"holder": "%s"}}
+      ]
+    }
+  }
+}
+`
+}
+
+// createBalanceRangeFilter matches the balances recorded within the range of the
+// options, and the balance held at the start of it
+func createBalanceRangeFilter(options *types.TokenQueryOptions) string {
 	rangeQuery := `
       "filter": [
         {
@@ -150,21 +211,7 @@ func QueryTokenBalanceAtBlockRange(options *types.TokenQueryOptions) string {
         }
       ],
 `
-	rangeQuery = fmt.Sprintf(rangeQuery, options.BeginBlockNumber.Uint64(), options.BeginBlockNumber.Uint64())
-
-	return `
-{
-  "query": {
-    "bool": {
-` + rangeQuery + `
-      "must": [
-        {"match": {"contract": "%s"}},
-        {"match": {"holder": "%s"}}
-      ]
-    }
-  }
-}
-`
+	return fmt.Sprintf(rangeQuery, options.BeginBlockNumber.Uint64(), options.BeginBlockNumber.Uint64())
 }
 
 func QueryERC20TokenBalanceAtBlock() string {
@@ -233,6 +280,23 @@ func createRangeQuery(name string, start *big.Int, end *big.Int) string {
 	return fmt.Sprintf(`{ "range": { "%s": { "gte": %s, "lte": %s } } }`, name, start.String(), end.String())
 }
 
+// createValueRangeQuery limits transactions to the value range of the options, if
+// one is given, by comparing the padded value
+func createValueRangeQuery(options *types.QueryOptions) string {
+	if !options.HasValueRange() {
+		return ""
+	}
+	var bounds []string
+	if options.MinValue != nil {
+		bounds = append(bounds, fmt.Sprintf(`"gte": "%s"`, padValue(options.MinValue)))
+	}
+	if options.MaxValue != nil {
+		bounds = append(bounds, fmt.Sprintf(`"lte": "%s"`, padValue(options.MaxValue)))
+	}
+	return fmt.Sprintf(`,
+{ "range": { "paddedValue": { %s } } }`, strings.Join(bounds, ", "))
+}
+
 func QueryERC721TokenAtBlock() string {
 	return `
 {
@@ -342,6 +406,98 @@ func QueryERC721AllHoldersAtBlock() string {
 `
 }
 
+func QueryERC1155TokenBalanceAtBlock() string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "token": "%s"} },
+				{ "match": { "holder": "%s" } },
+				{ "range": { "blockNumber": { "lte": %d } } }
+			]
+		}
+	},
+	"sort": [
+			{
+				"blockNumber": {
+					"order": "desc",
+					"unmapped_type": "long"
+				}
+			}
+	]
+}
+`
+}
+
+func QueryERC1155TokenHoldersAtBlock() string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "token": "%s"} },
+				{ "range": { "blockNumber": { "lte": %d } } }
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } }
+			],
+			"filter": [{
+                "bool": {
+                    "should": [
+						{ "range": { "heldUntil": { "gte": %d } } }, 
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+                }
+            }]
+		}
+	},
+	"size": 0,
+	"aggs" : {
+		"result_buckets": {
+			"composite" : {
+				"size": %d,
+				%s
+				"sources" : [
+					{ "holder": { "terms" : { "field": "holder.keyword" } } }
+				]
+		  	}
+		}
+	}
+}
+`
+}
+
+func QueryERC1155HolderAtBlock(start *big.Int) string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "holder": "%s"} },
+				{ "range": { "blockNumber": { "lte": %d } } },
+` + createTokenRangeQuery(start) + `
+			],
+			"must_not": [
+				{ "term": { "amount.keyword": "0" } }
+			],
+			"filter": [{
+                "bool": {
+                    "should": [
+						{ "range": { "heldUntil": { "gte": %d } } }, 
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+                }
+            }]
+		}
+	}
+}
+`
+}
+
 func createTokenRangeQuery(start *big.Int) string {
 	next := new(big.Int).Add(start, big.NewInt(1))
 
@@ -361,3 +517,114 @@ func createTokenRangeQuery(start *big.Int) string {
 		fmt.Sprintf(`{ "range": { "%s": { "gte": %d } } }`, "fifth", startFifth),
 	)
 }
+
+// rollback query templates, removing or reopening documents above a given block
+func QueryERC777OperatorAtBlock() string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "holder": "%s" } },
+				{ "match": { "operator": "%s" } },
+				{ "range": { "blockNumber": { "lte": %d } } }
+			]
+		}
+	},
+	"sort": [
+			{
+				"blockNumber": {
+					"order": "desc",
+					"unmapped_type": "long"
+				}
+			}
+	]
+}
+`
+}
+
+func QueryERC777OperatorsAtBlock() string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "match": { "holder": "%s" } },
+				{ "match": { "authorized": true } },
+				{ "range": { "blockNumber": { "lte": %d } } }
+			],
+			"filter": [{
+                "bool": {
+                    "should": [
+						{ "range": { "heldUntil": { "gte": %d } } },
+						{ "bool": { "must_not": { "exists": { "field": "heldUntil" } } } }
+					]
+                }
+            }]
+		}
+	}
+}
+`
+}
+
+func QueryERC4626AssetsAtBlock() string {
+	return `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "%s"} },
+				{ "range": { "blockNumber": { "lte": %d } } }
+			]
+		}
+	},
+	"sort": [
+			{
+				"blockNumber": {
+					"order": "desc",
+					"unmapped_type": "long"
+				}
+			}
+	]
+}
+`
+}
+
+const QueryRollbackAboveBlock = `{ "query": { "range": { "%s": { "gt": %d } } } }`
+
+const QueryRollbackHeldUntil = `
+{
+	"query": { "range": { "heldUntil": { "gte": %d } } },
+	"script": { "source": "ctx._source.heldUntil = null", "lang": "painless" }
+}
+`
+
+const QueryRollbackLastFiltered = `
+{
+	"query": { "range": { "lastFiltered": { "gt": %d } } },
+	"script": { "source": "ctx._source.lastFiltered = params.block", "lang": "painless", "params": { "block": %d } }
+}
+`
+
+// migration query templates
+const QueryReindexTransactionValues = `
+{
+	"source": { "index": "%s" },
+	"dest": { "index": "%s" },
+	"script": {
+		"source": "String value = ctx._source.value == null ? '0' : ctx._source.value.toString(); while (value.length() < 78) { value = '0' + value; } ctx._source.paddedValue = value;",
+		"lang": "painless"
+	}
+}
+`
+
+const QueryReplaceTransactionIndex = `
+{
+	"actions": [
+		{ "add": { "index": "%s", "alias": "%s" } },
+		{ "remove_index": { "index": "%s" } }
+	]
+}
+`
@@ -6,6 +6,7 @@ import (
 	"github.com/elastic/go-elasticsearch/v7/esapi"
 	"github.com/elastic/go-elasticsearch/v7/esutil"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"io/ioutil"
 )
 
@@ -79,20 +80,18 @@ func (rm *DeleteRequestMatcher) String() string {
 }
 
 type DeleteByQueryRequestMatcher struct {
-	req esapi.DeleteByQueryRequest
+	req  esapi.DeleteByQueryRequest
+	body string
 }
 
 func NewDeleteByQueryRequestMatcher(req esapi.DeleteByQueryRequest) *DeleteByQueryRequestMatcher {
-	return &DeleteByQueryRequestMatcher{req: req}
+	body, _ := ioutil.ReadAll(req.Body)
+	return &DeleteByQueryRequestMatcher{req: req, body: string(body)}
 }
 
 func (rm *DeleteByQueryRequestMatcher) Matches(x interface{}) bool {
 	if val, ok := x.(esapi.DeleteByQueryRequest); ok {
-		expectedBody, _ := ioutil.ReadAll(rm.req.Body)
-		actualBody, _ := ioutil.ReadAll(val.Body)
-		a := string(expectedBody)
-		b := string(actualBody)
-		return len(rm.req.Index) == len(val.Index) && a == b
+		return len(rm.req.Index) == len(val.Index) && readRewindable(val.Body) == rm.body
 	}
 	return false
 }
@@ -169,3 +168,34 @@ func (bim *BulkIndexItemMatcher) Matches(x interface{}) bool {
 func (bim *BulkIndexItemMatcher) String() string {
 	return fmt.Sprintf("BulkIndexItemMatcher{%s/%s/%s}", bim.item.Index, bim.item.DocumentID, bim.body)
 }
+
+type UpdateByQueryRequestMatcher struct {
+	req  esapi.UpdateByQueryRequest
+	body string
+}
+
+func NewUpdateByQueryRequestMatcher(req esapi.UpdateByQueryRequest) *UpdateByQueryRequestMatcher {
+	body, _ := ioutil.ReadAll(req.Body)
+	return &UpdateByQueryRequestMatcher{req: req, body: string(body)}
+}
+
+func (rm *UpdateByQueryRequestMatcher) Matches(x interface{}) bool {
+	if val, ok := x.(esapi.UpdateByQueryRequest); ok {
+		return assert.ObjectsAreEqual(rm.req.Index, val.Index) && readRewindable(val.Body) == rm.body
+	}
+	return false
+}
+
+func (rm *UpdateByQueryRequestMatcher) String() string {
+	return fmt.Sprintf("UpdateByQueryRequestMatcher{%s}", rm.req.Index)
+}
+
+// readRewindable reads the request body, rewinding it afterwards if possible so
+// that it can be checked against several expected requests
+func readRewindable(body io.Reader) string {
+	data, _ := ioutil.ReadAll(body)
+	if seeker, ok := body.(io.Seeker); ok {
+		_, _ = seeker.Seek(0, io.SeekStart)
+	}
+	return string(data)
+}
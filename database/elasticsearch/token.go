@@ -92,13 +92,18 @@ func (es *ElasticsearchDB) GetERC20EntryAtBlock(contract types.Address, holder t
 
 func (es *ElasticsearchDB) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
 	queryString := fmt.Sprintf(QueryTokenBalanceAtBlockRange(options), contract.String(), holder.String())
+	return es.balanceHistory(ERC20TokenIndex, queryString, options)
+}
 
+// balanceHistory returns the balances matched by the query, keyed by the block they
+// were recorded at, or the start of the range if recorded before it
+func (es *ElasticsearchDB) balanceHistory(index string, queryString string, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
 	from := options.PageSize * options.PageNumber
 	if from+options.PageSize > 1000 {
 		return nil, ErrPaginationLimitExceeded
 	}
 	req := esapi.SearchRequest{
-		Index: []string{ERC20TokenIndex},
+		Index: []string{index},
 		Body:  strings.NewReader(queryString),
 		From:  &from,
 		Size:  &options.PageSize,
@@ -172,12 +177,7 @@ func (es *ElasticsearchDB) RecordERC721Token(contract types.Address, holder type
 		return errExisting
 	}
 
-	paddedTokenId := fmt.Sprintf("%085d", tokenId)
-	first, _ := strconv.ParseUint(paddedTokenId[0:17], 10, 64)
-	second, _ := strconv.ParseUint(paddedTokenId[17:34], 10, 64)
-	third, _ := strconv.ParseUint(paddedTokenId[34:51], 10, 64)
-	fourth, _ := strconv.ParseUint(paddedTokenId[51:68], 10, 64)
-	fifth, _ := strconv.ParseUint(paddedTokenId[68:85], 10, 64)
+	first, second, third, fourth, fifth := splitTokenId(tokenId)
 
 	//add new entry
 	tokenHolderInfo := SortableERC721Token{
@@ -374,3 +374,340 @@ func (es *ElasticsearchDB) AllHoldersAtBlock(contract types.Address, block uint6
 	}
 	return convertedResults, nil
 }
+
+func (es *ElasticsearchDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	//find old entry
+	existingTokenEntry, errExisting := es.getERC1155EntryAtBlock(contract, holder, tokenId, block-1)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+
+	//add new entry
+	first, second, third, fourth, fifth := splitTokenId(tokenId)
+	tokenInfo := ERC1155TokenHolder{
+		Contract:    contract,
+		Holder:      holder,
+		Token:       tokenId.String(),
+		BlockNumber: block,
+		Amount:      amount.String(),
+		First:       first,
+		Second:      second,
+		Third:       third,
+		Fourth:      fourth,
+		Fifth:       fifth,
+	}
+
+	req := esapi.IndexRequest{
+		Index:      ERC1155TokenIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", contract.String(), tokenId.String(), holder.String(), block),
+		Body:       esutil.NewJSONReader(tokenInfo),
+		Refresh:    "true",
+		OpType:     "create",
+	}
+
+	if _, err := es.apiClient.DoRequest(req); err != nil {
+		return err
+	}
+
+	if errExisting == database.ErrNotFound {
+		return nil
+	}
+
+	//update the older entry
+	query := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"heldUntil": block - 1,
+		},
+	}
+
+	updateRequest := esapi.UpdateRequest{
+		Index:      ERC1155TokenIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", contract.String(), tokenId.String(), holder.String(), existingTokenEntry.BlockNumber),
+		Body:       esutil.NewJSONReader(query),
+		Refresh:    "true",
+	}
+
+	_, err := es.apiClient.DoRequest(updateRequest)
+	return err
+}
+
+func (es *ElasticsearchDB) getERC1155EntryAtBlock(contract types.Address, holder types.Address, tokenId *big.Int, block uint64) (ERC1155TokenHolder, error) {
+	queryString := fmt.Sprintf(QueryERC1155TokenBalanceAtBlock(), contract.String(), tokenId.String(), holder.String(), block)
+
+	size := 1
+	req := esapi.SearchRequest{
+		Index: []string{ERC1155TokenIndex},
+		Body:  strings.NewReader(queryString),
+		Size:  &size,
+	}
+	results, err := es.doSearchRequest(req)
+	if err != nil {
+		return ERC1155TokenHolder{}, err
+	}
+
+	if len(results.Hits.Hits) == 0 {
+		return ERC1155TokenHolder{}, database.ErrNotFound
+	}
+
+	var tokenResult ERC1155TokenHolder
+	err = mapstructure.Decode(results.Hits.Hits[0].Source, &tokenResult)
+	return tokenResult, err
+}
+
+func (es *ElasticsearchDB) GetERC1155Balance(contract types.Address, holder types.Address, tokenId *big.Int, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	queryString := fmt.Sprintf(QueryERC1155TokenBalanceAtBlockRange(options), contract.String(), tokenId.String(), holder.String())
+	return es.balanceHistory(ERC1155TokenIndex, queryString, options)
+}
+
+func (es *ElasticsearchDB) ERC1155HoldersAtBlock(contract types.Address, tokenId *big.Int, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	if options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	afterQuery := ""
+	if options.After != "" {
+		afterQuery = fmt.Sprintf(`"after": { "holder": "%s"},`, options.After)
+	}
+
+	formattedQuery := fmt.Sprintf(QueryERC1155TokenHoldersAtBlock(), contract.String(), tokenId.String(), block, block, options.PageSize, afterQuery)
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{ERC1155TokenIndex},
+		Body:  strings.NewReader(formattedQuery),
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var aggResult ERC721HolderAggregateResult
+	rawAggResult := results.Aggregations.Results
+	if err := mapstructure.Decode(rawAggResult, &aggResult); err != nil {
+		return nil, err
+	}
+
+	convertedResults := make([]types.Address, 0, len(aggResult.Buckets))
+	for _, result := range aggResult.Buckets {
+		convertedResults = append(convertedResults, types.NewAddress(result.Key.Holder))
+	}
+	return convertedResults, nil
+}
+
+func (es *ElasticsearchDB) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	formattedQuery := fmt.Sprintf(QueryERC1155HolderAtBlock(startTokenId), contract.String(), holder.String(), block, block)
+
+	from := options.PageSize * options.PageNumber
+	if from+options.PageSize > 1000 {
+		return nil, ErrPaginationLimitExceeded
+	}
+
+	searchReq := esapi.SearchRequest{
+		Index: []string{ERC1155TokenIndex},
+		Body:  strings.NewReader(formattedQuery),
+		From:  &from,
+		Size:  &options.PageSize,
+		Sort:  []string{"first:asc", "second:asc", "third:asc", "fourth:asc", "fifth:asc"},
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	convertedResults := make([]types.ERC1155Token, 0, len(results.Hits.Hits))
+	for _, result := range results.Hits.Hits {
+		var entry ERC1155TokenHolder
+		if err := mapstructure.Decode(result.Source, &entry); err != nil {
+			return nil, err
+		}
+		amount, success := new(big.Int).SetString(entry.Amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		convertedResults = append(convertedResults, types.ERC1155Token{
+			Contract: types.NewAddress(string(entry.Contract)),
+			Holder:   types.NewAddress(string(entry.Holder)),
+			Token:    entry.Token,
+			Amount:   amount,
+			HeldFrom: entry.BlockNumber,
+		})
+	}
+	return convertedResults, nil
+}
+
+func (es *ElasticsearchDB) RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error {
+	//find old entry
+	existingEntry, errExisting := es.getERC777OperatorAtBlock(contract, holder, operator, block-1)
+	if errExisting != nil && errExisting != database.ErrNotFound {
+		return errExisting
+	}
+
+	//add new entry, replacing any change already recorded at the block
+	operatorInfo := ERC777Operator{
+		Contract:    contract,
+		Holder:      holder,
+		Operator:    operator,
+		BlockNumber: block,
+		Authorized:  authorized,
+	}
+
+	req := esapi.IndexRequest{
+		Index:      ERC777OperatorIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", contract.String(), holder.String(), operator.String(), block),
+		Body:       esutil.NewJSONReader(operatorInfo),
+		Refresh:    "true",
+	}
+
+	if _, err := es.apiClient.DoRequest(req); err != nil {
+		return err
+	}
+
+	if errExisting == database.ErrNotFound {
+		return nil
+	}
+
+	//update the older entry
+	query := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"heldUntil": block - 1,
+		},
+	}
+
+	updateRequest := esapi.UpdateRequest{
+		Index:      ERC777OperatorIndex,
+		DocumentID: fmt.Sprintf("%s-%s-%s-%d", contract.String(), holder.String(), operator.String(), existingEntry.BlockNumber),
+		Body:       esutil.NewJSONReader(query),
+		Refresh:    "true",
+	}
+
+	_, err := es.apiClient.DoRequest(updateRequest)
+	return err
+}
+
+func (es *ElasticsearchDB) getERC777OperatorAtBlock(contract types.Address, holder types.Address, operator types.Address, block uint64) (ERC777Operator, error) {
+	queryString := fmt.Sprintf(QueryERC777OperatorAtBlock(), contract.String(), holder.String(), operator.String(), block)
+
+	size := 1
+	req := esapi.SearchRequest{
+		Index: []string{ERC777OperatorIndex},
+		Body:  strings.NewReader(queryString),
+		Size:  &size,
+	}
+	results, err := es.doSearchRequest(req)
+	if err != nil {
+		return ERC777Operator{}, err
+	}
+
+	if len(results.Hits.Hits) == 0 {
+		return ERC777Operator{}, database.ErrNotFound
+	}
+
+	var operatorResult ERC777Operator
+	err = mapstructure.Decode(results.Hits.Hits[0].Source, &operatorResult)
+	return operatorResult, err
+}
+
+func (es *ElasticsearchDB) ERC777OperatorsAtBlock(contract types.Address, holder types.Address, block uint64) ([]types.Address, error) {
+	formattedQuery := fmt.Sprintf(QueryERC777OperatorsAtBlock(), contract.String(), holder.String(), block, block)
+
+	size := 1000
+	searchReq := esapi.SearchRequest{
+		Index: []string{ERC777OperatorIndex},
+		Body:  strings.NewReader(formattedQuery),
+		Size:  &size,
+		Sort:  []string{"operator.keyword:asc"},
+	}
+
+	results, err := es.doSearchRequest(searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	convertedResults := make([]types.Address, 0, len(results.Hits.Hits))
+	for _, result := range results.Hits.Hits {
+		var entry ERC777Operator
+		if err := mapstructure.Decode(result.Source, &entry); err != nil {
+			return nil, err
+		}
+		convertedResults = append(convertedResults, types.NewAddress(string(entry.Operator)))
+	}
+	return convertedResults, nil
+}
+
+func (es *ElasticsearchDB) RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error {
+	assetsInfo := ERC4626Assets{
+		Contract:    contract,
+		BlockNumber: block,
+		TotalAssets: totalAssets.String(),
+		TotalSupply: totalSupply.String(),
+	}
+
+	req := esapi.IndexRequest{
+		Index:      ERC4626AssetsIndex,
+		DocumentID: fmt.Sprintf("%s-%d", contract.String(), block),
+		Body:       esutil.NewJSONReader(assetsInfo),
+		Refresh:    "true",
+	}
+
+	_, err := es.apiClient.DoRequest(req)
+	return err
+}
+
+func (es *ElasticsearchDB) GetERC4626AssetsAtBlock(contract types.Address, block uint64) (*types.ERC4626Assets, error) {
+	queryString := fmt.Sprintf(QueryERC4626AssetsAtBlock(), contract.String(), block)
+
+	size := 1
+	req := esapi.SearchRequest{
+		Index: []string{ERC4626AssetsIndex},
+		Body:  strings.NewReader(queryString),
+		Size:  &size,
+	}
+	results, err := es.doSearchRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results.Hits.Hits) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	var entry ERC4626Assets
+	if err := mapstructure.Decode(results.Hits.Hits[0].Source, &entry); err != nil {
+		return nil, err
+	}
+	totalAssets, success := new(big.Int).SetString(entry.TotalAssets, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	totalSupply, success := new(big.Int).SetString(entry.TotalSupply, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return &types.ERC4626Assets{
+		Contract:    types.NewAddress(string(entry.Contract)),
+		BlockNumber: entry.BlockNumber,
+		TotalAssets: totalAssets,
+		TotalSupply: totalSupply,
+	}, nil
+}
+
+// splitTokenId splits a token ID into component parts that can be sorted on
+func splitTokenId(tokenId *big.Int) (uint64, uint64, uint64, uint64, uint64) {
+	paddedTokenId := fmt.Sprintf("%085d", tokenId)
+	first, _ := strconv.ParseUint(paddedTokenId[0:17], 10, 64)
+	second, _ := strconv.ParseUint(paddedTokenId[17:34], 10, 64)
+	third, _ := strconv.ParseUint(paddedTokenId[34:51], 10, 64)
+	fourth, _ := strconv.ParseUint(paddedTokenId[51:68], 10, 64)
+	fifth, _ := strconv.ParseUint(paddedTokenId[68:85], 10, 64)
+	return first, second, third, fourth, fifth
+}
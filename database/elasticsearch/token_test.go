@@ -10,6 +10,7 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 
+	"quorumengineering/quorum-report/database"
 	elasticsearchmocks "quorumengineering/quorum-report/database/elasticsearch/mocks"
 	"quorumengineering/quorum-report/types"
 )
@@ -505,3 +506,215 @@ func TestElasticsearchDB_ERC721TokenByTokenID(t *testing.T) {
 	assert.Nil(t, err)
 	assert.EqualValues(t, expected, *result)
 }
+
+func TestElasticsearchDB_RecordERC1155Balance_WithPrevious(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	tokenContractAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holderAddress := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+	blockNumber := uint64(10)
+	tokenId := big.NewInt(2000)
+	balance := big.NewInt(1989)
+
+	token := ERC1155TokenHolder{
+		Contract:    tokenContractAddress,
+		Holder:      holderAddress,
+		Token:       "2000",
+		BlockNumber: blockNumber,
+		Amount:      balance.String(),
+		Fifth:       2000,
+	}
+	ex := esapi.IndexRequest{
+		Index:      ERC1155TokenIndex,
+		DocumentID: "0x1932c48b2bf8102ba33b4a6b545c32236e342f34-2000-0x1349f3e1b8d71effb47b840594ff27da7e603d17-10",
+		Body:       esutil.NewJSONReader(token),
+	}
+
+	searchQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34"} },
+				{ "match": { "token": "2000"} },
+				{ "match": { "holder": "0x1349f3e1b8d71effb47b840594ff27da7e603d17" } },
+				{ "range": { "blockNumber": { "lte": 9 } } }
+			]
+		}
+	},
+	"sort": [
+			{
+				"blockNumber": {
+					"order": "desc",
+					"unmapped_type": "long"
+				}
+			}
+	]
+}
+`
+	size := 1
+	req := esapi.SearchRequest{
+		Index: []string{ERC1155TokenIndex},
+		Body:  strings.NewReader(searchQuery),
+		Size:  &size,
+	}
+	searchResult := `{"hits": {"hits": [
+{"_source": {
+		"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34",
+		"holder": "0x1349f3e1b8d71effb47b840594ff27da7e603d17",
+		"token": "2000",
+		"amount": "500",
+		"blockNumber": 7
+	}
+}
+]}}`
+
+	oldTokenUpdateReq := esapi.UpdateRequest{
+		Index:      ERC1155TokenIndex,
+		DocumentID: "0x1932c48b2bf8102ba33b4a6b545c32236e342f34-2000-0x1349f3e1b8d71effb47b840594ff27da7e603d17-7",
+		Body: strings.NewReader(`{"doc":{"heldUntil":9}}
+`),
+	}
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(searchResult), nil)
+	mockedClient.EXPECT().DoRequest(NewIndexRequestMatcher(ex)).Do(func(input esapi.IndexRequest) {
+		assert.Equal(t, "create", input.OpType)
+	})
+	mockedClient.EXPECT().DoRequest(NewUpdateRequestMatcher(oldTokenUpdateReq)).Return(nil, nil)
+
+	db, _ := New(mockedClient)
+	err := db.RecordERC1155Balance(tokenContractAddress, holderAddress, blockNumber, tokenId, balance)
+	assert.Nil(t, err, "expected error to be nil")
+}
+
+func TestElasticsearchDB_ERC1155TokensForAccountAtBlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	tokenContractAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holderAddress := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+
+	resultJson := `{"hits": {"hits": [{"_source": {
+"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34",
+"holder": "0x1349f3e1b8d71effb47b840594ff27da7e603d17",
+"token": "500",
+"amount": "25",
+"blockNumber": 3
+}}]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.SearchRequest{})).DoAndReturn(func(req esapi.Request) ([]byte, error) {
+		search := req.(esapi.SearchRequest)
+		assert.Equal(t, []string{ERC1155TokenIndex}, search.Index)
+		assert.Equal(t, []string{"first:asc", "second:asc", "third:asc", "fourth:asc", "fifth:asc"}, search.Sort)
+		return []byte(resultJson), nil
+	})
+
+	db, _ := New(mockedClient)
+	options := &types.TokenQueryOptions{}
+	options.SetDefaults()
+	result, err := db.ERC1155TokensForAccountAtBlock(tokenContractAddress, holderAddress, 12, options)
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, tokenContractAddress, result[0].Contract)
+	assert.Equal(t, holderAddress, result[0].Holder)
+	assert.Equal(t, "500", result[0].Token)
+	assert.Equal(t, "25", result[0].Amount.String())
+	assert.EqualValues(t, 3, result[0].HeldFrom)
+}
+
+func TestElasticsearchDB_ERC777OperatorsAtBlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	tokenContractAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holderAddress := types.NewAddress("0x1349f3e1b8d71effb47b840594ff27da7e603d17")
+
+	resultJson := `{"hits": {"hits": [{"_source": {
+"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34",
+"holder": "0x1349f3e1b8d71effb47b840594ff27da7e603d17",
+"operator": "0xed9d02e382b34818e88b88a309c7fe71e65f419d",
+"authorized": true,
+"blockNumber": 3
+}}]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(gomock.AssignableToTypeOf(esapi.SearchRequest{})).DoAndReturn(func(req esapi.Request) ([]byte, error) {
+		search := req.(esapi.SearchRequest)
+		assert.Equal(t, []string{ERC777OperatorIndex}, search.Index)
+		assert.Equal(t, []string{"operator.keyword:asc"}, search.Sort)
+		return []byte(resultJson), nil
+	})
+
+	db, _ := New(mockedClient)
+	result, err := db.ERC777OperatorsAtBlock(tokenContractAddress, holderAddress, 12)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Address{types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")}, result)
+}
+
+func TestElasticsearchDB_GetERC4626AssetsAtBlock(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockedClient := elasticsearchmocks.NewMockAPIClient(ctrl)
+
+	vaultAddress := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+
+	searchQuery := `
+{
+	"query": {
+		"bool": {
+			"must": [
+				{ "match": { "contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34"} },
+				{ "range": { "blockNumber": { "lte": 12 } } }
+			]
+		}
+	},
+	"sort": [
+			{
+				"blockNumber": {
+					"order": "desc",
+					"unmapped_type": "long"
+				}
+			}
+	]
+}
+`
+	size := 1
+	req := esapi.SearchRequest{
+		Index: []string{ERC4626AssetsIndex},
+		Body:  strings.NewReader(searchQuery),
+		Size:  &size,
+	}
+	resultJson := `{"hits": {"hits": [{"_source": {
+"contract": "0x1932c48b2bf8102ba33b4a6b545c32236e342f34",
+"blockNumber": 10,
+"totalAssets": "100000000000000000000000000000",
+"totalSupply": "99000000000000000000000000000"
+}}]}}`
+
+	mockedClient.EXPECT().DoRequest(gomock.Any()) //for setup, not relevant to test
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(req)).Return([]byte(resultJson), nil)
+	emptyReq := req
+	emptyReq.Body = strings.NewReader(searchQuery)
+	mockedClient.EXPECT().DoRequest(NewSearchRequestMatcher(emptyReq)).Return([]byte(`{"hits": {"hits": []}}`), nil)
+
+	db, _ := New(mockedClient)
+	result, err := db.GetERC4626AssetsAtBlock(vaultAddress, 12)
+
+	assert.Nil(t, err)
+	assert.Equal(t, vaultAddress, result.Contract)
+	assert.EqualValues(t, 10, result.BlockNumber)
+	assert.Equal(t, "100000000000000000000000000000", result.TotalAssets.String())
+	assert.Equal(t, "99000000000000000000000000000", result.TotalSupply.String())
+
+	_, err = db.GetERC4626AssetsAtBlock(vaultAddress, 12)
+	assert.Equal(t, database.ErrNotFound, err)
+}
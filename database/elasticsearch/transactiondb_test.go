@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/elastic/go-elasticsearch/v7/esapi"
@@ -27,8 +28,9 @@ var testTransaction = types.Transaction{
 	Nonce:             4,
 	From:              types.NewAddress("0x586e8164bc8863013fe8f1b82092b028a5f8afad"),
 	To:                types.NewAddress("0xcc11df45aba0a4ff198b18300d0b148ad2468834"),
-	Value:             10,
+	Value:             big.NewInt(10),
 	Gas:               30,
+	GasPrice:          big.NewInt(1000000000),
 	GasUsed:           20,
 	CumulativeGasUsed: 40,
 	CreatedContract:   types.NewAddress("0x67bb49f7bd40b6a1226d77dc07fb38f03680c94f"),
@@ -49,7 +51,7 @@ func TestElasticsearchDB_WriteSingleTransaction(t *testing.T) {
 	req := esapi.IndexRequest{
 		Index:      TransactionIndex,
 		DocumentID: testTransaction.Hash.String(),
-		Body:       esutil.NewJSONReader(&testTransaction),
+		Body:       esutil.NewJSONReader(NewTransactionDocument(&testTransaction)),
 		Refresh:    "true",
 	}
 
@@ -71,7 +73,7 @@ func TestElasticsearchDB_WriteTransactions_WithError(t *testing.T) {
 	req := esutil.BulkIndexerItem{
 		Action:     "create",
 		DocumentID: testTransaction.Hash.String(),
-		Body:       esutil.NewJSONReader(&testTransaction),
+		Body:       esutil.NewJSONReader(NewTransactionDocument(&testTransaction)),
 	}
 	reqMatcher := NewBulkIndexerItemMatcher(req)
 
@@ -101,7 +103,7 @@ func TestElasticsearchDB_WriteTransactions(t *testing.T) {
 	req := esutil.BulkIndexerItem{
 		Action:     "create",
 		DocumentID: testTransaction.Hash.String(),
-		Body:       esutil.NewJSONReader(&testTransaction),
+		Body:       esutil.NewJSONReader(NewTransactionDocument(&testTransaction)),
 	}
 	reqMatcher := NewBulkIndexerItemMatcher(req)
 
@@ -1,6 +1,9 @@
 package elasticsearch
 
 import (
+	"fmt"
+	"math/big"
+
 	"quorumengineering/quorum-report/types"
 )
 
@@ -29,6 +32,26 @@ type StorageEntry struct {
 	Value string
 }
 
+// TransactionDocument is the document stored for a transaction. Values can be too
+// large for a numeric field, so they are mapped as keywords, and a copy of the value
+// padded with zeros is kept that compares in the same order as the number.
+type TransactionDocument struct {
+	*types.Transaction
+	PaddedValue string `json:"paddedValue"`
+}
+
+func NewTransactionDocument(transaction *types.Transaction) *TransactionDocument {
+	return &TransactionDocument{Transaction: transaction, PaddedValue: padValue(transaction.Value)}
+}
+
+// padValue pads a value with zeros to the 78 digits of the largest uint256
+func padValue(value *big.Int) string {
+	if value == nil {
+		value = new(big.Int)
+	}
+	return fmt.Sprintf("%078d", value)
+}
+
 type ERC20TokenHolder struct {
 	Contract    types.Address `json:"contract"`
 	Holder      types.Address `json:"holder"`
@@ -48,6 +71,38 @@ type SortableERC721Token struct {
 	Fifth  uint64 `json:"fifth"`
 }
 
+type ERC1155TokenHolder struct {
+	Contract    types.Address `json:"contract"`
+	Holder      types.Address `json:"holder"`
+	Token       string        `json:"token"`
+	BlockNumber uint64        `json:"blockNumber"`
+	Amount      string        `json:"amount"`
+	HeldUntil   *uint64       `json:"heldUntil"`
+
+	//Allows the token to be sortable by splitting it into component parts
+	First  uint64 `json:"first"`
+	Second uint64 `json:"second"`
+	Third  uint64 `json:"third"`
+	Fourth uint64 `json:"fourth"`
+	Fifth  uint64 `json:"fifth"`
+}
+
+type ERC777Operator struct {
+	Contract    types.Address `json:"contract"`
+	Holder      types.Address `json:"holder"`
+	Operator    types.Address `json:"operator"`
+	BlockNumber uint64        `json:"blockNumber"`
+	Authorized  bool          `json:"authorized"`
+	HeldUntil   *uint64       `json:"heldUntil"`
+}
+
+type ERC4626Assets struct {
+	Contract    types.Address `json:"contract"`
+	BlockNumber uint64        `json:"blockNumber"`
+	TotalAssets string        `json:"totalAssets"`
+	TotalSupply string        `json:"totalSupply"`
+}
+
 //
 
 type ContractQueryResult struct {
@@ -58,6 +113,16 @@ type TemplateQueryResult struct {
 	Source Template `json:"_source"`
 }
 
+type WebhookQueryResult struct {
+	Source types.Webhook `json:"_source"`
+}
+
+type WebhookSearchResult struct {
+	Hits struct {
+		Hits []WebhookQueryResult `json:"hits"`
+	} `json:"hits"`
+}
+
 type TransactionQueryResult struct {
 	Source *types.Transaction `json:"_source"`
 }
@@ -80,6 +145,12 @@ type LastPersistedResult struct {
 	} `json:"_source"`
 }
 
+type SchemaVersionResult struct {
+	Source struct {
+		SchemaVersion int `json:"schemaVersion"`
+	} `json:"_source"`
+}
+
 type SearchQueryResult struct {
 	Hits struct {
 		Hits []IndividualResult `json:"hits"`
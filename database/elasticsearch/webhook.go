@@ -0,0 +1,122 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+	"github.com/elastic/go-elasticsearch/v7/esutil"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+// maxWebhooks is the most webhooks fetched at once, which is the largest page
+// size ElasticSearch allows by default
+const maxWebhooks = 10000
+
+func (es *ElasticsearchDB) AddWebhook(webhook *types.Webhook) error {
+	stored := *webhook
+	existing, err := es.getWebhookByID(webhook.ID)
+	if err != nil && err != database.ErrNotFound && err != ErrIndexNotFound {
+		return err
+	}
+	if existing != nil {
+		stored.Cursor = existing.Cursor
+	}
+
+	req := esapi.IndexRequest{
+		Index:      WebhookIndex,
+		DocumentID: webhook.ID,
+		Body:       esutil.NewJSONReader(stored),
+		Refresh:    "true",
+	}
+	_, err = es.apiClient.DoRequest(req)
+	return err
+}
+
+func (es *ElasticsearchDB) DeleteWebhook(id string) error {
+	req := esapi.DeleteRequest{
+		Index:      WebhookIndex,
+		DocumentID: id,
+		Refresh:    "true",
+	}
+	_, err := es.apiClient.DoRequest(req)
+	if err == ErrIndexNotFound {
+		return database.ErrNotFound
+	}
+	return err
+}
+
+func (es *ElasticsearchDB) GetWebhooks() ([]*types.Webhook, error) {
+	size := maxWebhooks
+	req := esapi.SearchRequest{
+		Index: []string{WebhookIndex},
+		Body:  strings.NewReader(QueryAllWebhooksTemplate),
+		Size:  &size,
+	}
+	body, err := es.apiClient.DoRequest(req)
+	if err == ErrIndexNotFound {
+		// no webhooks have been added yet
+		return []*types.Webhook{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var results WebhookSearchResult
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, err
+	}
+	webhooks := make([]*types.Webhook, len(results.Hits.Hits))
+	for i := range results.Hits.Hits {
+		webhooks[i] = &results.Hits.Hits[i].Source
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (es *ElasticsearchDB) SetWebhookCursor(id string, cursor types.WebhookCursor) error {
+	//check webhook exists before updating
+	if _, err := es.getWebhookByID(id); err != nil {
+		if err == ErrIndexNotFound {
+			return database.ErrNotFound
+		}
+		return err
+	}
+
+	query := map[string]interface{}{
+		"doc": map[string]interface{}{
+			"cursor": cursor,
+		},
+	}
+	req := esapi.UpdateRequest{
+		Index:      WebhookIndex,
+		DocumentID: id,
+		Body:       esutil.NewJSONReader(query),
+		Refresh:    "true",
+	}
+	_, err := es.apiClient.DoRequest(req)
+	return err
+}
+
+// internal functions
+
+func (es *ElasticsearchDB) getWebhookByID(id string) (*types.Webhook, error) {
+	fetchReq := esapi.GetRequest{
+		Index:      WebhookIndex,
+		DocumentID: id,
+	}
+
+	body, err := es.apiClient.DoRequest(fetchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	var webhook WebhookQueryResult
+	if err = json.Unmarshal(body, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook.Source, nil
+}
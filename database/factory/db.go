@@ -1,9 +1,15 @@
 package factory
 
 import (
+	"database/sql"
+
+	_ "github.com/lib/pq"
+
 	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/boltdb"
 	"quorumengineering/quorum-report/database/elasticsearch"
 	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/database/postgres"
 	"quorumengineering/quorum-report/log"
 	"quorumengineering/quorum-report/types"
 )
@@ -23,6 +29,22 @@ func (dbFactory *Factory) Database(config *types.DatabaseConfig) (database.Datab
 		log.Info("Created database connection", "type", "elasticsearch")
 		return NewDatabaseWithCache(db, config.CacheSize)
 	}
+	if config != nil && config.Bolt != nil {
+		db, err := dbFactory.NewBoltDatabase(config.Bolt)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Created database connection", "type", "bolt", "path", config.Bolt.Path)
+		return NewDatabaseWithCache(db, config.CacheSize)
+	}
+	if config != nil && config.Postgres != nil {
+		db, err := dbFactory.NewPostgresDatabase(config.Postgres)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("Created database connection", "type", "postgres")
+		return NewDatabaseWithCache(db, config.CacheSize)
+	}
 	log.Info("Created database connection", "type", "memory")
 	return dbFactory.NewInMemoryDatabase(), nil
 }
@@ -31,6 +53,26 @@ func (dbFactory *Factory) NewInMemoryDatabase() *memory.MemoryDB {
 	return memory.NewMemoryDB()
 }
 
+func (dbFactory *Factory) NewBoltDatabase(config *types.BoltConfig) (*boltdb.BoltDB, error) {
+	return boltdb.New(config)
+}
+
+func (dbFactory *Factory) NewPostgresDatabase(config *types.PostgresConfig) (*postgres.PostgresDB, error) {
+	db, err := sql.Open("postgres", config.URL)
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxOpenConnections > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConnections)
+	}
+	pg, err := postgres.New(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return pg, nil
+}
+
 func (dbFactory *Factory) NewElasticsearchDatabase(config *types.ElasticsearchConfig) (*elasticsearch.ElasticsearchDB, error) {
 	esConfig, err := elasticsearch.NewConfig(config)
 	if err != nil {
@@ -44,5 +86,12 @@ func (dbFactory *Factory) NewElasticsearchDatabase(config *types.ElasticsearchCo
 	if err != nil {
 		return nil, err
 	}
-	return elasticsearch.New(apiClient)
+	db, err := elasticsearch.New(apiClient)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Migrate(); err != nil {
+		return nil, err
+	}
+	return db, nil
 }
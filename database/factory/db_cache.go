@@ -7,6 +7,7 @@ import (
 	"github.com/bluele/gcache"
 
 	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/metrics"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -142,8 +143,10 @@ func (cachingDB *DatabaseWithCache) WriteBlocks(blocks []*types.Block) error {
 
 func (cachingDB *DatabaseWithCache) ReadBlock(blockNumber uint64) (*types.Block, error) {
 	if cachedBlock, err := cachingDB.blockCache.Get(blockNumber); err == nil {
+		metrics.CacheLookup("block", true)
 		return cachedBlock.(*types.Block), nil
 	}
+	metrics.CacheLookup("block", false)
 	block, err := cachingDB.db.ReadBlock(blockNumber)
 	if err != nil {
 		return nil, err
@@ -171,8 +174,10 @@ func (cachingDB *DatabaseWithCache) WriteTransactions(txns []*types.Transaction)
 
 func (cachingDB *DatabaseWithCache) ReadTransaction(hash types.Hash) (*types.Transaction, error) {
 	if cachedTx, err := cachingDB.transactionCache.Get(hash.String()); err == nil {
+		metrics.CacheLookup("transaction", true)
 		return cachedTx.(*types.Transaction), nil
 	}
+	metrics.CacheLookup("transaction", false)
 	tx, err := cachingDB.db.ReadTransaction(hash)
 	if err != nil {
 		return nil, err
@@ -195,8 +200,10 @@ func (cachingDB *DatabaseWithCache) SetContractCreationTransaction(creationTxns
 
 func (cachingDB *DatabaseWithCache) GetContractCreationTransaction(address types.Address) (types.Hash, error) {
 	if cachedHash, err := cachingDB.contractCreationCache.Get(address); err == nil {
+		metrics.CacheLookup("contractCreation", true)
 		return cachedHash.(types.Hash), nil
 	}
+	metrics.CacheLookup("contractCreation", false)
 	hash, err := cachingDB.db.GetContractCreationTransaction(address)
 	if err != nil {
 		return "", err
@@ -227,6 +234,14 @@ func (cachingDB *DatabaseWithCache) GetTransactionsInternalToAddressTotal(addres
 	return cachingDB.db.GetTransactionsInternalToAddressTotal(address, options)
 }
 
+func (cachingDB *DatabaseWithCache) GetAllFailedTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	return cachingDB.db.GetAllFailedTransactionsToAddress(address, options)
+}
+
+func (cachingDB *DatabaseWithCache) GetFailedTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	return cachingDB.db.GetFailedTransactionsToAddressTotal(address, options)
+}
+
 func (cachingDB *DatabaseWithCache) GetEventsFromAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
 	return cachingDB.db.GetEventsFromAddressTotal(address, options)
 }
@@ -283,6 +298,68 @@ func (cachingDB *DatabaseWithCache) AllHoldersAtBlock(contract types.Address, bl
 	return cachingDB.db.AllHoldersAtBlock(contract, block, options)
 }
 
+func (cachingDB *DatabaseWithCache) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	return cachingDB.db.RecordERC1155Balance(contract, holder, block, tokenId, amount)
+}
+
+func (cachingDB *DatabaseWithCache) GetERC1155Balance(contract types.Address, holder types.Address, tokenId *big.Int, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return cachingDB.db.GetERC1155Balance(contract, holder, tokenId, options)
+}
+
+func (cachingDB *DatabaseWithCache) ERC1155HoldersAtBlock(contract types.Address, tokenId *big.Int, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	return cachingDB.db.ERC1155HoldersAtBlock(contract, tokenId, block, options)
+}
+
+func (cachingDB *DatabaseWithCache) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
+	return cachingDB.db.ERC1155TokensForAccountAtBlock(contract, holder, block, options)
+}
+
+func (cachingDB *DatabaseWithCache) RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error {
+	return cachingDB.db.RecordERC777Operator(contract, holder, operator, block, authorized)
+}
+
+func (cachingDB *DatabaseWithCache) ERC777OperatorsAtBlock(contract types.Address, holder types.Address, block uint64) ([]types.Address, error) {
+	return cachingDB.db.ERC777OperatorsAtBlock(contract, holder, block)
+}
+
+func (cachingDB *DatabaseWithCache) RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error {
+	return cachingDB.db.RecordERC4626Assets(contract, block, totalAssets, totalSupply)
+}
+
+func (cachingDB *DatabaseWithCache) GetERC4626AssetsAtBlock(contract types.Address, block uint64) (*types.ERC4626Assets, error) {
+	return cachingDB.db.GetERC4626AssetsAtBlock(contract, block)
+}
+
+func (cachingDB *DatabaseWithCache) AddWebhook(webhook *types.Webhook) error {
+	return cachingDB.db.AddWebhook(webhook)
+}
+
+func (cachingDB *DatabaseWithCache) DeleteWebhook(id string) error {
+	return cachingDB.db.DeleteWebhook(id)
+}
+
+func (cachingDB *DatabaseWithCache) GetWebhooks() ([]*types.Webhook, error) {
+	return cachingDB.db.GetWebhooks()
+}
+
+func (cachingDB *DatabaseWithCache) SetWebhookCursor(id string, cursor types.WebhookCursor) error {
+	return cachingDB.db.SetWebhookCursor(id, cursor)
+}
+
+func (cachingDB *DatabaseWithCache) Rollback(blockNumber uint64) error {
+	cachingDB.blockMux.Lock()
+	defer cachingDB.blockMux.Unlock()
+	if err := cachingDB.db.Rollback(blockNumber); err != nil {
+		return err
+	}
+	// cached entries may belong to the removed blocks
+	cachingDB.blockCache.Purge()
+	cachingDB.transactionCache.Purge()
+	cachingDB.storageCache.Purge()
+	cachingDB.contractCreationCache.Purge()
+	return nil
+}
+
 func (cachingDB *DatabaseWithCache) Stop() {
 	cachingDB.db.Stop()
 }
@@ -0,0 +1,19 @@
+package factory
+
+import (
+	"testing"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/dbtest"
+	"quorumengineering/quorum-report/database/memory"
+)
+
+func TestDatabaseWithCache_Conformance(t *testing.T) {
+	dbtest.RunConformanceTests(t, func(t *testing.T) database.Database {
+		db, err := NewDatabaseWithCache(memory.NewMemoryDB(), 10)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		return db
+	})
+}
@@ -12,6 +12,12 @@ type Database interface {
 	TransactionDB
 	IndexDB
 	TokenDB
+	WebhookDB
+
+	// Rollback removes all blocks, transactions, indexed data and token records
+	// above the given block number, e.g. after a chain reorganisation
+	Rollback(uint64) error
+
 	Stop()
 }
 
@@ -63,6 +69,8 @@ type IndexDB interface {
 	GetTransactionsToAddressTotal(types.Address, *types.QueryOptions) (uint64, error)
 	GetAllTransactionsInternalToAddress(types.Address, *types.QueryOptions) ([]types.Hash, error)
 	GetTransactionsInternalToAddressTotal(types.Address, *types.QueryOptions) (uint64, error)
+	GetAllFailedTransactionsToAddress(types.Address, *types.QueryOptions) ([]types.Hash, error)
+	GetFailedTransactionsToAddressTotal(types.Address, *types.QueryOptions) (uint64, error)
 	GetAllEventsFromAddress(types.Address, *types.QueryOptions) ([]*types.Event, error)
 	GetEventsFromAddressTotal(types.Address, *types.QueryOptions) (uint64, error)
 
@@ -84,4 +92,32 @@ type TokenDB interface {
 	ERC721TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error)
 	AllERC721TokensAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error)
 	AllHoldersAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error)
+
+	RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error
+	GetERC1155Balance(contract types.Address, holder types.Address, tokenId *big.Int, options *types.TokenQueryOptions) (map[uint64]*big.Int, error)
+	// ERC1155HoldersAtBlock returns the accounts holding a non-zero balance of the token at the block
+	ERC1155HoldersAtBlock(contract types.Address, tokenId *big.Int, block uint64, options *types.TokenQueryOptions) ([]types.Address, error)
+	// ERC1155TokensForAccountAtBlock returns the tokens the account holds a non-zero balance of at the block
+	ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error)
+
+	// RecordERC777Operator records that the operator was authorized, or revoked, to
+	// send and burn the holder's tokens from the block onwards
+	RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error
+	// ERC777OperatorsAtBlock returns the operators authorized by the holder at the block,
+	// not including the contract's default operators
+	ERC777OperatorsAtBlock(contract types.Address, holder types.Address, block uint64) ([]types.Address, error)
+
+	RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error
+	// GetERC4626AssetsAtBlock returns the latest totals of the vault recorded at or before the block
+	GetERC4626AssetsAtBlock(contract types.Address, block uint64) (*types.ERC4626Assets, error)
+}
+
+// WebhookDB stores webhooks, along with how far each has been delivered.
+type WebhookDB interface {
+	// AddWebhook stores a webhook. If a webhook with the same ID already exists,
+	// it is updated but keeps its delivery cursor.
+	AddWebhook(*types.Webhook) error
+	DeleteWebhook(id string) error
+	GetWebhooks() ([]*types.Webhook, error)
+	SetWebhookCursor(id string, cursor types.WebhookCursor) error
 }
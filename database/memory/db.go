@@ -23,12 +23,17 @@ type MemoryDB struct {
 	txDB                     map[types.Hash]*types.Transaction
 	lastPersistedBlockNumber uint64
 	// index data
-	txIndexDB        map[types.Address]*TxIndexer
-	eventIndexDB     map[types.Address][]*types.Event
-	storageIndexDB   map[types.Address]*StorageIndexer
-	lastFiltered     map[types.Address]uint64
-	erc20BalancesDB  []ERC20TokenHolder
-	erc721BalancesDB []types.ERC721Token
+	txIndexDB         map[types.Address]*TxIndexer
+	eventIndexDB      map[types.Address][]*types.Event
+	storageIndexDB    map[types.Address]*StorageIndexer
+	lastFiltered      map[types.Address]uint64
+	erc20BalancesDB   []ERC20TokenHolder
+	erc721BalancesDB  []types.ERC721Token
+	erc1155BalancesDB []ERC1155TokenHolder
+	erc777OperatorsDB []ERC777Operator
+	erc4626AssetsDB   []types.ERC4626Assets
+	// webhook data
+	webhookDB map[string]*types.Webhook
 	// mutex lock
 	mux sync.RWMutex
 }
@@ -46,6 +51,7 @@ func NewMemoryDB() *MemoryDB {
 		storageIndexDB:           make(map[types.Address]*StorageIndexer),
 		lastPersistedBlockNumber: 0,
 		lastFiltered:             make(map[types.Address]uint64),
+		webhookDB:                make(map[string]*types.Webhook),
 	}
 }
 
@@ -63,6 +69,22 @@ type ERC20TokenHolder struct {
 	HeldUntil   *uint64
 }
 
+type ERC1155TokenHolder struct {
+	Contract    types.Address
+	Holder      types.Address
+	Token       string
+	BlockNumber uint64
+	Amount      string
+}
+
+type ERC777Operator struct {
+	Contract    types.Address
+	Holder      types.Address
+	Operator    types.Address
+	BlockNumber uint64
+	Authorized  bool
+}
+
 func NewTxIndexer() *TxIndexer {
 	return &TxIndexer{
 		contractCreationTx: "",
@@ -314,7 +336,7 @@ func (db *MemoryDB) SetContractCreationTransaction(creationTxns map[types.Hash][
 			if _, ok := db.txIndexDB[createdAddress]; !ok {
 				//tried to index a deleted address, do nothing
 				log.Debug("Ignored deleted address contract creation", "tx", txHash.Hex(), "contract", createdAddress)
-				return nil
+				continue
 			}
 			db.txIndexDB[createdAddress].contractCreationTx = txHash
 			log.Debug("Indexed address of contract creation", "tx", txHash.Hex(), "contract", createdAddress)
@@ -338,15 +360,9 @@ func (db *MemoryDB) GetAllTransactionsToAddress(address types.Address, options *
 	if !db.addressIsRegistered(address) {
 		return nil, errors.New("address is not registered")
 	}
-	var txs []types.Hash
-	txIndex := len(db.txIndexDB[address].txsTo) - 1
-
-	// reverse the order to get descending order
-	for txIndex >= 0 {
-		txs = append(txs, db.txIndexDB[address].txsTo[txIndex])
-		txIndex--
-	}
-	return txs, nil
+	txs := db.filterTxs(db.txIndexDB[address].txsTo, options)
+	from, to := pageBounds(len(txs), options)
+	return txs[from:to], nil
 }
 
 func (db *MemoryDB) GetTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
@@ -355,7 +371,7 @@ func (db *MemoryDB) GetTransactionsToAddressTotal(address types.Address, options
 	if !db.addressIsRegistered(address) {
 		return 0, errors.New("address is not registered")
 	}
-	return uint64(len(db.txIndexDB[address].txsTo)), nil
+	return uint64(len(db.filterTxs(db.txIndexDB[address].txsTo, options))), nil
 }
 
 func (db *MemoryDB) GetAllTransactionsInternalToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
@@ -364,25 +380,38 @@ func (db *MemoryDB) GetAllTransactionsInternalToAddress(address types.Address, o
 	if !db.addressIsRegistered(address) {
 		return nil, errors.New("address is not registered")
 	}
-	var txs []types.Hash
-	txIndex := len(db.txIndexDB[address].txsInternalTo) - 1
+	txs := db.filterTxs(db.txIndexDB[address].txsInternalTo, options)
+	from, to := pageBounds(len(txs), options)
+	return txs[from:to], nil
+}
 
-	// reverse the order to get descending order
-	for txIndex >= 0 {
-		txs = append(txs, db.txIndexDB[address].txsInternalTo[txIndex])
-		txIndex--
+func (db *MemoryDB) GetTransactionsInternalToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	if !db.addressIsRegistered(address) {
+		return 0, errors.New("address is not registered")
 	}
+	return uint64(len(db.filterTxs(db.txIndexDB[address].txsInternalTo, options))), nil
+}
 
-	return txs, nil
+func (db *MemoryDB) GetAllFailedTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	if !db.addressIsRegistered(address) {
+		return nil, errors.New("address is not registered")
+	}
+	txs := db.filterTxs(db.failedTxs(db.txIndexDB[address].txsTo), options)
+	from, to := pageBounds(len(txs), options)
+	return txs[from:to], nil
 }
 
-func (db *MemoryDB) GetTransactionsInternalToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+func (db *MemoryDB) GetFailedTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 	if !db.addressIsRegistered(address) {
 		return 0, errors.New("address is not registered")
 	}
-	return uint64(len(db.txIndexDB[address].txsInternalTo)), nil
+	return uint64(len(db.filterTxs(db.failedTxs(db.txIndexDB[address].txsTo), options))), nil
 }
 
 func (db *MemoryDB) GetAllEventsFromAddress(address types.Address, options *types.QueryOptions) ([]*types.Event, error) {
@@ -391,11 +420,9 @@ func (db *MemoryDB) GetAllEventsFromAddress(address types.Address, options *type
 	if !db.addressIsRegistered(address) {
 		return nil, errors.New("address is not registered")
 	}
-	events := db.eventIndexDB[address]
-	sort.SliceStable(events, func(i, j int) bool {
-		return events[i].BlockNumber > events[j].BlockNumber
-	})
-	return events, nil
+	events := filterEvents(db.eventIndexDB[address], options)
+	from, to := pageBounds(len(events), options)
+	return events[from:to], nil
 }
 
 func (db *MemoryDB) GetEventsFromAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
@@ -404,7 +431,7 @@ func (db *MemoryDB) GetEventsFromAddressTotal(address types.Address, options *ty
 	if !db.addressIsRegistered(address) {
 		return 0, errors.New("address is not registered")
 	}
-	return uint64(len(db.eventIndexDB[address])), nil
+	return uint64(len(filterEvents(db.eventIndexDB[address], options))), nil
 }
 
 func (db *MemoryDB) GetStorageWithOptions(address types.Address, options *types.PageOptions) ([]*types.StorageResult, error) {
@@ -434,7 +461,8 @@ func (db *MemoryDB) GetStorageWithOptions(address types.Address, options *types.
 	sort.SliceStable(convertedList, func(i, j int) bool {
 		return convertedList[i].BlockNumber > convertedList[j].BlockNumber
 	})
-	return convertedList, nil
+	from, to := pageBounds(len(convertedList), &types.QueryOptions{PageSize: options.PageSize, PageNumber: options.PageNumber})
+	return convertedList[from:to], nil
 }
 
 func (db *MemoryDB) GetStorageTotal(address types.Address, options *types.PageOptions) (uint64, error) {
@@ -443,13 +471,9 @@ func (db *MemoryDB) GetStorageTotal(address types.Address, options *types.PageOp
 	if !db.addressIsRegistered(address) {
 		return 0, errors.New("address is not registered")
 	}
-	fromBlockNum := options.BeginBlockNumber.Uint64()
-	endBlockNum := options.EndBlockNumber.Int64()
-	toBlockNum := options.EndBlockNumber.Uint64()
 	var total uint64
-	blockNum := fromBlockNum
-	for v := range db.storageIndexDB[address].root {
-		if v >= fromBlockNum && (endBlockNum == -1 || blockNum <= toBlockNum) {
+	for blockNum := range db.storageIndexDB[address].root {
+		if inRange(blockNum, options.BeginBlockNumber, options.EndBlockNumber) {
 			total++
 		}
 	}
@@ -542,6 +566,154 @@ func (db *MemoryDB) GetLastFiltered(address types.Address) (uint64, error) {
 	return db.lastFiltered[address], nil
 }
 
+func (db *MemoryDB) AddWebhook(webhook *types.Webhook) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	stored := *webhook
+	if existing, ok := db.webhookDB[webhook.ID]; ok {
+		stored.Cursor = existing.Cursor
+	}
+	db.webhookDB[webhook.ID] = &stored
+	return nil
+}
+
+func (db *MemoryDB) DeleteWebhook(id string) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	if _, ok := db.webhookDB[id]; !ok {
+		return database.ErrNotFound
+	}
+	delete(db.webhookDB, id)
+	return nil
+}
+
+func (db *MemoryDB) GetWebhooks() ([]*types.Webhook, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	webhooks := make([]*types.Webhook, 0, len(db.webhookDB))
+	for _, webhook := range db.webhookDB {
+		copied := *webhook
+		webhooks = append(webhooks, &copied)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (db *MemoryDB) SetWebhookCursor(id string, cursor types.WebhookCursor) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	webhook, ok := db.webhookDB[id]
+	if !ok {
+		return database.ErrNotFound
+	}
+	webhook.Cursor = cursor
+	return nil
+}
+
+func (db *MemoryDB) Rollback(blockNumber uint64) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	// remove blocks and their transactions
+	removedTxs := make(map[types.Hash]bool)
+	for number, block := range db.blockDB {
+		if number > blockNumber {
+			for _, txHash := range block.Transactions {
+				removedTxs[txHash] = true
+			}
+			delete(db.blockDB, number)
+		}
+	}
+	for hash, tx := range db.txDB {
+		if tx.BlockNumber > blockNumber {
+			removedTxs[hash] = true
+			delete(db.txDB, hash)
+		}
+	}
+	if db.lastPersistedBlockNumber > blockNumber {
+		db.lastPersistedBlockNumber = blockNumber
+	}
+
+	// remove indexed data
+	for _, txIndexer := range db.txIndexDB {
+		txIndexer.txsTo = filterRemovedTxs(txIndexer.txsTo, removedTxs)
+		txIndexer.txsInternalTo = filterRemovedTxs(txIndexer.txsInternalTo, removedTxs)
+	}
+	for address, events := range db.eventIndexDB {
+		remaining := make([]*types.Event, 0, len(events))
+		for _, event := range events {
+			if event.BlockNumber <= blockNumber {
+				remaining = append(remaining, event)
+			}
+		}
+		db.eventIndexDB[address] = remaining
+	}
+	for _, storageIndexer := range db.storageIndexDB {
+		for number := range storageIndexer.root {
+			if number > blockNumber {
+				delete(storageIndexer.root, number)
+			}
+		}
+	}
+	for address, lastFiltered := range db.lastFiltered {
+		if lastFiltered > blockNumber {
+			db.lastFiltered[address] = blockNumber
+		}
+	}
+
+	// remove token records, re-opening any that were closed by a removed record
+	erc20Balances := make([]ERC20TokenHolder, 0, len(db.erc20BalancesDB))
+	for _, entry := range db.erc20BalancesDB {
+		if entry.BlockNumber > blockNumber {
+			continue
+		}
+		if entry.HeldUntil != nil && *entry.HeldUntil >= blockNumber {
+			entry.HeldUntil = nil
+		}
+		erc20Balances = append(erc20Balances, entry)
+	}
+	db.erc20BalancesDB = erc20Balances
+
+	erc721Balances := make([]types.ERC721Token, 0, len(db.erc721BalancesDB))
+	for _, entry := range db.erc721BalancesDB {
+		if entry.HeldFrom > blockNumber {
+			continue
+		}
+		if entry.HeldUntil != nil && *entry.HeldUntil >= blockNumber {
+			entry.HeldUntil = nil
+		}
+		erc721Balances = append(erc721Balances, entry)
+	}
+	db.erc721BalancesDB = erc721Balances
+
+	erc1155Balances := make([]ERC1155TokenHolder, 0, len(db.erc1155BalancesDB))
+	for _, entry := range db.erc1155BalancesDB {
+		if entry.BlockNumber <= blockNumber {
+			erc1155Balances = append(erc1155Balances, entry)
+		}
+	}
+	db.erc1155BalancesDB = erc1155Balances
+
+	erc777Operators := make([]ERC777Operator, 0, len(db.erc777OperatorsDB))
+	for _, entry := range db.erc777OperatorsDB {
+		if entry.BlockNumber <= blockNumber {
+			erc777Operators = append(erc777Operators, entry)
+		}
+	}
+	db.erc777OperatorsDB = erc777Operators
+
+	erc4626Assets := make([]types.ERC4626Assets, 0, len(db.erc4626AssetsDB))
+	for _, entry := range db.erc4626AssetsDB {
+		if entry.BlockNumber <= blockNumber {
+			erc4626Assets = append(erc4626Assets, entry)
+		}
+	}
+	db.erc4626AssetsDB = erc4626Assets
+
+	log.Info("Rolled back database", "block number", blockNumber, "removed txs", len(removedTxs))
+	return nil
+}
+
 func (db *MemoryDB) Stop() {}
 
 // internal functions
@@ -600,6 +772,16 @@ func (db *MemoryDB) indexTransaction(filteredAddresses map[types.Address]bool, t
 	}
 }
 
+func filterRemovedTxs(txs []types.Hash, removed map[types.Hash]bool) []types.Hash {
+	remaining := make([]types.Hash, 0, len(txs))
+	for _, tx := range txs {
+		if !removed[tx] {
+			remaining = append(remaining, tx)
+		}
+	}
+	return remaining
+}
+
 func (db *MemoryDB) removeAllIndices(address types.Address) error {
 	delete(db.txIndexDB, address)
 	delete(db.eventIndexDB, address)
@@ -639,6 +821,12 @@ func (db *MemoryDB) RecordNewERC20Balance(contract types.Address, holder types.A
 		return errExisting
 	}
 
+	// close the previous entry before appending, as appending may move the entries
+	if errExisting != database.ErrNotFound {
+		blk := block - 1
+		existingTokenEntry.HeldUntil = &blk
+	}
+
 	//add new entry
 	tokenInfo := ERC20TokenHolder{
 		Contract:    contract,
@@ -647,13 +835,6 @@ func (db *MemoryDB) RecordNewERC20Balance(contract types.Address, holder types.A
 		Amount:      amount.String(),
 	}
 	db.erc20BalancesDB = append(db.erc20BalancesDB, tokenInfo)
-	/////
-	if errExisting == database.ErrNotFound {
-		return nil
-	}
-	blk := block - 1
-	existingTokenEntry.HeldUntil = &blk
-
 	return nil
 }
 
@@ -707,11 +888,7 @@ func (db *MemoryDB) GetAllTokenHolders(contract types.Address, block uint64, opt
 			holderMap[k.Holder] = true
 		}
 	}
-	holderArr := make([]types.Address, 0, len(holderMap))
-	for holdr := range holderMap {
-		holderArr = append(holderArr, holdr)
-	}
-	return holderArr, nil
+	return pageHolders(holderMap, options), nil
 }
 
 func (db *MemoryDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
@@ -723,6 +900,12 @@ func (db *MemoryDB) RecordERC721Token(contract types.Address, holder types.Addre
 		return errExisting
 	}
 
+	// close the previous entry before appending, as appending may move the entries
+	if errExisting != database.ErrNotFound {
+		blk := block - 1
+		existingTokenEntry.HeldUntil = &blk
+	}
+
 	//add new entry
 	tokenHolderInfo :=
 		types.ERC721Token{
@@ -733,13 +916,6 @@ func (db *MemoryDB) RecordERC721Token(contract types.Address, holder types.Addre
 			HeldUntil: nil,
 		}
 	db.erc721BalancesDB = append(db.erc721BalancesDB, tokenHolderInfo)
-	/////
-	if errExisting == database.ErrNotFound {
-		return nil
-	}
-
-	blk := block - 1
-	existingTokenEntry.HeldUntil = &blk
 	return nil
 }
 
@@ -782,35 +958,39 @@ func (db *MemoryDB) erc721TokensAtBlock(contract types.Address, holder *types.Ad
 		}
 		startTokenId = parsed
 	}
-	tokenId := new(big.Int).Add(startTokenId, big.NewInt(1))
 
-	var tmpItem types.ERC721Token
-	found := false
-	result := make([]types.ERC721Token, 0, 0)
+	// find the latest record of each token at the block
+	latest := make(map[string]types.ERC721Token)
 	for _, k := range db.erc721BalancesDB {
-		if k.Contract == contract && (holder == nil || *holder == k.Holder) && k.HeldFrom <= block {
-			ercTokenId, success := new(big.Int).SetString(k.Token, 10)
-			if !success {
-				return nil, errors.New(`could not parse "erc721" token ID`)
-			}
-			if ercTokenId.Cmp(tokenId) >= 0 {
-				if !found {
-					tmpItem = k
-					result = append(result, tmpItem)
-					found = true
-				} else {
-					if k.HeldFrom > tmpItem.HeldFrom {
-						tmpItem = k
-						result = append(result, tmpItem)
-					}
-				}
-			}
-
+		if k.Contract != contract || k.HeldFrom > block {
+			continue
+		}
+		if existing, ok := latest[k.Token]; !ok || k.HeldFrom > existing.HeldFrom {
+			latest[k.Token] = k
 		}
+	}
 
+	result := make([]types.ERC721Token, 0, len(latest))
+	for _, k := range latest {
+		if (holder != nil && *holder != k.Holder) || (k.HeldUntil != nil && *k.HeldUntil < block) {
+			continue
+		}
+		ercTokenId, success := new(big.Int).SetString(k.Token, 10)
+		if !success {
+			return nil, errors.New(`could not parse "erc721" token ID`)
+		}
+		if ercTokenId.Cmp(startTokenId) > 0 {
+			result = append(result, k)
+		}
 	}
 
-	return result, nil
+	sort.Slice(result, func(i, j int) bool {
+		first, _ := new(big.Int).SetString(result[i].Token, 10)
+		second, _ := new(big.Int).SetString(result[j].Token, 10)
+		return first.Cmp(second) < 0
+	})
+	from, to := pageBounds(len(result), &types.QueryOptions{PageSize: options.PageSize, PageNumber: options.PageNumber})
+	return result[from:to], nil
 }
 
 func (db *MemoryDB) AllERC721TokensAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
@@ -818,7 +998,7 @@ func (db *MemoryDB) AllERC721TokensAtBlock(contract types.Address, block uint64,
 }
 
 func (db *MemoryDB) AllHoldersAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
-	res, err := db.erc721TokensAtBlock(contract, nil, block, options)
+	res, err := db.erc721TokensAtBlock(contract, nil, block, &types.TokenQueryOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -827,9 +1007,328 @@ func (db *MemoryDB) AllHoldersAtBlock(contract types.Address, block uint64, opti
 	for _, k := range res {
 		hldrMap[k.Holder] = true
 	}
-	holders := make([]types.Address, 0, len(hldrMap))
-	for k := range hldrMap {
-		holders = append(holders, k)
+	return pageHolders(hldrMap, options), nil
+}
+
+func (db *MemoryDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	entry := ERC1155TokenHolder{
+		Contract:    contract,
+		Holder:      holder,
+		Token:       tokenId.String(),
+		BlockNumber: block,
+		Amount:      amount.String(),
+	}
+	// replace a balance already recorded at the block
+	for i, existing := range db.erc1155BalancesDB {
+		if existing.Contract == contract && existing.Holder == holder && existing.Token == entry.Token && existing.BlockNumber == block {
+			db.erc1155BalancesDB[i] = entry
+			return nil
+		}
+	}
+	db.erc1155BalancesDB = append(db.erc1155BalancesDB, entry)
+	return nil
+}
+
+func (db *MemoryDB) GetERC1155Balance(contract types.Address, holder types.Address, tokenId *big.Int, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	balanceMap := make(map[uint64]*big.Int)
+	frmBlkNum := options.BeginBlockNumber.Uint64()
+	endBlkNum := options.EndBlockNumber.Int64()
+	var previous *ERC1155TokenHolder
+	for i, b := range db.erc1155BalancesDB {
+		if contract != b.Contract || holder != b.Holder || tokenId.String() != b.Token {
+			continue
+		}
+		if b.BlockNumber >= frmBlkNum && (b.BlockNumber <= uint64(endBlkNum) || endBlkNum == -1) {
+			tokAmt, success := new(big.Int).SetString(b.Amount, 10)
+			if !success {
+				return nil, errors.New("could not parse token value")
+			}
+			balanceMap[b.BlockNumber] = tokAmt
+		}
+		if b.BlockNumber < frmBlkNum && (previous == nil || previous.BlockNumber < b.BlockNumber) {
+			previous = &db.erc1155BalancesDB[i]
+		}
+	}
+
+	// the balance at the start of the range comes from the last change before it
+	if _, ok := balanceMap[frmBlkNum]; !ok && previous != nil {
+		tokAmt, success := new(big.Int).SetString(previous.Amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		balanceMap[frmBlkNum] = tokAmt
+	}
+	return balanceMap, nil
+}
+
+func (db *MemoryDB) ERC1155HoldersAtBlock(contract types.Address, tokenId *big.Int, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	balances, err := db.erc1155BalancesAtBlock(contract, block, func(entry ERC1155TokenHolder) bool {
+		return entry.Token == tokenId.String()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	holderMap := make(map[types.Address]bool)
+	for _, balance := range balances {
+		holderMap[balance.Holder] = true
+	}
+	return pageHolders(holderMap, options), nil
+}
+
+func (db *MemoryDB) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	balances, err := db.erc1155BalancesAtBlock(contract, block, func(entry ERC1155TokenHolder) bool {
+		return entry.Holder == holder
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]types.ERC1155Token, 0, len(balances))
+	for _, balance := range balances {
+		tokenId, _ := new(big.Int).SetString(balance.Token, 10)
+		if tokenId.Cmp(startTokenId) > 0 {
+			result = append(result, balance)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		first, _ := new(big.Int).SetString(result[i].Token, 10)
+		second, _ := new(big.Int).SetString(result[j].Token, 10)
+		return first.Cmp(second) < 0
+	})
+	from, to := pageBounds(len(result), &types.QueryOptions{PageSize: options.PageSize, PageNumber: options.PageNumber})
+	return result[from:to], nil
+}
+
+func (db *MemoryDB) RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	entry := ERC777Operator{
+		Contract:    contract,
+		Holder:      holder,
+		Operator:    operator,
+		BlockNumber: block,
+		Authorized:  authorized,
+	}
+	// replace a change already recorded at the block
+	for i, existing := range db.erc777OperatorsDB {
+		if existing.Contract == contract && existing.Holder == holder && existing.Operator == operator && existing.BlockNumber == block {
+			db.erc777OperatorsDB[i] = entry
+			return nil
+		}
+	}
+	db.erc777OperatorsDB = append(db.erc777OperatorsDB, entry)
+	return nil
+}
+
+func (db *MemoryDB) ERC777OperatorsAtBlock(contract types.Address, holder types.Address, block uint64) ([]types.Address, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	latest := make(map[types.Address]ERC777Operator)
+	for _, entry := range db.erc777OperatorsDB {
+		if entry.Contract != contract || entry.Holder != holder || entry.BlockNumber > block {
+			continue
+		}
+		if existing, ok := latest[entry.Operator]; !ok || entry.BlockNumber > existing.BlockNumber {
+			latest[entry.Operator] = entry
+		}
+	}
+
+	operatorMap := make(map[types.Address]bool)
+	for operator, entry := range latest {
+		if entry.Authorized {
+			operatorMap[operator] = true
+		}
+	}
+	return pageHolders(operatorMap, &types.TokenQueryOptions{}), nil
+}
+
+func (db *MemoryDB) RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	entry := types.ERC4626Assets{
+		Contract:    contract,
+		BlockNumber: block,
+		TotalAssets: new(big.Int).Set(totalAssets),
+		TotalSupply: new(big.Int).Set(totalSupply),
+	}
+	// replace the totals already recorded at the block
+	for i, existing := range db.erc4626AssetsDB {
+		if existing.Contract == contract && existing.BlockNumber == block {
+			db.erc4626AssetsDB[i] = entry
+			return nil
+		}
+	}
+	db.erc4626AssetsDB = append(db.erc4626AssetsDB, entry)
+	return nil
+}
+
+func (db *MemoryDB) GetERC4626AssetsAtBlock(contract types.Address, block uint64) (*types.ERC4626Assets, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+	var latest *types.ERC4626Assets
+	for i, entry := range db.erc4626AssetsDB {
+		if entry.Contract != contract || entry.BlockNumber > block {
+			continue
+		}
+		if latest == nil || entry.BlockNumber > latest.BlockNumber {
+			latest = &db.erc4626AssetsDB[i]
+		}
+	}
+	if latest == nil {
+		return nil, database.ErrNotFound
+	}
+	return &types.ERC4626Assets{
+		Contract:    latest.Contract,
+		BlockNumber: latest.BlockNumber,
+		TotalAssets: new(big.Int).Set(latest.TotalAssets),
+		TotalSupply: new(big.Int).Set(latest.TotalSupply),
+	}, nil
+}
+
+// erc1155BalancesAtBlock finds the latest balance at the block of each holder and
+// token of a contract matching the filter, leaving out those that are zero
+func (db *MemoryDB) erc1155BalancesAtBlock(contract types.Address, block uint64, filter func(ERC1155TokenHolder) bool) ([]types.ERC1155Token, error) {
+	type holderToken struct {
+		holder types.Address
+		token  string
+	}
+	latest := make(map[holderToken]ERC1155TokenHolder)
+	for _, entry := range db.erc1155BalancesDB {
+		if entry.Contract != contract || entry.BlockNumber > block || !filter(entry) {
+			continue
+		}
+		key := holderToken{entry.Holder, entry.Token}
+		if existing, ok := latest[key]; !ok || entry.BlockNumber > existing.BlockNumber {
+			latest[key] = entry
+		}
+	}
+
+	balances := make([]types.ERC1155Token, 0, len(latest))
+	for _, entry := range latest {
+		amount, success := new(big.Int).SetString(entry.Amount, 10)
+		if !success {
+			return nil, errors.New("could not parse token value")
+		}
+		if amount.Sign() == 0 {
+			continue
+		}
+		balances = append(balances, types.ERC1155Token{
+			Contract: entry.Contract,
+			Holder:   entry.Holder,
+			Token:    entry.Token,
+			Amount:   amount,
+			HeldFrom: entry.BlockNumber,
+		})
+	}
+	return balances, nil
+}
+
+// failedTxs keeps the transactions that failed
+func (db *MemoryDB) failedTxs(txs []types.Hash) []types.Hash {
+	failed := make([]types.Hash, 0, len(txs))
+	for _, hash := range txs {
+		if tx, ok := db.txDB[hash]; ok && !tx.Status {
+			failed = append(failed, hash)
+		}
+	}
+	return failed
+}
+
+// filterTxs returns the indexed transactions within the range of the query options,
+// in descending order
+func (db *MemoryDB) filterTxs(txs []types.Hash, options *types.QueryOptions) []types.Hash {
+	filtered := make([]types.Hash, 0, len(txs))
+	for i := len(txs) - 1; i >= 0; i-- {
+		if tx, ok := db.txDB[txs[i]]; ok && (!inQueryRange(options, tx.BlockNumber, tx.Timestamp) || !options.InValueRange(tx.Value)) {
+			continue
+		}
+		filtered = append(filtered, txs[i])
+	}
+	return filtered
+}
+
+// filterEvents returns the events within the range of the query options, in
+// descending block order
+func filterEvents(events []*types.Event, options *types.QueryOptions) []*types.Event {
+	filtered := make([]*types.Event, 0, len(events))
+	for _, event := range events {
+		if inQueryRange(options, event.BlockNumber, event.Timestamp) {
+			filtered = append(filtered, event)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		return filtered[i].BlockNumber > filtered[j].BlockNumber
+	})
+	return filtered
+}
+
+func inQueryRange(options *types.QueryOptions, blockNumber uint64, timestamp uint64) bool {
+	if options == nil {
+		return true
+	}
+	return inRange(blockNumber, options.BeginBlockNumber, options.EndBlockNumber) &&
+		inRange(timestamp, options.BeginTimestamp, options.EndTimestamp)
+}
+
+// inRange checks a value against optional bounds, where an end of -1 means no upper bound
+func inRange(value uint64, begin *big.Int, end *big.Int) bool {
+	if begin != nil && begin.Sign() > 0 && value < begin.Uint64() {
+		return false
+	}
+	if end != nil && end.Sign() >= 0 && value > end.Uint64() {
+		return false
+	}
+	return true
+}
+
+// pageBounds returns the slice bounds of the requested page, if paging was requested
+func pageBounds(length int, options *types.QueryOptions) (int, int) {
+	if options == nil || options.PageSize <= 0 {
+		return 0, length
+	}
+	from := options.PageSize * options.PageNumber
+	if from > length {
+		from = length
+	}
+	to := from + options.PageSize
+	if to > length {
+		to = length
+	}
+	return from, to
+}
+
+// pageHolders sorts the holders, and returns the page after the requested holder
+func pageHolders(holderMap map[types.Address]bool, options *types.TokenQueryOptions) []types.Address {
+	holders := make([]types.Address, 0, len(holderMap))
+	for holder := range holderMap {
+		if options.After != "" && holder <= types.NewAddress(options.After) {
+			continue
+		}
+		holders = append(holders, holder)
+	}
+	sort.Slice(holders, func(i, j int) bool {
+		return holders[i] < holders[j]
+	})
+	if options.PageSize > 0 && len(holders) > options.PageSize {
+		holders = holders[:options.PageSize]
 	}
-	return holders, nil
+	return holders
 }
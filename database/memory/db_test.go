@@ -1,12 +1,14 @@
 package memory
 
 import (
+	"fmt"
 	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/dbtest"
 	"quorumengineering/quorum-report/types"
 )
 
@@ -25,7 +27,7 @@ var (
 		BlockNumber:     1,
 		From:            types.NewAddress("0x0000000000000000000000000000000000000009"),
 		To:              "",
-		Value:           666,
+		Value:           big.NewInt(666),
 		CreatedContract: addr,
 	}
 	tx2 = &types.Transaction{
@@ -33,7 +35,7 @@ var (
 		BlockNumber: 1,
 		From:        types.NewAddress("0x0000000000000000000000000000000000000009"),
 		To:          uselessAddress,
-		Value:       666,
+		Value:       big.NewInt(666),
 		InternalCalls: []*types.InternalCall{
 			{
 				To: addr,
@@ -45,7 +47,7 @@ var (
 		BlockNumber: 1,
 		From:        types.NewAddress("0x0000000000000000000000000000000000000010"),
 		To:          addr,
-		Value:       666,
+		Value:       big.NewInt(666),
 		Events: []*types.Event{
 			{}, // dummy event
 			{Address: addr},
@@ -149,6 +151,12 @@ func TestMemoryDB(t *testing.T) {
 	testGetLastFiltered(t, db, addr, 0)
 }
 
+func TestMemoryDB_Conformance(t *testing.T) {
+	dbtest.RunConformanceTests(t, func(t *testing.T) database.Database {
+		return NewMemoryDB()
+	})
+}
+
 func testAddAddresses(t *testing.T, db database.Database, addresses []types.Address, expectedErr bool) {
 	err := db.AddAddresses(addresses)
 	if err != nil && !expectedErr {
@@ -273,7 +281,7 @@ func testReadTransaction(t *testing.T, db database.Database, hash types.Hash, ex
 	if tx.To != expected.To {
 		t.Fatalf("expected from %v, but got %v", expected.To, tx.To)
 	}
-	if tx.Value != expected.Value {
+	if tx.Value.Cmp(expected.Value) != 0 {
 		t.Fatalf("expected from %v, but got %v", expected.Value, tx.Value)
 	}
 }
@@ -434,6 +442,210 @@ func TestMemoryDB_ContractCreationTransactions_DeletedAddress(t *testing.T) {
 	assert.EqualValues(t, "", actualTxHash)
 }
 
+func TestMemoryDB_ContractCreationTransactions_SomeAddressesDeleted(t *testing.T) {
+	db := NewMemoryDB()
+	registered := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	deleted := types.NewAddress("0x8a5e2a6343108babed07899510fb42297938d41f")
+	assert.Nil(t, db.AddAddresses([]types.Address{registered}))
+
+	// a deleted address does not stop the rest of the transaction being indexed
+	txHash := types.NewHash("0x86835cbb6c0502b5e67a30b20c4ad79a169d13782f74557775557f52307f0bdb")
+	assert.Nil(t, db.SetContractCreationTransaction(map[types.Hash][]types.Address{txHash: {deleted, registered}}))
+
+	actualTxHash, err := db.GetContractCreationTransaction(registered)
+	assert.Nil(t, err)
+	assert.Equal(t, txHash, actualTxHash)
+}
+
+// indexTransactionsToAddr writes and indexes a transaction to, calling and
+// emitting an event from addr at each of the blocks
+func indexTransactionsToAddr(t *testing.T, db *MemoryDB, blocks uint64) []types.Hash {
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	var hashes []types.Hash
+	for i := uint64(1); i <= blocks; i++ {
+		tx := &types.Transaction{
+			Hash:          types.NewHash(fmt.Sprintf("0x%064x", i)),
+			BlockNumber:   i,
+			Timestamp:     i * 10,
+			To:            addr,
+			InternalCalls: []*types.InternalCall{{To: addr}},
+			Events:        []*types.Event{{Address: addr, BlockNumber: i, Timestamp: i * 10}},
+		}
+		assert.Nil(t, db.WriteTransactions([]*types.Transaction{tx}))
+		assert.Nil(t, db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{{Number: i, Transactions: []*types.Transaction{tx}}}))
+		hashes = append(hashes, tx.Hash)
+	}
+	return hashes
+}
+
+func TestMemoryDB_TransactionsPaged(t *testing.T) {
+	db := NewMemoryDB()
+	hashes := indexTransactionsToAddr(t, db, 5)
+
+	options := &types.QueryOptions{PageSize: 2, PageNumber: 1}
+	txs, err := db.GetAllTransactionsToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{hashes[2], hashes[1]}, txs)
+	txs, err = db.GetAllTransactionsInternalToAddress(addr, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{hashes[2], hashes[1]}, txs)
+	events, err := db.GetAllEventsFromAddress(addr, options)
+	assert.Nil(t, err)
+	if assert.Len(t, events, 2) {
+		assert.EqualValues(t, 3, events[0].BlockNumber)
+		assert.EqualValues(t, 2, events[1].BlockNumber)
+	}
+
+	// the last page is cut short, and pages past the end are empty
+	txs, err = db.GetAllTransactionsToAddress(addr, &types.QueryOptions{PageSize: 2, PageNumber: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{hashes[0]}, txs)
+	txs, err = db.GetAllTransactionsToAddress(addr, &types.QueryOptions{PageSize: 2, PageNumber: 3})
+	assert.Nil(t, err)
+	assert.Empty(t, txs)
+}
+
+func TestMemoryDB_TransactionsFiltered(t *testing.T) {
+	db := NewMemoryDB()
+	hashes := indexTransactionsToAddr(t, db, 5)
+
+	byBlock := &types.QueryOptions{BeginBlockNumber: big.NewInt(2), EndBlockNumber: big.NewInt(3)}
+	byTime := &types.QueryOptions{BeginTimestamp: big.NewInt(20), EndTimestamp: big.NewInt(30)}
+	for _, options := range []*types.QueryOptions{byBlock, byTime} {
+		txs, err := db.GetAllTransactionsToAddress(addr, options)
+		assert.Nil(t, err)
+		assert.Equal(t, []types.Hash{hashes[2], hashes[1]}, txs)
+		total, err := db.GetTransactionsToAddressTotal(addr, options)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, total)
+
+		txs, err = db.GetAllTransactionsInternalToAddress(addr, options)
+		assert.Nil(t, err)
+		assert.Equal(t, []types.Hash{hashes[2], hashes[1]}, txs)
+		total, err = db.GetTransactionsInternalToAddressTotal(addr, options)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, total)
+
+		events, err := db.GetAllEventsFromAddress(addr, options)
+		assert.Nil(t, err)
+		assert.Len(t, events, 2)
+		total, err = db.GetEventsFromAddressTotal(addr, options)
+		assert.Nil(t, err)
+		assert.EqualValues(t, 2, total)
+	}
+}
+
+func TestMemoryDB_StoragePagedAndCounted(t *testing.T) {
+	db := NewMemoryDB()
+	assert.Nil(t, db.AddAddresses([]types.Address{addr}))
+	for i := uint64(1); i <= 5; i++ {
+		root := fmt.Sprintf("0x%064x", i)
+		assert.Nil(t, db.IndexStorage(map[types.Address]*types.AccountState{addr: {Root: types.NewHash(root)}}, i))
+	}
+
+	results, err := db.GetStorageWithOptions(addr, &types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(-1), PageSize: 2, PageNumber: 1})
+	assert.Nil(t, err)
+	if assert.Len(t, results, 2) {
+		assert.EqualValues(t, 3, results[0].BlockNumber)
+		assert.EqualValues(t, 2, results[1].BlockNumber)
+	}
+
+	// only the storage within the block range is counted
+	total, err := db.GetStorageTotal(addr, &types.PageOptions{BeginBlockNumber: big.NewInt(2), EndBlockNumber: big.NewInt(3)})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, total)
+}
+
+func TestMemoryDB_ERC20HeldUntil(t *testing.T) {
+	db := NewMemoryDB()
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holder := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+
+	// enough balances that appending moves the entries
+	for i := uint64(1); i <= 20; i++ {
+		assert.Nil(t, db.RecordNewERC20Balance(contract, holder, i, big.NewInt(int64(i))))
+	}
+	for i, entry := range db.erc20BalancesDB[:19] {
+		if assert.NotNil(t, entry.HeldUntil, "entry %d", i) {
+			assert.EqualValues(t, entry.BlockNumber, *entry.HeldUntil)
+		}
+	}
+	assert.Nil(t, db.erc20BalancesDB[19].HeldUntil)
+}
+
+func TestMemoryDB_ERC721HeldUntil(t *testing.T) {
+	db := NewMemoryDB()
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holder0 := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	holder1 := types.NewAddress("0xca843569e3427144cead5e4d5999a3d0ccf92b8e")
+
+	// the token is passed between the holders, enough times that appending moves the entries
+	for i := uint64(1); i <= 20; i++ {
+		holder := holder0
+		if i%2 == 0 {
+			holder = holder1
+		}
+		assert.Nil(t, db.RecordERC721Token(contract, holder, i, big.NewInt(1)))
+	}
+	for i, entry := range db.erc721BalancesDB[:19] {
+		if assert.NotNil(t, entry.HeldUntil, "entry %d", i) {
+			assert.EqualValues(t, entry.HeldFrom, *entry.HeldUntil)
+		}
+	}
+	assert.Nil(t, db.erc721BalancesDB[19].HeldUntil)
+
+	// a holder no longer has the token once it is passed on
+	tokens, err := db.ERC721TokensForAccountAtBlock(contract, holder0, 20, &types.TokenQueryOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, tokens)
+	tokens, err = db.ERC721TokensForAccountAtBlock(contract, holder1, 20, &types.TokenQueryOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+}
+
+func TestMemoryDB_ERC721TokensPaged(t *testing.T) {
+	db := NewMemoryDB()
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holder := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+	for _, tokenId := range []int64{3, 1, 2} {
+		assert.Nil(t, db.RecordERC721Token(contract, holder, 1, big.NewInt(tokenId)))
+	}
+
+	// tokens are ordered by ID, and paged after the given token
+	tokens, err := db.AllERC721TokensAtBlock(contract, 1, &types.TokenQueryOptions{PageSize: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, []string{tokens[0].Token, tokens[1].Token})
+	tokens, err = db.AllERC721TokensAtBlock(contract, 1, &types.TokenQueryOptions{After: "2", PageSize: 2})
+	assert.Nil(t, err)
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, "3", tokens[0].Token)
+	}
+}
+
+func TestMemoryDB_TokenHoldersPaged(t *testing.T) {
+	db := NewMemoryDB()
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holders := []types.Address{
+		types.NewAddress("0xc000000000000000000000000000000000000003"),
+		types.NewAddress("0xa000000000000000000000000000000000000001"),
+		types.NewAddress("0xb000000000000000000000000000000000000002"),
+	}
+	for i, holder := range holders {
+		assert.Nil(t, db.RecordNewERC20Balance(contract, holder, 1, big.NewInt(1)))
+		assert.Nil(t, db.RecordERC721Token(contract, holder, 1, big.NewInt(int64(i))))
+	}
+
+	// holders are ordered by address, and paged after the given holder
+	for _, allHolders := range []func(types.Address, uint64, *types.TokenQueryOptions) ([]types.Address, error){db.GetAllTokenHolders, db.AllHoldersAtBlock} {
+		page, err := allHolders(contract, 1, &types.TokenQueryOptions{PageSize: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, []types.Address{holders[1], holders[2]}, page)
+		page, err = allHolders(contract, 1, &types.TokenQueryOptions{After: holders[2].Hex(), PageSize: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, []types.Address{holders[0]}, page)
+	}
+}
+
 func TestMemoryDB_GetStorageRanges(t *testing.T) {
 	db := NewMemoryDB()
 	contract := types.NewAddress("0x8a5e2a6343108babed07899510fb42297938d41f")
@@ -678,3 +890,74 @@ func TestMemorydb_erc721Balance(t *testing.T) {
 	assert.Equal(t, holder1Found, true)
 
 }
+
+func TestMemoryDB_Rollback(t *testing.T) {
+	db := NewMemoryDB()
+	contract := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	holder := types.NewAddress("0xed9d02e382b34818e88b88a309c7fe71e65f419d")
+
+	// block 1 is kept, block 2 is orphaned
+	orphanedTx := &types.Transaction{
+		Hash:        types.NewHash("0x5c83fa5955aff33c61813105851777bcd2adc85deb9af6286ba42c05cd768de0"),
+		BlockNumber: 2,
+		To:          addr,
+		Events:      []*types.Event{{Address: addr, BlockNumber: 2}},
+	}
+	orphanedBlock := &types.Block{
+		Hash:         types.NewHash("orphaned"),
+		ParentHash:   block.Hash,
+		Number:       2,
+		Transactions: []types.Hash{orphanedTx.Hash},
+	}
+	_ = db.AddAddresses([]types.Address{addr})
+	_ = db.WriteTransactions([]*types.Transaction{tx1, tx2, tx3, orphanedTx})
+	_ = db.WriteBlocks([]*types.Block{block, orphanedBlock})
+	_ = db.IndexBlocks([]types.Address{addr}, []*types.BlockWithTransactions{
+		blockWithTransactions,
+		{Hash: orphanedBlock.Hash, Number: 2, Transactions: []*types.Transaction{orphanedTx}},
+	})
+	_ = db.IndexStorage(map[types.Address]*types.AccountState{addr: {Root: types.NewHash("0x1")}}, 1)
+	_ = db.IndexStorage(map[types.Address]*types.AccountState{addr: {Root: types.NewHash("0x2")}}, 2)
+	_ = db.RecordNewERC20Balance(contract, holder, 1, big.NewInt(100))
+	_ = db.RecordNewERC20Balance(contract, holder, 2, big.NewInt(50))
+	_ = db.RecordERC721Token(contract, holder, 1, big.NewInt(1))
+	_ = db.RecordERC721Token(contract, addr, 2, big.NewInt(1))
+
+	testGetLastPersistedBlockNumeber(t, db, 2)
+	testGetLastFiltered(t, db, addr, 2)
+	testGetTransactionsToAddressTotal(t, db, addr, 2)
+
+	err := db.Rollback(1)
+	assert.Nil(t, err)
+
+	// chain data
+	testGetLastPersistedBlockNumeber(t, db, 1)
+	testReadBlock(t, db, 1, block.Hash)
+	_, err = db.ReadBlock(2)
+	assert.EqualError(t, err, "block does not exist")
+	_, err = db.ReadTransaction(orphanedTx.Hash)
+	assert.EqualError(t, err, "transaction does not exist")
+	testReadTransaction(t, db, tx3.Hash, tx3)
+
+	// index data
+	testGetLastFiltered(t, db, addr, 1)
+	testGetAllTransactionsToAddress(t, db, addr, tx3.Hash)
+	testGetTransactionsToAddressTotal(t, db, addr, 1)
+	testGetTransactionsInternalToAddressTotal(t, db, addr, 1)
+	testGetAllEventsByAddress(t, db, addr, 1)
+	storage, err := db.GetStorageWithOptions(addr, &types.PageOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(-1)})
+	assert.Nil(t, err)
+	assert.Len(t, storage, 1)
+	assert.Equal(t, uint64(1), storage[0].BlockNumber)
+
+	// token data
+	balances, err := db.GetERC20Balance(contract, holder, &types.TokenQueryOptions{BeginBlockNumber: big.NewInt(0), EndBlockNumber: big.NewInt(-1)})
+	assert.Nil(t, err)
+	assert.Equal(t, map[uint64]*big.Int{1: big.NewInt(100)}, balances)
+	assert.Nil(t, db.erc20BalancesDB[0].HeldUntil)
+
+	token, err := db.ERC721TokenByTokenID(contract, 5, big.NewInt(1))
+	assert.Nil(t, err)
+	assert.Equal(t, holder, token.Holder)
+	assert.Nil(t, token.HeldUntil)
+}
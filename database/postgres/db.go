@@ -0,0 +1,416 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+const lastPersistedKey = "lastPersisted"
+
+var errAddressNotRegistered = errors.New("address is not registered")
+
+// PostgresDB stores all data in a PostgreSQL database, so that it can be
+// queried alongside other relational data.
+type PostgresDB struct {
+	db *sql.DB
+}
+
+// New creates the database from an open connection pool, migrating the schema
+// to the latest version.
+func New(db *sql.DB) (*PostgresDB, error) {
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	return &PostgresDB{db: db}, nil
+}
+
+func (pg *PostgresDB) AddAddresses(addresses []types.Address) error {
+	return pg.withTx(func(tx *sql.Tx) error {
+		for _, address := range addresses {
+			if _, err := tx.Exec(`INSERT INTO contracts (address) VALUES ($1) ON CONFLICT DO NOTHING`, address.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) AddAddressFrom(address types.Address, from uint64) error {
+	var lastFiltered uint64
+	if from > 0 {
+		lastFiltered = from - 1
+	}
+	_, err := pg.db.Exec(`INSERT INTO contracts (address, last_filtered) VALUES ($1, $2) ON CONFLICT DO NOTHING`, address.String(), lastFiltered)
+	return err
+}
+
+func (pg *PostgresDB) DeleteAddress(address types.Address) error {
+	return pg.withTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM contracts WHERE address = $1`, address.String())
+		if err != nil {
+			return err
+		}
+		if deleted, err := result.RowsAffected(); err != nil {
+			return err
+		} else if deleted == 0 {
+			return errors.New("address does not exist")
+		}
+		for _, table := range []string{"address_transactions", "events", "storage", "storage_data"} {
+			if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE address = $1`, table), address.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) GetAddresses() ([]types.Address, error) {
+	rows, err := pg.db.Query(`SELECT address FROM contracts ORDER BY address`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addresses := []types.Address{}
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, types.NewAddress(address))
+	}
+	return addresses, rows.Err()
+}
+
+func (pg *PostgresDB) GetContractTemplate(address types.Address) (string, error) {
+	var templateName string
+	err := pg.db.QueryRow(`SELECT template_name FROM contract_templates WHERE address = $1`, address.String()).Scan(&templateName)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return templateName, err
+}
+
+func (pg *PostgresDB) GetContractABI(address types.Address) (string, error) {
+	return pg.getContractTemplateField("abi", address)
+}
+
+func (pg *PostgresDB) GetStorageLayout(address types.Address) (string, error) {
+	return pg.getContractTemplateField("storage_layout", address)
+}
+
+func (pg *PostgresDB) AddTemplate(name string, abi string, layout string) error {
+	_, err := pg.db.Exec(`INSERT INTO templates (name, abi, storage_layout) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET abi = EXCLUDED.abi, storage_layout = EXCLUDED.storage_layout`, name, abi, layout)
+	return err
+}
+
+func (pg *PostgresDB) AssignTemplate(address types.Address, name string) error {
+	_, err := pg.db.Exec(`INSERT INTO contract_templates (address, template_name) VALUES ($1, $2)
+		ON CONFLICT (address) DO UPDATE SET template_name = EXCLUDED.template_name`, address.String(), name)
+	return err
+}
+
+func (pg *PostgresDB) GetTemplates() ([]string, error) {
+	rows, err := pg.db.Query(`SELECT name FROM templates ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templateNames := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		templateNames = append(templateNames, name)
+	}
+	return templateNames, rows.Err()
+}
+
+func (pg *PostgresDB) GetTemplateDetails(templateName string) (*types.Template, error) {
+	template := types.Template{TemplateName: templateName}
+	err := pg.db.QueryRow(`SELECT abi, storage_layout FROM templates WHERE name = $1`, templateName).Scan(&template.ABI, &template.StorageLayout)
+	if err == sql.ErrNoRows {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (pg *PostgresDB) WriteBlocks(blocks []*types.Block) error {
+	return pg.withTx(func(tx *sql.Tx) error {
+		for _, block := range blocks {
+			if block == nil {
+				return errors.New("block is nil")
+			}
+			data, err := json.Marshal(block)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`INSERT INTO blocks (number, hash, parent_hash, timestamp, data) VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT (number) DO UPDATE SET hash = EXCLUDED.hash, parent_hash = EXCLUDED.parent_hash, timestamp = EXCLUDED.timestamp, data = EXCLUDED.data`,
+				block.Number, block.Hash.String(), block.ParentHash.String(), block.Timestamp, data)
+			if err != nil {
+				return err
+			}
+			log.Debug("Block stored", "number", block.Number, "hash", block.Hash.String())
+		}
+		return updateLastPersisted(tx)
+	})
+}
+
+func (pg *PostgresDB) ReadBlock(blockNumber uint64) (*types.Block, error) {
+	var data []byte
+	err := pg.db.QueryRow(`SELECT data FROM blocks WHERE number = $1`, blockNumber).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("block does not exist")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var block types.Block
+	if err := json.Unmarshal(data, &block); err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+func (pg *PostgresDB) GetLastPersistedBlockNumber() (uint64, error) {
+	return getLastPersisted(pg.db)
+}
+
+func (pg *PostgresDB) WriteTransactions(transactions []*types.Transaction) error {
+	return pg.withTx(func(tx *sql.Tx) error {
+		for _, transaction := range transactions {
+			if transaction == nil {
+				return errors.New("transaction is nil")
+			}
+			data, err := json.Marshal(transaction)
+			if err != nil {
+				return err
+			}
+			value := "0"
+			if transaction.Value != nil {
+				value = transaction.Value.String()
+			}
+			_, err = tx.Exec(`INSERT INTO transactions (hash, block_number, tx_index, from_address, to_address, created_contract, status, timestamp, value, data)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+				ON CONFLICT (hash) DO UPDATE SET block_number = EXCLUDED.block_number, tx_index = EXCLUDED.tx_index, status = EXCLUDED.status, timestamp = EXCLUDED.timestamp, value = EXCLUDED.value, data = EXCLUDED.data`,
+				transaction.Hash.String(), transaction.BlockNumber, transaction.Index, transaction.From.String(), transaction.To.String(),
+				transaction.CreatedContract.String(), transaction.Status, transaction.Timestamp, value, data)
+			if err != nil {
+				return err
+			}
+			log.Debug("Transaction stored", "hash", transaction.Hash.Hex())
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) ReadTransaction(hash types.Hash) (*types.Transaction, error) {
+	var data []byte
+	err := pg.db.QueryRow(`SELECT data FROM transactions WHERE hash = $1`, hash.String()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("transaction does not exist")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var transaction types.Transaction
+	if err := json.Unmarshal(data, &transaction); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (pg *PostgresDB) Rollback(blockNumber uint64) error {
+	return pg.withTx(func(tx *sql.Tx) error {
+		// remove blocks and their transactions
+		if _, err := tx.Exec(`DELETE FROM blocks WHERE number > $1`, blockNumber); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`DELETE FROM transactions WHERE block_number > $1`, blockNumber)
+		if err != nil {
+			return err
+		}
+		removedTxs, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`UPDATE meta SET value = $1 WHERE key = $2 AND value > $1`, blockNumber, lastPersistedKey); err != nil {
+			return err
+		}
+
+		// remove indexed data
+		statements := []string{
+			`DELETE FROM address_transactions WHERE block_number > $1`,
+			`DELETE FROM events WHERE block_number > $1`,
+			`DELETE FROM storage WHERE block_number > $1`,
+			`UPDATE contracts SET last_filtered = $1 WHERE last_filtered > $1`,
+			// remove token records, re-opening any that were closed by a removed record
+			`DELETE FROM erc20_balances WHERE block_number > $1`,
+			`DELETE FROM erc721_tokens WHERE held_from > $1`,
+			`UPDATE erc721_tokens SET held_until = NULL WHERE held_until >= $1`,
+			`DELETE FROM erc1155_balances WHERE block_number > $1`,
+			`DELETE FROM erc777_operators WHERE block_number > $1`,
+			`DELETE FROM erc4626_assets WHERE block_number > $1`,
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement, blockNumber); err != nil {
+				return err
+			}
+		}
+
+		log.Info("Rolled back database", "block number", blockNumber, "removed txs", removedTxs)
+		return nil
+	})
+}
+
+func (pg *PostgresDB) Stop() {
+	if err := pg.db.Close(); err != nil {
+		log.Error("Failed to close database", "err", err)
+	}
+}
+
+// internal functions
+
+func (pg *PostgresDB) withTx(fn func(*sql.Tx) error) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (pg *PostgresDB) getContractTemplateField(field string, address types.Address) (string, error) {
+	var value string
+	query := fmt.Sprintf(`SELECT t.%s FROM contract_templates c JOIN templates t ON t.name = c.template_name WHERE c.address = $1`, field)
+	err := pg.db.QueryRow(query, address.String()).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func (pg *PostgresDB) isRegistered(address types.Address) (bool, error) {
+	var registered bool
+	err := pg.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM contracts WHERE address = $1)`, address.String()).Scan(&registered)
+	return registered, err
+}
+
+// queryer is satisfied by both the database and a transaction
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func getLastPersisted(q queryer) (uint64, error) {
+	var lastPersisted uint64
+	err := q.QueryRow(`SELECT value FROM meta WHERE key = $1`, lastPersistedKey).Scan(&lastPersisted)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return lastPersisted, err
+}
+
+// updateLastPersisted moves the last persisted block number forward over any
+// blocks that are now contiguous with it.
+func updateLastPersisted(tx *sql.Tx) error {
+	lastPersisted, err := getLastPersisted(tx)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Query(`SELECT number FROM blocks WHERE number > $1 ORDER BY number`, lastPersisted)
+	if err != nil {
+		return err
+	}
+	updated := lastPersisted
+	for rows.Next() {
+		var number uint64
+		if err := rows.Scan(&number); err != nil {
+			rows.Close()
+			return err
+		}
+		if number != updated+1 {
+			break
+		}
+		updated = number
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if updated == lastPersisted {
+		return nil
+	}
+	log.Debug("Last persisted block", "number", updated)
+	_, err = tx.Exec(`INSERT INTO meta (key, value) VALUES ($1, $2) ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value`, lastPersistedKey, updated)
+	return err
+}
+
+// whereClause collects SQL conditions and their arguments, numbering the
+// placeholders in the order the conditions are added.
+type whereClause struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (w *whereClause) add(condition string, arg interface{}) {
+	w.args = append(w.args, arg)
+	w.conditions = append(w.conditions, strings.Replace(condition, "?", fmt.Sprintf("$%d", len(w.args)), 1))
+}
+
+func (w *whereClause) addRange(column string, begin, end *big.Int) {
+	from, to := blockRange(begin, end)
+	if from > 0 {
+		w.add(column+" >= ?", from)
+	}
+	if to != math.MaxUint64 {
+		w.add(column+" <= ?", to)
+	}
+}
+
+func (w *whereClause) String() string {
+	return strings.Join(w.conditions, " AND ")
+}
+
+// limitClause returns the LIMIT/OFFSET for the requested page, if paging was requested
+func limitClause(pageSize, pageNumber int) string {
+	if pageSize <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d OFFSET %d", pageSize, pageSize*pageNumber)
+}
+
+// blockRange converts the optional begin and end block numbers of a query,
+// where an end block of -1 means no upper limit.
+func blockRange(begin, end *big.Int) (uint64, uint64) {
+	from, to := uint64(0), uint64(math.MaxUint64)
+	if begin != nil && begin.Sign() > 0 {
+		from = begin.Uint64()
+	}
+	if end != nil && end.Sign() >= 0 {
+		to = end.Uint64()
+	}
+	return from, to
+}
@@ -0,0 +1,327 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/database/dbtest"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	testAddress = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	testHolder  = types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f35")
+)
+
+// newMockDB returns a database over a mocked connection, with the schema
+// already at the latest version.
+func newMockDB(t *testing.T) (*PostgresDB, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS schema_migrations`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(len(migrations)))
+
+	db, err := New(conn)
+	assert.Nil(t, err)
+	return db, mock
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS schema_migrations`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(0))
+	for _, m := range migrations {
+		mock.ExpectBegin()
+		for _, statement := range m.statements {
+			mock.ExpectExec(regexp.QuoteMeta(statement)).WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`)).
+			WithArgs(m.version, m.description).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	_, err = New(conn)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateUpToDateDatabase(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrationFailureIsRolledBack(t *testing.T) {
+	conn, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS schema_migrations`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(migrations[0].statements[0])).WillReturnError(errors.New("permission denied"))
+	mock.ExpectRollback()
+
+	_, err = New(conn)
+	assert.EqualError(t, err, "permission denied")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestAddAddresses(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO contracts (address) VALUES ($1) ON CONFLICT DO NOTHING`)).
+		WithArgs(testAddress.String()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO contracts (address) VALUES ($1) ON CONFLICT DO NOTHING`)).
+		WithArgs(testHolder.String()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := db.AddAddresses([]types.Address{testAddress, testHolder})
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestAddAddressFrom(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO contracts (address, last_filtered) VALUES ($1, $2) ON CONFLICT DO NOTHING`)).
+		WithArgs(testAddress.String(), 99).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := db.AddAddressFrom(testAddress, 100)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteAddressNotExist(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM contracts WHERE address = $1`)).
+		WithArgs(testAddress.String()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := db.DeleteAddress(testAddress)
+	assert.EqualError(t, err, "address does not exist")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetTemplateDetailsNotFound(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT abi, storage_layout FROM templates WHERE name = $1`)).
+		WithArgs("missing").WillReturnError(sql.ErrNoRows)
+
+	_, err := db.GetTemplateDetails("missing")
+	assert.Equal(t, database.ErrNotFound, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestReadBlockNotExist(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT data FROM blocks WHERE number = $1`)).
+		WithArgs(1).WillReturnError(sql.ErrNoRows)
+
+	_, err := db.ReadBlock(1)
+	assert.EqualError(t, err, "block does not exist")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWriteBlocksUpdatesLastPersisted(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blocks`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blocks`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT value FROM meta WHERE key = $1`)).
+		WithArgs(lastPersistedKey).WillReturnError(sql.ErrNoRows)
+	// block 4 is not contiguous, so the last persisted block stops at 2
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT number FROM blocks WHERE number > $1 ORDER BY number`)).
+		WithArgs(0).WillReturnRows(sqlmock.NewRows([]string{"number"}).AddRow(1).AddRow(2).AddRow(4))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO meta (key, value) VALUES ($1, $2)`)).
+		WithArgs(lastPersistedKey, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := db.WriteBlocks([]*types.Block{{Number: 1}, {Number: 2}})
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllTransactionsToAddressNotRegistered(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM contracts WHERE address = $1)`)).
+		WithArgs(testAddress.String()).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	_, err := db.GetAllTransactionsToAddress(testAddress, nil)
+	assert.Equal(t, errAddressNotRegistered, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllTransactionsToAddressWithOptions(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM contracts WHERE address = $1)`)).
+		WithArgs(testAddress.String()).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT tx_hash FROM address_transactions WHERE address = $1 AND internal = $2 AND block_number >= $3 AND block_number <= $4 ORDER BY block_number DESC, id DESC LIMIT 10 OFFSET 20`)).
+		WithArgs(testAddress.String(), false, 5, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"tx_hash"}).AddRow("0xb1"))
+
+	options := &types.QueryOptions{
+		BeginBlockNumber: big.NewInt(5),
+		EndBlockNumber:   big.NewInt(50),
+		PageSize:         10,
+		PageNumber:       2,
+	}
+	txs, err := db.GetAllTransactionsToAddress(testAddress, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{types.NewHash("0xb1")}, txs)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllTransactionsToAddressWithValueRange(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM contracts WHERE address = $1)`)).
+		WithArgs(testAddress.String()).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT tx_hash FROM address_transactions WHERE address = $1 AND internal = $2 AND tx_hash IN (SELECT hash FROM transactions WHERE value >= $3) ORDER BY block_number DESC, id DESC LIMIT 10 OFFSET 0`)).
+		WithArgs(testAddress.String(), false, "100000000000000000000").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_hash"}).AddRow("0xb1"))
+
+	minValue, _ := new(big.Int).SetString("100000000000000000000", 10)
+	options := &types.QueryOptions{MinValue: minValue, PageSize: 10}
+	txs, err := db.GetAllTransactionsToAddress(testAddress, options)
+	assert.Nil(t, err)
+	assert.Equal(t, []types.Hash{types.NewHash("0xb1")}, txs)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestGetFailedTransactionsToAddressTotal(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM contracts WHERE address = $1)`)).
+		WithArgs(testAddress.String()).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM address_transactions WHERE address = $1 AND internal = $2 AND tx_hash IN (SELECT hash FROM transactions WHERE status = $3)`)).
+		WithArgs(testAddress.String(), false, false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	total, err := db.GetFailedTransactionsToAddressTotal(testAddress, nil)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, total)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestERC721TokensAfterInvalidToken(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	_, err := db.AllERC721TokensAtBlock(testAddress, 1, &types.TokenQueryOptions{After: "abc"})
+	assert.EqualError(t, err, `could not parse "after" token ID`)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestRollback(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Stop()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM blocks WHERE number > $1`)).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM transactions WHERE block_number > $1`)).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE meta SET value = $1 WHERE key = $2 AND value > $1`)).
+		WithArgs(10, lastPersistedKey).WillReturnResult(sqlmock.NewResult(0, 1))
+	for _, table := range []string{"address_transactions", "events", "storage"} {
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM ` + table + ` WHERE block_number > $1`)).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE contracts SET last_filtered = $1 WHERE last_filtered > $1`)).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM erc20_balances WHERE block_number > $1`)).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM erc721_tokens WHERE held_from > $1`)).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE erc721_tokens SET held_until = NULL WHERE held_until >= $1`)).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, table := range []string{"erc1155_balances", "erc777_operators", "erc4626_assets"} {
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM ` + table + ` WHERE block_number > $1`)).WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectCommit()
+
+	err := db.Rollback(10)
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+// TestConformance runs against a real PostgreSQL server, given by a URL in the
+// POSTGRES_TEST_URL environment variable, e.g. a local or containerised instance.
+// All data in the database is removed before each test.
+func TestConformance(t *testing.T) {
+	url := os.Getenv("POSTGRES_TEST_URL")
+	if url == "" {
+		t.Skip("POSTGRES_TEST_URL not set")
+	}
+
+	dbtest.RunConformanceTests(t, func(t *testing.T) database.Database {
+		conn, err := sql.Open("postgres", url)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		db, err := New(conn)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		// migrating again is a no-op
+		assert.Nil(t, migrate(conn))
+
+		_, err = conn.Exec("TRUNCATE " + strings.Join(migratedTables(), ", ") + " RESTART IDENTITY")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		return db
+	})
+}
+
+var createTable = regexp.MustCompile(`^CREATE TABLE (\w+)`)
+
+// migratedTables returns the tables created by the migrations, so that every
+// table is emptied between conformance tests
+func migratedTables() []string {
+	var tables []string
+	for _, m := range migrations {
+		for _, statement := range m.statements {
+			if match := createTable.FindStringSubmatch(statement); match != nil {
+				tables = append(tables, match[1])
+			}
+		}
+	}
+	return tables
+}
+
+func TestMigratedTables(t *testing.T) {
+	assert.Equal(t, []string{"contracts", "templates", "contract_templates", "blocks", "transactions", "meta",
+		"address_transactions", "events", "storage", "storage_data", "erc20_balances", "erc721_tokens", "webhooks",
+		"erc1155_balances", "erc777_operators", "erc4626_assets"}, migratedTables())
+}
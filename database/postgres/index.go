@@ -0,0 +1,433 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math/big"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+func (pg *PostgresDB) IndexStorage(rawStorage map[types.Address]*types.AccountState, blockNumber uint64) error {
+	return pg.withTx(func(tx *sql.Tx) error {
+		for address, dumpAccount := range rawStorage {
+			_, err := tx.Exec(`INSERT INTO storage (address, block_number, storage_root) VALUES ($1, $2, $3)
+				ON CONFLICT (address, block_number) DO UPDATE SET storage_root = EXCLUDED.storage_root`,
+				address.String(), blockNumber, dumpAccount.Root.String())
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(dumpAccount.Storage)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`INSERT INTO storage_data (address, storage_root, data) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+				address.String(), dumpAccount.Root.String(), data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) IndexBlocks(addresses []types.Address, blocks []*types.BlockWithTransactions) error {
+	return pg.withTx(func(tx *sql.Tx) error {
+		for _, block := range blocks {
+			if err := indexBlock(tx, addresses, block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) SetContractCreationTransaction(creationTxns map[types.Hash][]types.Address) error {
+	return pg.withTx(func(tx *sql.Tx) error {
+		for txHash, addresses := range creationTxns {
+			for _, createdAddress := range addresses {
+				// a deleted address is not updated
+				if _, err := tx.Exec(`UPDATE contracts SET creation_tx = $1 WHERE address = $2`, txHash.String(), createdAddress.String()); err != nil {
+					return err
+				}
+				log.Debug("Indexed address of contract creation", "tx", txHash.Hex(), "contract", createdAddress)
+			}
+		}
+		return nil
+	})
+}
+
+func (pg *PostgresDB) GetContractCreationTransaction(address types.Address) (types.Hash, error) {
+	var hash string
+	err := pg.db.QueryRow(`SELECT creation_tx FROM contracts WHERE address = $1`, address.String()).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return "", errAddressNotRegistered
+	}
+	if err != nil || hash == "" {
+		return "", err
+	}
+	return types.NewHash(hash), nil
+}
+
+func (pg *PostgresDB) GetAllTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	internal := false
+	return pg.getIndexedTransactions(address, transactionsWhereClause(address, &internal, options), options)
+}
+
+func (pg *PostgresDB) GetTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	internal := false
+	return pg.countIndexed("address_transactions", address, transactionsWhereClause(address, &internal, options))
+}
+
+func (pg *PostgresDB) GetAllTransactionsInternalToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	internal := true
+	return pg.getIndexedTransactions(address, transactionsWhereClause(address, &internal, options), options)
+}
+
+func (pg *PostgresDB) GetTransactionsInternalToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	internal := true
+	return pg.countIndexed("address_transactions", address, transactionsWhereClause(address, &internal, options))
+}
+
+func (pg *PostgresDB) GetAllFailedTransactionsToAddress(address types.Address, options *types.QueryOptions) ([]types.Hash, error) {
+	return pg.getIndexedTransactions(address, failedWhereClause(address, options), options)
+}
+
+func (pg *PostgresDB) GetFailedTransactionsToAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	return pg.countIndexed("address_transactions", address, failedWhereClause(address, options))
+}
+
+func (pg *PostgresDB) GetAllEventsFromAddress(address types.Address, options *types.QueryOptions) ([]*types.Event, error) {
+	if registered, err := pg.isRegistered(address); err != nil {
+		return nil, err
+	} else if !registered {
+		return nil, errAddressNotRegistered
+	}
+
+	where := indexedWhereClause(address, nil, options)
+	rows, err := pg.db.Query(`SELECT data FROM events WHERE `+where.String()+` ORDER BY block_number DESC, id DESC`+queryLimitClause(options), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*types.Event
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var event types.Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func (pg *PostgresDB) GetEventsFromAddressTotal(address types.Address, options *types.QueryOptions) (uint64, error) {
+	return pg.countIndexed("events", address, indexedWhereClause(address, nil, options))
+}
+
+func (pg *PostgresDB) GetStorage(address types.Address, blockNumber uint64) (*types.StorageResult, error) {
+	if registered, err := pg.isRegistered(address); err != nil {
+		return nil, err
+	} else if !registered {
+		return nil, errAddressNotRegistered
+	}
+
+	result := &types.StorageResult{
+		Storage:     make(map[types.Hash]string),
+		StorageRoot: types.NewHash(""),
+		BlockNumber: blockNumber,
+	}
+	var root string
+	var data []byte
+	err := pg.db.QueryRow(`SELECT s.storage_root, d.data FROM storage s
+		LEFT JOIN storage_data d ON d.address = s.address AND d.storage_root = s.storage_root
+		WHERE s.address = $1 AND s.block_number = $2`, address.String(), blockNumber).Scan(&root, &data)
+	if err == sql.ErrNoRows {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.StorageRoot = types.NewHash(root)
+	if data != nil {
+		if err := json.Unmarshal(data, &result.Storage); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (pg *PostgresDB) GetStorageWithOptions(address types.Address, options *types.PageOptions) ([]*types.StorageResult, error) {
+	if registered, err := pg.isRegistered(address); err != nil {
+		return nil, err
+	} else if !registered {
+		return nil, errAddressNotRegistered
+	}
+
+	where := storageWhereClause(address, options.BeginBlockNumber, options.EndBlockNumber)
+	rows, err := pg.db.Query(`SELECT s.block_number, s.storage_root, d.data FROM storage s
+		LEFT JOIN storage_data d ON d.address = s.address AND d.storage_root = s.storage_root
+		WHERE `+where.String()+` ORDER BY s.block_number DESC`+limitClause(options.PageSize, options.PageNumber), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*types.StorageResult
+	for rows.Next() {
+		var blockNumber uint64
+		var root string
+		var data []byte
+		if err := rows.Scan(&blockNumber, &root, &data); err != nil {
+			return nil, err
+		}
+		result := &types.StorageResult{
+			StorageRoot: types.NewHash(root),
+			BlockNumber: blockNumber,
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, &result.Storage); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+func (pg *PostgresDB) GetStorageTotal(address types.Address, options *types.PageOptions) (uint64, error) {
+	if registered, err := pg.isRegistered(address); err != nil {
+		return 0, err
+	} else if !registered {
+		return 0, errAddressNotRegistered
+	}
+
+	var total uint64
+	where := storageWhereClause(address, options.BeginBlockNumber, options.EndBlockNumber)
+	err := pg.db.QueryRow(`SELECT COUNT(*) FROM storage s WHERE `+where.String(), where.args...).Scan(&total)
+	return total, err
+}
+
+func (pg *PostgresDB) GetStorageRanges(contract types.Address, options *types.PageOptions) ([]types.RangeResult, error) {
+	if registered, err := pg.isRegistered(contract); err != nil {
+		return nil, err
+	} else if !registered {
+		return nil, errAddressNotRegistered
+	}
+
+	start, end := blockRange(options.BeginBlockNumber, options.EndBlockNumber)
+	if big.NewInt(-1).Cmp(options.EndBlockNumber) == 0 {
+		lastFiltered, err := pg.GetLastFiltered(contract)
+		if err != nil {
+			return nil, err
+		}
+		end = lastFiltered
+	}
+
+	// every 1000th block with storage, newest first, marks the start of a range
+	rows, err := pg.db.Query(`SELECT block_number FROM (
+			SELECT block_number, ROW_NUMBER() OVER (ORDER BY block_number DESC) AS position
+			FROM storage WHERE address = $1 AND block_number >= $2 AND block_number <= $3
+		) numbered WHERE position % 1000 = 0 ORDER BY block_number DESC`, contract.String(), start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []types.RangeResult
+	lastEnd := end
+	for rows.Next() {
+		var blockNumber uint64
+		if err := rows.Scan(&blockNumber); err != nil {
+			return nil, err
+		}
+		results = append(results, types.RangeResult{Start: blockNumber, End: lastEnd, ResultCount: 1000})
+		lastEnd = blockNumber - 1
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var remaining int
+	err = pg.db.QueryRow(`SELECT COUNT(*) FROM storage WHERE address = $1 AND block_number >= $2 AND block_number <= $3`,
+		contract.String(), start, lastEnd).Scan(&remaining)
+	if err != nil {
+		return nil, err
+	}
+	results = append(results, types.RangeResult{Start: start, End: lastEnd, ResultCount: remaining})
+	return results, nil
+}
+
+func (pg *PostgresDB) GetLastFiltered(address types.Address) (uint64, error) {
+	var lastFiltered uint64
+	err := pg.db.QueryRow(`SELECT last_filtered FROM contracts WHERE address = $1`, address.String()).Scan(&lastFiltered)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return lastFiltered, err
+}
+
+// internal functions
+
+func indexBlock(tx *sql.Tx, addresses []types.Address, block *types.BlockWithTransactions) error {
+	// filter out registered and unfiltered address only
+	filteredAddresses := map[types.Address]bool{}
+	for _, address := range addresses {
+		var lastFiltered uint64
+		err := tx.QueryRow(`SELECT last_filtered FROM contracts WHERE address = $1`, address.String()).Scan(&lastFiltered)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if lastFiltered < block.Number {
+			filteredAddresses[address] = true
+			log.Info("Index registered address ", "address", address.Hex(), "blocknumber", block.Number)
+		}
+	}
+
+	// index transactions and events
+	for _, transaction := range block.Transactions {
+		if err := indexTransaction(tx, filteredAddresses, block.Number, transaction); err != nil {
+			return err
+		}
+	}
+
+	for address := range filteredAddresses {
+		if _, err := tx.Exec(`UPDATE contracts SET last_filtered = $1 WHERE address = $2`, block.Number, address.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func indexTransaction(tx *sql.Tx, filteredAddresses map[types.Address]bool, blockNumber uint64, transaction *types.Transaction) error {
+	const insertTransaction = `INSERT INTO address_transactions (address, internal, tx_hash, block_number, timestamp) VALUES ($1, $2, $3, $4, $5)`
+
+	if filteredAddresses[transaction.To] {
+		if _, err := tx.Exec(insertTransaction, transaction.To.String(), false, transaction.Hash.String(), blockNumber, transaction.Timestamp); err != nil {
+			return err
+		}
+		log.Debug("Indexed tx recipient", "tx", transaction.Hash.Hex(), "recipient", transaction.To.Hex())
+	}
+
+	for _, internalCall := range transaction.InternalCalls {
+		if filteredAddresses[internalCall.To] {
+			if _, err := tx.Exec(insertTransaction, internalCall.To.String(), true, transaction.Hash.String(), blockNumber, transaction.Timestamp); err != nil {
+				return err
+			}
+			log.Debug("Indexed transactions internal calls", "tx", transaction.Hash.Hex(), "internal-recipient", internalCall.To.Hex())
+		}
+	}
+	// Index events emitted by the given address
+	for _, event := range transaction.Events {
+		if filteredAddresses[event.Address] {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(`INSERT INTO events (address, block_number, timestamp, data) VALUES ($1, $2, $3, $4)`,
+				event.Address.String(), blockNumber, event.Timestamp, data)
+			if err != nil {
+				return err
+			}
+			log.Debug("Indexed emitted event", "tx", event.TransactionHash.Hex(), "address", event.Address.Hex())
+		}
+	}
+	return nil
+}
+
+func (pg *PostgresDB) getIndexedTransactions(address types.Address, where *whereClause, options *types.QueryOptions) ([]types.Hash, error) {
+	if registered, err := pg.isRegistered(address); err != nil {
+		return nil, err
+	} else if !registered {
+		return nil, errAddressNotRegistered
+	}
+
+	rows, err := pg.db.Query(`SELECT tx_hash FROM address_transactions WHERE `+where.String()+` ORDER BY block_number DESC, id DESC`+queryLimitClause(options), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txs []types.Hash
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		txs = append(txs, types.NewHash(hash))
+	}
+	return txs, rows.Err()
+}
+
+func (pg *PostgresDB) countIndexed(table string, address types.Address, where *whereClause) (uint64, error) {
+	if registered, err := pg.isRegistered(address); err != nil {
+		return 0, err
+	} else if !registered {
+		return 0, errAddressNotRegistered
+	}
+
+	var total uint64
+	err := pg.db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE `+where.String(), where.args...).Scan(&total)
+	return total, err
+}
+
+func indexedWhereClause(address types.Address, internal *bool, options *types.QueryOptions) *whereClause {
+	where := &whereClause{}
+	where.add("address = ?", address.String())
+	if internal != nil {
+		where.add("internal = ?", *internal)
+	}
+	if options != nil {
+		where.addRange("block_number", options.BeginBlockNumber, options.EndBlockNumber)
+		where.addRange("timestamp", options.BeginTimestamp, options.EndTimestamp)
+	}
+	return where
+}
+
+// transactionsWhereClause selects the transactions indexed against the address,
+// limited to those sending a value within the range of the options
+func transactionsWhereClause(address types.Address, internal *bool, options *types.QueryOptions) *whereClause {
+	where := indexedWhereClause(address, internal, options)
+	if !options.HasValueRange() {
+		return where
+	}
+	if options.MinValue != nil {
+		where.add("tx_hash IN (SELECT hash FROM transactions WHERE value >= ?)", options.MinValue.String())
+	}
+	if options.MaxValue != nil {
+		where.add("tx_hash IN (SELECT hash FROM transactions WHERE value <= ?)", options.MaxValue.String())
+	}
+	return where
+}
+
+// failedWhereClause selects the transactions sent to the address that failed,
+// whose status is only stored with the transaction itself
+func failedWhereClause(address types.Address, options *types.QueryOptions) *whereClause {
+	internal := false
+	where := transactionsWhereClause(address, &internal, options)
+	where.add("tx_hash IN (SELECT hash FROM transactions WHERE status = ?)", false)
+	return where
+}
+
+func storageWhereClause(address types.Address, begin, end *big.Int) *whereClause {
+	where := &whereClause{}
+	where.add("s.address = ?", address.String())
+	where.addRange("s.block_number", begin, end)
+	return where
+}
+
+func queryLimitClause(options *types.QueryOptions) string {
+	if options == nil {
+		return ""
+	}
+	return limitClause(options.PageSize, options.PageNumber)
+}
@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"quorumengineering/quorum-report/log"
+)
+
+// migration is a single versioned change to the schema. Once released, a
+// migration must never be changed; changes to the schema are made by adding a
+// new migration with the next version.
+type migration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+var migrations = []migration{
+	{
+		version:     1,
+		description: "initial schema",
+		statements: []string{
+			// registered contract data
+			`CREATE TABLE contracts (
+				address             TEXT PRIMARY KEY,
+				creation_tx         TEXT NOT NULL DEFAULT '',
+				last_filtered       BIGINT NOT NULL DEFAULT 0
+			)`,
+			`CREATE TABLE templates (
+				name                TEXT PRIMARY KEY,
+				abi                 TEXT NOT NULL,
+				storage_layout      TEXT NOT NULL
+			)`,
+			`CREATE TABLE contract_templates (
+				address             TEXT PRIMARY KEY,
+				template_name       TEXT NOT NULL
+			)`,
+			// blockchain data
+			`CREATE TABLE blocks (
+				number              BIGINT PRIMARY KEY,
+				hash                TEXT NOT NULL,
+				parent_hash         TEXT NOT NULL,
+				timestamp           BIGINT NOT NULL,
+				data                JSONB NOT NULL
+			)`,
+			`CREATE TABLE transactions (
+				hash                TEXT PRIMARY KEY,
+				block_number        BIGINT NOT NULL,
+				tx_index            BIGINT NOT NULL,
+				from_address        TEXT NOT NULL,
+				to_address          TEXT NOT NULL,
+				created_contract    TEXT NOT NULL,
+				status              BOOLEAN NOT NULL,
+				timestamp           BIGINT NOT NULL,
+				data                JSONB NOT NULL
+			)`,
+			`CREATE INDEX transactions_block_number_idx ON transactions (block_number)`,
+			`CREATE TABLE meta (
+				key                 TEXT PRIMARY KEY,
+				value               BIGINT NOT NULL
+			)`,
+			// index data
+			`CREATE TABLE address_transactions (
+				id                  BIGSERIAL PRIMARY KEY,
+				address             TEXT NOT NULL,
+				internal            BOOLEAN NOT NULL,
+				tx_hash             TEXT NOT NULL,
+				block_number        BIGINT NOT NULL,
+				timestamp           BIGINT NOT NULL
+			)`,
+			`CREATE INDEX address_transactions_address_idx ON address_transactions (address, internal, block_number)`,
+			`CREATE TABLE events (
+				id                  BIGSERIAL PRIMARY KEY,
+				address             TEXT NOT NULL,
+				block_number        BIGINT NOT NULL,
+				timestamp           BIGINT NOT NULL,
+				data                JSONB NOT NULL
+			)`,
+			`CREATE INDEX events_address_idx ON events (address, block_number)`,
+			`CREATE TABLE storage (
+				address             TEXT NOT NULL,
+				block_number        BIGINT NOT NULL,
+				storage_root        TEXT NOT NULL,
+				PRIMARY KEY (address, block_number)
+			)`,
+			`CREATE TABLE storage_data (
+				address             TEXT NOT NULL,
+				storage_root        TEXT NOT NULL,
+				data                JSONB NOT NULL,
+				PRIMARY KEY (address, storage_root)
+			)`,
+			// token data
+			`CREATE TABLE erc20_balances (
+				contract            TEXT NOT NULL,
+				holder              TEXT NOT NULL,
+				block_number        BIGINT NOT NULL,
+				amount              NUMERIC(78, 0) NOT NULL,
+				PRIMARY KEY (contract, holder, block_number)
+			)`,
+			`CREATE TABLE erc721_tokens (
+				contract            TEXT NOT NULL,
+				token               NUMERIC(78, 0) NOT NULL,
+				holder              TEXT NOT NULL,
+				held_from           BIGINT NOT NULL,
+				held_until          BIGINT,
+				PRIMARY KEY (contract, token, held_from)
+			)`,
+		},
+	},
+	{
+		version:     2,
+		description: "webhooks",
+		statements: []string{
+			`CREATE TABLE webhooks (
+				id                  TEXT PRIMARY KEY,
+				address             TEXT NOT NULL,
+				kind                TEXT NOT NULL,
+				event_signature     TEXT NOT NULL DEFAULT '',
+				url                 TEXT NOT NULL,
+				secret              TEXT NOT NULL DEFAULT '',
+				cursor_block        BIGINT NOT NULL DEFAULT 0,
+				cursor_tx_index     BIGINT NOT NULL DEFAULT 0,
+				cursor_event_index  BIGINT NOT NULL DEFAULT 0
+			)`,
+		},
+	},
+	{
+		version:     3,
+		description: "transaction values",
+		statements: []string{
+			`ALTER TABLE transactions ADD COLUMN value NUMERIC(78, 0) NOT NULL DEFAULT 0`,
+			`UPDATE transactions SET value = (data->>'value')::NUMERIC WHERE data->>'value' IS NOT NULL`,
+		},
+	},
+	{
+		version:     4,
+		description: "erc1155 balances",
+		statements: []string{
+			`CREATE TABLE erc1155_balances (
+				contract            TEXT NOT NULL,
+				token               NUMERIC(78, 0) NOT NULL,
+				holder              TEXT NOT NULL,
+				block_number        BIGINT NOT NULL,
+				amount              NUMERIC(78, 0) NOT NULL,
+				PRIMARY KEY (contract, token, holder, block_number)
+			)`,
+			`CREATE INDEX erc1155_balances_holder_idx ON erc1155_balances (contract, holder, block_number)`,
+		},
+	},
+	{
+		version:     5,
+		description: "erc777 operators and erc4626 assets",
+		statements: []string{
+			`CREATE TABLE erc777_operators (
+				contract            TEXT NOT NULL,
+				holder              TEXT NOT NULL,
+				operator            TEXT NOT NULL,
+				block_number        BIGINT NOT NULL,
+				authorized          BOOLEAN NOT NULL,
+				PRIMARY KEY (contract, holder, operator, block_number)
+			)`,
+			`CREATE TABLE erc4626_assets (
+				contract            TEXT NOT NULL,
+				block_number        BIGINT NOT NULL,
+				total_assets        NUMERIC(78, 0) NOT NULL,
+				total_supply        NUMERIC(78, 0) NOT NULL,
+				PRIMARY KEY (contract, block_number)
+			)`,
+		},
+	},
+}
+
+// migrate brings the schema up to the latest version, applying each pending
+// migration in its own transaction.
+func migrate(db *sql.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		log.Info("Applying database migration", "version", m.version, "description", m.description)
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, statement := range m.statements {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`, m.version, m.description); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
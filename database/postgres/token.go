@@ -0,0 +1,342 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"math/big"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+// ERC20 balances are stored as a row for each block the balance changed at.
+// ERC721 tokens are stored as a row for each holder of the token, recording the
+// blocks the token was held from and until.
+// ERC1155 balances are stored as a row for each block the balance of a holder of a
+// token changed at.
+// ERC777 operators are stored as a row for each block an operator of a holder was
+// authorized or revoked at.
+// ERC4626 vault totals are stored as a row for each block they were recorded at.
+
+var zeroAddress = types.NewAddress("")
+
+// tokensAtBlockQuery selects the latest holder of each token of a contract at a
+// block, skipping tokens that have since been moved on.
+const tokensAtBlockQuery = `SELECT DISTINCT ON (token) token, holder, held_from, held_until FROM erc721_tokens
+	WHERE contract = $1 AND held_from <= $2 ORDER BY token, held_from DESC`
+
+// erc1155BalancesAtBlockQuery selects the latest balance at a block of each holder
+// of each token of a contract matching the condition, which is given the third
+// argument.
+func erc1155BalancesAtBlockQuery(condition string) string {
+	return `SELECT DISTINCT ON (token, holder) token, holder, block_number, amount FROM erc1155_balances
+	WHERE contract = $1 AND block_number <= $2 AND ` + condition + ` ORDER BY token, holder, block_number DESC`
+}
+
+func (pg *PostgresDB) RecordNewERC20Balance(contract types.Address, holder types.Address, block uint64, amount *big.Int) error {
+	_, err := pg.db.Exec(`INSERT INTO erc20_balances (contract, holder, block_number, amount) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (contract, holder, block_number) DO UPDATE SET amount = EXCLUDED.amount`,
+		contract.String(), holder.String(), block, amount.String())
+	return err
+}
+
+func (pg *PostgresDB) GetERC20Balance(contract types.Address, holder types.Address, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return pg.balanceHistory("erc20_balances", options, []string{"contract", "holder"}, contract.String(), holder.String())
+}
+
+func (pg *PostgresDB) GetAllTokenHolders(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	where := &whereClause{}
+	where.add("contract = ?", contract.String())
+	where.add("block_number <= ?", block)
+	where.add("holder <> ?", zeroAddress.String())
+	if options.After != "" {
+		after := types.NewAddress(options.After)
+		where.add(`holder COLLATE "C" > ?`, after.String())
+	}
+	return pg.queryHolders(`SELECT DISTINCT holder COLLATE "C" AS holder FROM erc20_balances WHERE `+where.String()+
+		` ORDER BY holder`+limitClause(options.PageSize, 0), where.args...)
+}
+
+func (pg *PostgresDB) RecordERC721Token(contract types.Address, holder types.Address, block uint64, tokenId *big.Int) error {
+	return pg.withTx(func(tx *sql.Tx) error {
+		// close off the previous holder's ownership
+		if block > 0 {
+			_, err := tx.Exec(`UPDATE erc721_tokens SET held_until = $3 WHERE contract = $1 AND token = $2 AND held_from = (
+					SELECT MAX(held_from) FROM erc721_tokens WHERE contract = $1 AND token = $2 AND held_from <= $3
+				)`, contract.String(), tokenId.String(), block-1)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(`INSERT INTO erc721_tokens (contract, token, holder, held_from, held_until) VALUES ($1, $2, $3, $4, NULL)
+			ON CONFLICT (contract, token, held_from) DO UPDATE SET holder = EXCLUDED.holder, held_until = NULL`,
+			contract.String(), tokenId.String(), holder.String(), block)
+		return err
+	})
+}
+
+func (pg *PostgresDB) ERC721TokenByTokenID(contract types.Address, block uint64, tokenId *big.Int) (*types.ERC721Token, error) {
+	token := types.ERC721Token{Contract: contract, Token: tokenId.String()}
+	var holder string
+	var heldUntil sql.NullInt64
+	err := pg.db.QueryRow(`SELECT holder, held_from, held_until FROM erc721_tokens WHERE contract = $1 AND token = $2 AND held_from <= $3
+		ORDER BY held_from DESC LIMIT 1`, contract.String(), tokenId.String(), block).Scan(&holder, &token.HeldFrom, &heldUntil)
+	if err == sql.ErrNoRows {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	token.Holder = types.NewAddress(holder)
+	token.HeldUntil = nullableUint64(heldUntil)
+	return &token, nil
+}
+
+func (pg *PostgresDB) ERC721TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return pg.erc721TokensAtBlock(contract, &holder, block, options)
+}
+
+func (pg *PostgresDB) AllERC721TokensAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	return pg.erc721TokensAtBlock(contract, nil, block, options)
+}
+
+func (pg *PostgresDB) AllHoldersAtBlock(contract types.Address, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	query := `SELECT DISTINCT holder COLLATE "C" AS holder FROM (` + tokensAtBlockQuery + `) latest
+		WHERE (held_until IS NULL OR held_until >= $2)`
+	args := []interface{}{contract.String(), block}
+	if options.After != "" {
+		after := types.NewAddress(options.After)
+		args = append(args, after.String())
+		query += ` AND holder COLLATE "C" > $3`
+	}
+	return pg.queryHolders(query+` ORDER BY holder`+limitClause(options.PageSize, 0), args...)
+}
+
+func (pg *PostgresDB) RecordERC1155Balance(contract types.Address, holder types.Address, block uint64, tokenId *big.Int, amount *big.Int) error {
+	_, err := pg.db.Exec(`INSERT INTO erc1155_balances (contract, token, holder, block_number, amount) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (contract, token, holder, block_number) DO UPDATE SET amount = EXCLUDED.amount`,
+		contract.String(), tokenId.String(), holder.String(), block, amount.String())
+	return err
+}
+
+func (pg *PostgresDB) GetERC1155Balance(contract types.Address, holder types.Address, tokenId *big.Int, options *types.TokenQueryOptions) (map[uint64]*big.Int, error) {
+	return pg.balanceHistory("erc1155_balances", options, []string{"contract", "token", "holder"}, contract.String(), tokenId.String(), holder.String())
+}
+
+func (pg *PostgresDB) ERC1155HoldersAtBlock(contract types.Address, tokenId *big.Int, block uint64, options *types.TokenQueryOptions) ([]types.Address, error) {
+	query := `SELECT holder COLLATE "C" AS holder FROM (` + erc1155BalancesAtBlockQuery("token = $3") + `) latest
+		WHERE amount <> 0`
+	args := []interface{}{contract.String(), block, tokenId.String()}
+	if options.After != "" {
+		after := types.NewAddress(options.After)
+		args = append(args, after.String())
+		query += ` AND holder COLLATE "C" > $4`
+	}
+	return pg.queryHolders(query+` ORDER BY holder`+limitClause(options.PageSize, 0), args...)
+}
+
+func (pg *PostgresDB) ERC1155TokensForAccountAtBlock(contract types.Address, holder types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC1155Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	query := `SELECT token::TEXT, amount::TEXT, block_number FROM (` + erc1155BalancesAtBlockQuery("holder = $3") + `) latest
+		WHERE amount <> 0 AND token > $4 ORDER BY token` + limitClause(options.PageSize, options.PageNumber)
+	rows, err := pg.db.Query(query, contract.String(), block, holder.String(), startTokenId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.ERC1155Token, 0)
+	for rows.Next() {
+		token := types.ERC1155Token{Contract: contract, Holder: holder}
+		var value string
+		if err := rows.Scan(&token.Token, &value, &token.HeldFrom); err != nil {
+			return nil, err
+		}
+		if token.Amount, err = parseAmount(value); err != nil {
+			return nil, err
+		}
+		result = append(result, token)
+	}
+	return result, rows.Err()
+}
+
+func (pg *PostgresDB) RecordERC777Operator(contract types.Address, holder types.Address, operator types.Address, block uint64, authorized bool) error {
+	_, err := pg.db.Exec(`INSERT INTO erc777_operators (contract, holder, operator, block_number, authorized) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (contract, holder, operator, block_number) DO UPDATE SET authorized = EXCLUDED.authorized`,
+		contract.String(), holder.String(), operator.String(), block, authorized)
+	return err
+}
+
+func (pg *PostgresDB) ERC777OperatorsAtBlock(contract types.Address, holder types.Address, block uint64) ([]types.Address, error) {
+	return pg.queryHolders(`SELECT operator COLLATE "C" AS operator FROM (
+			SELECT DISTINCT ON (operator) operator, authorized FROM erc777_operators
+			WHERE contract = $1 AND holder = $2 AND block_number <= $3 ORDER BY operator, block_number DESC
+		) latest WHERE authorized ORDER BY operator`, contract.String(), holder.String(), block)
+}
+
+func (pg *PostgresDB) RecordERC4626Assets(contract types.Address, block uint64, totalAssets *big.Int, totalSupply *big.Int) error {
+	_, err := pg.db.Exec(`INSERT INTO erc4626_assets (contract, block_number, total_assets, total_supply) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (contract, block_number) DO UPDATE SET total_assets = EXCLUDED.total_assets, total_supply = EXCLUDED.total_supply`,
+		contract.String(), block, totalAssets.String(), totalSupply.String())
+	return err
+}
+
+func (pg *PostgresDB) GetERC4626AssetsAtBlock(contract types.Address, block uint64) (*types.ERC4626Assets, error) {
+	assets := types.ERC4626Assets{Contract: contract}
+	var totalAssets, totalSupply string
+	err := pg.db.QueryRow(`SELECT block_number, total_assets::TEXT, total_supply::TEXT FROM erc4626_assets
+		WHERE contract = $1 AND block_number <= $2 ORDER BY block_number DESC LIMIT 1`, contract.String(), block).Scan(&assets.BlockNumber, &totalAssets, &totalSupply)
+	if err == sql.ErrNoRows {
+		return nil, database.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if assets.TotalAssets, err = parseAmount(totalAssets); err != nil {
+		return nil, err
+	}
+	if assets.TotalSupply, err = parseAmount(totalSupply); err != nil {
+		return nil, err
+	}
+	return &assets, nil
+}
+
+// internal functions
+
+// balanceHistory returns the balances within the block range of the options from a
+// table of balance changes, for the rows whose key columns have the given values
+func (pg *PostgresDB) balanceHistory(table string, options *types.TokenQueryOptions, keyColumns []string, keys ...interface{}) (map[uint64]*big.Int, error) {
+	fromBlock, _ := blockRange(options.BeginBlockNumber, options.EndBlockNumber)
+
+	where := &whereClause{}
+	for i, column := range keyColumns {
+		where.add(column+" = ?", keys[i])
+	}
+	where.addRange("block_number", options.BeginBlockNumber, options.EndBlockNumber)
+	rows, err := pg.db.Query(`SELECT block_number, amount::TEXT FROM `+table+` WHERE `+where.String(), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balanceMap := make(map[uint64]*big.Int)
+	for rows.Next() {
+		var blockNumber uint64
+		var value string
+		if err := rows.Scan(&blockNumber, &value); err != nil {
+			return nil, err
+		}
+		amount, err := parseAmount(value)
+		if err != nil {
+			return nil, err
+		}
+		balanceMap[blockNumber] = amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// the balance at the start of the range comes from the last change before it
+	if _, ok := balanceMap[fromBlock]; !ok {
+		previous := &whereClause{}
+		for i, column := range keyColumns {
+			previous.add(column+" = ?", keys[i])
+		}
+		previous.add("block_number < ?", fromBlock)
+		var value string
+		err := pg.db.QueryRow(`SELECT amount::TEXT FROM `+table+` WHERE `+previous.String()+
+			` ORDER BY block_number DESC LIMIT 1`, previous.args...).Scan(&value)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err == nil {
+			amount, err := parseAmount(value)
+			if err != nil {
+				return nil, err
+			}
+			balanceMap[fromBlock] = amount
+		}
+	}
+	return balanceMap, nil
+}
+
+func (pg *PostgresDB) erc721TokensAtBlock(contract types.Address, holder *types.Address, block uint64, options *types.TokenQueryOptions) ([]types.ERC721Token, error) {
+	startTokenId := big.NewInt(-1)
+	if options.After != "" {
+		parsed, success := new(big.Int).SetString(options.After, 10)
+		if !success {
+			return nil, errors.New(`could not parse "after" token ID`)
+		}
+		startTokenId = parsed
+	}
+
+	query := `SELECT token::TEXT, holder, held_from, held_until FROM (` + tokensAtBlockQuery + `) latest
+		WHERE (held_until IS NULL OR held_until >= $2) AND token > $3`
+	args := []interface{}{contract.String(), block, startTokenId.String()}
+	if holder != nil {
+		args = append(args, holder.String())
+		query += ` AND holder = $4`
+	}
+	rows, err := pg.db.Query(query+` ORDER BY token`+limitClause(options.PageSize, options.PageNumber), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]types.ERC721Token, 0)
+	for rows.Next() {
+		token := types.ERC721Token{Contract: contract}
+		var tokenHolder string
+		var heldUntil sql.NullInt64
+		if err := rows.Scan(&token.Token, &tokenHolder, &token.HeldFrom, &heldUntil); err != nil {
+			return nil, err
+		}
+		token.Holder = types.NewAddress(tokenHolder)
+		token.HeldUntil = nullableUint64(heldUntil)
+		result = append(result, token)
+	}
+	return result, rows.Err()
+}
+
+func (pg *PostgresDB) queryHolders(query string, args ...interface{}) ([]types.Address, error) {
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holders := make([]types.Address, 0)
+	for rows.Next() {
+		var holder string
+		if err := rows.Scan(&holder); err != nil {
+			return nil, err
+		}
+		holders = append(holders, types.NewAddress(holder))
+	}
+	return holders, rows.Err()
+}
+
+func nullableUint64(value sql.NullInt64) *uint64 {
+	if !value.Valid {
+		return nil
+	}
+	result := uint64(value.Int64)
+	return &result
+}
+
+func parseAmount(value string) (*big.Int, error) {
+	amount, success := new(big.Int).SetString(value, 10)
+	if !success {
+		return nil, errors.New("could not parse token value")
+	}
+	return amount, nil
+}
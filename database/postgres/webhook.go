@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"quorumengineering/quorum-report/database"
+	"quorumengineering/quorum-report/types"
+)
+
+func (pg *PostgresDB) AddWebhook(webhook *types.Webhook) error {
+	// the delivery cursor of an existing webhook is left as it is
+	_, err := pg.db.Exec(`INSERT INTO webhooks (id, address, kind, event_signature, url, secret, cursor_block, cursor_tx_index, cursor_event_index)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE SET address = EXCLUDED.address, kind = EXCLUDED.kind, event_signature = EXCLUDED.event_signature,
+			url = EXCLUDED.url, secret = EXCLUDED.secret`,
+		webhook.ID, webhook.Address.String(), webhook.Kind, webhook.EventSignature, webhook.URL, webhook.Secret,
+		webhook.Cursor.BlockNumber, webhook.Cursor.TransactionIndex, webhook.Cursor.EventIndex)
+	return err
+}
+
+func (pg *PostgresDB) DeleteWebhook(id string) error {
+	result, err := pg.db.Exec(`DELETE FROM webhooks WHERE id = $1`, id)
+	return notFoundIfUnchanged(result, err)
+}
+
+func (pg *PostgresDB) GetWebhooks() ([]*types.Webhook, error) {
+	rows, err := pg.db.Query(`SELECT id, address, kind, event_signature, url, secret, cursor_block, cursor_tx_index, cursor_event_index
+		FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]*types.Webhook, 0)
+	for rows.Next() {
+		var webhook types.Webhook
+		var address string
+		err := rows.Scan(&webhook.ID, &address, &webhook.Kind, &webhook.EventSignature, &webhook.URL, &webhook.Secret,
+			&webhook.Cursor.BlockNumber, &webhook.Cursor.TransactionIndex, &webhook.Cursor.EventIndex)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Address = types.NewAddress(address)
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (pg *PostgresDB) SetWebhookCursor(id string, cursor types.WebhookCursor) error {
+	result, err := pg.db.Exec(`UPDATE webhooks SET cursor_block = $2, cursor_tx_index = $3, cursor_event_index = $4 WHERE id = $1`,
+		id, cursor.BlockNumber, cursor.TransactionIndex, cursor.EventIndex)
+	return notFoundIfUnchanged(result, err)
+}
+
+// internal functions
+
+func notFoundIfUnchanged(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
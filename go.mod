@@ -3,21 +3,27 @@ module quorumengineering/quorum-report
 go 1.13
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.4.1
 	github.com/bluele/gcache v0.0.0-20190518031135-bc40bd653833
 	github.com/elastic/go-elasticsearch/v7 v7.5.1-0.20200409075911-14061b088525
 	github.com/gin-gonic/gin v1.6.3
+	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/golang/mock v1.4.4
 	github.com/gorilla/rpc v1.2.1-0.20190627040322-27d3316e212c
 	github.com/gorilla/websocket v1.4.2
+	github.com/lib/pq v1.7.0
 	github.com/machinebox/graphql v0.2.2
 	github.com/matryer/is v1.3.0 // indirect
 	github.com/mitchellh/mapstructure v1.3.3
 	github.com/naoina/go-stringutil v0.1.0 // indirect
 	github.com/naoina/toml v0.1.2-0.20170918210437-9fafd6967416
 	github.com/pkg/errors v0.9.1 // indirect
+	github.com/prometheus/client_golang v1.11.1
 	github.com/rakyll/statik v0.1.7
 	github.com/rs/cors v1.7.0
 	github.com/sirupsen/logrus v1.6.0
 	github.com/stretchr/testify v1.4.0
-	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/oauth2 v0.0.0-20200902213428-5d25da1a8d43
 )
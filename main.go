@@ -92,7 +92,7 @@ func run() error {
 	log.Debug("UI Port", "port number", config.Server.UIPort)
 	if config.Server.UIPort > 0 {
 		// start a light weighted sample sample ui
-		uiHandler := ui.NewUIHandler(config.Server.UIPort)
+		uiHandler := ui.NewUIHandler(config.Server.UIPort, config.Server.TLS)
 		if err := uiHandler.Start(); err != nil {
 			log.Error("Unable to start the UI", "err", err)
 			return err
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
+)
+
+var (
+	chainHeadDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sync", "chain_head_block"),
+		"Latest block number seen from Quorum.", nil, nil,
+	)
+	lastPersistedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sync", "last_persisted_block"),
+		"Latest block number persisted to the database.", nil, nil,
+	)
+	syncLagDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sync", "lag_blocks"),
+		"Number of blocks the last persisted block is behind the chain head.", nil, nil,
+	)
+	lastFilteredDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "filter", "last_filtered_block"),
+		"Latest block number indexed for a registered address.", []string{"address"}, nil,
+	)
+	filterLagDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "filter", "lag_blocks"),
+		"Number of blocks indexing of a registered address is behind the last persisted block.", []string{"address"}, nil,
+	)
+)
+
+type IndexingDB interface {
+	GetLastPersistedBlockNumber() (uint64, error)
+	GetAddresses() ([]types.Address, error)
+	GetLastFiltered(types.Address) (uint64, error)
+}
+
+// indexingCollector reads how far syncing and indexing have progressed from the
+// database each time metrics are gathered.
+type indexingCollector struct {
+	db IndexingDB
+}
+
+// RegisterIndexingCollector adds the sync and indexing progress metrics, read
+// from the given database.
+func RegisterIndexingCollector(db IndexingDB) error {
+	return Registry.Register(&indexingCollector{db: db})
+}
+
+func (c *indexingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- chainHeadDesc
+	ch <- lastPersistedDesc
+	ch <- syncLagDesc
+	ch <- lastFilteredDesc
+	ch <- filterLagDesc
+}
+
+func (c *indexingCollector) Collect(ch chan<- prometheus.Metric) {
+	head := atomic.LoadUint64(&chainHead)
+	ch <- prometheus.MustNewConstMetric(chainHeadDesc, prometheus.GaugeValue, float64(head))
+
+	lastPersisted, err := c.db.GetLastPersistedBlockNumber()
+	if err != nil {
+		log.Warn("Fetching last persisted block number for metrics failed", "err", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(lastPersistedDesc, prometheus.GaugeValue, float64(lastPersisted))
+	ch <- prometheus.MustNewConstMetric(syncLagDesc, prometheus.GaugeValue, float64(lag(head, lastPersisted)))
+
+	addresses, err := c.db.GetAddresses()
+	if err != nil {
+		log.Warn("Fetching addresses for metrics failed", "err", err)
+		return
+	}
+	for _, address := range addresses {
+		lastFiltered, err := c.db.GetLastFiltered(address)
+		if err != nil {
+			log.Warn("Fetching last filtered for metrics failed", "address", address.Hex(), "err", err)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(lastFilteredDesc, prometheus.GaugeValue, float64(lastFiltered), address.Hex())
+		ch <- prometheus.MustNewConstMetric(filterLagDesc, prometheus.GaugeValue, float64(lag(lastPersisted, lastFiltered)), address.Hex())
+	}
+}
+
+func lag(ahead uint64, behind uint64) uint64 {
+	if behind > ahead {
+		return 0
+	}
+	return ahead - behind
+}
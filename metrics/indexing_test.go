@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"quorumengineering/quorum-report/database/memory"
+	"quorumengineering/quorum-report/types"
+)
+
+func TestIndexingCollector(t *testing.T) {
+	address := types.NewAddress("0x1932c48b2bf8102ba33b4a6b545c32236e342f34")
+	db := memory.NewMemoryDB()
+	assert.Nil(t, db.AddAddresses([]types.Address{address}))
+	assert.Nil(t, db.WriteBlocks([]*types.Block{{Number: 1}, {Number: 2}, {Number: 3}}))
+	assert.Nil(t, db.IndexBlocks([]types.Address{address}, []*types.BlockWithTransactions{{Number: 1}}))
+	SetChainHead(10)
+
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, registry.Register(&indexingCollector{db: db}))
+	families, err := registry.Gather()
+	assert.Nil(t, err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			values[family.GetName()] = metric.GetGauge().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		"reporting_sync_chain_head_block":      10,
+		"reporting_sync_last_persisted_block":  3,
+		"reporting_sync_lag_blocks":            7,
+		"reporting_filter_last_filtered_block": 1,
+		"reporting_filter_lag_blocks":          2,
+	}, values)
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "reporting"
+
+var (
+	// Registry holds all the reporting metrics, along with the Go runtime and process metrics
+	Registry = prometheus.NewRegistry()
+
+	// BatchWriteBlocks is the number of blocks in each batch written to the database
+	BatchWriteBlocks = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "batch_writer",
+		Name:      "blocks",
+		Help:      "Number of blocks in each batch written.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 11),
+	})
+	// BatchWriteTransactions is the number of transactions in each batch written to the database
+	BatchWriteTransactions = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "batch_writer",
+		Name:      "transactions",
+		Help:      "Number of transactions in each batch written.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+	})
+	// BatchWriteDuration is the time taken to flush each batch to the database
+	BatchWriteDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "batch_writer",
+		Name:      "flush_duration_seconds",
+		Help:      "Time taken to flush a batch of blocks and transactions to the database.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	// BatchWriteErrors counts the batch writes that failed and are retried
+	BatchWriteErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "batch_writer",
+		Name:      "errors_total",
+		Help:      "Number of batch writes that failed.",
+	})
+
+	// StorageQueueDepth is the number of blocks waiting for their contract storage to be fetched and saved
+	StorageQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage_filter",
+		Name:      "queue_depth",
+		Help:      "Number of blocks queued for contract storage to be fetched and saved.",
+	})
+
+	// ERC20BalanceDiscrepancies counts the token balances replayed from events that
+	// were negative, or differed from the balance from the contract when reconciled
+	ERC20BalanceDiscrepancies = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "filter",
+		Name:      "erc20_balance_discrepancies_total",
+		Help:      "Number of token balances replayed from events found to be wrong.",
+	})
+
+	// QuorumRPCDuration is the latency of the RPC calls made to Quorum, by method
+	QuorumRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "quorum",
+		Name:      "rpc_duration_seconds",
+		Help:      "Latency of RPC calls made to Quorum.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	// QuorumRPCErrors counts the RPC calls made to Quorum that failed, by method
+	QuorumRPCErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "quorum",
+		Name:      "rpc_errors_total",
+		Help:      "Number of RPC calls made to Quorum that failed.",
+	}, []string{"method"})
+
+	// QuorumNodeHealthy is whether each Quorum node is reachable and not lagging behind the others
+	QuorumNodeHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "quorum",
+		Name:      "node_healthy",
+		Help:      "Whether the Quorum node is reachable and not lagging behind the other nodes.",
+	}, []string{"node"})
+	// QuorumNodeHead is the latest block number of each Quorum node, as of the last health check
+	QuorumNodeHead = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "quorum",
+		Name:      "node_head_block",
+		Help:      "Latest block number of the Quorum node.",
+	}, []string{"node"})
+
+	// CacheRequests counts the lookups of the database cache, by cache and whether it was a hit or miss
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "database_cache",
+		Name:      "requests_total",
+		Help:      "Number of database cache lookups, by result.",
+	}, []string{"cache", "result"})
+
+	// APIRequestDuration is the time taken to serve each JSON-RPC request, by method
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "api",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to serve JSON-RPC requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	// APIRequestErrors counts the JSON-RPC requests that returned an error, by method
+	APIRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "api",
+		Name:      "request_errors_total",
+		Help:      "Number of JSON-RPC requests that returned an error.",
+	}, []string{"method"})
+
+	// chainHead is the latest block number seen from Quorum
+	chainHead uint64
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		BatchWriteBlocks,
+		BatchWriteTransactions,
+		BatchWriteDuration,
+		BatchWriteErrors,
+		StorageQueueDepth,
+		ERC20BalanceDiscrepancies,
+		QuorumRPCDuration,
+		QuorumRPCErrors,
+		QuorumNodeHealthy,
+		QuorumNodeHead,
+		CacheRequests,
+		APIRequestDuration,
+		APIRequestErrors,
+	)
+}
+
+// Handler serves all the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// SetChainHead records the latest block number seen from Quorum
+func SetChainHead(number uint64) {
+	atomic.StoreUint64(&chainHead, number)
+}
+
+// CacheLookup records a lookup of the named database cache
+func CacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.WithLabelValues(cache, result).Inc()
+}
@@ -10,6 +10,7 @@ type ContractABI struct {
 	Constructor ContractABIFunction
 	Functions   []ContractABIFunction
 	Events      []ContractABIEvent
+	Errors      []ContractABIFunction
 }
 
 type ContractABIFunction struct {
@@ -26,6 +26,8 @@ func (abi ABIStructure) ToInternalABI() *ContractABI {
 			contractAbi.Functions = append(contractAbi.Functions, entry.AsFunction())
 		case "event":
 			contractAbi.Events = append(contractAbi.Events, entry.AsEvent())
+		case "error":
+			contractAbi.Errors = append(contractAbi.Errors, entry.AsError())
 		}
 	}
 
@@ -56,6 +58,10 @@ func (entry ABIStructureEntry) AsFunction() ContractABIFunction {
 	return ContractABIFunction{"function", entry.Name, inputs, outputs}
 }
 
+func (entry ABIStructureEntry) AsError() ContractABIFunction {
+	return ContractABIFunction{"error", entry.Name, entry.AsFunction().Inputs, nil}
+}
+
 func (entry ABIStructureEntry) AsEvent() ContractABIEvent {
 	var inputs []ContractABIEventArgument
 	for _, input := range entry.Inputs {
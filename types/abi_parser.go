@@ -81,6 +81,9 @@ func ParseAllData(inputs []ContractABIArgument, data []byte) (map[string]interfa
 
 	//handle all the heads, then handle all the tails
 	for _, input := range inputs {
+		if currentOffset+32 > uint64(len(data)) {
+			return nil, errors.New("data too short for its parameters")
+		}
 		if input.IsDynamic() {
 			elementStartOffsetBytes := data[currentOffset : currentOffset+32]
 			elementStartOffset := ParseUint(elementStartOffsetBytes).Uint64()
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"io/ioutil"
 	"os"
@@ -95,3 +96,195 @@ func TestConfigFile(t *testing.T) {
 	_, err = ReadConfig("../config.sample.toml")
 	assert.Nil(t, err, "error reading sample config file")
 }
+
+func TestValidateWebhooks(t *testing.T) {
+	config := ReportingConfig{
+		Webhooks: []*WebhookConfig{{Address: NewAddress("0x1"), URL: "http://localhost:8080/hook"}},
+	}
+	assert.Nil(t, config.Validate())
+	config.SetDefaults()
+	assert.Equal(t, WebhookEvents, config.Webhooks[0].Kind)
+	assert.Equal(t, 5, config.Tuning.WebhookMaxRetries)
+	assert.Equal(t, 1, config.Tuning.WebhookRetryInterval)
+
+	config.Webhooks[0].Kind = "unknown"
+	config.Webhooks[0].Secret = "hmac-secret"
+	assert.EqualError(t, config.Validate(), "invalid webhook kind: http://localhost:8080/hook")
+
+	config.Webhooks[0].Kind = WebhookERC20Transfers
+	config.Webhooks[0].URL = ""
+	assert.EqualError(t, config.Validate(), "empty webhook url: 0x0000000000000000000000000000000000000001")
+
+	config.Webhooks[0].URL = "http://localhost:8080/hook"
+	config.Webhooks[0].Address = ""
+	assert.EqualError(t, config.Validate(), "empty webhook address: http://localhost:8080/hook")
+}
+
+func TestValidateRules(t *testing.T) {
+	config := ReportingConfig{
+		Rules: []*RuleConfig{{Scope: AllScope, TemplateName: "ERC1155", EIP165: "0xd9b67a26"}},
+	}
+	assert.Nil(t, config.Validate())
+
+	config.Rules[0].EIP165 = "d9b67a26"
+	assert.Nil(t, config.Validate())
+
+	config.Rules[0].EIP165 = "0xd9b67a"
+	assert.EqualError(t, config.Validate(), fmt.Sprintf("invalid rule eip165 interface id: %v", config.Rules[0]))
+
+	config.Rules[0].EIP165 = "erc1155"
+	assert.EqualError(t, config.Validate(), fmt.Sprintf("invalid rule eip165 interface id: %v", config.Rules[0]))
+}
+
+func TestValidateAuth(t *testing.T) {
+	var config ReportingConfig
+	config.Server.Auth = &AuthConfig{
+		APIKeys: []*APIKeyConfig{{Name: "reader", Key: "key", Roles: []string{ReadRole}}},
+		JWT:     &JWTConfig{JWKSFile: "jwks.json"},
+	}
+	assert.Nil(t, config.Validate())
+	config.SetDefaults()
+	assert.Equal(t, "roles", config.Server.Auth.JWT.RolesClaim)
+	assert.Equal(t, "addresses", config.Server.Auth.JWT.AddressesClaim)
+
+	config.Server.Auth.APIKeys[0].Roles = []string{"owner"}
+	assert.EqualError(t, config.Validate(), "invalid api key role: owner")
+
+	config.Server.Auth.APIKeys[0].Roles = []string{AdminRole}
+	config.Server.Auth.APIKeys[0].Key = ""
+	assert.EqualError(t, config.Validate(), "empty api key: reader")
+
+	config.Server.Auth.APIKeys[0].Key = "key"
+	config.Server.Auth.JWT.JWKSFile = ""
+	assert.EqualError(t, config.Validate(), "empty jwt jwks file")
+}
+
+func TestValidateTLS(t *testing.T) {
+	var config ReportingConfig
+	config.Server.TLS = &TLSConfig{CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: "ca.crt"}
+	assert.Nil(t, config.Validate())
+
+	config.Server.TLS.KeyFile = ""
+	assert.EqualError(t, config.Validate(), "empty tls key file")
+
+	config.Server.TLS.CertFile = ""
+	assert.EqualError(t, config.Validate(), "empty tls certificate file")
+}
+
+func TestConnectionConfig(t *testing.T) {
+	var config ReportingConfig
+	err := toml.Unmarshal([]byte(`
+[connection]
+wsUrl = "wss://localhost:23000"
+graphQLUrl = "https://localhost:8547/graphql"
+headers = { X-Tenant = "reporting" }
+
+[connection.oauth2]
+tokenUrl = "https://auth.example.com/token"
+clientId = "reporting"
+scopes = ["quorum"]
+
+[connection.tls]
+cacert = "ca.crt"
+certFile = "client.crt"
+keyFile = "client.key"
+`), &config)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"X-Tenant": "reporting"}, config.Connection.Headers)
+	assert.Equal(t, &OAuth2Config{TokenURL: "https://auth.example.com/token", ClientID: "reporting", Scopes: []string{"quorum"}}, config.Connection.OAuth2)
+	assert.Nil(t, config.Validate())
+
+	config.Connection.Token = "token"
+	assert.EqualError(t, config.Validate(), "only one of connection token and oauth2 can be configured")
+
+	config.Connection.Token = ""
+	config.Connection.OAuth2.ClientID = ""
+	assert.EqualError(t, config.Validate(), "empty connection oauth2 token url or client id")
+
+	config.Connection.OAuth2 = nil
+	config.Connection.TLS.KeyFile = ""
+	assert.EqualError(t, config.Validate(), "connection tls certificate and key files must be set together")
+}
+
+func TestChainHeadMode(t *testing.T) {
+	var config ReportingConfig
+	config.Connection.WSUrl = "ws://localhost:23000"
+	config.SetDefaults()
+	assert.Equal(t, SubscribeChainHeadMode, config.Connection.ChainHeadMode)
+	assert.Equal(t, 1, config.Connection.PollInterval)
+
+	// chain heads are polled if there is no WebSocket to subscribe over
+	config = ReportingConfig{}
+	config.Connection.RPCUrl = "http://localhost:22000"
+	config.SetDefaults()
+	assert.Equal(t, PollChainHeadMode, config.Connection.ChainHeadMode)
+
+	config.Connection.ChainHeadMode = "push"
+	assert.EqualError(t, config.Validate(), "invalid connection chain head mode: push")
+}
+
+func TestConnectionNodes(t *testing.T) {
+	var config ReportingConfig
+	config.Connection.Nodes = []*NodeConfig{
+		{WSUrl: "ws://node1:23000", GraphQLUrl: "http://node1:8547/graphql"},
+		{Name: "backup", GraphQLUrl: "http://node2:8547/graphql", RPCUrl: "http://node2:22000"},
+	}
+	assert.Nil(t, config.Validate())
+	config.SetDefaults()
+	assert.Equal(t, "node1", config.Connection.Nodes[0].Name)
+	assert.Equal(t, "backup", config.Connection.Nodes[1].Name)
+	assert.Equal(t, SubscribeChainHeadMode, config.Connection.ChainHeadMode)
+	assert.Equal(t, 5, config.Connection.HealthCheckInterval)
+	assert.EqualValues(t, 5, config.Connection.MaxNodeBlocksBehind)
+
+	config.Connection.Nodes[0].GraphQLUrl = ""
+	assert.EqualError(t, config.Validate(), "connection node needs a graphql url, and a websocket or http json-rpc url: node1")
+
+	config.Connection.Nodes[0].GraphQLUrl = "http://node1:8547/graphql"
+	config.Connection.WSUrl = "ws://localhost:23000"
+	assert.EqualError(t, config.Validate(), "only one of connection urls and nodes can be configured")
+}
+
+func TestConnectionTimeouts(t *testing.T) {
+	var config ReportingConfig
+	config.Connection.RPCMethodTimeouts = map[string]int{"debug_traceTransaction": 300}
+	assert.Nil(t, config.Validate())
+
+	config.Connection.RPCMethodTimeouts["debug_dumpAddress"] = 0
+	assert.EqualError(t, config.Validate(), "invalid connection rpc method timeout: debug_dumpAddress")
+}
+
+func TestTracerMode(t *testing.T) {
+	var config ReportingConfig
+	config.SetDefaults()
+	assert.Equal(t, CallTracerMode, config.Connection.TracerMode)
+
+	config.Connection.TracerMode = BlockTracerMode
+	assert.Nil(t, config.Validate())
+
+	config.Connection.TracerMode = "prestateTracer"
+	assert.EqualError(t, config.Validate(), "invalid connection tracer mode: prestateTracer")
+}
+
+func TestERC20BalanceMode(t *testing.T) {
+	var config ReportingConfig
+	config.SetDefaults()
+	assert.Equal(t, CallBalanceMode, config.Tuning.ERC20BalanceMode)
+
+	config.Tuning.ERC20BalanceMode = EventBalanceMode
+	assert.Nil(t, config.Validate())
+
+	config.Tuning.ERC20BalanceMode = "logs"
+	assert.EqualError(t, config.Validate(), "invalid tuning erc20 balance mode: logs")
+}
+
+func TestReadinessMaxBlocksBehind(t *testing.T) {
+	var config ReportingConfig
+	config.SetDefaults()
+	assert.EqualValues(t, 10, *config.Tuning.ReadinessMaxBlocksBehind)
+
+	maxBlocksBehind := uint64(0)
+	config.Tuning.ReadinessMaxBlocksBehind = &maxBlocksBehind
+	config.SetDefaults()
+	assert.EqualValues(t, 0, *config.Tuning.ReadinessMaxBlocksBehind)
+}
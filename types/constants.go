@@ -5,3 +5,38 @@ const (
 	InternalScope = "internal"
 	ExternalScope = "external"
 )
+
+// Roles that can be given to clients of the RPC server. The admin role also
+// grants read access.
+const (
+	ReadRole  = "read"
+	AdminRole = "admin"
+)
+
+// Ways new chain heads are tracked. In subscribe mode, polling is used instead
+// if subscribing repeatedly fails.
+const (
+	SubscribeChainHeadMode = "subscribe"
+	PollChainHeadMode      = "poll"
+)
+
+// Ways the internal calls of transactions are found, recorded on each transaction
+// indexed. The call tracers trace each transaction, the block tracer traces all
+// the transactions of a block at once, the struct log tracer works on nodes with
+// no call tracer available but finds no call input, output or gas used, and no
+// internal calls are found without tracing.
+const (
+	CallTracerMode       = "callTracer"
+	NativeCallTracerMode = "nativeCallTracer"
+	BlockTracerMode      = "blockTracer"
+	StructLogTracerMode  = "structLog"
+	NoTracerMode         = "none"
+)
+
+// Ways the balances of fungible tokens are found, by calling balanceOf on the
+// contract for each holder whose balance changed, or by replaying the amounts of
+// the token's events onto the last balance recorded
+const (
+	CallBalanceMode  = "call"
+	EventBalanceMode = "events"
+)
@@ -4,6 +4,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 )
@@ -188,3 +189,28 @@ func (num *HexNumber) UnmarshalJSON(input []byte) error {
 func (num *HexNumber) ToUint64() uint64 {
 	return uint64(*num)
 }
+
+// HexBigNumber is a number given in hex by the node that can exceed 64 bits, such
+// as an amount of wei
+type HexBigNumber big.Int
+
+func (num HexBigNumber) MarshalJSON() ([]byte, error) {
+	return json.Marshal("0x" + (*big.Int)(&num).Text(16))
+}
+
+func (num *HexBigNumber) UnmarshalJSON(input []byte) error {
+	var unwrapped string
+	if err := json.Unmarshal(input, &unwrapped); err != nil {
+		return err
+	}
+	out, ok := new(big.Int).SetString(unwrapped, 0)
+	if !ok {
+		return fmt.Errorf("invalid number %q", unwrapped)
+	}
+	*num = HexBigNumber(*out)
+	return nil
+}
+
+func (num *HexBigNumber) ToBigInt() *big.Int {
+	return new(big.Int).Set((*big.Int)(num))
+}
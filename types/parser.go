@@ -14,6 +14,19 @@ type ParsedTransaction struct {
 	ParsedData     map[string]interface{} `json:"parsedData"`
 	ParsedEvents   []*ParsedEvent         `json:"parsedEvents"`
 	RawTransaction *Transaction           `json:"rawTransaction"`
+	// the custom error of the contract a failed transaction reverted with, if any
+	RevertSig        string                 `json:"revertSig,omitempty"`
+	ParsedRevertData map[string]interface{} `json:"parsedRevertData,omitempty"`
+	// the internal calls that failed, with the custom errors they reverted with
+	FailedInternalCalls []*ParsedInternalCall `json:"failedInternalCalls,omitempty"`
+}
+
+// ParsedInternalCall is a failed internal call, with the custom error of the
+// called contract it reverted with, if any
+type ParsedInternalCall struct {
+	RevertSig        string                 `json:"revertSig,omitempty"`
+	ParsedRevertData map[string]interface{} `json:"parsedRevertData,omitempty"`
+	RawCall          *InternalCall          `json:"rawCall"`
 }
 
 func (ptx *ParsedTransaction) ParseTransaction(rawABI string) error {
@@ -78,9 +91,51 @@ func (ptx *ParsedTransaction) ParseTransaction(rawABI string) error {
 			ptx.ParsedData["error"] = "unable to parse params"
 		}
 	}
+	ptx.RevertSig, ptx.ParsedRevertData = parseRevert(internalAbi, ptx.RawTransaction.RevertData.AsBytes())
+	return nil
+}
+
+// ParseRevert decodes the custom error of the called contract the call reverted with
+func (pc *ParsedInternalCall) ParseRevert(rawABI string) error {
+	if pc.RawCall == nil {
+		return errors.New("internal call is nil")
+	}
+
+	structure, err := NewABIStructureFromJSON(rawABI)
+	if err != nil {
+		log.Error("Could not unmarshal ABI", "abi", rawABI)
+		return errors.New("could not unmarshal ABI")
+	}
+
+	pc.RevertSig, pc.ParsedRevertData = parseRevert(structure.ToInternalABI(), pc.RawCall.Output.AsBytes())
 	return nil
 }
 
+// parseRevert decodes the data a call reverted with as a custom error of the
+// contract. If the error is found but its arguments cannot be decoded, the raw
+// arguments are given instead.
+func parseRevert(internalAbi *ContractABI, revertData []byte) (string, map[string]interface{}) {
+	if len(revertData) < 4 {
+		return "", nil
+	}
+	var sig string
+	var parsed map[string]interface{}
+	selector := hex.EncodeToString(revertData[:4])
+	for _, customErr := range internalAbi.Errors {
+		if customErr.Signature() == selector {
+			sig = "error " + customErr.String()
+			result, err := customErr.Parse(revertData[4:])
+			if err != nil {
+				log.Debug("Could not parse revert data", "error", sig, "err", err)
+				result = map[string]interface{}{"error": "unable to parse params", "data": "0x" + hex.EncodeToString(revertData[4:])}
+			}
+			parsed = result
+			break
+		}
+	}
+	return sig, parsed
+}
+
 type ParsedEvent struct {
 	Sig        string                 `json:"eventSig"`
 	ParsedData map[string]interface{} `json:"parsedData"`
@@ -27,6 +27,11 @@ type QueryOptions struct {
 	BeginTimestamp *big.Int `json:"beginTimestamp"`
 	EndTimestamp   *big.Int `json:"endTimestamp"`
 
+	// MinValue and MaxValue limit transactions to those sending an amount of wei
+	// in the range, inclusive. They are not applied to events.
+	MinValue *big.Int `json:"minValue,omitempty"`
+	MaxValue *big.Int `json:"maxValue,omitempty"`
+
 	PageSize   int `json:"pageSize"`
 	PageNumber int `json:"pageNumber"`
 }
@@ -52,6 +57,26 @@ func (opts *QueryOptions) SetDefaults() {
 	}
 }
 
+// HasValueRange reports whether the options limit the value of transactions
+func (opts *QueryOptions) HasValueRange() bool {
+	return opts != nil && (opts.MinValue != nil || opts.MaxValue != nil)
+}
+
+// InValueRange reports whether a transaction value is within the value range of
+// the options, taking a missing value to be zero
+func (opts *QueryOptions) InValueRange(value *big.Int) bool {
+	if !opts.HasValueRange() {
+		return true
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	if opts.MinValue != nil && value.Cmp(opts.MinValue) < 0 {
+		return false
+	}
+	return opts.MaxValue == nil || value.Cmp(opts.MaxValue) <= 0
+}
+
 type PageOptions struct {
 	BeginBlockNumber *big.Int `json:"beginBlockNumber"`
 	EndBlockNumber   *big.Int `json:"endBlockNumber"`
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// selectors of Error(string) and Panic(uint256), which Solidity reverts with
+	errorSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+	panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+)
+
+// panicReasons describes the codes Solidity panics with
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to uninitialized function",
+}
+
+// DecodeRevertReason decodes the data a call reverted with, if it is an
+// Error(string) or a Panic(uint256). Custom errors need the ABI of the contract,
+// and are not decoded.
+func DecodeRevertReason(data []byte) (string, bool) {
+	if len(data) < 4 {
+		return "", false
+	}
+	args := data[4:]
+	switch {
+	case bytes.Equal(data[:4], errorSelector):
+		if len(args) < 64 {
+			return "", false
+		}
+		offset := new(big.Int).SetBytes(args[:32])
+		if !offset.IsUint64() || offset.Uint64() > uint64(len(args)-32) {
+			return "", false
+		}
+		start := offset.Uint64() + 32
+		length := new(big.Int).SetBytes(args[start-32 : start])
+		if !length.IsUint64() || length.Uint64() > uint64(len(args))-start {
+			return "", false
+		}
+		return string(args[start : start+length.Uint64()]), true
+	case bytes.Equal(data[:4], panicSelector):
+		if len(args) < 32 {
+			return "", false
+		}
+		code := new(big.Int).SetBytes(args[:32])
+		if reason, ok := panicReasons[code.Uint64()]; ok && code.IsUint64() {
+			return fmt.Sprintf("panic: %s (0x%x)", reason, code), true
+		}
+		return fmt.Sprintf("panic: 0x%x", code), true
+	}
+	return "", false
+}
+
+// RevertReason describes why a call failed, from the error it failed with and the
+// data it reverted with
+func RevertReason(callErr string, revertData HexData) string {
+	data := revertData.AsBytes()
+	if reason, ok := DecodeRevertReason(data); ok {
+		return reason
+	}
+	if len(data) >= 4 {
+		return "custom error 0x" + hex.EncodeToString(data[:4])
+	}
+	return callErr
+}
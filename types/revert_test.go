@@ -0,0 +1,35 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hexBytes(data string) []byte {
+	hexData := NewHexData(data)
+	return hexData.AsBytes()
+}
+
+func TestDecodeRevertReason(t *testing.T) {
+	reason, ok := DecodeRevertReason(hexBytes("0x08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000a4e6f7420656e6f75676800000000000000000000000000000000000000000000"))
+	assert.True(t, ok)
+	assert.Equal(t, "Not enough", reason)
+
+	reason, ok = DecodeRevertReason(hexBytes("0x4e487b710000000000000000000000000000000000000000000000000000000000000011"))
+	assert.True(t, ok)
+	assert.Equal(t, "panic: arithmetic underflow or overflow (0x11)", reason)
+
+	// the length of the string is past the end of the data
+	_, ok = DecodeRevertReason(hexBytes("0x08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000ffff"))
+	assert.False(t, ok)
+
+	_, ok = DecodeRevertReason(hexBytes("0xcf479181"))
+	assert.False(t, ok)
+}
+
+func TestRevertReason(t *testing.T) {
+	assert.Equal(t, "Not enough", RevertReason("execution reverted", NewHexData("0x08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000a4e6f7420656e6f75676800000000000000000000000000000000000000000000")))
+	assert.Equal(t, "custom error 0xcf479181", RevertReason("execution reverted", NewHexData("0xcf4791810000000000000000000000000000000000000000000000000000000000000001")))
+	assert.Equal(t, "out of gas", RevertReason("out of gas", NewHexData("0x")))
+}
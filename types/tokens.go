@@ -1,5 +1,7 @@
 package types
 
+import "math/big"
+
 type ERC721Token struct {
 	Contract  Address `json:"contract"`
 	Holder    Address `json:"holder"`
@@ -7,3 +9,37 @@ type ERC721Token struct {
 	HeldFrom  uint64  `json:"heldFrom"`
 	HeldUntil *uint64 `json:"heldUntil"`
 }
+
+// ERC1155Token is the balance an account holds of a token of an ERC1155 contract,
+// along with the block the balance changed to that amount at
+type ERC1155Token struct {
+	Contract Address  `json:"contract"`
+	Holder   Address  `json:"holder"`
+	Token    string   `json:"token"`
+	Amount   *big.Int `json:"amount"`
+	HeldFrom uint64   `json:"heldFrom"`
+}
+
+// EtherContract is the contract native ether balances are recorded under, alongside
+// the balances of ERC20 tokens. Nothing can be deployed at the zero address, so it
+// is never the address of a token.
+var EtherContract = NewAddress("")
+
+// ERC4626Assets is the total assets held by an ERC4626 vault and the total supply
+// of its shares at a block, from which shares are converted to assets
+type ERC4626Assets struct {
+	Contract    Address  `json:"contract"`
+	BlockNumber uint64   `json:"blockNumber"`
+	TotalAssets *big.Int `json:"totalAssets"`
+	TotalSupply *big.Int `json:"totalSupply"`
+}
+
+// ConvertToAssets returns the amount of assets the shares are worth, rounding down
+// as the vault's convertToAssets does. Shares are worth nothing when none exist.
+func (a *ERC4626Assets) ConvertToAssets(shares *big.Int) *big.Int {
+	if a.TotalSupply.Sign() == 0 {
+		return new(big.Int)
+	}
+	assets := new(big.Int).Mul(shares, a.TotalAssets)
+	return assets.Quo(assets, a.TotalSupply)
+}
@@ -0,0 +1,18 @@
+package types
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestERC4626Assets_ConvertToAssets(t *testing.T) {
+	assets := &ERC4626Assets{TotalAssets: big.NewInt(1000), TotalSupply: big.NewInt(300)}
+	assert.Equal(t, "333", assets.ConvertToAssets(big.NewInt(100)).String())
+	assert.Equal(t, "1000", assets.ConvertToAssets(big.NewInt(300)).String())
+
+	// an empty vault has no shares to convert
+	assets = &ERC4626Assets{TotalAssets: big.NewInt(0), TotalSupply: big.NewInt(0)}
+	assert.Equal(t, "0", assets.ConvertToAssets(big.NewInt(100)).String())
+}
@@ -1,5 +1,7 @@
 package types
 
+import "math/big"
+
 type Template struct {
 	TemplateName  string `json:"templateName"`
 	ABI           string `json:"abi"`
@@ -31,15 +33,40 @@ type RawInnerCall struct {
 	To      Address
 	Input   HexData
 	From    Address
-	Value   HexNumber
+	Value   HexBigNumber
 	Gas     HexNumber
 	GasUsed HexNumber
 	Output  HexData
+	Error   string
 	Calls   []RawInnerCall
 }
 
 type RawOuterCall struct {
-	Calls []RawInnerCall
+	Output HexData
+	Error  string
+	Calls  []RawInnerCall
+}
+
+// RawTransactionTrace is the trace of one of the transactions of a traced block
+type RawTransactionTrace struct {
+	Result RawOuterCall
+	Error  string
+}
+
+// RawStructLogTrace is a transaction traced by the default struct logger, with a
+// log of each opcode executed
+type RawStructLogTrace struct {
+	Failed      bool
+	ReturnValue string
+	StructLogs  []RawStructLog
+}
+
+type RawStructLog struct {
+	Op    string
+	Gas   uint64
+	Depth int
+	Stack []string
+	Error string
 }
 
 type Block struct {
@@ -79,9 +106,9 @@ type Transaction struct {
 	Nonce             uint64          `json:"nonce"`
 	From              Address         `json:"from"`
 	To                Address         `json:"to"`
-	Value             uint64          `json:"value"`
+	Value             *big.Int        `json:"value"`
 	Gas               uint64          `json:"gas"`
-	GasPrice          uint64          `json:"gasPrice"`
+	GasPrice          *big.Int        `json:"gasPrice"`
 	GasUsed           uint64          `json:"gasUsed"`
 	CumulativeGasUsed uint64          `json:"cumulativeGasUsed"`
 	CreatedContract   Address         `json:"createdContract"`
@@ -91,17 +118,26 @@ type Transaction struct {
 	Timestamp         uint64          `json:"timestamp"`
 	Events            []*Event        `json:"events"`
 	InternalCalls     []*InternalCall `json:"internalCalls"`
+	// TracerMode is how the internal calls were found
+	TracerMode string `json:"tracerMode"`
+	// RevertReason is why a failed transaction failed, and RevertData the data it
+	// reverted with, which may be a custom error of the contract
+	RevertReason string  `json:"revertReason"`
+	RevertData   HexData `json:"revertData"`
 }
 
 type InternalCall struct {
-	From    Address `json:"from"`
-	To      Address `json:"to"`
-	Gas     uint64  `json:"gas"`
-	GasUsed uint64  `json:"gasUsed"`
-	Value   uint64  `json:"value"`
-	Input   HexData `json:"input"`
-	Output  HexData `json:"output"`
-	Type    string  `json:"type"`
+	From    Address  `json:"from"`
+	To      Address  `json:"to"`
+	Gas     uint64   `json:"gas"`
+	GasUsed uint64   `json:"gasUsed"`
+	Value   *big.Int `json:"value"`
+	Input   HexData  `json:"input"`
+	Output  HexData  `json:"output"`
+	Type    string   `json:"type"`
+	// Error is why the call failed, and RevertReason the reason it reverted with
+	Error        string `json:"error"`
+	RevertReason string `json:"revertReason"`
 }
 
 type Event struct {
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// The kinds of notification a webhook can be sent
+const (
+	WebhookEvents          = "events"
+	WebhookERC20Transfers  = "erc20Transfers"
+	WebhookERC721Transfers = "erc721Transfers"
+)
+
+// Webhook is an HTTP endpoint that is sent the events, or token transfers, of a
+// registered address as they are indexed.
+type Webhook struct {
+	ID      string  `json:"id"`
+	Address Address `json:"address"`
+	Kind    string  `json:"kind"`
+	// EventSignature optionally restricts the events sent, given either as the
+	// event topic hash or as a signature, e.g. "Transfer(address,address,uint256)"
+	EventSignature string `json:"eventSignature,omitempty"`
+	URL            string `json:"url"`
+	// Secret is used to sign the payloads sent, if set
+	Secret string        `json:"secret,omitempty"`
+	Cursor WebhookCursor `json:"cursor"`
+}
+
+// WebhookID derives the ID of a webhook from what it is sent and where to, so
+// registering the same webhook again keeps its delivery cursor.
+func WebhookID(address Address, kind string, eventSignature string, url string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{address.String(), kind, eventSignature, url}, "|")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// EventTopic returns the topic hash for an event signature, such as
+// "Transfer(address,address,uint256)". A signature that is already given as the
+// topic hash is returned as it is.
+func EventTopic(signature string) Hash {
+	if strings.HasPrefix(signature, "0x") {
+		return NewHash(signature)
+	}
+	return NewHash(hex.EncodeToString(hash(strings.ReplaceAll(signature, " ", ""))))
+}
+
+// WebhookCursor is the position of the next event to be delivered to a webhook.
+// Events before the cursor have already been delivered.
+type WebhookCursor struct {
+	BlockNumber      uint64 `json:"blockNumber"`
+	TransactionIndex uint64 `json:"transactionIndex"`
+	EventIndex       uint64 `json:"eventIndex"`
+}
+
+// Includes checks whether the event is at or after the cursor
+func (c WebhookCursor) Includes(event *Event) bool {
+	if event.BlockNumber != c.BlockNumber {
+		return event.BlockNumber > c.BlockNumber
+	}
+	if event.TransactionIndex != c.TransactionIndex {
+		return event.TransactionIndex > c.TransactionIndex
+	}
+	return event.Index >= c.EventIndex
+}
+
+// WebhookCursorAfter returns the cursor for the event following the given one
+func WebhookCursorAfter(event *Event) WebhookCursor {
+	return WebhookCursor{BlockNumber: event.BlockNumber, TransactionIndex: event.TransactionIndex, EventIndex: event.Index + 1}
+}
@@ -10,20 +10,24 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rakyll/statik/fs"
 
+	"quorumengineering/quorum-report/core/certs"
 	"quorumengineering/quorum-report/log"
+	"quorumengineering/quorum-report/types"
 	_ "quorumengineering/quorum-report/ui/statik" //allow the packages `init` function to run, registered the asset data
 )
 
 type UIHandler struct {
-	port int
+	port      int
+	tlsConfig *types.TLSConfig
 
 	srv *http.Server
 
 	mu sync.Mutex
 }
 
-func NewUIHandler(port int) *UIHandler {
-	return &UIHandler{port: port}
+// NewUIHandler creates the UI server, which is served over HTTPS if a TLS config is given
+func NewUIHandler(port int, tlsConfig *types.TLSConfig) *UIHandler {
+	return &UIHandler{port: port, tlsConfig: tlsConfig}
 }
 
 func (handler *UIHandler) Start() error {
@@ -48,9 +52,22 @@ func (handler *UIHandler) Start() error {
 		Addr:    ":" + strconv.Itoa(handler.port),
 		Handler: router,
 	}
+	if handler.tlsConfig != nil {
+		reloader, err := certs.NewReloader(handler.tlsConfig)
+		if err != nil {
+			return err
+		}
+		handler.srv.TLSConfig = reloader.TLSConfig()
+	}
 
 	go func() {
-		if err := handler.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if handler.srv.TLSConfig != nil {
+			err = handler.srv.ListenAndServeTLS("", "")
+		} else {
+			err = handler.srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Error("Unable to start UI", "err", err)
 		}
 	}()